@@ -8,9 +8,11 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
 )
 
@@ -27,6 +29,12 @@ type handlerFunc func(rw http.ResponseWriter, r *http.Request) Error
 // NewHandlerFunc adds our own context to the HTTP request and handles error response
 func NewHandlerFunc(ctx context.Context, fn handlerFunc) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
+		// Request scoped log
+		ctx := SetLog(ctx, Log(ctx).WithFields(logrus.Fields{
+			"method": r.Method,
+			"path":   r.URL.Path,
+		}))
+
 		// Get mux Vars and add them to our own Context
 		vars := mux.Vars(r)
 		r = mux.SetURLVars(r.WithContext(ctx), vars)
@@ -35,6 +43,7 @@ func NewHandlerFunc(ctx context.Context, fn handlerFunc) http.HandlerFunc {
 			e := ErrorResponseJSON(rw, err.StatusCode(), &ErrorResponse{
 				ErrorCode:    err.Code(),
 				ErrorMessage: err.Message(),
+				Details:      err.Details(),
 			})
 
 			if e != nil {
@@ -88,6 +97,22 @@ func ReadJSONRequest(r *http.Request, v interface{}) Error {
 	return nil
 }
 
+// ReadQueryInt reads an integer from the requests query string, returning
+// the default if it isn't given
+func ReadQueryInt(r *http.Request, key string, def int) (int, Error) {
+	v := r.URL.Query().Get(key)
+	if v == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, NewError(err, "").WithField("key", key)
+	}
+
+	return i, nil
+}
+
 /**************************************************************************
 ***************************************************************************
 **                                                                       **
@@ -98,8 +123,9 @@ func ReadJSONRequest(r *http.Request, v interface{}) Error {
 
 // ErrorResponse is the default error response json format
 type ErrorResponse struct {
-	ErrorCode    string `json:"errorCode"`
-	ErrorMessage string `json:"errorMessage"`
+	ErrorCode    string      `json:"errorCode"`
+	ErrorMessage string      `json:"errorMessage"`
+	Details      interface{} `json:"details,omitempty"`
 }
 
 // ErrorResponseJSON sends a response, intended for error handling.
@@ -17,6 +17,10 @@ type Error interface {
 	Code() string
 	SetInternal(internal interface{}) Error
 
+	// Details returned to the client, such as validation errors
+	Details() interface{}
+	SetDetails(details interface{}) Error
+
 	// HTTP Specific
 	StatusCode() int
 	SetStatusCode(status int) Error
@@ -31,6 +35,7 @@ type baseError struct {
 	message    string
 	internal   string
 	httpStatus int
+	details    interface{}
 	fields     map[string]interface{}
 }
 
@@ -89,6 +94,15 @@ func (e *baseError) SetInternal(internal interface{}) Error {
 	return e
 }
 
+func (e *baseError) Details() interface{} {
+	return e.details
+}
+
+func (e *baseError) SetDetails(details interface{}) Error {
+	e.details = details
+	return e
+}
+
 // Helper functions for error creation
 func (e *baseError) setCode(code string) {
 	e.code = code
@@ -118,6 +132,12 @@ func (e *ErrorTemplate) SetStatusCode(status int) *ErrorTemplate {
 	return e
 }
 
+// Code returns the error code of the template, for comparing with the
+// code of errors created from it
+func (e *ErrorTemplate) Code() string {
+	return e.code
+}
+
 // NewError creates a new error using a previous entity, or from scratch
 func NewError(err interface{}, msg string) Error {
 	newErr := new(baseError)
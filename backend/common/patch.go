@@ -0,0 +1,513 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is a single operation of a JSON Patch (RFC 6902)
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// PatchError describes why a JSON Patch couldn't be applied
+type PatchError struct {
+	Operation int    `json:"operation"`
+	Path      string `json:"path"`
+	Message   string `json:"message"`
+
+	// Set if a test operation failed, rather than the patch being invalid
+	TestFailed bool `json:"-"`
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("operation %d at %q: %s", e.Operation, e.Path, e.Message)
+}
+
+// DecodeJSON decodes a JSON value, keeping numbers as json.Number so
+// integers are kept exact
+func DecodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// CopyJSON returns a deep copy of a decoded JSON value
+func CopyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			c[key] = CopyJSON(value)
+		}
+		return c
+
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, value := range v {
+			c[i] = CopyJSON(value)
+		}
+		return c
+	}
+
+	return v
+}
+
+// EqualJSON compares two decoded JSON values, numbers being equal if they
+// have the same value
+func EqualJSON(a, b interface{}) bool {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for key, value := range a {
+			other, ok := b[key]
+			if !ok || !EqualJSON(value, other) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+
+		for i := range a {
+			if !EqualJSON(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+
+	case json.Number, float64:
+		x, ok := schemaNumber(a)
+		y, isNumber := schemaNumber(b)
+		return ok && isNumber && x == y
+	}
+
+	return a == b
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**    JSON Merge Patch (RFC 7396)                                        **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// MergePatch applies a merge patch to a decoded JSON value, returning the
+// result without modifying the target
+func MergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return CopyJSON(patch)
+	}
+
+	result, ok := CopyJSON(target).(map[string]interface{})
+	if !ok {
+		result = make(map[string]interface{})
+	}
+
+	for key, value := range p {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+
+		result[key] = MergePatch(result[key], value)
+	}
+
+	return result
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**    JSON Patch (RFC 6902)                                              **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// ApplyPatch applies the operations of a JSON Patch to a decoded JSON value.
+// Either every operation is applied or none, the target isn't modified.
+func ApplyPatch(target interface{}, ops []PatchOperation) (interface{}, error) {
+	doc := CopyJSON(target)
+
+	for i, op := range ops {
+		var err error
+		if doc, err = applyOperation(doc, op); err != nil {
+			if e, ok := err.(*PatchError); ok {
+				e.Operation = i
+				return nil, e
+			}
+
+			return nil, &PatchError{Operation: i, Path: op.Path, Message: err.Error()}
+		}
+	}
+
+	return doc, nil
+}
+
+func applyOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := func() (interface{}, error) {
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("missing value")
+		}
+
+		return DecodeJSON(op.Value)
+	}
+
+	switch op.Op {
+	case "add":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+
+	case "remove":
+		doc, _, err := pointerRemove(doc, path)
+		return doc, err
+
+	case "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+
+		if doc, _, err = pointerRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, v)
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" && len(from) < len(path) && isPrefix(from, path) {
+			return nil, fmt.Errorf("can't move a value into itself")
+		}
+
+		v, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			if doc, _, err = pointerRemove(doc, from); err != nil {
+				return nil, err
+			}
+		}
+		return pointerAdd(doc, path, CopyJSON(v))
+
+	case "test":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+
+		actual, err := pointerGet(doc, path)
+		if err != nil || !EqualJSON(actual, v) {
+			return nil, &PatchError{Path: op.Path, Message: "Test failed", TestFailed: true}
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(token, "~1", "/", -1), "~0", "~", -1)
+	}
+
+	return tokens, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// arrayIndex parses an array index token, allowing the end of the array
+// if end is set
+func arrayIndex(token string, length int, end bool) (int, error) {
+	if end && token == "-" {
+		return length, nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+
+	if i > length || (!end && i == length) {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+
+	return i, nil
+}
+
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]interface{}:
+			v, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("path doesn't exist")
+			}
+			doc = v
+
+		case []interface{}:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+
+		default:
+			return nil, fmt.Errorf("path doesn't exist")
+		}
+	}
+
+	return doc, nil
+}
+
+// pointerAdd adds a value at the path, returning the updated document
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		if len(path) == 1 {
+			node[token] = value
+			return node, nil
+		}
+
+		child, ok := node[token]
+		if !ok {
+			return nil, fmt.Errorf("path doesn't exist")
+		}
+
+		child, err := pointerAdd(child, path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+
+		node[token] = child
+		return node, nil
+
+	case []interface{}:
+		i, err := arrayIndex(token, len(node), len(path) == 1)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(path) == 1 {
+			node = append(node, nil)
+			copy(node[i+1:], node[i:])
+			node[i] = value
+			return node, nil
+		}
+
+		if node[i], err = pointerAdd(node[i], path[1:], value); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+
+	return nil, fmt.Errorf("path doesn't exist")
+}
+
+// pointerRemove removes the value at the path, returning the updated
+// document and the removed value
+func pointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	token := path[0]
+	switch node := doc.(type) {
+	case map[string]interface{}:
+		child, ok := node[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("path doesn't exist")
+		}
+
+		if len(path) == 1 {
+			delete(node, token)
+			return node, child, nil
+		}
+
+		child, removed, err := pointerRemove(child, path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		node[token] = child
+		return node, removed, nil
+
+	case []interface{}:
+		i, err := arrayIndex(token, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(path) == 1 {
+			removed := node[i]
+			return append(node[:i], node[i+1:]...), removed, nil
+		}
+
+		child, removed, err := pointerRemove(node[i], path[1:])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		node[i] = child
+		return node, removed, nil
+	}
+
+	return nil, nil, fmt.Errorf("path doesn't exist")
+}
+
+// GetPointer returns the value at a JSON Pointer, and whether it exists
+func GetPointer(doc interface{}, pointer string) (interface{}, bool) {
+	path, err := parsePointer(pointer)
+	if err != nil {
+		return nil, false
+	}
+
+	v, err := pointerGet(doc, path)
+	return v, err == nil
+}
+
+// SetPointer sets the value at a JSON Pointer, creating the objects missing
+// on the way. Only objects are traversed, the document is updated in place.
+func SetPointer(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	path, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	return pointerSet(doc, path, value)
+}
+
+func pointerSet(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	node, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("path isn't an object")
+	}
+
+	child, ok := node[path[0]]
+	if !ok && len(path) > 1 {
+		child = map[string]interface{}{}
+	}
+
+	child, err := pointerSet(child, path[1:], value)
+	if err != nil {
+		return nil, err
+	}
+
+	node[path[0]] = child
+	return node, nil
+}
+
+// DiffJSON returns the JSON Patch operations transforming a into b. Objects
+// are compared member by member, other values are replaced as a whole.
+func DiffJSON(a, b interface{}) []PatchOperation {
+	return diffJSON(make([]PatchOperation, 0), "", a, b)
+}
+
+func diffJSON(ops []PatchOperation, path string, a, b interface{}) []PatchOperation {
+	if EqualJSON(a, b) {
+		return ops
+	}
+
+	from, isObject := a.(map[string]interface{})
+	to, ok := b.(map[string]interface{})
+	if !isObject || !ok {
+		return append(ops, PatchOperation{Op: "replace", Path: path, Value: rawJSON(b)})
+	}
+
+	keys := make([]string, 0, len(from)+len(to))
+	for key := range from {
+		keys = append(keys, key)
+	}
+	for key := range to {
+		if _, ok := from[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		member := path + "/" + escapePointer(key)
+		a, inFrom := from[key]
+		b, inTo := to[key]
+
+		switch {
+		case !inTo:
+			ops = append(ops, PatchOperation{Op: "remove", Path: member})
+		case !inFrom:
+			ops = append(ops, PatchOperation{Op: "add", Path: member, Value: rawJSON(b)})
+		default:
+			ops = diffJSON(ops, member, a, b)
+		}
+	}
+
+	return ops
+}
+
+// rawJSON encodes a decoded JSON value, which can't fail
+func rawJSON(v interface{}) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
@@ -0,0 +1,287 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Schema is a JSON Schema, supporting the subset of keywords needed to
+// describe our documents: type, enum, properties, required,
+// additionalProperties, maxProperties, items, minItems, maxItems, minimum,
+// maximum, minLength and maxLength. The readOnly annotation tells clients
+// which values are kept by the server, and isn't validated.
+type Schema struct {
+	Type                 schemaTypes        `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	ReadOnly             bool               `json:"readOnly,omitempty"`
+
+	// Set for the boolean schemas true and false
+	boolean *bool
+}
+
+// SchemaError is a single violation of a schema, at the JSON Pointer path
+// of the offending value
+type SchemaError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// ParseSchema parses a JSON Schema
+func ParseSchema(b []byte) (*Schema, error) {
+	s := new(Schema)
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// MustParseSchema parses a JSON Schema, panicking if it's invalid. Used for
+// schemas defined in code.
+func MustParseSchema(s string) *Schema {
+	schema, err := ParseSchema([]byte(s))
+	if err != nil {
+		panic(fmt.Sprintf("invalid schema: %s", err))
+	}
+
+	return schema
+}
+
+// UnmarshalJSON decodes a schema, which may be a boolean
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	var boolean bool
+	if err := json.Unmarshal(b, &boolean); err == nil {
+		*s = Schema{boolean: &boolean}
+		return nil
+	}
+
+	type plain Schema
+	return json.Unmarshal(b, (*plain)(s))
+}
+
+// MarshalJSON encodes a schema, which may be a boolean
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.boolean != nil {
+		return json.Marshal(*s.boolean)
+	}
+
+	type plain Schema
+	return json.Marshal((*plain)(s))
+}
+
+// schemaTypes is the type keyword, either a single type or a list of them
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+func (t schemaTypes) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+
+	return json.Marshal([]string(t))
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**    Validation                                                         **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// Validate validates a decoded JSON value, returning every violation found.
+// Numbers may be decoded as either float64 or json.Number.
+func (s *Schema) Validate(v interface{}) []SchemaError {
+	errs := make([]SchemaError, 0)
+	s.validate("", v, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, v interface{}, errs *[]SchemaError) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.boolean != nil {
+		if !*s.boolean {
+			fail("Value isn't allowed")
+		}
+		return
+	}
+
+	if len(s.Type) > 0 && !s.Type.matches(v) {
+		fail("Expected %s, got %s", strings.Join(s.Type, " or "), schemaTypeOf(v))
+		return
+	}
+
+	if len(s.Enum) > 0 && !s.inEnum(v) {
+		fail("Value must be one of the allowed values")
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		s.validateObject(path, v, errs)
+
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("Must have at least %d items", *s.MinItems)
+		}
+
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("Must have at most %d items", *s.MaxItems)
+		}
+
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(path+"/"+strconv.Itoa(i), item, errs)
+			}
+		}
+
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("Must be at least %d characters", *s.MinLength)
+		}
+
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("Must be at most %d characters", *s.MaxLength)
+		}
+
+	case json.Number, float64:
+		f, _ := schemaNumber(v)
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("Must be at least %v", *s.Minimum)
+		}
+
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("Must be at most %v", *s.Maximum)
+		}
+	}
+}
+
+func (s *Schema) validateObject(path string, v map[string]interface{}, errs *[]SchemaError) {
+	for _, key := range s.Required {
+		if _, ok := v[key]; !ok {
+			*errs = append(*errs, SchemaError{
+				Path:    path + "/" + escapePointer(key),
+				Message: "Missing required property",
+			})
+		}
+	}
+
+	if s.MaxProperties != nil && len(v) > *s.MaxProperties {
+		*errs = append(*errs, SchemaError{
+			Path:    path,
+			Message: fmt.Sprintf("Must have at most %d properties", *s.MaxProperties),
+		})
+	}
+
+	// Sorted, so the errors are in a stable order
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		schema, ok := s.Properties[key]
+		if !ok {
+			schema = s.AdditionalProperties
+		}
+
+		if schema != nil {
+			schema.validate(path+"/"+escapePointer(key), v[key], errs)
+		}
+	}
+}
+
+func (s *Schema) inEnum(v interface{}) bool {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+
+	for _, allowed := range s.Enum {
+		if a, err := json.Marshal(allowed); err == nil && bytes.Equal(a, b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (t schemaTypes) matches(v interface{}) bool {
+	actual := schemaTypeOf(v)
+	for _, expected := range t {
+		if expected == actual || (expected == "number" && actual == "integer") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// schemaTypeOf returns the JSON Schema type of a decoded value
+func schemaTypeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number, float64:
+		if f, ok := schemaNumber(v); ok && f == float64(int64(f)) {
+			return "integer"
+		}
+		return "number"
+	}
+
+	return "unknown"
+}
+
+func schemaNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	}
+
+	return 0, false
+}
+
+// escapePointer escapes a key for use in a JSON Pointer
+func escapePointer(key string) string {
+	return strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+}
@@ -2,12 +2,16 @@ package datastore
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/valsgaard/interview-case/backend/common"
 	"github.com/valsgaard/interview-case/backend/endpoints/port"
 )
 
@@ -81,11 +85,49 @@ var UserNames = []string{
 	"bot3",
 }
 
-var GameStates = []port.GameState{
-	{GamesPlayed: 10, Score: 110},
-	{GamesPlayed: 0, Score: 0},
-	{GamesPlayed: 0, Score: 0},
-	{GamesPlayed: 0, Score: 0},
+var GameStates = []*port.GameState{
+	port.NewGameState(10, 110),
+	port.NewGameState(0, 0),
+	port.NewGameState(0, 0),
+	port.NewGameState(0, 0),
+}
+
+// Change is the change recorded by writes of the tests
+var Change = port.StateChange{Author: "test", Source: port.StateSourceUpdate}
+
+// levelState returns a game state at a level of the progress, which updates
+// store, and a score, which they keep
+func levelState(level, score int) *port.GameState {
+	state := port.NewGameState(1, score)
+	state.Document[port.GameStateFieldProgress] = map[string]interface{}{"level": json.Number(strconv.Itoa(level))}
+	return state
+}
+
+// seedGameState writes the game state of a user with its games played and
+// best score, which only SubmitScore changes, by exporting and importing the user
+func seedGameState(t *testing.T, db port.Datastore, userID string, state *port.GameState) {
+	record, err := db.ExportUser(userID)
+	require.Nil(t, err)
+
+	revision := record.GameState.Revision
+	record.GameState = *state.Copy()
+	record.GameState.Revision = revision + 1
+	record.Revisions = append(record.Revisions, port.StateRevision{
+		Revision:  record.GameState.Revision,
+		State:     *record.GameState.Copy(),
+		Author:    Change.Author,
+		Source:    Change.Source,
+		CreatedAt: time.Now(),
+	})
+	require.Nil(t, db.ImportUser(record))
+}
+
+// stateLevel returns the level of the progress of a game state, zero if missing
+func stateLevel(state *port.GameState) int {
+	progress, _ := state.Document[port.GameStateFieldProgress].(map[string]interface{})
+	number, _ := progress["level"].(json.Number)
+	level, _ := number.Int64()
+	return int(level)
 }
 
 var Friends = [][]string{
@@ -95,6 +137,21 @@ var Friends = [][]string{
 	{},
 }
 
+var GameIDs = []string{
+	"a0e6feba-043b-4ba4-a7a4-9d6705595049",
+}
+
+var Games = []port.Game{
+	{
+		GameID: GameIDs[0],
+		Status: port.GamePlaying,
+		Participants: []*port.Participant{
+			{UserID: Users[0], Status: port.GameFinished, Score: 110},
+			{UserID: Users[1], Status: port.GamePlaying},
+		},
+	},
+}
+
 /**************************************************************************
 ***************************************************************************
 **                                                                       **
@@ -153,6 +210,54 @@ func (suite *DatastoreTestSuite) TestGetUsers() {
 	}
 }
 
+func (suite *DatastoreTestSuite) TestListUsers() {
+	tests := []struct {
+		Name            string
+		Query           port.ListQuery
+		ExpectedUserIDs []string
+	}{
+		{
+			Name:            "First",
+			Query:           port.ListQuery{Sort: port.SortByID, Limit: 3},
+			ExpectedUserIDs: Users[:3],
+		}, {
+			Name: "After",
+			Query: port.ListQuery{
+				Sort:  port.SortByName,
+				Limit: 3,
+				After: &port.ListKey{UserID: Users[1], Name: UserNames[1]},
+			},
+			ExpectedUserIDs: Users[2:],
+		}, {
+			Name: "Before",
+			Query: port.ListQuery{
+				Sort:   port.SortByID,
+				Limit:  2,
+				Before: &port.ListKey{UserID: Users[3]},
+			},
+			ExpectedUserIDs: Users[1:3],
+		}, {
+			Name:            "NamePrefix",
+			Query:           port.ListQuery{Sort: port.SortByID, Limit: 10, NamePrefix: "BOT3"},
+			ExpectedUserIDs: Users[3:],
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			users, err := suite.Datastore.ListUsers(&test.Query)
+			require.Nil(t, err)
+
+			require.Equal(t, len(test.ExpectedUserIDs), len(users))
+			for i, user := range users {
+				assert.Equal(t, test.ExpectedUserIDs[i], user.UserID)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
 func (suite *DatastoreTestSuite) TestUserExists() {
 	tests := []struct {
 		Name            string
@@ -183,6 +288,66 @@ func (suite *DatastoreTestSuite) TestUserExists() {
 	}
 }
 
+func (suite *DatastoreTestSuite) TestSearchUsers() {
+	tests := []struct {
+		Name            string
+		Query           string
+		Fuzzy           bool
+		Offset          int
+		Limit           int
+		ExpectedUserIDs []string
+	}{
+		{
+			Name:            "Prefix",
+			Query:           "Bot",
+			Limit:           10,
+			ExpectedUserIDs: Users,
+		}, {
+			Name:            "PrefixPage",
+			Query:           "bot",
+			Offset:          3,
+			Limit:           10,
+			ExpectedUserIDs: Users[3:],
+		}, {
+			Name:            "PrefixNoMatch",
+			Query:           "ot",
+			Limit:           10,
+			ExpectedUserIDs: []string{},
+		}, {
+			Name:            "Fuzzy",
+			Query:           "bot1",
+			Fuzzy:           true,
+			Limit:           2,
+			ExpectedUserIDs: []string{Users[1], Users[0]},
+		}, {
+			Name:            "FuzzyNoMatch",
+			Query:           "flaf",
+			Fuzzy:           true,
+			Limit:           10,
+			ExpectedUserIDs: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			search := suite.Datastore.SearchUsersByPrefix
+			if test.Fuzzy {
+				search = suite.Datastore.SearchUsersFuzzy
+			}
+
+			matches, err := search(test.Query, "", test.Offset, test.Limit)
+			require.Nil(t, err)
+
+			require.Equal(t, len(test.ExpectedUserIDs), len(matches))
+			for i, match := range matches {
+				assert.Equal(t, test.ExpectedUserIDs[i], match.UserID)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
 func (suite *DatastoreTestSuite) TestGetGameState() {
 	tests := []struct {
 		Name                string
@@ -195,8 +360,8 @@ func (suite *DatastoreTestSuite) TestGetGameState() {
 			Name:                "test",
 			ID:                  Users[0],
 			ExpectedSuccess:     true,
-			ExpectedGamesPlayed: GameStates[0].GamesPlayed,
-			ExpectedScore:       GameStates[0].Score,
+			ExpectedGamesPlayed: GameStates[0].GamesPlayed(),
+			ExpectedScore:       GameStates[0].Score(),
 		},
 	}
 
@@ -205,15 +370,127 @@ func (suite *DatastoreTestSuite) TestGetGameState() {
 			gameState, err := suite.Datastore.GetGameState(test.ID)
 			if test.ExpectedSuccess {
 				require.Nil(t, err)
-				assert.Equal(t, test.ExpectedGamesPlayed, gameState.GamesPlayed)
-				assert.Equal(t, test.ExpectedScore, gameState.Score)
+				assert.Equal(t, test.ExpectedGamesPlayed, gameState.GamesPlayed())
+				assert.Equal(t, test.ExpectedScore, gameState.Score())
+			} else {
+				assert.NotNil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestGetGameStateUpgrade() {
+	// The well-known fields are kept by updates, whatever the document holds
+	tests := []struct {
+		Name            string
+		Stored          string
+		ExpectedSuccess bool
+	}{
+		{
+			Name:            "Unversioned",
+			Stored:          `{"gamesPlayed": 4, "score": 40}`,
+			ExpectedSuccess: true,
+		}, {
+			Name:            "Current",
+			Stored:          `{"version": 2, "gamesPlayed": 5, "score": 50, "progress": {"level": 3}, "settings": {}}`,
+			ExpectedSuccess: true,
+		}, {
+			Name:            "Unsupported",
+			Stored:          `{"version": 99}`,
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			stored := new(port.GameState)
+			require.Nil(t, json.Unmarshal([]byte(test.Stored), stored))
+			require.Nil(t, suite.Datastore.UpdateGameState(Users[0], stored, Change))
+
+			gameState, err := suite.Datastore.GetGameState(Users[0])
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, port.GameStateVersion, gameState.Version)
+				assert.Equal(t, GameStates[0].GamesPlayed(), gameState.GamesPlayed())
+				assert.Equal(t, GameStates[0].Score(), gameState.Score())
+				assert.NotNil(t, gameState.Document[port.GameStateFieldProgress])
+				assert.NotNil(t, gameState.Document[port.GameStateFieldSettings])
 			} else {
+				assert.Nil(t, gameState)
+				assert.NotNil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestFlagUser() {
+	tests := []struct {
+		Name            string
+		ID              string
+		Reason          string
+		ExpectedSuccess bool
+		ExpectedCount   int
+	}{
+		{
+			Name:            "First",
+			ID:              Users[1],
+			Reason:          "first",
+			ExpectedSuccess: true,
+			ExpectedCount:   1,
+		}, {
+			Name:            "Again",
+			ID:              Users[1],
+			Reason:          "again",
+			ExpectedSuccess: true,
+			ExpectedCount:   2,
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Reason:          "first",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			err := suite.Datastore.FlagUser(test.ID, test.Reason)
+			if !test.ExpectedSuccess {
 				assert.NotNil(t, err)
+				return
 			}
+
+			require.Nil(t, err)
+
+			flags, err := suite.Datastore.GetFlaggedUsers()
+			require.Nil(t, err)
+			require.Equal(t, 1, len(flags))
+			assert.Equal(t, test.ID, flags[0].UserID)
+			assert.Equal(t, test.Reason, flags[0].Reason)
+			assert.Equal(t, test.ExpectedCount, flags[0].Count)
+			assert.False(t, flags[0].LastFlaggedAt.Before(flags[0].FirstFlaggedAt))
 		}
 
 		suite.T().Run(test.Name, fn)
 	}
+
+	// Flags are moved with the user
+	record, err := suite.Datastore.ExportUser(Users[1])
+	require.Nil(suite.T(), err)
+	require.NotNil(suite.T(), record.Flag)
+	assert.Equal(suite.T(), 2, record.Flag.Count)
+
+	// Cleared flags are no longer listed
+	require.Nil(suite.T(), suite.Datastore.ClearUserFlag(Users[1]))
+
+	flags, err := suite.Datastore.GetFlaggedUsers()
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, len(flags))
+
+	assert.NotNil(suite.T(), suite.Datastore.ClearUserFlag("fee6feba-043b-4ba4-a7a4-9d6705595049"))
 }
 
 func (suite *DatastoreTestSuite) TestGetFriends() {
@@ -252,6 +529,189 @@ func (suite *DatastoreTestSuite) TestGetFriends() {
 	}
 }
 
+func (suite *DatastoreTestSuite) TestListFriends() {
+	minScore := 1
+
+	tests := []struct {
+		Name            string
+		ID              string
+		Query           port.ListQuery
+		ExpectedSuccess bool
+		ExpectedFriends []string
+	}{
+		{
+			Name:            "First",
+			ID:              Users[0],
+			Query:           port.ListQuery{Sort: port.SortByID, Limit: 2},
+			ExpectedSuccess: true,
+			ExpectedFriends: []string{Users[1], Users[2]},
+		}, {
+			Name: "After",
+			ID:   Users[0],
+			Query: port.ListQuery{
+				Sort:  port.SortByID,
+				Limit: 2,
+				After: &port.ListKey{UserID: Users[2]},
+			},
+			ExpectedSuccess: true,
+			ExpectedFriends: []string{Users[3]},
+		}, {
+			Name: "BeforeDescending",
+			ID:   Users[0],
+			Query: port.ListQuery{
+				Sort:       port.SortByName,
+				Descending: true,
+				Limit:      1,
+				Before:     &port.ListKey{UserID: Users[1], Name: UserNames[1]},
+			},
+			ExpectedSuccess: true,
+			ExpectedFriends: []string{Users[2]},
+		}, {
+			Name:            "MinScore",
+			ID:              Users[0],
+			Query:           port.ListQuery{Sort: port.SortByScore, Limit: 10, MinScore: &minScore},
+			ExpectedSuccess: true,
+			ExpectedFriends: []string{},
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Query:           port.ListQuery{Sort: port.SortByID, Limit: 10},
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			friends, err := suite.Datastore.ListFriends(test.ID, &test.Query)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+
+				require.Equal(t, len(test.ExpectedFriends), len(friends))
+				for i, friend := range friends {
+					assert.Equal(t, test.ExpectedFriends[i], friend.UserID)
+				}
+			} else {
+				assert.Nil(t, friends)
+				assert.NotNil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestGetFriendIDs() {
+	tests := []struct {
+		Name            string
+		ID              string
+		ExpectedSuccess bool
+		ExpectedFriends []string
+	}{
+		{
+			Name:            "test",
+			ID:              Users[0],
+			ExpectedSuccess: true,
+			ExpectedFriends: Friends[0],
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			friendIDs, err := suite.Datastore.GetFriendIDs(test.ID)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedFriends, friendIDs)
+			} else {
+				assert.Nil(t, friendIDs)
+				assert.NotNil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestGetFriendsByID() {
+	tests := []struct {
+		Name            string
+		IDs             []string
+		ExpectedFriends []string
+		ExpectedScores  []int
+	}{
+		{
+			Name:            "test",
+			IDs:             []string{Users[2], Users[0]},
+			ExpectedFriends: []string{Users[0], Users[2]},
+			ExpectedScores:  []int{GameStates[0].Score(), GameStates[2].Score()},
+		}, {
+			Name:            "UnknownID",
+			IDs:             []string{"fee6feba-043b-4ba4-a7a4-9d6705595049", Users[1]},
+			ExpectedFriends: []string{Users[1]},
+			ExpectedScores:  []int{GameStates[1].Score()},
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			friends, err := suite.Datastore.GetFriendsByID(test.IDs, "")
+			require.Nil(t, err)
+
+			require.Equal(t, len(test.ExpectedFriends), len(friends))
+			for i, friend := range friends {
+				assert.Equal(t, test.ExpectedFriends[i], friend.UserID)
+				assert.Equal(t, test.ExpectedScores[i], friend.HighScore)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestExportImportUser() {
+	tests := []struct {
+		Name            string
+		ID              string
+		ExpectedSuccess bool
+	}{
+		{
+			Name:            "test",
+			ID:              Users[0],
+			ExpectedSuccess: true,
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			record, err := suite.Datastore.ExportUser(test.ID)
+			if !test.ExpectedSuccess {
+				assert.Nil(t, record)
+				assert.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+
+			// Import into an empty datastore
+			target := NewDatastoreSimulator()
+			require.Nil(t, target.ImportUser(record))
+
+			exported, err := target.ExportUser(test.ID)
+			require.Nil(t, err)
+			assert.Equal(t, record, exported)
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
 func (suite *DatastoreTestSuite) TestNewUser() {
 	tests := []struct {
 		Name            string
@@ -295,25 +755,33 @@ func (suite *DatastoreTestSuite) TestNewUser() {
 }
 
 func (suite *DatastoreTestSuite) TestUpdateGameState() {
+	// Updates store the document, keeping the games played and best score
 	tests := []struct {
-		Name            string
-		ID              string
-		GamesPlayed     int
-		Score           int
-		ExpectedSuccess bool
+		Name                string
+		ID                  string
+		Level               int
+		ExpectedSuccess     bool
+		ExpectedGamesPlayed int
+		ExpectedScore       int
 	}{
 		{
-			Name:            "test",
-			ID:              Users[3],
-			GamesPlayed:     1,
-			Score:           200,
-			ExpectedSuccess: true,
+			Name:                "test",
+			ID:                  Users[0],
+			Level:               3,
+			ExpectedSuccess:     true,
+			ExpectedGamesPlayed: GameStates[0].GamesPlayed(),
+			ExpectedScore:       GameStates[0].Score(),
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Level:           1,
+			ExpectedSuccess: false,
 		},
 	}
 
 	for _, test := range tests {
 		fn := func(t *testing.T) {
-			err := suite.Datastore.UpdateGameState(test.ID, test.GamesPlayed, test.Score)
+			err := suite.Datastore.UpdateGameState(test.ID, levelState(test.Level, 200), Change)
 			if test.ExpectedSuccess {
 				require.Nil(t, err)
 
@@ -321,8 +789,9 @@ func (suite *DatastoreTestSuite) TestUpdateGameState() {
 				gameState, err := suite.Datastore.GetGameState(test.ID)
 				require.Nil(t, err)
 
-				assert.Equal(t, test.GamesPlayed, gameState.GamesPlayed)
-				assert.Equal(t, test.Score, gameState.Score)
+				assert.Equal(t, test.Level, stateLevel(gameState))
+				assert.Equal(t, test.ExpectedGamesPlayed, gameState.GamesPlayed())
+				assert.Equal(t, test.ExpectedScore, gameState.Score())
 
 			} else {
 				assert.NotNil(t, err)
@@ -333,40 +802,83 @@ func (suite *DatastoreTestSuite) TestUpdateGameState() {
 	}
 }
 
-func (suite *DatastoreTestSuite) TestUpdateFriends() {
+func (suite *DatastoreTestSuite) TestSubmitScore() {
+	// Runs are submitted in order
+	best := GameStates[0].Score()
 	tests := []struct {
-		Name            string
-		ID              string
-		Friends         []string
-		ExpectedSuccess bool
+		Name                 string
+		ID                   string
+		Score                int
+		Key                  string
+		ExpectedSuccess      bool
+		ExpectedGamesPlayed  int
+		ExpectedScore        int
+		ExpectedPersonalBest bool
+		ExpectedReplayed     bool
 	}{
 		{
-			Name: "test",
-			ID:   Users[3],
-			Friends: []string{
-				Users[0],
-				Users[1],
-			},
-			ExpectedSuccess: true,
+			Name:                 "Lower",
+			ID:                   Users[0],
+			Score:                best - 1,
+			ExpectedSuccess:      true,
+			ExpectedGamesPlayed:  GameStates[0].GamesPlayed() + 1,
+			ExpectedScore:        best,
+			ExpectedPersonalBest: false,
+		}, {
+			Name:                 "Higher",
+			ID:                   Users[0],
+			Score:                best + 1,
+			ExpectedSuccess:      true,
+			ExpectedGamesPlayed:  GameStates[0].GamesPlayed() + 2,
+			ExpectedScore:        best + 1,
+			ExpectedPersonalBest: true,
+		}, {
+			Name:                 "Keyed",
+			ID:                   Users[0],
+			Score:                best + 2,
+			Key:                  "game:a0e6feba",
+			ExpectedSuccess:      true,
+			ExpectedGamesPlayed:  GameStates[0].GamesPlayed() + 3,
+			ExpectedScore:        best + 2,
+			ExpectedPersonalBest: true,
+		}, {
+			Name:                "Replayed",
+			ID:                  Users[0],
+			Score:               best + 2,
+			Key:                 "game:a0e6feba",
+			ExpectedSuccess:     true,
+			ExpectedGamesPlayed: GameStates[0].GamesPlayed() + 3,
+			ExpectedScore:       best + 2,
+			ExpectedReplayed:    true,
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Score:           10,
+			ExpectedSuccess: false,
 		},
 	}
 
 	for _, test := range tests {
 		fn := func(t *testing.T) {
-			err := suite.Datastore.UpdateFriends(test.ID, test.Friends)
+			change := Change
+			change.Key = test.Key
+
+			result, err := suite.Datastore.SubmitScore(test.ID, test.Score, change)
 			if test.ExpectedSuccess {
 				require.Nil(t, err)
 
-				friends, err := suite.Datastore.GetFriends(test.ID)
+				assert.Equal(t, test.ExpectedGamesPlayed, result.GameState.GamesPlayed())
+				assert.Equal(t, test.ExpectedScore, result.GameState.Score())
+				assert.Equal(t, test.ExpectedPersonalBest, result.PersonalBest)
+				assert.Equal(t, test.ExpectedReplayed, result.Replayed)
 
+				// Read to verify changes
+				gameState, err := suite.Datastore.GetGameState(test.ID)
 				require.Nil(t, err)
-				require.NotNil(t, friends)
+				assert.Equal(t, result.GameState, *gameState)
 
-				require.Equal(t, len(test.Friends), len(friends))
-				for i, friend := range friends {
-					assert.Equal(t, test.Friends[i], friend.UserID)
-				}
 			} else {
+				assert.Nil(t, result)
 				assert.NotNil(t, err)
 			}
 		}
@@ -374,6 +886,1335 @@ func (suite *DatastoreTestSuite) TestUpdateFriends() {
 		suite.T().Run(test.Name, fn)
 	}
 
+	// Every run is recorded, replayed runs only once
+	record, err := suite.Datastore.ExportUser(Users[0])
+	require.Nil(suite.T(), err)
+	require.Equal(suite.T(), 3, len(record.Runs))
+	assert.Equal(suite.T(), "game:a0e6feba", record.Runs[2].Key)
+}
+
+func (suite *DatastoreTestSuite) TestModifyGameState() {
+	// Modifications of the games played and best score aren't stored
+	tests := []struct {
+		Name            string
+		ID              string
+		Modify          port.GameStateModifier
+		ExpectedSuccess bool
+		ExpectedLevel   int
+	}{
+		{
+			Name: "Modify",
+			ID:   Users[0],
+			Modify: func(state *port.GameState) common.Error {
+				state.Document[port.GameStateFieldProgress] = map[string]interface{}{"level": json.Number("5")}
+				state.SetScore(state.Score() + 5)
+				return nil
+			},
+			ExpectedSuccess: true,
+			ExpectedLevel:   5,
+		}, {
+			Name: "Failed",
+			ID:   Users[0],
+			Modify: func(state *port.GameState) common.Error {
+				state.Document[port.GameStateFieldProgress] = map[string]interface{}{}
+				return common.NewError(port.ErrInvalidDocument, "Failed")
+			},
+			ExpectedSuccess: false,
+			ExpectedLevel:   5,
+		}, {
+			Name: "InvalidID",
+			ID:   "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Modify: func(state *port.GameState) common.Error {
+				return nil
+			},
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			state, err := suite.Datastore.ModifyGameState(test.ID, Change, test.Modify)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, port.GameStateVersion, state.Version)
+				assert.Equal(t, test.ExpectedLevel, stateLevel(state))
+				assert.Equal(t, GameStates[0].Score(), state.Score())
+
+			} else {
+				assert.Nil(t, state)
+				assert.NotNil(t, err)
+			}
+
+			// Read to verify that failed modifications aren't stored
+			if test.ID == Users[0] {
+				gameState, err := suite.Datastore.GetGameState(test.ID)
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedLevel, stateLevel(gameState))
+				assert.Equal(t, GameStates[0].Score(), gameState.Score())
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestCompareAndSwapGameState() {
+	// Swaps are applied in order, starting at the revision of the fixture
+	revision := GameStates[0].Revision
+	tests := []struct {
+		Name             string
+		ID               string
+		Revision         int
+		Level            int
+		ExpectedSuccess  bool
+		ExpectedRevision int
+		ExpectedLevel    int
+	}{
+		{
+			Name:             "Swap",
+			ID:               Users[0],
+			Revision:         revision,
+			Level:            2,
+			ExpectedSuccess:  true,
+			ExpectedRevision: revision + 1,
+			ExpectedLevel:    2,
+		}, {
+			Name:             "Stale",
+			ID:               Users[0],
+			Revision:         revision,
+			Level:            3,
+			ExpectedSuccess:  false,
+			ExpectedRevision: revision + 1,
+			ExpectedLevel:    2,
+		}, {
+			Name:             "SwapAgain",
+			ID:               Users[0],
+			Revision:         revision + 1,
+			Level:            3,
+			ExpectedSuccess:  true,
+			ExpectedRevision: revision + 2,
+			ExpectedLevel:    3,
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Revision:        0,
+			Level:           1,
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			swapped, err := suite.Datastore.CompareAndSwapGameState(test.ID, test.Revision, levelState(test.Level, 300), Change)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedRevision, swapped)
+
+			} else {
+				require.NotNil(t, err)
+				if test.ID == Users[0] {
+					assert.Equal(t, port.ErrConflict.Code(), err.Code())
+				}
+			}
+
+			// Read to verify only matching revisions are swapped
+			if test.ID == Users[0] {
+				gameState, err := suite.Datastore.GetGameState(test.ID)
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedRevision, gameState.Revision)
+				assert.Equal(t, test.ExpectedLevel, stateLevel(gameState))
+				assert.Equal(t, GameStates[0].Score(), gameState.Score())
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestGameStateRevisions() {
+	// Every write is recorded as a revision
+	update := port.StateChange{Author: Users[0], Source: port.StateSourceUpdate}
+	require.Nil(suite.T(), suite.Datastore.UpdateGameState(Users[0], port.NewGameState(11, 120), update))
+
+	score := port.StateChange{Author: Users[0], Source: port.StateSourceScore}
+	result, err := suite.Datastore.SubmitScore(Users[0], 130, score)
+	require.Nil(suite.T(), err)
+
+	tests := []struct {
+		Name                string
+		ID                  string
+		Revision            int
+		ExpectedSuccess     bool
+		ExpectedSource      string
+		ExpectedGamesPlayed int
+		ExpectedScore       int
+	}{
+		{
+			Name:                "Update",
+			ID:                  Users[0],
+			Revision:            result.GameState.Revision - 1,
+			ExpectedSuccess:     true,
+			ExpectedSource:      port.StateSourceUpdate,
+			ExpectedGamesPlayed: 10,
+			ExpectedScore:       110,
+		}, {
+			Name:                "Score",
+			ID:                  Users[0],
+			Revision:            result.GameState.Revision,
+			ExpectedSuccess:     true,
+			ExpectedSource:      port.StateSourceScore,
+			ExpectedGamesPlayed: 11,
+			ExpectedScore:       130,
+		}, {
+			Name:            "InvalidRevision",
+			ID:              Users[0],
+			Revision:        result.GameState.Revision + 1,
+			ExpectedSuccess: false,
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Revision:        1,
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			revision, err := suite.Datastore.GetGameStateRevision(test.ID, test.Revision)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, test.Revision, revision.Revision)
+				assert.Equal(t, test.Revision, revision.State.Revision)
+				assert.Equal(t, Users[0], revision.Author)
+				assert.Equal(t, test.ExpectedSource, revision.Source)
+				assert.False(t, revision.CreatedAt.IsZero())
+				assert.Equal(t, test.ExpectedGamesPlayed, revision.State.GamesPlayed())
+				assert.Equal(t, test.ExpectedScore, revision.State.Score())
+
+			} else {
+				assert.Nil(t, revision)
+				assert.NotNil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// Revisions are listed newest first
+	revisions, err := suite.Datastore.GetGameStateRevisions(Users[0])
+	require.Nil(suite.T(), err)
+	require.True(suite.T(), len(revisions) >= 2)
+	assert.Equal(suite.T(), result.GameState.Revision, revisions[0].Revision)
+	assert.Equal(suite.T(), result.GameState.Revision-1, revisions[1].Revision)
+
+	_, err = suite.Datastore.GetGameStateRevisions("fee6feba-043b-4ba4-a7a4-9d6705595049")
+	assert.NotNil(suite.T(), err)
+}
+
+func (suite *DatastoreTestSuite) TestSaveSlots() {
+	slotID := "a1e6feba-043b-4ba4-a7a4-9d6705595049"
+	change := port.StateChange{Author: Users[0], Source: port.StateSourceSlot}
+
+	// Users start with the default slot, holding their game state
+	slots, err := suite.Datastore.GetSaveSlots(Users[0])
+	require.Nil(suite.T(), err)
+	require.Equal(suite.T(), 1, len(slots))
+	assert.Equal(suite.T(), Users[0], slots[0].SlotID)
+	assert.Equal(suite.T(), port.DefaultSaveSlotName, slots[0].Name)
+	assert.True(suite.T(), slots[0].Active)
+	assert.Equal(suite.T(), 110, slots[0].State.Score())
+
+	created, err := suite.Datastore.NewSaveSlot(Users[0], &port.SaveSlot{
+		SlotID:     slotID,
+		Name:       "Hard mode",
+		State:      *levelState(1, 0),
+		LastDevice: "phone",
+	}, 8)
+	require.Nil(suite.T(), err)
+	assert.False(suite.T(), created.Active)
+	assert.Equal(suite.T(), "phone", created.LastDevice)
+	assert.Equal(suite.T(), port.GameStateVersion, created.State.Version)
+
+	tests := []struct {
+		Name            string
+		ID              string
+		Slot            *port.SaveSlot
+		MaxSlots        int
+		ExpectedSuccess bool
+		ExpectedCode    string
+	}{
+		{
+			Name:            "ExistingID",
+			ID:              Users[0],
+			MaxSlots:        8,
+			Slot:            &port.SaveSlot{SlotID: slotID, Name: "Other", State: *port.NewGameState(0, 0)},
+			ExpectedSuccess: false,
+			ExpectedCode:    port.ErrEntryExists.Code(),
+		}, {
+			Name:            "ExistingName",
+			ID:              Users[0],
+			MaxSlots:        8,
+			Slot:            &port.SaveSlot{SlotID: "a2e6feba-043b-4ba4-a7a4-9d6705595049", Name: "Hard mode", State: *port.NewGameState(0, 0)},
+			ExpectedSuccess: false,
+			ExpectedCode:    port.ErrEntryExists.Code(),
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			MaxSlots:        8,
+			Slot:            &port.SaveSlot{SlotID: slotID, Name: "Other", State: *port.NewGameState(0, 0)},
+			ExpectedSuccess: false,
+			ExpectedCode:    port.ErrInvalidKey.Code(),
+		}, {
+			Name:            "OtherUser",
+			ID:              Users[1],
+			MaxSlots:        8,
+			Slot:            &port.SaveSlot{SlotID: slotID, Name: "Hard mode", State: *port.NewGameState(0, 0)},
+			ExpectedSuccess: true,
+		}, {
+			Name:            "LimitReached",
+			ID:              Users[0],
+			MaxSlots:        2,
+			Slot:            &port.SaveSlot{SlotID: "a2e6feba-043b-4ba4-a7a4-9d6705595049", Name: "Other", State: *port.NewGameState(0, 0)},
+			ExpectedSuccess: false,
+			ExpectedCode:    port.ErrLimitReached.Code(),
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			slot, err := suite.Datastore.NewSaveSlot(test.ID, test.Slot, test.MaxSlots)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, test.Slot.SlotID, slot.SlotID)
+			} else {
+				assert.Nil(t, slot)
+				require.NotNil(t, err)
+				assert.Equal(t, test.ExpectedCode, err.Code())
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// Renaming keeps the other metadata
+	name, playTime := "Nightmare", 3600
+	updated, err := suite.Datastore.UpdateSaveSlot(Users[0], slotID, &port.SaveSlotUpdate{Name: &name, PlayTime: &playTime})
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), name, updated.Name)
+	assert.Equal(suite.T(), "phone", updated.LastDevice)
+	assert.Equal(suite.T(), playTime, updated.PlayTime)
+
+	taken := port.DefaultSaveSlotName
+	_, err = suite.Datastore.UpdateSaveSlot(Users[0], slotID, &port.SaveSlotUpdate{Name: &taken})
+	require.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), port.ErrEntryExists.Code(), err.Code())
+
+	// Activating swaps the state of the slot into the user, as a new revision.
+	// The games played and best score stay with the user.
+	before, err := suite.Datastore.GetGameState(Users[0])
+	require.Nil(suite.T(), err)
+
+	state, err := suite.Datastore.ActivateSaveSlot(Users[0], slotID, change)
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), before.Revision+1, state.Revision)
+	assert.Equal(suite.T(), 1, stateLevel(state))
+	assert.Equal(suite.T(), 110, state.Score())
+
+	revision, err := suite.Datastore.GetGameStateRevision(Users[0], state.Revision)
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), port.StateSourceSlot, revision.Source)
+
+	previous, err := suite.Datastore.GetSaveSlot(Users[0], Users[0])
+	require.Nil(suite.T(), err)
+	assert.False(suite.T(), previous.Active)
+	assert.Equal(suite.T(), 110, previous.State.Score())
+
+	// Activating the active slot changes nothing
+	again, err := suite.Datastore.ActivateSaveSlot(Users[0], slotID, change)
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), state.Revision, again.Revision)
+
+	// The active slot can't be deleted
+	err = suite.Datastore.DeleteSaveSlot(Users[0], slotID)
+	require.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), port.ErrEntryInUse.Code(), err.Code())
+
+	restored, err := suite.Datastore.ActivateSaveSlot(Users[0], Users[0], change)
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, stateLevel(restored))
+	assert.Equal(suite.T(), 110, restored.Score())
+
+	require.Nil(suite.T(), suite.Datastore.DeleteSaveSlot(Users[0], slotID))
+
+	_, err = suite.Datastore.GetSaveSlot(Users[0], slotID)
+	require.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), port.ErrInvalidKey.Code(), err.Code())
+
+	_, err = suite.Datastore.ActivateSaveSlot(Users[0], slotID, change)
+	require.NotNil(suite.T(), err)
+	assert.Equal(suite.T(), port.ErrInvalidKey.Code(), err.Code())
+}
+
+func (suite *DatastoreTestSuite) TestNewGame() {
+	tests := []struct {
+		Name            string
+		ID              string
+		UserIDs         []string
+		ExpectedSuccess bool
+	}{
+		{
+			Name:            "test",
+			ID:              "b0e6feba-043b-4ba4-a7a4-9d6705595049",
+			UserIDs:         []string{Users[2], Users[3]},
+			ExpectedSuccess: true,
+		}, {
+			Name:            "Exists",
+			ID:              GameIDs[0],
+			UserIDs:         []string{Users[2]},
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			game, err := suite.Datastore.NewGame(test.ID, test.UserIDs)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, test.ID, game.GameID)
+				assert.Equal(t, port.GamePlaying, game.Status)
+
+				// Read to verify changes
+				stored, err := suite.Datastore.GetGame(test.ID)
+				require.Nil(t, err)
+				assert.Equal(t, game, stored)
+
+				require.Equal(t, len(test.UserIDs), len(stored.Participants))
+				for i, p := range stored.Participants {
+					assert.Equal(t, test.UserIDs[i], p.UserID)
+					assert.Equal(t, port.GamePlaying, p.Status)
+				}
+			} else {
+				assert.Nil(t, game)
+				assert.NotNil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestGetGame() {
+	// The personal game of a user is the game state of the user
+	state, err := suite.Datastore.GetGameState(Users[1])
+	suite.Require().Nil(err)
+
+	tests := []struct {
+		Name                 string
+		ID                   string
+		ExpectedSuccess      bool
+		ExpectedParticipants []string
+		ExpectedState        *port.GameState
+	}{
+		{
+			Name:                 "Stored",
+			ID:                   GameIDs[0],
+			ExpectedSuccess:      true,
+			ExpectedParticipants: []string{Users[0], Users[1]},
+		}, {
+			Name:                 "Personal",
+			ID:                   Users[1],
+			ExpectedSuccess:      true,
+			ExpectedParticipants: []string{Users[1]},
+			ExpectedState:        state,
+		}, {
+			Name:            "InvalidID",
+			ID:              "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			game, err := suite.Datastore.GetGame(test.ID)
+			if !test.ExpectedSuccess {
+				assert.Nil(t, game)
+				require.NotNil(t, err)
+				assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, test.ID, game.GameID)
+
+			participants := make([]string, 0)
+			for _, p := range game.Participants {
+				participants = append(participants, p.UserID)
+			}
+
+			assert.Equal(t, test.ExpectedParticipants, participants)
+			assert.Equal(t, test.ExpectedState, game.State)
+
+			// Personal games are scored with the best score
+			if test.ExpectedState != nil {
+				assert.Equal(t, port.GamePlaying, game.Status)
+				assert.Equal(t, test.ExpectedState.Score(), game.Participants[0].Score)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestSetGameResult() {
+	// Results are submitted in order
+	tests := []struct {
+		Name            string
+		GameID          string
+		UserID          string
+		Score           int
+		ExpectedSuccess bool
+		ExpectedStatus  string
+	}{
+		{
+			Name:            "AlreadySubmitted",
+			GameID:          GameIDs[0],
+			UserID:          Users[0],
+			Score:           10,
+			ExpectedSuccess: false,
+		}, {
+			Name:            "NotParticipating",
+			GameID:          GameIDs[0],
+			UserID:          Users[2],
+			Score:           10,
+			ExpectedSuccess: false,
+		}, {
+			Name:            "InvalidGameID",
+			GameID:          "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			UserID:          Users[1],
+			Score:           10,
+			ExpectedSuccess: false,
+		}, {
+			Name:            "PersonalGame",
+			GameID:          Users[1],
+			UserID:          Users[1],
+			Score:           10,
+			ExpectedSuccess: false,
+		}, {
+			Name:            "Finish",
+			GameID:          GameIDs[0],
+			UserID:          Users[1],
+			Score:           20,
+			ExpectedSuccess: true,
+			ExpectedStatus:  port.GameFinished,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			game, err := suite.Datastore.SetGameResult(test.GameID, test.UserID, test.Score)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedStatus, game.Status)
+
+				for _, p := range game.Participants {
+					if p.UserID == test.UserID {
+						assert.Equal(t, port.GameFinished, p.Status)
+						assert.Equal(t, test.Score, p.Score)
+					}
+				}
+			} else {
+				assert.Nil(t, game)
+				assert.NotNil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *DatastoreTestSuite) TestUpdateFriends() {
+	// Replaces are applied in order, to both the user and the friends
+	tests := []struct {
+		Name               string
+		ID                 string
+		Friends            []string
+		ExpectedSuccess    bool
+		ExpectedUnfriended []string
+	}{
+		{
+			Name: "test",
+			ID:   Users[3],
+			Friends: []string{
+				Users[0],
+				Users[1],
+			},
+			ExpectedSuccess: true,
+		}, {
+			Name:               "Replace",
+			ID:                 Users[3],
+			Friends:            []string{Users[1], Users[2]},
+			ExpectedSuccess:    true,
+			ExpectedUnfriended: []string{Users[0]},
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			err := suite.Datastore.UpdateFriends(test.ID, test.Friends)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+
+				friends, err := suite.Datastore.GetFriends(test.ID)
+
+				require.Nil(t, err)
+				require.NotNil(t, friends)
+
+				require.Equal(t, len(test.Friends), len(friends))
+				for i, friend := range friends {
+					assert.Equal(t, test.Friends[i], friend.UserID)
+				}
+
+				for _, friendID := range test.Friends {
+					friendIDs, err := suite.Datastore.GetFriendIDs(friendID)
+					require.Nil(t, err)
+					assert.Contains(t, friendIDs, test.ID)
+				}
+
+				for _, friendID := range test.ExpectedUnfriended {
+					friendIDs, err := suite.Datastore.GetFriendIDs(friendID)
+					require.Nil(t, err)
+					assert.NotContains(t, friendIDs, test.ID)
+				}
+			} else {
+				assert.NotNil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+}
+
+func (suite *DatastoreTestSuite) TestAddRemoveFriend() {
+	t := suite.T()
+
+	// Adding is idempotent
+	added, err := suite.Datastore.AddFriend(Users[1], Users[0])
+	require.Nil(t, err)
+	assert.True(t, added)
+
+	added, err = suite.Datastore.AddFriend(Users[1], Users[0])
+	require.Nil(t, err)
+	assert.False(t, added)
+
+	friendIDs, err := suite.Datastore.GetFriendIDs(Users[1])
+	require.Nil(t, err)
+	assert.Equal(t, []string{Users[0]}, friendIDs)
+
+	// Adding adds the friendship to both users
+	added, err = suite.Datastore.AddFriend(Users[2], Users[3])
+	require.Nil(t, err)
+	assert.True(t, added)
+
+	friendIDs, err = suite.Datastore.GetFriendIDs(Users[3])
+	require.Nil(t, err)
+	assert.Equal(t, []string{Users[2]}, friendIDs)
+
+	// Removing keeps the order of the other friends
+	removed, err := suite.Datastore.RemoveFriend(Users[0], Users[2])
+	require.Nil(t, err)
+	assert.True(t, removed)
+
+	removed, err = suite.Datastore.RemoveFriend(Users[0], Users[2])
+	require.Nil(t, err)
+	assert.False(t, removed)
+
+	friendIDs, err = suite.Datastore.GetFriendIDs(Users[0])
+	require.Nil(t, err)
+	assert.Equal(t, []string{Users[1], Users[3]}, friendIDs)
+
+	// Removing removes the friendship from both users
+	removed, err = suite.Datastore.RemoveFriend(Users[1], Users[0])
+	require.Nil(t, err)
+	assert.True(t, removed)
+
+	friendIDs, err = suite.Datastore.GetFriendIDs(Users[0])
+	require.Nil(t, err)
+	assert.Equal(t, []string{Users[3]}, friendIDs)
+
+	friendIDs, err = suite.Datastore.GetFriendIDs(Users[1])
+	require.Nil(t, err)
+	assert.Empty(t, friendIDs)
+
+	// Unknown users
+	unknownID := "fee6feba-043b-4ba4-a7a4-9d6705595049"
+	_, err = suite.Datastore.AddFriend(unknownID, Users[0])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	_, err = suite.Datastore.RemoveFriend(unknownID, Users[0])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+}
+
+func (suite *DatastoreTestSuite) TestFriendRequests() {
+	t := suite.T()
+	expires := time.Now().Add(time.Hour)
+
+	_, err := suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[2], ToID: Users[1], ExpiresAt: expires}, 50)
+	require.Nil(t, err)
+
+	_, err = suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[2], ToID: Users[1], ExpiresAt: expires}, 50)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrEntryExists.Code(), err.Code())
+
+	// Expired requests are ignored, and may be sent again
+	_, err = suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[3], ToID: Users[1], ExpiresAt: time.Now()}, 50)
+	require.Nil(t, err)
+
+	requests, err := suite.Datastore.GetFriendRequests(Users[1])
+	require.Nil(t, err)
+	require.Equal(t, 1, len(requests))
+	assert.Equal(t, Users[2], requests[0].FromID)
+
+	_, err = suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[3], ToID: Users[1], ExpiresAt: expires}, 50)
+	require.Nil(t, err)
+
+	// Pending requests sent and received are limited, not counting the
+	// request between the users
+	_, err = suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[0], ToID: Users[1], ExpiresAt: expires}, 2)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrLimitReached.Code(), err.Code())
+
+	_, err = suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[3], ToID: Users[2], ExpiresAt: expires}, 1)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrLimitReached.Code(), err.Code())
+
+	_, err = suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[3], ToID: Users[1], ExpiresAt: expires}, 2)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrEntryExists.Code(), err.Code())
+
+	sent, err := suite.Datastore.GetSentFriendRequests(Users[3])
+	require.Nil(t, err)
+	require.Equal(t, 1, len(sent))
+	assert.Equal(t, Users[1], sent[0].ToID)
+
+	// Accepting makes the users friends of each other
+	require.Nil(t, suite.Datastore.AcceptFriendRequest(Users[2], Users[1]))
+
+	friendIDs, err := suite.Datastore.GetFriendIDs(Users[1])
+	require.Nil(t, err)
+	assert.Equal(t, []string{Users[2]}, friendIDs)
+
+	friendIDs, err = suite.Datastore.GetFriendIDs(Users[2])
+	require.Nil(t, err)
+	assert.Equal(t, []string{Users[1]}, friendIDs)
+
+	err = suite.Datastore.AcceptFriendRequest(Users[2], Users[1])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	// Declining only removes the request
+	require.Nil(t, suite.Datastore.DeleteFriendRequest(Users[3], Users[1]))
+
+	requests, err = suite.Datastore.GetFriendRequests(Users[1])
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(requests))
+
+	// Unknown users
+	unknownID := "fee6feba-043b-4ba4-a7a4-9d6705595049"
+	_, err = suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[3], ToID: unknownID, ExpiresAt: expires}, 50)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	_, err = suite.Datastore.GetFriendRequests(unknownID)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+}
+
+func (suite *DatastoreTestSuite) TestBlockUser() {
+	t := suite.T()
+
+	// User 1 blocks user 0, who has user 1 as a friend
+	blocked, err := suite.Datastore.BlockUser(Users[1], Users[0])
+	require.Nil(t, err)
+	assert.True(t, blocked)
+
+	blocked, err = suite.Datastore.BlockUser(Users[1], Users[0])
+	require.Nil(t, err)
+	assert.False(t, blocked)
+
+	friendIDs, err := suite.Datastore.GetFriendIDs(Users[0])
+	require.Nil(t, err)
+	assert.Equal(t, []string{Users[2], Users[3]}, friendIDs)
+
+	isBlocked, err := suite.Datastore.IsBlocked(Users[0], Users[1])
+	require.Nil(t, err)
+	assert.True(t, isBlocked)
+
+	_, err = suite.Datastore.AddFriend(Users[0], Users[1])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrBlocked.Code(), err.Code())
+
+	err = suite.Datastore.UpdateFriends(Users[0], []string{Users[1], Users[2], Users[3]})
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrBlocked.Code(), err.Code())
+
+	_, err = suite.Datastore.NewFriendRequest(&port.FriendRequest{FromID: Users[0], ToID: Users[1], ExpiresAt: time.Now().Add(time.Hour)}, 50)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrBlocked.Code(), err.Code())
+
+	// The blocker is hidden from the blocked user only
+	matches, err := suite.Datastore.SearchUsersByPrefix("bot", Users[0], 0, 10)
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(matches))
+
+	matches, err = suite.Datastore.SearchUsersFuzzy("bot1", Users[0], 0, 10)
+	require.Nil(t, err)
+	for _, match := range matches {
+		assert.NotEqual(t, Users[1], match.UserID)
+	}
+
+	users, err := suite.Datastore.ListUsers(&port.ListQuery{Sort: port.SortByID, Limit: 10, ViewerID: Users[0]})
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(users))
+
+	users, err = suite.Datastore.ListUsers(&port.ListQuery{Sort: port.SortByID, Limit: 10, ViewerID: Users[1]})
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(users))
+
+	friends, err := suite.Datastore.GetFriendsByID([]string{Users[1], Users[2]}, Users[0])
+	require.Nil(t, err)
+	require.Equal(t, 1, len(friends))
+	assert.Equal(t, Users[2], friends[0].UserID)
+
+	blocks, err := suite.Datastore.GetBlockedUsers(Users[1])
+	require.Nil(t, err)
+	require.Equal(t, 1, len(blocks))
+	assert.Equal(t, Users[0], blocks[0].BlockedID)
+
+	// Unblocking doesn't restore the friendship
+	unblocked, err := suite.Datastore.UnblockUser(Users[1], Users[0])
+	require.Nil(t, err)
+	assert.True(t, unblocked)
+
+	isBlocked, err = suite.Datastore.IsBlocked(Users[0], Users[1])
+	require.Nil(t, err)
+	assert.False(t, isBlocked)
+
+	friendIDs, err = suite.Datastore.GetFriendIDs(Users[0])
+	require.Nil(t, err)
+	assert.Equal(t, []string{Users[2], Users[3]}, friendIDs)
+
+	// Unknown users
+	_, err = suite.Datastore.BlockUser("fee6feba-043b-4ba4-a7a4-9d6705595049", Users[0])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+}
+
+func (suite *DatastoreTestSuite) TestGetFriendSuggestions() {
+	t := suite.T()
+
+	// User 1 is a friend of user 0 and 2, who both are friends of user 3.
+	// Friendships are mutual, replacing the list of user 2 keeps user 1.
+	require.Nil(t, suite.Datastore.UpdateFriends(Users[1], []string{Users[0], Users[2]}))
+	require.Nil(t, suite.Datastore.UpdateFriends(Users[2], []string{Users[1], Users[3]}))
+
+	suggestions, err := suite.Datastore.GetFriendSuggestions(Users[1], 10)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(suggestions))
+	assert.Equal(t, Users[3], suggestions[0].UserID)
+	assert.Equal(t, UserNames[3], suggestions[0].Name)
+	assert.Equal(t, 2, suggestions[0].MutualFriends)
+	assert.Nil(t, suggestions[0].LastActiveAt)
+
+	// Activity is the latest game state revision
+	require.Nil(t, suite.Datastore.UpdateGameState(Users[3], port.NewGameState(1, 5), Change))
+
+	suggestions, err = suite.Datastore.GetFriendSuggestions(Users[1], 10)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(suggestions))
+	assert.NotNil(t, suggestions[0].LastActiveAt)
+
+	// User 3 is a friend of user 2 in turn, and only suggested user 1
+	suggestions, err = suite.Datastore.GetFriendSuggestions(Users[3], 10)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(suggestions))
+	assert.Equal(t, Users[1], suggestions[0].UserID)
+	assert.Equal(t, 1, suggestions[0].MutualFriends)
+
+	// Blocked users aren't suggested
+	_, err = suite.Datastore.BlockUser(Users[3], Users[1])
+	require.Nil(t, err)
+
+	suggestions, err = suite.Datastore.GetFriendSuggestions(Users[1], 10)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(suggestions))
+
+	// Unknown users
+	_, err = suite.Datastore.GetFriendSuggestions("fee6feba-043b-4ba4-a7a4-9d6705595049", 10)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+}
+
+func (suite *DatastoreTestSuite) TestReferralCodes() {
+	t := suite.T()
+
+	created, err := suite.Datastore.NewReferralCode(&port.ReferralCode{
+		Code:           "CODE2345",
+		UserID:         Users[0],
+		MaxRedemptions: 2,
+		ExpiresAt:      time.Now().Add(time.Hour),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "CODE2345", created.Code)
+	assert.Equal(t, 0, created.Redemptions)
+
+	// Codes are unique
+	_, err = suite.Datastore.NewReferralCode(&port.ReferralCode{Code: "CODE2345", UserID: Users[1], MaxRedemptions: 1, ExpiresAt: time.Now().Add(time.Hour)})
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrEntryExists.Code(), err.Code())
+
+	found, err := suite.Datastore.LookupReferralCode("CODE2345")
+	require.Nil(t, err)
+	assert.Equal(t, Users[0], found.UserID)
+
+	// Redemptions are limited
+	referred := []string{"a2e6feba-043b-4ba4-a7a4-9d6705595049", "a3e6feba-043b-4ba4-a7a4-9d6705595049"}
+	for i, userID := range referred {
+		code, err := suite.Datastore.RedeemReferralCode("CODE2345", userID)
+		require.Nil(t, err)
+		assert.Equal(t, i+1, code.Redemptions)
+	}
+
+	_, err = suite.Datastore.RedeemReferralCode("CODE2345", "a4e6feba-043b-4ba4-a7a4-9d6705595049")
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrUnavailable.Code(), err.Code())
+
+	referrals, err := suite.Datastore.GetReferrals(Users[0])
+	require.Nil(t, err)
+	require.Equal(t, 2, len(referrals))
+	for i, referral := range referrals {
+		assert.Equal(t, Users[0], referral.ReferrerID)
+		assert.Equal(t, referred[i], referral.UserID)
+		assert.Equal(t, "CODE2345", referral.Code)
+	}
+
+	// Replacing the code resets the redemptions, and keeps the referrals
+	_, err = suite.Datastore.NewReferralCode(&port.ReferralCode{Code: "CODE6789", UserID: Users[0], MaxRedemptions: 2, ExpiresAt: time.Now().Add(-time.Hour)})
+	require.Nil(t, err)
+
+	_, err = suite.Datastore.RedeemReferralCode("CODE2345", "a4e6feba-043b-4ba4-a7a4-9d6705595049")
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	// Expired codes can't be redeemed
+	_, err = suite.Datastore.RedeemReferralCode("CODE6789", "a4e6feba-043b-4ba4-a7a4-9d6705595049")
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrUnavailable.Code(), err.Code())
+
+	code, err := suite.Datastore.GetReferralCode(Users[0])
+	require.Nil(t, err)
+	assert.Equal(t, "CODE6789", code.Code)
+	assert.Equal(t, 0, code.Redemptions)
+
+	referrals, err = suite.Datastore.GetReferrals(Users[0])
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(referrals))
+
+	// Users without a code
+	_, err = suite.Datastore.GetReferralCode(Users[1])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	_, err = suite.Datastore.GetReferrals("fee6feba-043b-4ba4-a7a4-9d6705595049")
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+}
+
+func (suite *DatastoreTestSuite) TestLinkedAccounts() {
+	t := suite.T()
+
+	linked, err := suite.Datastore.LinkAccount(&port.LinkedAccount{UserID: Users[0], Provider: "steam", ExternalID: "s-0"})
+	require.Nil(t, err)
+	assert.Equal(t, "s-0", linked.ExternalID)
+	assert.False(t, linked.LinkedAt.IsZero())
+
+	_, err = suite.Datastore.LinkAccount(&port.LinkedAccount{UserID: Users[0], Provider: "discord", ExternalID: "d-0"})
+	require.Nil(t, err)
+
+	_, err = suite.Datastore.LinkAccount(&port.LinkedAccount{UserID: Users[1], Provider: "steam", ExternalID: "s-1"})
+	require.Nil(t, err)
+
+	// Accounts are unique per provider
+	_, err = suite.Datastore.LinkAccount(&port.LinkedAccount{UserID: Users[2], Provider: "steam", ExternalID: "s-0"})
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrEntryExists.Code(), err.Code())
+
+	// Linking again replaces the account of the provider
+	_, err = suite.Datastore.LinkAccount(&port.LinkedAccount{UserID: Users[1], Provider: "steam", ExternalID: "s-2"})
+	require.Nil(t, err)
+
+	accounts, err := suite.Datastore.GetLinkedAccounts(Users[0])
+	require.Nil(t, err)
+	require.Equal(t, 2, len(accounts))
+	assert.Equal(t, "discord", accounts[0].Provider)
+	assert.Equal(t, "steam", accounts[1].Provider)
+
+	found, err := suite.Datastore.FindLinkedAccounts("steam", []string{"s-0", "s-1", "s-2", "d-0"})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(found))
+	assert.Equal(t, Users[0], found[0].UserID)
+	assert.Equal(t, Users[1], found[1].UserID)
+	assert.Equal(t, "s-2", found[1].ExternalID)
+
+	// Unlinking frees the account
+	removed, err := suite.Datastore.UnlinkAccount(Users[0], "steam")
+	require.Nil(t, err)
+	assert.True(t, removed)
+
+	removed, err = suite.Datastore.UnlinkAccount(Users[0], "steam")
+	require.Nil(t, err)
+	assert.False(t, removed)
+
+	_, err = suite.Datastore.LinkAccount(&port.LinkedAccount{UserID: Users[2], Provider: "steam", ExternalID: "s-0"})
+	require.Nil(t, err)
+
+	// Unknown users
+	_, err = suite.Datastore.LinkAccount(&port.LinkedAccount{UserID: "fee6feba-043b-4ba4-a7a4-9d6705595049", Provider: "steam", ExternalID: "s-9"})
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	_, err = suite.Datastore.GetLinkedAccounts("fee6feba-043b-4ba4-a7a4-9d6705595049")
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+}
+
+func (suite *DatastoreTestSuite) TestLeaderboard() {
+	t := suite.T()
+
+	// User 0 has a high score of 110, users 1 and 3 share the second rank
+	for _, userID := range []string{Users[1], Users[3]} {
+		_, err := suite.Datastore.SubmitScore(userID, 50, Change)
+		require.Nil(t, err)
+	}
+
+	// User 1 is hidden from user 2
+	_, err := suite.Datastore.BlockUser(Users[1], Users[2])
+	require.Nil(t, err)
+
+	size, err := suite.Datastore.GetLeaderboardSize()
+	require.Nil(t, err)
+	assert.Equal(t, 4, size)
+
+	tests := []struct {
+		Name          string
+		Read          func() ([]*port.LeaderboardEntry, common.Error)
+		ExpectedUsers []string
+		ExpectedRanks []int
+	}{
+		{
+			Name: "Top",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetLeaderboard(0, 10, "")
+			},
+			ExpectedUsers: []string{Users[0], Users[1], Users[3], Users[2]},
+			ExpectedRanks: []int{1, 2, 2, 4},
+		}, {
+			Name: "Offset",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetLeaderboard(2, 1, "")
+			},
+			ExpectedUsers: []string{Users[3]},
+			ExpectedRanks: []int{2},
+		}, {
+			Name: "AfterEnd",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetLeaderboard(4, 10, "")
+			},
+			ExpectedUsers: []string{},
+			ExpectedRanks: []int{},
+		}, {
+			Name: "Around",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetLeaderboardAround(Users[3], 1, "")
+			},
+			ExpectedUsers: []string{Users[1], Users[3], Users[2]},
+			ExpectedRanks: []int{2, 2, 4},
+		}, {
+			Name: "AroundTop",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetLeaderboardAround(Users[0], 1, "")
+			},
+			ExpectedUsers: []string{Users[0], Users[1]},
+			ExpectedRanks: []int{1, 2},
+		}, {
+			Name: "Viewer",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetLeaderboard(0, 10, Users[2])
+			},
+			ExpectedUsers: []string{Users[0], Users[3], Users[2]},
+			ExpectedRanks: []int{1, 2, 4},
+		}, {
+			Name: "AroundViewer",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetLeaderboardAround(Users[3], 1, Users[2])
+			},
+			ExpectedUsers: []string{Users[3], Users[2]},
+			ExpectedRanks: []int{2, 4},
+		}, {
+			Name: "Range",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetLeaderboardRange(port.LeaderboardKey{Score: 50}, 1, 2)
+			},
+			ExpectedUsers: []string{Users[0], Users[1], Users[3]},
+			ExpectedRanks: []int{1, 2, 2},
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			entries, err := test.Read()
+			require.Nil(t, err)
+
+			userIDs, ranks := make([]string, 0), make([]int, 0)
+			for _, entry := range entries {
+				userIDs = append(userIDs, entry.UserID)
+				ranks = append(ranks, entry.Rank)
+			}
+
+			assert.Equal(t, test.ExpectedUsers, userIDs)
+			assert.Equal(t, test.ExpectedRanks, ranks)
+		}
+
+		t.Run(test.Name, fn)
+	}
+
+	// Counting ahead of a key
+	count, err := suite.Datastore.CountLeaderboardAhead(port.LeaderboardKey{Score: 50, UserID: Users[3]})
+	require.Nil(t, err)
+	assert.Equal(t, 2, count)
+
+	count, err = suite.Datastore.CountLeaderboardAhead(port.LeaderboardKey{Score: 50})
+	require.Nil(t, err)
+	assert.Equal(t, 1, count)
+
+	// Unknown users
+	_, err = suite.Datastore.GetLeaderboardAround("fee6feba-043b-4ba4-a7a4-9d6705595049", 1, "")
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	// Users who blocked the viewer are unknown to the viewer
+	_, err = suite.Datastore.GetLeaderboardAround(Users[1], 1, Users[2])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+}
+
+func (suite *DatastoreTestSuite) TestScoreDistribution() {
+	t := suite.T()
+
+	// User 0 has a high score of 110, the others start at 0
+	for _, userID := range []string{Users[1], Users[3]} {
+		_, err := suite.Datastore.SubmitScore(userID, 50, Change)
+		require.Nil(t, err)
+	}
+
+	// Improving a high score moves the user to another bucket
+	_, err := suite.Datastore.SubmitScore(Users[3], 70, Change)
+	require.Nil(t, err)
+
+	distribution, err := suite.Datastore.GetScoreDistribution()
+	require.Nil(t, err)
+	assert.Equal(t, &port.ScoreDistribution{
+		Total: 4,
+		Buckets: []port.ScoreBucket{
+			{Min: 0, Max: 1, Count: 1},
+			{Min: 50, Max: 52, Count: 1},
+			{Min: 68, Max: 72, Count: 1},
+			{Min: 108, Max: 112, Count: 1},
+		},
+	}, distribution)
+
+	// Deleted users are no longer counted
+	require.Nil(t, suite.Datastore.DeleteUser(Users[1]))
+
+	distribution, err = suite.Datastore.GetScoreDistribution()
+	require.Nil(t, err)
+	assert.Equal(t, 3, distribution.Total)
+	assert.Equal(t, 3, len(distribution.Buckets))
+}
+
+func (suite *DatastoreTestSuite) TestPeriodLeaderboard() {
+	t := suite.T()
+
+	period := &port.LeaderboardPeriod{
+		Window: "weekly",
+		Key:    "2026-10-12",
+		Start:  time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC),
+		End:    time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+	}
+
+	// Runs before and at the end of the period are left out, users 2 and 3
+	// share the first rank
+	runs := map[string][]port.ScoreRun{
+		Users[1]: {
+			{Score: 80, SubmittedAt: period.Start.Add(-time.Second)},
+			{Score: 30, SubmittedAt: period.Start},
+		},
+		Users[2]: {
+			{Score: 60, SubmittedAt: period.Start.Add(time.Hour)},
+		},
+		Users[3]: {
+			{Score: 60, SubmittedAt: period.End.Add(-time.Second)},
+			{Score: 90, SubmittedAt: period.End},
+		},
+	}
+
+	for userID, userRuns := range runs {
+		record, err := suite.Datastore.ExportUser(userID)
+		require.Nil(t, err)
+
+		record.Runs = userRuns
+		require.Nil(t, suite.Datastore.ImportUser(record))
+	}
+
+	size, err := suite.Datastore.GetPeriodLeaderboardSize(period)
+	require.Nil(t, err)
+	assert.Equal(t, 3, size)
+
+	tests := []struct {
+		Name          string
+		Read          func() ([]*port.LeaderboardEntry, common.Error)
+		ExpectedUsers []string
+		ExpectedRanks []int
+	}{
+		{
+			Name: "Top",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetPeriodLeaderboard(period, 0, 10)
+			},
+			ExpectedUsers: []string{Users[2], Users[3], Users[1]},
+			ExpectedRanks: []int{1, 1, 3},
+		}, {
+			Name: "Offset",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetPeriodLeaderboard(period, 1, 1)
+			},
+			ExpectedUsers: []string{Users[3]},
+			ExpectedRanks: []int{1},
+		}, {
+			Name: "AfterEnd",
+			Read: func() ([]*port.LeaderboardEntry, common.Error) {
+				return suite.Datastore.GetPeriodLeaderboard(period, 3, 10)
+			},
+			ExpectedUsers: []string{},
+			ExpectedRanks: []int{},
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			entries, err := test.Read()
+			require.Nil(t, err)
+
+			userIDs, ranks := make([]string, 0), make([]int, 0)
+			for _, entry := range entries {
+				userIDs = append(userIDs, entry.UserID)
+				ranks = append(ranks, entry.Rank)
+			}
+
+			assert.Equal(t, test.ExpectedUsers, userIDs)
+			assert.Equal(t, test.ExpectedRanks, ranks)
+		}
+
+		t.Run(test.Name, fn)
+	}
+
+	// Not archived before the archive is completed
+	_, err = suite.Datastore.GetLeaderboardArchive(period)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	entries, err := suite.Datastore.GetPeriodLeaderboard(period, 0, 10)
+	require.Nil(t, err)
+
+	// Unknown users are skipped, and archiving again replaces the results
+	unknown := &port.LeaderboardEntry{
+		LeaderboardKey: port.LeaderboardKey{Score: 10, UserID: "fee6feba-043b-4ba4-a7a4-9d6705595049"},
+		Rank:           4,
+	}
+
+	require.Nil(t, suite.Datastore.ArchiveLeaderboard(period, entries[:1]))
+	require.Nil(t, suite.Datastore.ArchiveLeaderboard(period, append(entries, unknown)))
+
+	err = suite.Datastore.CompleteLeaderboardArchive(&port.LeaderboardArchive{
+		Window: period.Window,
+		Period: period.Key,
+		Total:  len(entries),
+	})
+	require.Nil(t, err)
+
+	archive, err := suite.Datastore.GetLeaderboardArchive(period)
+	require.Nil(t, err)
+	assert.Equal(t, period.Window, archive.Window)
+	assert.Equal(t, period.Key, archive.Period)
+	assert.Equal(t, 3, archive.Total)
+	assert.False(t, archive.ArchivedAt.IsZero())
+
+	archived, err := suite.Datastore.GetArchivedLeaderboard(period, 1, 10)
+	require.Nil(t, err)
+	require.Len(t, archived, 2)
+	assert.Equal(t, entries[1:], archived)
+
+	// Results are moved with the user
+	record, err := suite.Datastore.ExportUser(Users[2])
+	require.Nil(t, err)
+	assert.Equal(t, []port.LeaderboardResult{{
+		Window: period.Window,
+		Period: period.Key,
+		Score:  60,
+		Rank:   1,
+	}}, record.LeaderboardResults)
+}
+
+func (suite *DatastoreTestSuite) TestViewFriendScores() {
+	t := suite.T()
+
+	viewed := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	views := []*port.FriendScoreView{
+		{FriendID: Users[1], Score: 50, ViewedAt: viewed},
+		{FriendID: Users[2], Score: 20, ViewedAt: viewed},
+	}
+
+	previous, err := suite.Datastore.ViewFriendScores(Users[0], views)
+	require.Nil(t, err)
+	assert.Equal(t, []*port.FriendScoreView{}, previous)
+
+	// Viewing again returns the previous scores, and drops friends no longer viewed
+	later := []*port.FriendScoreView{
+		{FriendID: Users[2], Score: 70, ViewedAt: viewed.Add(time.Hour)},
+	}
+
+	previous, err = suite.Datastore.ViewFriendScores(Users[0], later)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(previous))
+	for i, view := range previous {
+		assert.Equal(t, views[i].FriendID, view.FriendID)
+		assert.Equal(t, views[i].Score, view.Score)
+		assert.True(t, views[i].ViewedAt.Equal(view.ViewedAt))
+	}
+
+	// Views are moved with the user
+	record, err := suite.Datastore.ExportUser(Users[0])
+	require.Nil(t, err)
+	require.Equal(t, 1, len(record.FriendScoreViews))
+	assert.Equal(t, Users[2], record.FriendScoreViews[0].FriendID)
+	assert.Equal(t, 70, record.FriendScoreViews[0].Score)
+
+	// Unknown users
+	_, err = suite.Datastore.ViewFriendScores("fee6feba-043b-4ba4-a7a4-9d6705595049", views)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
 }
 
 func (suite *DatastoreTestSuite) TestDeleteUser() {
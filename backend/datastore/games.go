@@ -0,0 +1,52 @@
+package datastore
+
+import (
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// setGameStatus derives the status of a game from its participants, so it
+// can't disagree with them when results are submitted concurrently
+func setGameStatus(game *port.Game) {
+	game.Status = port.GameFinished
+	for _, p := range game.Participants {
+		if p.Status != port.GameFinished {
+			game.Status = port.GamePlaying
+			return
+		}
+	}
+}
+
+// copyGame returns a deep copy of a game
+func copyGame(game *port.Game) *port.Game {
+	c := &port.Game{
+		GameID:       game.GameID,
+		Status:       game.Status,
+		Participants: make([]*port.Participant, 0, len(game.Participants)),
+	}
+
+	for _, p := range game.Participants {
+		participant := *p
+		c.Participants = append(c.Participants, &participant)
+	}
+
+	if game.State != nil {
+		c.State = game.State.Copy()
+	}
+
+	return c
+}
+
+// personalGame returns the personal game of a user, see port.Game. The user
+// plays it for as long as the user exists.
+func personalGame(userID string, state *port.GameState) *port.Game {
+	return &port.Game{
+		GameID: userID,
+		Status: port.GamePlaying,
+		Participants: []*port.Participant{{
+			UserID: userID,
+			Status: port.GamePlaying,
+			Score:  state.Score(),
+		}},
+		State: state,
+	}
+}
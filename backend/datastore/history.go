@@ -0,0 +1,46 @@
+package datastore
+
+import (
+	"time"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// HistoryRetention limits the game state revisions kept for each user. The
+// current revision is always kept, and zero values are unlimited.
+type HistoryRetention struct {
+	// MaxRevisions is the number of revisions kept
+	MaxRevisions int
+
+	// MaxAge is how long revisions are kept
+	MaxAge time.Duration
+}
+
+// DefaultHistoryRetention is the retention of the simulator
+var DefaultHistoryRetention = HistoryRetention{
+	MaxRevisions: 100,
+	MaxAge:       90 * 24 * time.Hour,
+}
+
+// retain returns the revisions to keep of the given, ordered oldest first
+func (r HistoryRetention) retain(revisions []port.StateRevision, now time.Time) []port.StateRevision {
+	start := 0
+	if r.MaxRevisions > 0 && len(revisions) > r.MaxRevisions {
+		start = len(revisions) - r.MaxRevisions
+	}
+
+	if r.MaxAge > 0 {
+		for start < len(revisions)-1 && now.Sub(revisions[start].CreatedAt) > r.MaxAge {
+			start++
+		}
+	}
+
+	return revisions[start:]
+}
+
+// copyRevision returns a deep copy of a revision
+func copyRevision(revision *port.StateRevision) *port.StateRevision {
+	c := *revision
+	c.State = *revision.State.Copy()
+	return &c
+}
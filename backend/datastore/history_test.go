@@ -0,0 +1,60 @@
+package datastore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func TestHistoryRetention(t *testing.T) {
+	now := time.Now()
+	revisions := []port.StateRevision{
+		{Revision: 1, CreatedAt: now.Add(-3 * time.Hour)},
+		{Revision: 2, CreatedAt: now.Add(-2 * time.Hour)},
+		{Revision: 3, CreatedAt: now.Add(-1 * time.Hour)},
+	}
+
+	tests := []struct {
+		Name              string
+		Retention         HistoryRetention
+		ExpectedRevisions []int
+	}{
+		{
+			Name:              "Unlimited",
+			Retention:         HistoryRetention{},
+			ExpectedRevisions: []int{1, 2, 3},
+		}, {
+			Name:              "MaxRevisions",
+			Retention:         HistoryRetention{MaxRevisions: 2},
+			ExpectedRevisions: []int{2, 3},
+		}, {
+			Name:              "MaxAge",
+			Retention:         HistoryRetention{MaxAge: 150 * time.Minute},
+			ExpectedRevisions: []int{2, 3},
+		}, {
+			Name:              "Both",
+			Retention:         HistoryRetention{MaxRevisions: 2, MaxAge: 90 * time.Minute},
+			ExpectedRevisions: []int{3},
+		}, {
+			Name:              "KeepsCurrent",
+			Retention:         HistoryRetention{MaxAge: time.Minute},
+			ExpectedRevisions: []int{3},
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			kept := make([]int, 0)
+			for _, revision := range test.Retention.retain(revisions, now) {
+				kept = append(kept, revision.Revision)
+			}
+
+			assert.Equal(t, test.ExpectedRevisions, kept)
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
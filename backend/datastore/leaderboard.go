@@ -0,0 +1,92 @@
+package datastore
+
+import (
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// leaderboardKeyLess compares the keys of two entries in leaderboard order,
+// descending score then UserID
+func leaderboardKeyLess(a, b port.LeaderboardKey) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+
+	return a.UserID < b.UserID
+}
+
+// rankEntries sets the ranks of consecutive leaderboard entries, given the
+// number of entries before the first, and the number of those with a
+// higher score
+func rankEntries(entries []*port.LeaderboardEntry, position, higher int) {
+	for i, entry := range entries {
+		switch {
+		case i == 0:
+			entry.Rank = higher + 1
+		case entry.Score == entries[i-1].Score:
+			entry.Rank = entries[i-1].Rank
+		default:
+			entry.Rank = position + i + 1
+		}
+	}
+}
+
+// pageKeys returns the keys within the offset and limit
+func pageKeys(keys []port.LeaderboardKey, offset, limit int) []port.LeaderboardKey {
+	if offset >= len(keys) {
+		return []port.LeaderboardKey{}
+	}
+
+	keys = keys[offset:]
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	return keys
+}
+
+// scoreIndex keeps the high score of every user in leaderboard order, and
+// counts the scores in the score sketch
+type scoreIndex struct {
+	list   *skipList
+	sketch *scoreSketch
+	scores map[string]int
+}
+
+func newScoreIndex() *scoreIndex {
+	return &scoreIndex{
+		list:   newSkipList(),
+		sketch: newScoreSketch(),
+		scores: make(map[string]int),
+	}
+}
+
+// set indexes the high score of a user, replacing any previous score
+func (idx *scoreIndex) set(userID string, score int) {
+	if previous, ok := idx.scores[userID]; ok {
+		if previous == score {
+			return
+		}
+
+		idx.list.remove(port.LeaderboardKey{Score: previous, UserID: userID})
+		idx.sketch.add(previous, -1)
+	}
+
+	idx.scores[userID] = score
+	idx.list.insert(port.LeaderboardKey{Score: score, UserID: userID})
+	idx.sketch.add(score, 1)
+}
+
+// remove drops a user from the index
+func (idx *scoreIndex) remove(userID string) {
+	if score, ok := idx.scores[userID]; ok {
+		idx.list.remove(port.LeaderboardKey{Score: score, UserID: userID})
+		idx.sketch.add(score, -1)
+		delete(idx.scores, userID)
+	}
+}
+
+// key returns the leaderboard key of a user, false if not indexed
+func (idx *scoreIndex) key(userID string) (port.LeaderboardKey, bool) {
+	score, ok := idx.scores[userID]
+	return port.LeaderboardKey{Score: score, UserID: userID}, ok
+}
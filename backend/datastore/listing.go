@@ -0,0 +1,75 @@
+package datastore
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// listKeyLess compares the keys of two entries in ascending sort order
+func listKeyLess(field string, a, b port.ListKey) bool {
+	switch field {
+	case port.SortByName:
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+
+	case port.SortByScore:
+		if a.Score != b.Score {
+			return a.Score < b.Score
+		}
+	}
+
+	return a.UserID < b.UserID
+}
+
+// listPage filters, sorts and pages a list in memory, the same way the
+// keyset queries do in SQL
+func listPage(keys []port.ListKey, query *port.ListQuery) []port.ListKey {
+	prefix := strings.ToLower(query.NamePrefix)
+
+	less := func(a, b port.ListKey) bool {
+		if query.Descending {
+			return listKeyLess(query.Sort, b, a)
+		}
+
+		return listKeyLess(query.Sort, a, b)
+	}
+
+	page := make([]port.ListKey, 0, len(keys))
+	for _, key := range keys {
+		if prefix != "" && !strings.HasPrefix(strings.ToLower(key.Name), prefix) {
+			continue
+		}
+
+		if query.MinScore != nil && key.Score < *query.MinScore {
+			continue
+		}
+
+		if query.After != nil && !less(*query.After, key) {
+			continue
+		}
+
+		if query.Before != nil && !less(key, *query.Before) {
+			continue
+		}
+
+		page = append(page, key)
+	}
+
+	sort.Slice(page, func(i, j int) bool {
+		return less(page[i], page[j])
+	})
+
+	if len(page) <= query.Limit {
+		return page
+	}
+
+	// Pages before an entry are the entries closest to it
+	if query.Before != nil {
+		return page[len(page)-query.Limit:]
+	}
+
+	return page[:query.Limit]
+}
@@ -0,0 +1,284 @@
+package datastore
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Minimum similarity of a fuzzy match, same as the pg_trgm default
+const fuzzyThreshold = 0.3
+
+// Rank of prefix matches, matching the start of the name ranks highest
+const (
+	rankNamePrefix = 1.0
+	rankWordPrefix = 0.5
+)
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Name index                                                          **
+**   In-memory equivalent of the trigram and full text indexes           **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+type indexEntry struct {
+	key    string
+	userID string
+}
+
+type nameIndex struct {
+	names    map[string]string
+	prefixes []indexEntry // Full names and words, sorted
+	trigrams map[string]map[string]bool
+}
+
+func newNameIndex() *nameIndex {
+	return &nameIndex{
+		names:    make(map[string]string),
+		prefixes: make([]indexEntry, 0),
+		trigrams: make(map[string]map[string]bool),
+	}
+}
+
+// add indexes the name of a user, replacing any previous name
+func (idx *nameIndex) add(userID, name string) {
+	idx.remove(userID)
+	idx.names[userID] = name
+
+	keys := append([]string{strings.ToLower(name)}, nameWords(name)...)
+	for _, key := range keys {
+		entry := indexEntry{key: key, userID: userID}
+		i := sort.Search(len(idx.prefixes), func(i int) bool {
+			return !entryLess(idx.prefixes[i], entry)
+		})
+
+		idx.prefixes = append(idx.prefixes, indexEntry{})
+		copy(idx.prefixes[i+1:], idx.prefixes[i:])
+		idx.prefixes[i] = entry
+	}
+
+	for trigram := range trigrams(name) {
+		if idx.trigrams[trigram] == nil {
+			idx.trigrams[trigram] = make(map[string]bool)
+		}
+
+		idx.trigrams[trigram][userID] = true
+	}
+}
+
+// remove drops a user from the index
+func (idx *nameIndex) remove(userID string) {
+	name, ok := idx.names[userID]
+	if !ok {
+		return
+	}
+
+	delete(idx.names, userID)
+
+	prefixes := idx.prefixes[:0]
+	for _, entry := range idx.prefixes {
+		if entry.userID != userID {
+			prefixes = append(prefixes, entry)
+		}
+	}
+	idx.prefixes = prefixes
+
+	for trigram := range trigrams(name) {
+		delete(idx.trigrams[trigram], userID)
+		if len(idx.trigrams[trigram]) == 0 {
+			delete(idx.trigrams, trigram)
+		}
+	}
+}
+
+// withPrefix returns the users with a full name or word starting with the prefix
+func (idx *nameIndex) withPrefix(prefix string) map[string]bool {
+	i := sort.Search(len(idx.prefixes), func(i int) bool {
+		return idx.prefixes[i].key >= prefix
+	})
+
+	found := make(map[string]bool)
+	for ; i < len(idx.prefixes) && strings.HasPrefix(idx.prefixes[i].key, prefix); i++ {
+		found[idx.prefixes[i].userID] = true
+	}
+
+	return found
+}
+
+// prefix matches names starting with the query, or where every word of the
+// query is the start of a word in the name
+func (idx *nameIndex) prefix(query string) []*port.UserMatch {
+	lower := strings.ToLower(query)
+	words := nameWords(query)
+
+	candidates := idx.withPrefix(lower)
+	for _, word := range words {
+		if word == lower {
+			continue
+		}
+
+		for userID := range idx.withPrefix(word) {
+			candidates[userID] = true
+		}
+	}
+
+	matches := make([]*port.UserMatch, 0)
+	for userID := range candidates {
+		name := idx.names[userID]
+		if rank := prefixRank(lower, words, name); rank > 0 {
+			matches = append(matches, &port.UserMatch{
+				User: port.User{UserID: userID, Name: name},
+				Rank: rank,
+			})
+		}
+	}
+
+	sortMatches(matches)
+	return matches
+}
+
+// fuzzy matches names by trigram similarity
+func (idx *nameIndex) fuzzy(query string) []*port.UserMatch {
+	queryTrigrams := trigrams(query)
+
+	candidates := make(map[string]bool)
+	for trigram := range queryTrigrams {
+		for userID := range idx.trigrams[trigram] {
+			candidates[userID] = true
+		}
+	}
+
+	matches := make([]*port.UserMatch, 0)
+	for userID := range candidates {
+		name := idx.names[userID]
+		if similarity := trigramSimilarity(queryTrigrams, trigrams(name)); similarity >= fuzzyThreshold {
+			matches = append(matches, &port.UserMatch{
+				User: port.User{UserID: userID, Name: name},
+				Rank: similarity,
+			})
+		}
+	}
+
+	sortMatches(matches)
+	return matches
+}
+
+func entryLess(a, b indexEntry) bool {
+	if a.key == b.key {
+		return a.userID < b.userID
+	}
+
+	return a.key < b.key
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Matching                                                            **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// prefixRank ranks a name against a lower cased query and its words,
+// zero if it doesn't match
+func prefixRank(lower string, words []string, name string) float64 {
+	if strings.HasPrefix(strings.ToLower(name), lower) {
+		return rankNamePrefix
+	}
+
+	if len(words) == 0 {
+		return 0
+	}
+
+	nameWords := nameWords(name)
+	for _, word := range words {
+		found := false
+		for _, nameWord := range nameWords {
+			if strings.HasPrefix(nameWord, word) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return 0
+		}
+	}
+
+	return rankWordPrefix
+}
+
+// nameWords splits a name into lower cased words of letters and digits
+func nameWords(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// trigrams extracts the trigrams of a string, the same way as pg_trgm
+func trigrams(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range nameWords(s) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+
+	return set
+}
+
+// trigramSimilarity is the number of shared trigrams over the total
+func trigramSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range a {
+		if b[trigram] {
+			shared++
+		}
+	}
+
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// sortMatches orders matches by rank, then name and user ID
+func sortMatches(matches []*port.UserMatch) {
+	sort.Slice(matches, func(i, j int) bool {
+		return matchLess(matches[i], matches[j])
+	})
+}
+
+func matchLess(a, b *port.UserMatch) bool {
+	if a.Rank != b.Rank {
+		return a.Rank > b.Rank
+	}
+
+	aName, bName := strings.ToLower(a.Name), strings.ToLower(b.Name)
+	if aName != bName {
+		return aName < bName
+	}
+
+	return a.UserID < b.UserID
+}
+
+// pageMatches returns the matches within the offset and limit
+func pageMatches(matches []*port.UserMatch, offset, limit int) []*port.UserMatch {
+	if offset >= len(matches) {
+		return []*port.UserMatch{}
+	}
+
+	end := offset + limit
+	if end > len(matches) {
+		end = len(matches)
+	}
+
+	return matches[offset:end]
+}
@@ -0,0 +1,1959 @@
+package datastore
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Number of points each shard has on the hash ring, more points gives a
+// more even distribution of users
+const shardVirtualNodes = 64
+
+// Number of locks users are spread across, while being moved
+const shardUserLocks = 64
+
+// ShardedDatastore spreads users across a set of datastores, using
+// consistent hashing of the user IDs
+type ShardedDatastore struct {
+	*shardRouter
+
+	log *logrus.Entry
+}
+
+var _ port.Datastore = &ShardedDatastore{}
+var _ port.Traceable = &ShardedDatastore{}
+var _ port.Reshardable = &ShardedDatastore{}
+
+// shardRouter is the state shared by all copies of the adapter
+type shardRouter struct {
+	sync.RWMutex
+
+	shards map[string]port.Datastore
+	ring   *hashRing
+
+	// Set while resharding, users are routed to the target once moved
+	target       *hashRing
+	targetShards map[string]port.Datastore
+	moved        map[string]bool
+
+	// Only a single resharding at a time
+	resharding sync.Mutex
+	running    bool
+
+	// Users are locked for writing while being moved
+	userLocks [shardUserLocks]sync.RWMutex
+
+	// Friend requests of a user are counted and sent holding its sender lock
+	senderLocks [shardUserLocks]sync.Mutex
+}
+
+// NewShardedDatastore creates a datastore spreading users across the given
+// named shards. The names decide the placement of users, and must be kept
+// the same for the same shards.
+func NewShardedDatastore(shards map[string]port.Datastore, log *logrus.Entry) *ShardedDatastore {
+	return &ShardedDatastore{
+		shardRouter: &shardRouter{
+			shards: shards,
+			ring:   newHashRing(shards),
+		},
+		log: log,
+	}
+}
+
+// ParseShardURIs parses named shards as "name=uri,...". The URIs may contain
+// anything but commas.
+func ParseShardURIs(s string) (map[string]string, common.Error) {
+	uris := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, common.NewError(port.ErrInvalidDocument, "Invalid shard, expected name=uri")
+		}
+
+		if _, ok := uris[parts[0]]; ok {
+			return nil, common.NewError(port.ErrInvalidDocument, "Duplicate shard "+parts[0])
+		}
+
+		uris[parts[0]] = parts[1]
+	}
+
+	return uris, nil
+}
+
+// WithLog returns a copy of the adapter, which logs to the given log
+func (db *ShardedDatastore) WithLog(log *logrus.Entry) port.Datastore {
+	scoped := *db
+	scoped.log = log
+	return &scoped
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Hash ring                                                           **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+type hashRingPoint struct {
+	hash  uint64
+	shard string
+}
+
+type hashRing struct {
+	points []hashRingPoint
+}
+
+func newHashRing(shards map[string]port.Datastore) *hashRing {
+	ring := &hashRing{
+		points: make([]hashRingPoint, 0, len(shards)*shardVirtualNodes),
+	}
+
+	for name := range shards {
+		for i := 0; i < shardVirtualNodes; i++ {
+			ring.points = append(ring.points, hashRingPoint{
+				hash:  hashKey(fmt.Sprintf("%s#%d", name, i)),
+				shard: name,
+			})
+		}
+	}
+
+	sort.Slice(ring.points, func(i, j int) bool {
+		if ring.points[i].hash == ring.points[j].hash {
+			return ring.points[i].shard < ring.points[j].shard
+		}
+
+		return ring.points[i].hash < ring.points[j].hash
+	})
+
+	return ring
+}
+
+// owner returns the name of the shard owning the given key
+func (r *hashRing) owner(key string) string {
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool {
+		return r.points[i].hash >= h
+	})
+
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.points[i].shard
+}
+
+// hashKey uses MD5 as in ketama, as it spreads short similar keys evenly
+func hashKey(key string) uint64 {
+	sum := md5.Sum([]byte(key))
+	return binary.BigEndian.Uint64(sum[:8])
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Routing                                                             **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// scoped passes the request scoped log on to the shard
+func (db *ShardedDatastore) scoped(shard port.Datastore) port.Datastore {
+	if t, ok := shard.(port.Traceable); ok && db.log != nil {
+		return t.WithLog(db.log)
+	}
+
+	return shard
+}
+
+// shardOf returns the shard currently holding the given user
+func (db *ShardedDatastore) shardOf(userID string) port.Datastore {
+	return db.scoped(db.locate(userID))
+}
+
+// locate returns the unscoped shard currently holding the given user
+func (db *ShardedDatastore) locate(userID string) port.Datastore {
+	db.RLock()
+	defer db.RUnlock()
+
+	if db.target != nil && db.moved[userID] {
+		return db.targetShards[db.target.owner(userID)]
+	}
+
+	return db.shards[db.ring.owner(userID)]
+}
+
+// userLock returns the lock guarding the given user while being moved
+func (db *ShardedDatastore) userLock(userID string) *sync.RWMutex {
+	return &db.userLocks[hashKey(userID)%shardUserLocks]
+}
+
+// senderLock returns the lock guarding the friend requests sent by the user
+func (db *ShardedDatastore) senderLock(userID string) *sync.Mutex {
+	return &db.senderLocks[hashKey(userID)%shardUserLocks]
+}
+
+// allShards returns every shard which may hold users
+func (db *ShardedDatastore) allShards() []port.Datastore {
+	db.RLock()
+	defer db.RUnlock()
+
+	seen := make(map[port.Datastore]bool)
+	shards := make([]port.Datastore, 0, len(db.shards)+len(db.targetShards))
+	for _, set := range []map[string]port.Datastore{db.shards, db.targetShards} {
+		for _, shard := range set {
+			if seen[shard] {
+				continue
+			}
+
+			seen[shard] = true
+			shards = append(shards, db.scoped(shard))
+		}
+	}
+
+	return shards
+}
+
+// fanOut calls fn on each of the shards in parallel, returning the first error
+func fanOut(shards []port.Datastore, fn func(i int, shard port.Datastore) common.Error) common.Error {
+	errs := make([]common.Error, len(shards))
+
+	var wg sync.WaitGroup
+	for i, shard := range shards {
+		wg.Add(1)
+		go func(i int, shard port.Datastore) {
+			defer wg.Done()
+			errs[i] = fn(i, shard)
+		}(i, shard)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Resharding                                                          **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// Shards returns the current shards by name
+func (db *ShardedDatastore) Shards() map[string]port.Datastore {
+	db.RLock()
+	defer db.RUnlock()
+
+	shards := make(map[string]port.Datastore, len(db.shards))
+	for name, shard := range db.shards {
+		shards[name] = shard
+	}
+
+	return shards
+}
+
+// ReshardStatus returns the shards being resharded onto, nil when idle, and
+// whether the resharding is running. A failed resharding keeps its target
+// until resumed.
+func (db *ShardedDatastore) ReshardStatus() (map[string]port.Datastore, bool) {
+	db.RLock()
+	defer db.RUnlock()
+
+	if db.targetShards == nil {
+		return nil, db.running
+	}
+
+	shards := make(map[string]port.Datastore, len(db.targetShards))
+	for name, shard := range db.targetShards {
+		shards[name] = shard
+	}
+
+	return shards, db.running
+}
+
+// Reshard moves users and games onto a new set of named shards, while the
+// datastore keeps serving requests. Shards keeping their name are expected
+// to be the same datastore, and only entries changing shard are moved. If
+// resharding fails it can be resumed by calling it again with the same
+// shards, a different set of shards is refused until then. Returns the
+// number of users and games moved.
+func (db *ShardedDatastore) Reshard(shards map[string]port.Datastore) (int, common.Error) {
+	if !db.resharding.TryLock() {
+		return 0, common.NewError(port.ErrConflict, "Resharding is already running")
+	}
+	defer db.resharding.Unlock()
+
+	db.Lock()
+	if db.target != nil && !sameShardNames(db.targetShards, shards) {
+		db.Unlock()
+		return 0, common.NewError(port.ErrConflict, "An unfinished resharding onto other shards must be resumed first")
+	}
+
+	if db.target == nil {
+		db.moved = make(map[string]bool)
+	}
+	db.running = true
+	db.target = newHashRing(shards)
+	db.targetShards = shards
+	current := db.shards
+	db.Unlock()
+
+	defer func() {
+		db.Lock()
+		db.running = false
+		db.Unlock()
+	}()
+
+	moved := 0
+	for name, shard := range current {
+		entries, err := db.reshardEntries(db.scoped(shard))
+		if err != nil {
+			return moved, err
+		}
+
+		for _, entry := range entries {
+			to := db.target.owner(entry.id)
+			if to == name {
+				continue
+			}
+
+			// A stale copy left behind by an earlier failed resharding
+			db.RLock()
+			stale := db.moved[entry.id]
+			db.RUnlock()
+
+			if stale {
+				if err := entry.remove(entry.id); err != nil {
+					return moved, err
+				}
+
+				continue
+			}
+
+			if err := entry.move(entry.id, shard, shards[to]); err != nil {
+				return moved, err.WithField("shard", to)
+			}
+
+			moved++
+		}
+
+		db.log.WithFields(logrus.Fields{
+			"shard": name,
+			"moved": moved,
+		}).Info("Resharded shard")
+	}
+
+	db.Lock()
+	db.shards = shards
+	db.ring = db.target
+	db.target = nil
+	db.targetShards = nil
+	db.moved = nil
+	db.Unlock()
+
+	return moved, nil
+}
+
+// sameShardNames tells whether both sets have the same shard names
+func sameShardNames(a, b map[string]port.Datastore) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// reshardEntry is a user or game stored on a shard, both placed on the ring
+// by their ID
+type reshardEntry struct {
+	id     string
+	move   func(id string, from, to port.Datastore) common.Error
+	remove func(id string) common.Error
+}
+
+// reshardEntries lists the users and games stored on a shard
+func (db *ShardedDatastore) reshardEntries(shard port.Datastore) ([]reshardEntry, common.Error) {
+	users, err := shard.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	gameIDs, err := shard.GetGameIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]reshardEntry, 0, len(users)+len(gameIDs))
+	for _, user := range users {
+		entries = append(entries, reshardEntry{id: user.UserID, move: db.move, remove: shard.DeleteUser})
+	}
+
+	for _, gameID := range gameIDs {
+		entries = append(entries, reshardEntry{id: gameID, move: db.moveGame, remove: shard.DeleteGame})
+	}
+
+	return entries, nil
+}
+
+// move copies a user to its new shard, routes it there and removes it
+// from the old one
+func (db *ShardedDatastore) move(userID string, from, to port.Datastore) common.Error {
+	lock := db.userLock(userID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	record, err := db.scoped(from).ExportUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.scoped(to).ImportUser(record); err != nil {
+		return err
+	}
+
+	db.Lock()
+	db.moved[userID] = true
+	db.Unlock()
+
+	return db.scoped(from).DeleteUser(userID)
+}
+
+// moveGame copies a game to its new shard, routes it there and removes it
+// from the old one
+func (db *ShardedDatastore) moveGame(gameID string, from, to port.Datastore) common.Error {
+	lock := db.userLock(gameID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	game, err := db.scoped(from).ExportGame(gameID)
+	if err != nil {
+		return err
+	}
+
+	if err := db.scoped(to).ImportGame(game); err != nil {
+		return err
+	}
+
+	db.Lock()
+	db.moved[gameID] = true
+	db.Unlock()
+
+	return db.scoped(from).DeleteGame(gameID)
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Users                                                               **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// NewUser ...
+func (db *ShardedDatastore) NewUser(id, name string) (*port.User, common.Error) {
+	lock := db.userLock(id)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	// New users go straight to their target while resharding
+	db.Lock()
+	if db.target != nil {
+		db.moved[id] = true
+	}
+	db.Unlock()
+
+	return db.shardOf(id).NewUser(id, name)
+}
+
+// GetUsers ...
+func (db *ShardedDatastore) GetUsers() ([]*port.User, common.Error) {
+	shards := db.allShards()
+	results := make([][]*port.User, len(shards))
+
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		users, err := shard.GetUsers()
+		results[i] = users
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Users being moved may briefly exist on two shards
+	seen := make(map[string]bool)
+	users := make([]*port.User, 0)
+	for _, result := range results {
+		for _, user := range result {
+			if seen[user.UserID] {
+				continue
+			}
+
+			seen[user.UserID] = true
+			users = append(users, user)
+		}
+	}
+
+	sort.Sort(userByUserID(users))
+
+	return users, nil
+}
+
+// ListUsers merges the pages of every shard
+func (db *ShardedDatastore) ListUsers(query *port.ListQuery) ([]*port.User, common.Error) {
+	shards := db.allShards()
+	results := make([][]*port.User, len(shards))
+
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		users, err := shard.ListUsers(query)
+		results[i] = users
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]port.ListKey, 0)
+	seen := make(map[string]bool)
+	for _, result := range results {
+		for _, user := range result {
+			if seen[user.UserID] {
+				continue
+			}
+
+			seen[user.UserID] = true
+			keys = append(keys, port.ListKey{UserID: user.UserID, Name: user.Name})
+		}
+	}
+
+	users := make([]*port.User, 0, query.Limit)
+	for _, key := range listPage(keys, query) {
+		users = append(users, &port.User{UserID: key.UserID, Name: key.Name})
+	}
+
+	return users, nil
+}
+
+// UserExists ...
+func (db *ShardedDatastore) UserExists(id string) (bool, common.Error) {
+	lock := db.userLock(id)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(id).UserExists(id)
+}
+
+// SearchUsersByPrefix ...
+func (db *ShardedDatastore) SearchUsersByPrefix(prefix, viewerID string, offset, limit int) ([]*port.UserMatch, common.Error) {
+	return db.searchUsers(offset, limit, func(shard port.Datastore) ([]*port.UserMatch, common.Error) {
+		return shard.SearchUsersByPrefix(prefix, viewerID, 0, offset+limit)
+	})
+}
+
+// SearchUsersFuzzy ...
+func (db *ShardedDatastore) SearchUsersFuzzy(query, viewerID string, offset, limit int) ([]*port.UserMatch, common.Error) {
+	return db.searchUsers(offset, limit, func(shard port.Datastore) ([]*port.UserMatch, common.Error) {
+		return shard.SearchUsersFuzzy(query, viewerID, 0, offset+limit)
+	})
+}
+
+// BlockUser blocks on the shard of the user, then removes the friendship
+// and friend request stored on the shard of the blocked user
+func (db *ShardedDatastore) BlockUser(userID, blockedID string) (bool, common.Error) {
+	blocked, err := db.blockUser(userID, blockedID)
+	if err != nil {
+		return false, err
+	}
+
+	lock := db.userLock(blockedID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	// Both are no-ops if the shard already did it
+	shard := db.shardOf(blockedID)
+	if _, err := shard.RemoveFriend(blockedID, userID); err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return false, err
+	}
+
+	if err := shard.DeleteFriendRequest(userID, blockedID); err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+// blockUser blocks on the shard of the user, holding only its lock
+func (db *ShardedDatastore) blockUser(userID, blockedID string) (bool, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).BlockUser(userID, blockedID)
+}
+
+// UnblockUser ...
+func (db *ShardedDatastore) UnblockUser(userID, blockedID string) (bool, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).UnblockUser(userID, blockedID)
+}
+
+// GetBlockedUsers ...
+func (db *ShardedDatastore) GetBlockedUsers(userID string) ([]*port.UserBlock, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetBlockedUsers(userID)
+}
+
+// IsBlocked checks the blocks of each user on its own shard
+func (db *ShardedDatastore) IsBlocked(userID, otherID string) (bool, common.Error) {
+	for _, id := range []string{userID, otherID} {
+		blocked, err := db.isBlocked(id, userID, otherID)
+		if err != nil || blocked {
+			return blocked, err
+		}
+	}
+
+	return false, nil
+}
+
+// isBlocked checks the blocks stored on the shard of the given user
+func (db *ShardedDatastore) isBlocked(id, userID, otherID string) (bool, common.Error) {
+	lock := db.userLock(id)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(id).IsBlocked(userID, otherID)
+}
+
+// FlagUser ...
+func (db *ShardedDatastore) FlagUser(userID, reason string) common.Error {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).FlagUser(userID, reason)
+}
+
+// GetFlaggedUsers merges the flagged users of every shard
+func (db *ShardedDatastore) GetFlaggedUsers() ([]*port.UserFlag, common.Error) {
+	shards := db.allShards()
+	results := make([][]*port.UserFlag, len(shards))
+
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		flags, err := shard.GetFlaggedUsers()
+		results[i] = flags
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Users being moved may briefly exist on two shards
+	seen := make(map[string]bool)
+	flags := make([]*port.UserFlag, 0)
+	for _, result := range results {
+		for _, flag := range result {
+			if seen[flag.UserID] {
+				continue
+			}
+
+			seen[flag.UserID] = true
+			flags = append(flags, flag)
+		}
+	}
+
+	sort.Sort(flagByLastFlagged(flags))
+
+	return flags, nil
+}
+
+// ClearUserFlag ...
+func (db *ShardedDatastore) ClearUserFlag(userID string) common.Error {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).ClearUserFlag(userID)
+}
+
+// searchUsers merges the first offset+limit matches of every shard
+func (db *ShardedDatastore) searchUsers(offset, limit int, search func(shard port.Datastore) ([]*port.UserMatch, common.Error)) ([]*port.UserMatch, common.Error) {
+	shards := db.allShards()
+	results := make([][]*port.UserMatch, len(shards))
+
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		matches, err := search(shard)
+		results[i] = matches
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	matches := make([]*port.UserMatch, 0)
+	for _, result := range results {
+		for _, match := range result {
+			if seen[match.UserID] {
+				continue
+			}
+
+			seen[match.UserID] = true
+			matches = append(matches, match)
+		}
+	}
+
+	sortMatches(matches)
+
+	return pageMatches(matches, offset, limit), nil
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Game State                                                          **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// UpdateGameState ...
+func (db *ShardedDatastore) UpdateGameState(userID string, state *port.GameState, change port.StateChange) common.Error {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).UpdateGameState(userID, state, change)
+}
+
+// GetGameState ...
+func (db *ShardedDatastore) GetGameState(userID string) (*port.GameState, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetGameState(userID)
+}
+
+// SubmitScore ...
+func (db *ShardedDatastore) SubmitScore(userID string, score int, change port.StateChange) (*port.ScoreResult, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).SubmitScore(userID, score, change)
+}
+
+// ModifyGameState ...
+func (db *ShardedDatastore) ModifyGameState(userID string, change port.StateChange, modify port.GameStateModifier) (*port.GameState, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).ModifyGameState(userID, change, modify)
+}
+
+// CompareAndSwapGameState ...
+func (db *ShardedDatastore) CompareAndSwapGameState(userID string, revision int, state *port.GameState, change port.StateChange) (int, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).CompareAndSwapGameState(userID, revision, state, change)
+}
+
+// GetGameStateRevisions ...
+func (db *ShardedDatastore) GetGameStateRevisions(userID string) ([]*port.StateRevision, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetGameStateRevisions(userID)
+}
+
+// GetGameStateRevision ...
+func (db *ShardedDatastore) GetGameStateRevision(userID string, revision int) (*port.StateRevision, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetGameStateRevision(userID, revision)
+}
+
+// NewSaveSlot ...
+func (db *ShardedDatastore) NewSaveSlot(userID string, slot *port.SaveSlot, maxSlots int) (*port.SaveSlot, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).NewSaveSlot(userID, slot, maxSlots)
+}
+
+// GetSaveSlots ...
+func (db *ShardedDatastore) GetSaveSlots(userID string) ([]*port.SaveSlot, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetSaveSlots(userID)
+}
+
+// GetSaveSlot ...
+func (db *ShardedDatastore) GetSaveSlot(userID, slotID string) (*port.SaveSlot, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetSaveSlot(userID, slotID)
+}
+
+// UpdateSaveSlot ...
+func (db *ShardedDatastore) UpdateSaveSlot(userID, slotID string, update *port.SaveSlotUpdate) (*port.SaveSlot, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).UpdateSaveSlot(userID, slotID, update)
+}
+
+// DeleteSaveSlot ...
+func (db *ShardedDatastore) DeleteSaveSlot(userID, slotID string) common.Error {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).DeleteSaveSlot(userID, slotID)
+}
+
+// ActivateSaveSlot ...
+func (db *ShardedDatastore) ActivateSaveSlot(userID, slotID string, change port.StateChange) (*port.GameState, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).ActivateSaveSlot(userID, slotID, change)
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Games                                                               **
+**   Games are placed on the ring by their ID, the same way as users     **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// NewGame ...
+func (db *ShardedDatastore) NewGame(gameID string, userIDs []string) (*port.Game, common.Error) {
+	lock := db.userLock(gameID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(gameID).NewGame(gameID, userIDs)
+}
+
+// GetGame ...
+func (db *ShardedDatastore) GetGame(gameID string) (*port.Game, common.Error) {
+	lock := db.userLock(gameID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(gameID).GetGame(gameID)
+}
+
+// SetGameResult ...
+func (db *ShardedDatastore) SetGameResult(gameID, userID string, score int) (*port.Game, common.Error) {
+	lock := db.userLock(gameID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(gameID).SetGameResult(gameID, userID, score)
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Friends                                                             **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// UpdateFriends checks the blocks of the friends on their own shards, before
+// replacing the friend list on the shard of the user, then adds and removes
+// the user on the shards of the added and removed friends
+func (db *ShardedDatastore) UpdateFriends(userID string, friends []string) common.Error {
+	for _, friendID := range friends {
+		blocked, err := db.IsBlocked(userID, friendID)
+		if err != nil {
+			return err
+		}
+
+		if blocked {
+			return common.NewError(port.ErrBlocked, "User is blocked")
+		}
+	}
+
+	old, err := db.updateFriends(userID, friends)
+	if err != nil {
+		return err
+	}
+
+	// Each is a no-op if the shard already did it, or the friend is deleted
+	kept := make(map[string]bool, len(friends))
+	for _, friendID := range friends {
+		kept[friendID] = true
+		if friendID == userID {
+			continue
+		}
+
+		if _, err := db.addFriend(friendID, userID); err != nil && err.Code() != port.ErrInvalidKey.Code() {
+			return err
+		}
+	}
+
+	for _, friendID := range old {
+		if kept[friendID] {
+			continue
+		}
+
+		if _, err := db.removeFriend(friendID, userID); err != nil && err.Code() != port.ErrInvalidKey.Code() {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateFriends replaces the friend list on the shard of the user, holding
+// only its lock, and returns the list replaced
+func (db *ShardedDatastore) updateFriends(userID string, friends []string) ([]string, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	shard := db.shardOf(userID)
+	old, err := shard.GetFriendIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return old, shard.UpdateFriends(userID, friends)
+}
+
+// AddFriend adds the friendship on the shard of the user, then on the shard
+// of the friend
+func (db *ShardedDatastore) AddFriend(userID, friendID string) (bool, common.Error) {
+	// The friend may have blocked the user on another shard
+	blocked, err := db.IsBlocked(userID, friendID)
+	if err != nil {
+		return false, err
+	}
+
+	if blocked {
+		return false, common.NewError(port.ErrBlocked, "User is blocked")
+	}
+
+	added, err := db.addFriend(userID, friendID)
+	if err != nil {
+		return false, err
+	}
+
+	// A no-op if the shard already did it, or the friend is deleted
+	if _, err := db.addFriend(friendID, userID); err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return false, err
+	}
+
+	return added, nil
+}
+
+// addFriend adds the friend on the shard of the user, holding only its lock
+func (db *ShardedDatastore) addFriend(userID, friendID string) (bool, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).AddFriend(userID, friendID)
+}
+
+// RemoveFriend removes the friendship on the shard of the user, then on the
+// shard of the friend
+func (db *ShardedDatastore) RemoveFriend(userID, friendID string) (bool, common.Error) {
+	removed, err := db.removeFriend(userID, friendID)
+	if err != nil {
+		return false, err
+	}
+
+	// A no-op if the shard already did it, or the friend is deleted
+	if _, err := db.removeFriend(friendID, userID); err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return false, err
+	}
+
+	return removed, nil
+}
+
+// removeFriend removes the friend on the shard of the user, holding only its
+// lock
+func (db *ShardedDatastore) removeFriend(userID, friendID string) (bool, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).RemoveFriend(userID, friendID)
+}
+
+// GetFriends looks up the friend list on the users shard, and the friends
+// on their own shards
+func (db *ShardedDatastore) GetFriends(userID string) ([]*port.Friend, common.Error) {
+	friendIDs, err := db.GetFriendIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetFriendsByID(friendIDs, userID)
+}
+
+// ListFriends pages the friends looked up across the shards
+func (db *ShardedDatastore) ListFriends(userID string, query *port.ListQuery) ([]*port.Friend, common.Error) {
+	all, err := db.GetFriends(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]port.ListKey, 0, len(all))
+	byID := make(map[string]*port.Friend, len(all))
+	for _, friend := range all {
+		keys = append(keys, port.ListKey{
+			UserID: friend.UserID,
+			Name:   friend.Name,
+			Score:  friend.HighScore,
+		})
+		byID[friend.UserID] = friend
+	}
+
+	friends := make([]*port.Friend, 0, query.Limit)
+	for _, key := range listPage(keys, query) {
+		friends = append(friends, byID[key.UserID])
+	}
+
+	return friends, nil
+}
+
+// GetFriendIDs ...
+func (db *ShardedDatastore) GetFriendIDs(userID string) ([]string, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetFriendIDs(userID)
+}
+
+// GetFriendsByID ...
+func (db *ShardedDatastore) GetFriendsByID(userIDs []string, viewerID string) ([]*port.Friend, common.Error) {
+	// Group the users by shard
+	groups := make(map[port.Datastore][]string)
+	for _, userID := range userIDs {
+		shard := db.locate(userID)
+		groups[shard] = append(groups[shard], userID)
+	}
+
+	shards := make([]port.Datastore, 0, len(groups))
+	for shard := range groups {
+		shards = append(shards, shard)
+	}
+
+	results := make([][]*port.Friend, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		friends, err := db.scoped(shard).GetFriendsByID(groups[shard], viewerID)
+		results[i] = friends
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	friends := make([]*port.Friend, 0, len(userIDs))
+	for _, result := range results {
+		friends = append(friends, result...)
+	}
+
+	sort.Sort(friendByUserID(friends))
+
+	return friends, nil
+}
+
+// GetFriendSuggestions walks the friend graph across the shards. The friend
+// lists are read from the shard of each friend, and the candidates are
+// looked up on their own shards.
+func (db *ShardedDatastore) GetFriendSuggestions(userID string, limit int) ([]*port.FriendSuggestion, common.Error) {
+	friends, err := db.GetFriends(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Friends who blocked the user are left out by GetFriends, but are
+	// still friends
+	friendIDs, err := db.GetFriendIDs(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := db.GetBlockedUsers(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool, len(friendIDs)+len(blocks)+1)
+	excluded[userID] = true
+	for _, friendID := range friendIDs {
+		excluded[friendID] = true
+	}
+
+	for _, block := range blocks {
+		excluded[block.BlockedID] = true
+	}
+
+	mutual := make(map[string]int)
+	for _, friend := range friends {
+		ids, err := db.GetFriendIDs(friend.UserID)
+		if err != nil && err.Code() != port.ErrInvalidKey.Code() {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			if !excluded[id] {
+				mutual[id]++
+			}
+		}
+	}
+
+	candidateIDs := make([]string, 0, len(mutual))
+	for id := range mutual {
+		candidateIDs = append(candidateIDs, id)
+	}
+
+	// Candidates who blocked the user are left out
+	candidates, err := db.GetFriendsByID(candidateIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]*port.FriendSuggestion, 0, len(candidates))
+	for _, candidate := range candidates {
+		suggestions = append(suggestions, &port.FriendSuggestion{
+			Friend:        *candidate,
+			MutualFriends: mutual[candidate.UserID],
+		})
+	}
+
+	sort.Sort(suggestionByRank(suggestions))
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	return suggestions, nil
+}
+
+// ViewFriendScores ...
+func (db *ShardedDatastore) ViewFriendScores(userID string, views []*port.FriendScoreView) ([]*port.FriendScoreView, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).ViewFriendScores(userID, views)
+}
+
+// NewFriendRequest is stored on the shard of the recipient, unless either
+// user blocked the other on their own shard. Requests sent by a user are
+// stored across shards, so they're counted holding the sender lock of the
+// user, while the shard of the recipient limits the requests received.
+func (db *ShardedDatastore) NewFriendRequest(request *port.FriendRequest, maxPending int) (*port.FriendRequest, common.Error) {
+	blocked, err := db.IsBlocked(request.FromID, request.ToID)
+	if err != nil {
+		return nil, err
+	}
+
+	if blocked {
+		return nil, common.NewError(port.ErrBlocked, "User is blocked")
+	}
+
+	sender := db.senderLock(request.FromID)
+	sender.Lock()
+	defer sender.Unlock()
+
+	sent, err := db.GetSentFriendRequests(request.FromID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := 0
+	for _, other := range sent {
+		if other.ToID != request.ToID {
+			pending++
+		}
+	}
+
+	if pending >= maxPending {
+		return nil, common.NewError(port.ErrLimitReached, "Too many pending friend requests sent").
+			WithField("max", maxPending)
+	}
+
+	lock := db.userLock(request.ToID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(request.ToID).NewFriendRequest(request, maxPending)
+}
+
+// GetFriendRequests ...
+func (db *ShardedDatastore) GetFriendRequests(userID string) ([]*port.FriendRequest, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetFriendRequests(userID)
+}
+
+// GetSentFriendRequests merges the requests stored with the recipients on
+// every shard
+func (db *ShardedDatastore) GetSentFriendRequests(userID string) ([]*port.FriendRequest, common.Error) {
+	shards := db.allShards()
+	results := make([][]*port.FriendRequest, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		requests, err := shard.GetSentFriendRequests(userID)
+		results[i] = requests
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A recipient being moved may be seen on both shards
+	seen := make(map[string]bool)
+	requests := make([]*port.FriendRequest, 0)
+	for _, result := range results {
+		for _, request := range result {
+			if !seen[request.ToID] {
+				seen[request.ToID] = true
+				requests = append(requests, request)
+			}
+		}
+	}
+
+	sort.Sort(requestByCreated(requests))
+
+	return requests, nil
+}
+
+// AcceptFriendRequest accepts the request on the shard of the recipient, then
+// completes the friendship on the shard of the sender
+func (db *ShardedDatastore) AcceptFriendRequest(fromID, toID string) common.Error {
+	if err := db.acceptFriendRequest(fromID, toID); err != nil {
+		return err
+	}
+
+	lock := db.userLock(fromID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	// Both are no-ops if the shard already did it, and the sender may have
+	// been deleted since sending the request
+	shard := db.shardOf(fromID)
+	if _, err := shard.AddFriend(fromID, toID); err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return err
+	}
+
+	if err := shard.DeleteFriendRequest(toID, fromID); err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return err
+	}
+
+	return nil
+}
+
+// acceptFriendRequest accepts the request on the shard of the recipient,
+// holding only the lock of the recipient
+func (db *ShardedDatastore) acceptFriendRequest(fromID, toID string) common.Error {
+	lock := db.userLock(toID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(toID).AcceptFriendRequest(fromID, toID)
+}
+
+// DeleteFriendRequest ...
+func (db *ShardedDatastore) DeleteFriendRequest(fromID, toID string) common.Error {
+	lock := db.userLock(toID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(toID).DeleteFriendRequest(fromID, toID)
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Referrals                                                           **
+**   Codes are stored with the referrer, and looked up on every shard    **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// NewReferralCode is stored on the shard of the user. Shards only keep
+// their own codes unique, so codes of users on the other shards are looked
+// up first.
+func (db *ShardedDatastore) NewReferralCode(code *port.ReferralCode) (*port.ReferralCode, common.Error) {
+	existing, err := db.LookupReferralCode(code.Code)
+	if err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return nil, err
+	}
+
+	if existing != nil && existing.UserID != code.UserID {
+		return nil, common.NewError(port.ErrEntryExists, "Referral code already exists")
+	}
+
+	lock := db.userLock(code.UserID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(code.UserID).NewReferralCode(code)
+}
+
+// GetReferralCode ...
+func (db *ShardedDatastore) GetReferralCode(userID string) (*port.ReferralCode, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetReferralCode(userID)
+}
+
+// LookupReferralCode looks the code up on every shard
+func (db *ShardedDatastore) LookupReferralCode(code string) (*port.ReferralCode, common.Error) {
+	shards := db.allShards()
+	results := make([]*port.ReferralCode, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		found, err := shard.LookupReferralCode(code)
+		if err != nil && err.Code() == port.ErrInvalidKey.Code() {
+			return nil
+		}
+
+		results[i] = found
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A referrer being moved may be seen on both shards
+	for _, found := range results {
+		if found != nil {
+			return found, nil
+		}
+	}
+
+	return nil, common.NewError(port.ErrInvalidKey, "Invalid referral code")
+}
+
+// RedeemReferralCode looks up the referrer of the code, and redeems it on
+// the shard of the referrer
+func (db *ShardedDatastore) RedeemReferralCode(code, userID string) (*port.ReferralCode, common.Error) {
+	found, err := db.LookupReferralCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := db.userLock(found.UserID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(found.UserID).RedeemReferralCode(code, userID)
+}
+
+// GetReferrals ...
+func (db *ShardedDatastore) GetReferrals(userID string) ([]*port.Referral, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetReferrals(userID)
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Linked accounts                                                     **
+**   Accounts are stored with the user, and found on every shard         **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// LinkAccount is stored on the shard of the user. Shards only keep their own
+// accounts unique, so accounts linked by users on the other shards are
+// found first.
+func (db *ShardedDatastore) LinkAccount(account *port.LinkedAccount) (*port.LinkedAccount, common.Error) {
+	existing, err := db.FindLinkedAccounts(account.Provider, []string{account.ExternalID})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, linked := range existing {
+		if linked.UserID != account.UserID {
+			return nil, common.NewError(port.ErrEntryExists, "Account linked to another user")
+		}
+	}
+
+	lock := db.userLock(account.UserID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(account.UserID).LinkAccount(account)
+}
+
+// UnlinkAccount ...
+func (db *ShardedDatastore) UnlinkAccount(userID, provider string) (bool, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).UnlinkAccount(userID, provider)
+}
+
+// GetLinkedAccounts ...
+func (db *ShardedDatastore) GetLinkedAccounts(userID string) ([]*port.LinkedAccount, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).GetLinkedAccounts(userID)
+}
+
+// FindLinkedAccounts finds the accounts on every shard
+func (db *ShardedDatastore) FindLinkedAccounts(provider string, externalIDs []string) ([]*port.LinkedAccount, common.Error) {
+	shards := db.allShards()
+	results := make([][]*port.LinkedAccount, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		accounts, err := shard.FindLinkedAccounts(provider, externalIDs)
+		results[i] = accounts
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A user being moved may be seen on both shards
+	seen := make(map[string]bool)
+	accounts := make([]*port.LinkedAccount, 0)
+	for _, result := range results {
+		for _, account := range result {
+			if !seen[account.UserID] {
+				seen[account.UserID] = true
+				accounts = append(accounts, account)
+			}
+		}
+	}
+
+	sort.Sort(accountByUserID(accounts))
+
+	return accounts, nil
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Leaderboard                                                         **
+**   Every shard ranks its own users, pages are merged from all shards   **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// GetLeaderboard merges the top of every shard. Entries ranked before the
+// end of the page are within the top of their shard, so they're all known.
+// Blockers are left out of the ranked page.
+func (db *ShardedDatastore) GetLeaderboard(offset, limit int, viewerID string) ([]*port.LeaderboardEntry, common.Error) {
+	entries, err := db.mergeTop(offset, limit, true, func(shard port.Datastore, n int) ([]*port.LeaderboardEntry, common.Error) {
+		return shard.GetLeaderboard(0, n, "")
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return db.hideRankedBlockers(entries, viewerID)
+}
+
+// GetLeaderboardAround reads the key of the user from its shard, and merges
+// the range around it from every shard. The shard of the user tells if the
+// user blocked the viewer.
+func (db *ShardedDatastore) GetLeaderboardAround(userID string, radius int, viewerID string) ([]*port.LeaderboardEntry, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	own, err := db.shardOf(userID).GetLeaderboardAround(userID, 0, viewerID)
+	lock.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := db.GetLeaderboardRange(own[0].LeaderboardKey, radius, radius+1)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.hideRankedBlockers(entries, viewerID)
+}
+
+// hideRankedBlockers leaves out the ranked entries of users who blocked the viewer,
+// looking the users up on their own shards as seen by the viewer
+func (db *ShardedDatastore) hideRankedBlockers(entries []*port.LeaderboardEntry, viewerID string) ([]*port.LeaderboardEntry, common.Error) {
+	if viewerID == "" || len(entries) == 0 {
+		return entries, nil
+	}
+
+	userIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		userIDs = append(userIDs, entry.UserID)
+	}
+
+	users, err := db.GetFriendsByID(userIDs, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(users))
+	for _, user := range users {
+		seen[user.UserID] = true
+	}
+
+	visible := make([]*port.LeaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		if seen[entry.UserID] {
+			visible = append(visible, entry)
+		}
+	}
+
+	return visible, nil
+}
+
+// GetLeaderboardSize ...
+func (db *ShardedDatastore) GetLeaderboardSize() (int, common.Error) {
+	shards := db.allShards()
+	sizes := make([]int, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		size, err := shard.GetLeaderboardSize()
+		sizes[i] = size
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return sum(sizes), nil
+}
+
+// GetLeaderboardRange merges the ranges of every shard, keeping the entries
+// closest to the key. The entries are ranked by counting on every shard.
+func (db *ShardedDatastore) GetLeaderboardRange(key port.LeaderboardKey, before, after int) ([]*port.LeaderboardEntry, common.Error) {
+	entries, err := db.mergeLeaderboards(func(shard port.Datastore) ([]*port.LeaderboardEntry, common.Error) {
+		return shard.GetLeaderboardRange(key, before, after)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	split := sort.Search(len(entries), func(i int) bool {
+		return !leaderboardKeyLess(entries[i].LeaderboardKey, key)
+	})
+
+	start, end := split-before, split+after
+	if start < 0 {
+		start = 0
+	}
+
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	entries = entries[start:end]
+	if len(entries) == 0 {
+		return entries, nil
+	}
+
+	position, err := db.CountLeaderboardAhead(entries[0].LeaderboardKey)
+	if err != nil {
+		return nil, err
+	}
+
+	higher, err := db.CountLeaderboardAhead(port.LeaderboardKey{Score: entries[0].Score})
+	if err != nil {
+		return nil, err
+	}
+
+	rankEntries(entries, position, higher)
+	return entries, nil
+}
+
+// CountLeaderboardAhead counts on every shard, users being moved may be
+// counted twice
+func (db *ShardedDatastore) CountLeaderboardAhead(key port.LeaderboardKey) (int, common.Error) {
+	shards := db.allShards()
+	counts := make([]int, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		count, err := shard.CountLeaderboardAhead(key)
+		counts[i] = count
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return sum(counts), nil
+}
+
+// GetScoreDistribution adds up the buckets of every shard, users being moved
+// may be counted twice
+func (db *ShardedDatastore) GetScoreDistribution() (*port.ScoreDistribution, common.Error) {
+	shards := db.allShards()
+	results := make([]*port.ScoreDistribution, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		distribution, err := shard.GetScoreDistribution()
+		results[i] = distribution
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[int]int)
+	for _, distribution := range results {
+		for _, bucket := range distribution.Buckets {
+			counts[scoreBucket(bucket.Min)] += bucket.Count
+		}
+	}
+
+	return newScoreDistribution(counts), nil
+}
+
+// GetPeriodLeaderboard merges the top of every shard, as the runs of a user
+// are stored with the user
+func (db *ShardedDatastore) GetPeriodLeaderboard(period *port.LeaderboardPeriod, offset, limit int) ([]*port.LeaderboardEntry, common.Error) {
+	return db.mergeTop(offset, limit, true, func(shard port.Datastore, n int) ([]*port.LeaderboardEntry, common.Error) {
+		return shard.GetPeriodLeaderboard(period, 0, n)
+	})
+}
+
+// GetPeriodLeaderboardSize ...
+func (db *ShardedDatastore) GetPeriodLeaderboardSize(period *port.LeaderboardPeriod) (int, common.Error) {
+	shards := db.allShards()
+	sizes := make([]int, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		size, err := shard.GetPeriodLeaderboardSize(period)
+		sizes[i] = size
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return sum(sizes), nil
+}
+
+// ArchiveLeaderboard stores the results with their users. The users are
+// locked while grouped by shard, so none of them are moved before their
+// results are stored.
+func (db *ShardedDatastore) ArchiveLeaderboard(period *port.LeaderboardPeriod, entries []*port.LeaderboardEntry) common.Error {
+	// Locks are taken in order, as other batches may lock the same users
+	var locked [shardUserLocks]bool
+	for _, entry := range entries {
+		locked[hashKey(entry.UserID)%shardUserLocks] = true
+	}
+
+	for i := range locked {
+		if locked[i] {
+			db.userLocks[i].RLock()
+			defer db.userLocks[i].RUnlock()
+		}
+	}
+
+	batches := make(map[port.Datastore][]*port.LeaderboardEntry)
+	for _, entry := range entries {
+		shard := db.locate(entry.UserID)
+		batches[shard] = append(batches[shard], entry)
+	}
+
+	shards := make([]port.Datastore, 0, len(batches))
+	for shard := range batches {
+		shards = append(shards, shard)
+	}
+
+	return fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		return db.scoped(shard).ArchiveLeaderboard(period, batches[shard])
+	})
+}
+
+// CompleteLeaderboardArchive stores the summary on every shard, so it's
+// found wherever the results are
+func (db *ShardedDatastore) CompleteLeaderboardArchive(archive *port.LeaderboardArchive) common.Error {
+	stored := *archive
+	if stored.ArchivedAt.IsZero() {
+		stored.ArchivedAt = time.Now()
+	}
+
+	return fanOut(db.allShards(), func(i int, shard port.Datastore) common.Error {
+		return shard.CompleteLeaderboardArchive(&stored)
+	})
+}
+
+// GetLeaderboardArchive returns the summary of any shard, shards added since
+// the period was archived don't know it
+func (db *ShardedDatastore) GetLeaderboardArchive(period *port.LeaderboardPeriod) (*port.LeaderboardArchive, common.Error) {
+	shards := db.allShards()
+	archives := make([]*port.LeaderboardArchive, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		archive, err := shard.GetLeaderboardArchive(period)
+		if err != nil && err.Code() == port.ErrInvalidKey.Code() {
+			return nil
+		}
+
+		archives[i] = archive
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, archive := range archives {
+		if archive != nil {
+			return archive, nil
+		}
+	}
+
+	return nil, common.NewError(port.ErrInvalidKey, "Period not archived")
+}
+
+// GetArchivedLeaderboard merges the top of every shard, keeping the ranks
+// the results are archived with
+func (db *ShardedDatastore) GetArchivedLeaderboard(period *port.LeaderboardPeriod, offset, limit int) ([]*port.LeaderboardEntry, common.Error) {
+	return db.mergeTop(offset, limit, false, func(shard port.Datastore, n int) ([]*port.LeaderboardEntry, common.Error) {
+		return shard.GetArchivedLeaderboard(period, 0, n)
+	})
+}
+
+// mergeTop merges the top n = offset+limit entries of every shard, and
+// returns the page. Entries ranked before the end of the page are within the
+// top of their shard, so they're all known and can be ranked.
+func (db *ShardedDatastore) mergeTop(offset, limit int, rank bool, read func(shard port.Datastore, n int) ([]*port.LeaderboardEntry, common.Error)) ([]*port.LeaderboardEntry, common.Error) {
+	entries, err := db.mergeLeaderboards(func(shard port.Datastore) ([]*port.LeaderboardEntry, common.Error) {
+		return read(shard, offset+limit)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if rank {
+		rankEntries(entries, 0, 0)
+	}
+
+	if offset >= len(entries) {
+		return []*port.LeaderboardEntry{}, nil
+	}
+
+	entries = entries[offset:]
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return entries, nil
+}
+
+// mergeLeaderboards merges the entries read from every shard, in
+// leaderboard order without ranks
+func (db *ShardedDatastore) mergeLeaderboards(read func(shard port.Datastore) ([]*port.LeaderboardEntry, common.Error)) ([]*port.LeaderboardEntry, common.Error) {
+	shards := db.allShards()
+	results := make([][]*port.LeaderboardEntry, len(shards))
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		entries, err := read(shard)
+		results[i] = entries
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// A user being moved may be seen on both shards
+	seen := make(map[string]bool)
+	entries := make([]*port.LeaderboardEntry, 0)
+	for _, result := range results {
+		for _, entry := range result {
+			if !seen[entry.UserID] {
+				seen[entry.UserID] = true
+				entries = append(entries, entry)
+			}
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return leaderboardKeyLess(entries[i].LeaderboardKey, entries[j].LeaderboardKey)
+	})
+
+	return entries, nil
+}
+
+// sum adds up the counts of the shards
+func sum(counts []int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+
+	return total
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Moving users                                                        **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// ExportUser ...
+func (db *ShardedDatastore) ExportUser(userID string) (*port.UserRecord, common.Error) {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).ExportUser(userID)
+}
+
+// ImportUser ...
+func (db *ShardedDatastore) ImportUser(record *port.UserRecord) common.Error {
+	lock := db.userLock(record.User.UserID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(record.User.UserID).ImportUser(record)
+}
+
+// GetGameIDs merges the games of every shard
+func (db *ShardedDatastore) GetGameIDs() ([]string, common.Error) {
+	shards := db.allShards()
+	results := make([][]string, len(shards))
+
+	err := fanOut(shards, func(i int, shard port.Datastore) common.Error {
+		gameIDs, err := shard.GetGameIDs()
+		results[i] = gameIDs
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Games being moved may briefly exist on two shards
+	seen := make(map[string]bool)
+	gameIDs := make([]string, 0)
+	for _, result := range results {
+		for _, gameID := range result {
+			if seen[gameID] {
+				continue
+			}
+
+			seen[gameID] = true
+			gameIDs = append(gameIDs, gameID)
+		}
+	}
+
+	sort.Strings(gameIDs)
+
+	return gameIDs, nil
+}
+
+// ExportGame ...
+func (db *ShardedDatastore) ExportGame(gameID string) (*port.Game, common.Error) {
+	lock := db.userLock(gameID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(gameID).ExportGame(gameID)
+}
+
+// ImportGame ...
+func (db *ShardedDatastore) ImportGame(game *port.Game) common.Error {
+	lock := db.userLock(game.GameID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(game.GameID).ImportGame(game)
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Testing                                                             **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// DeleteUser ...
+func (db *ShardedDatastore) DeleteUser(userID string) common.Error {
+	lock := db.userLock(userID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(userID).DeleteUser(userID)
+}
+
+// DeleteGame ...
+func (db *ShardedDatastore) DeleteGame(gameID string) common.Error {
+	lock := db.userLock(gameID)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return db.shardOf(gameID).DeleteGame(gameID)
+}
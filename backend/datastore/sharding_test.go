@@ -0,0 +1,568 @@
+package datastore
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// newShardedSimulators creates a sharded datastore over the given number
+// of simulators, with the testing users and friends
+func newShardedSimulators(t *testing.T, n int) (*ShardedDatastore, map[string]port.Datastore) {
+	shards := make(map[string]port.Datastore)
+	for i := 0; i < n; i++ {
+		shards[fmt.Sprintf("shard%d", i)] = NewDatastoreSimulator()
+	}
+
+	db := NewShardedDatastore(shards, common.NewLog("test", os.Stdout))
+	for i := range Users {
+		_, err := db.NewUser(Users[i], UserNames[i])
+		require.Nil(t, err)
+
+		seedGameState(t, db, Users[i], GameStates[i])
+		require.Nil(t, db.UpdateFriends(Users[i], Friends[i]))
+	}
+
+	return db, shards
+}
+
+func TestHashRing(t *testing.T) {
+	shards := map[string]port.Datastore{"a": nil, "b": nil, "c": nil}
+	ring := newHashRing(shards)
+
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		counts[ring.owner(fmt.Sprintf("user%d", i))]++
+	}
+
+	// Every shard gets a fair share of the users
+	for name := range shards {
+		assert.True(t, counts[name] > 500, "shard %s only owns %d users", name, counts[name])
+	}
+
+	// Adding a shard only moves the users it takes over
+	shards["d"] = nil
+	grown := newHashRing(shards)
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("user%d", i)
+		if owner := grown.owner(key); owner != "d" {
+			assert.Equal(t, ring.owner(key), owner)
+		}
+	}
+}
+
+func TestShardedUsers(t *testing.T) {
+	db, shards := newShardedSimulators(t, 3)
+
+	users, err := db.GetUsers()
+	require.Nil(t, err)
+	require.Equal(t, len(Users), len(users))
+	for i, user := range users {
+		assert.Equal(t, Users[i], user.UserID)
+		assert.Equal(t, UserNames[i], user.Name)
+	}
+
+	// Each user is stored on a single shard
+	for _, userID := range Users {
+		found := 0
+		for _, shard := range shards {
+			if exists, _ := shard.UserExists(userID); exists {
+				found++
+			}
+		}
+
+		assert.Equal(t, 1, found, userID)
+	}
+}
+
+func TestShardedFriends(t *testing.T) {
+	db, _ := newShardedSimulators(t, 3)
+
+	friends, err := db.GetFriends(Users[0])
+	require.Nil(t, err)
+	require.Equal(t, len(Friends[0]), len(friends))
+	for i, friend := range friends {
+		assert.Equal(t, Friends[0][i], friend.UserID)
+	}
+}
+
+func TestShardedFriendSuggestions(t *testing.T) {
+	db, _ := newShardedSimulators(t, 3)
+
+	// User 1 is a friend of user 0 and 2, who both are friends of user 3.
+	// Friendships are mutual, replacing the list of user 2 keeps users 0 and 1.
+	require.Nil(t, db.UpdateFriends(Users[1], []string{Users[0], Users[2]}))
+	require.Nil(t, db.UpdateFriends(Users[2], []string{Users[0], Users[1], Users[3]}))
+	require.Nil(t, db.UpdateGameState(Users[3], port.NewGameState(1, 5), Change))
+
+	suggestions, err := db.GetFriendSuggestions(Users[1], 10)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(suggestions))
+	assert.Equal(t, Users[3], suggestions[0].UserID)
+	assert.Equal(t, 2, suggestions[0].MutualFriends)
+	assert.NotNil(t, suggestions[0].LastActiveAt)
+
+	// The block is stored on the shard of user 3
+	_, err = db.BlockUser(Users[3], Users[1])
+	require.Nil(t, err)
+
+	suggestions, err = db.GetFriendSuggestions(Users[1], 10)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(suggestions))
+}
+
+func TestShardedReferralCodes(t *testing.T) {
+	db, _ := newShardedSimulators(t, 3)
+
+	_, err := db.NewReferralCode(&port.ReferralCode{Code: "SHARD234", UserID: Users[1], MaxRedemptions: 1, ExpiresAt: time.Now().Add(time.Hour)})
+	require.Nil(t, err)
+
+	// Codes are unique across the shards
+	_, err = db.NewReferralCode(&port.ReferralCode{Code: "SHARD234", UserID: Users[2], MaxRedemptions: 1, ExpiresAt: time.Now().Add(time.Hour)})
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrEntryExists.Code(), err.Code())
+
+	// Codes are redeemed on the shard of the referrer
+	code, err := db.RedeemReferralCode("SHARD234", Users[3])
+	require.Nil(t, err)
+	assert.Equal(t, Users[1], code.UserID)
+
+	_, err = db.RedeemReferralCode("SHARD234", Users[0])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrUnavailable.Code(), err.Code())
+
+	referrals, err := db.GetReferrals(Users[1])
+	require.Nil(t, err)
+	require.Equal(t, 1, len(referrals))
+	assert.Equal(t, Users[3], referrals[0].UserID)
+}
+
+func TestShardedLinkedAccounts(t *testing.T) {
+	db, _ := newShardedSimulators(t, 3)
+
+	for i, userID := range Users {
+		_, err := db.LinkAccount(&port.LinkedAccount{UserID: userID, Provider: "steam", ExternalID: fmt.Sprintf("s-%d", i)})
+		require.Nil(t, err)
+	}
+
+	// Accounts are unique across the shards
+	_, err := db.LinkAccount(&port.LinkedAccount{UserID: Users[1], Provider: "steam", ExternalID: "s-0"})
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrEntryExists.Code(), err.Code())
+
+	found, err := db.FindLinkedAccounts("steam", []string{"s-0", "s-1", "s-2", "s-3"})
+	require.Nil(t, err)
+	require.Equal(t, 4, len(found))
+	for i, account := range found {
+		assert.Equal(t, Users[i], account.UserID)
+	}
+}
+
+func TestShardedLeaderboard(t *testing.T) {
+	db, _ := newShardedSimulators(t, 3)
+
+	// A single simulator with the same users ranks them the same way
+	single := NewDatastoreSimulator()
+	userIDs := make([]string, 0)
+	for i := 0; i < 40; i++ {
+		userID := fmt.Sprintf("%02de6feba-043b-4ba4-a7a4-9d6705595049", i)
+		userIDs = append(userIDs, userID)
+
+		for _, store := range []port.Datastore{db, single} {
+			_, err := store.NewUser(userID, fmt.Sprintf("player%d", i))
+			require.Nil(t, err)
+
+			_, err = store.SubmitScore(userID, (i*7)%10, Change)
+			require.Nil(t, err)
+		}
+	}
+
+	for _, store := range []port.Datastore{db, single} {
+		for _, userID := range Users {
+			require.Nil(t, store.DeleteUser(userID))
+		}
+	}
+
+	size, err := db.GetLeaderboardSize()
+	require.Nil(t, err)
+	assert.Equal(t, len(userIDs), size)
+
+	for _, page := range [][2]int{{0, 10}, {5, 10}, {35, 10}, {40, 10}} {
+		expected, err := single.GetLeaderboard(page[0], page[1], "")
+		require.Nil(t, err)
+
+		entries, err := db.GetLeaderboard(page[0], page[1], "")
+		require.Nil(t, err)
+		assert.Equal(t, expected, entries, "offset %d", page[0])
+	}
+
+	for _, userID := range []string{userIDs[0], userIDs[13], userIDs[39]} {
+		expected, err := single.GetLeaderboardAround(userID, 3, "")
+		require.Nil(t, err)
+
+		entries, err := db.GetLeaderboardAround(userID, 3, "")
+		require.Nil(t, err)
+		assert.Equal(t, expected, entries, userID)
+	}
+
+	// Users on every shard who blocked the viewer are left out the same way
+	viewerID := userIDs[0]
+	for _, store := range []port.Datastore{db, single} {
+		for _, userID := range []string{userIDs[3], userIDs[13], userIDs[24], userIDs[30]} {
+			_, err := store.BlockUser(userID, viewerID)
+			require.Nil(t, err)
+		}
+	}
+
+	for _, page := range [][2]int{{0, 10}, {5, 10}, {35, 10}} {
+		expected, err := single.GetLeaderboard(page[0], page[1], viewerID)
+		require.Nil(t, err)
+
+		entries, err := db.GetLeaderboard(page[0], page[1], viewerID)
+		require.Nil(t, err)
+		assert.Equal(t, expected, entries, "offset %d", page[0])
+	}
+
+	expectedAround, err := single.GetLeaderboardAround(userIDs[20], 5, viewerID)
+	require.Nil(t, err)
+
+	around, err := db.GetLeaderboardAround(userIDs[20], 5, viewerID)
+	require.Nil(t, err)
+	assert.Equal(t, expectedAround, around)
+
+	_, err = db.GetLeaderboardAround(userIDs[13], 3, viewerID)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+
+	// The buckets of the shards add up to the same distribution
+	expected, err := single.GetScoreDistribution()
+	require.Nil(t, err)
+
+	distribution, err := db.GetScoreDistribution()
+	require.Nil(t, err)
+	assert.Equal(t, expected, distribution)
+}
+
+func TestShardedPeriodLeaderboard(t *testing.T) {
+	db, _ := newShardedSimulators(t, 3)
+
+	// A single simulator with the same users ranks them the same way
+	single := NewDatastoreSimulator()
+	for _, store := range []port.Datastore{db, single} {
+		for _, userID := range Users {
+			require.Nil(t, store.DeleteUser(userID))
+		}
+	}
+
+	for i := 0; i < 40; i++ {
+		userID := fmt.Sprintf("%02de6feba-043b-4ba4-a7a4-9d6705595049", i)
+		for _, store := range []port.Datastore{db, single} {
+			_, err := store.NewUser(userID, fmt.Sprintf("player%d", i))
+			require.Nil(t, err)
+
+			// Every third user hasn't played within the period
+			if i%3 != 0 {
+				_, err = store.SubmitScore(userID, (i*7)%10, Change)
+				require.Nil(t, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	period := &port.LeaderboardPeriod{Window: "daily", Key: "today", Start: now.Add(-time.Hour), End: now.Add(time.Hour)}
+
+	size, err := db.GetPeriodLeaderboardSize(period)
+	require.Nil(t, err)
+	assert.Equal(t, 26, size)
+
+	for _, page := range [][2]int{{0, 10}, {5, 10}, {20, 10}, {26, 10}} {
+		expected, err := single.GetPeriodLeaderboard(period, page[0], page[1])
+		require.Nil(t, err)
+
+		entries, err := db.GetPeriodLeaderboard(period, page[0], page[1])
+		require.Nil(t, err)
+		assert.Equal(t, expected, entries, "offset %d", page[0])
+	}
+
+	// Archived results are stored with their users, and read back with their ranks
+	entries, err := single.GetPeriodLeaderboard(period, 0, size)
+	require.Nil(t, err)
+
+	for _, store := range []port.Datastore{db, single} {
+		require.Nil(t, store.ArchiveLeaderboard(period, entries))
+		require.Nil(t, store.CompleteLeaderboardArchive(&port.LeaderboardArchive{Window: "daily", Period: "today", Total: size}))
+	}
+
+	archive, err := db.GetLeaderboardArchive(period)
+	require.Nil(t, err)
+	assert.Equal(t, size, archive.Total)
+
+	for _, page := range [][2]int{{0, 10}, {5, 10}, {20, 10}} {
+		expected, err := single.GetArchivedLeaderboard(period, page[0], page[1])
+		require.Nil(t, err)
+
+		archived, err := db.GetArchivedLeaderboard(period, page[0], page[1])
+		require.Nil(t, err)
+		assert.Equal(t, expected, archived, "offset %d", page[0])
+	}
+}
+
+func TestShardedFriendRequests(t *testing.T) {
+	db, shards := newShardedSimulators(t, 3)
+
+	// Find users stored on different shards
+	ring := newHashRing(shards)
+	fromID, toID := Users[1], ""
+	for _, userID := range Users[2:] {
+		if ring.owner(userID) != ring.owner(fromID) {
+			toID = userID
+			break
+		}
+	}
+	require.NotEqual(t, "", toID)
+
+	_, err := db.NewFriendRequest(&port.FriendRequest{FromID: fromID, ToID: toID, ExpiresAt: time.Now().Add(time.Hour)}, 50)
+	require.Nil(t, err)
+
+	sent, err := db.GetSentFriendRequests(fromID)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(sent))
+	assert.Equal(t, toID, sent[0].ToID)
+
+	// The friendship is completed on both shards
+	require.Nil(t, db.AcceptFriendRequest(fromID, toID))
+
+	friendIDs, err := db.GetFriendIDs(fromID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{toID}, friendIDs)
+
+	friendIDs, err = db.GetFriendIDs(toID)
+	require.Nil(t, err)
+	assert.Equal(t, []string{fromID}, friendIDs)
+}
+
+func TestShardedBlockUser(t *testing.T) {
+	db, shards := newShardedSimulators(t, 3)
+
+	// Find a friend of user 0 stored on another shard
+	ring := newHashRing(shards)
+	blockerID := ""
+	for _, userID := range Friends[0] {
+		if ring.owner(userID) != ring.owner(Users[0]) {
+			blockerID = userID
+			break
+		}
+	}
+	require.NotEqual(t, "", blockerID)
+
+	_, err := db.BlockUser(blockerID, Users[0])
+	require.Nil(t, err)
+
+	// The friendship stored on the other shard is removed, and can't be added again
+	friendIDs, err := db.GetFriendIDs(Users[0])
+	require.Nil(t, err)
+	assert.NotContains(t, friendIDs, blockerID)
+
+	_, err = db.AddFriend(Users[0], blockerID)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrBlocked.Code(), err.Code())
+
+	users, err := db.ListUsers(&port.ListQuery{Sort: port.SortByID, Limit: 10, ViewerID: Users[0]})
+	require.Nil(t, err)
+	assert.Equal(t, len(Users)-1, len(users))
+}
+
+func TestReshard(t *testing.T) {
+	db, shards := newShardedSimulators(t, 2)
+
+	// Add two shards, keeping the existing ones
+	target := map[string]port.Datastore{
+		"shard0": shards["shard0"],
+		"shard1": shards["shard1"],
+		"shard2": NewDatastoreSimulator(),
+		"shard3": NewDatastoreSimulator(),
+	}
+
+	gameID := "a0e6feba-043b-4ba4-a7a4-9d6705595049"
+	_, err := db.NewGame(gameID, Users[:2])
+	require.Nil(t, err)
+
+	slotID := "a1e6feba-043b-4ba4-a7a4-9d6705595049"
+	for _, userID := range Users {
+		_, err = db.NewSaveSlot(userID, &port.SaveSlot{SlotID: slotID, Name: "Hard mode", State: *port.NewGameState(1, 10)}, 8)
+		require.Nil(t, err)
+	}
+
+	require.Nil(t, db.FlagUser(Users[2], "review"))
+
+	_, err = db.NewFriendRequest(&port.FriendRequest{FromID: Users[1], ToID: Users[2], ExpiresAt: time.Now().Add(time.Hour)}, 50)
+	require.Nil(t, err)
+
+	_, err = db.BlockUser(Users[3], Users[2])
+	require.Nil(t, err)
+
+	_, err = db.NewReferralCode(&port.ReferralCode{Code: "MOVED234", UserID: Users[3], MaxRedemptions: 2, ExpiresAt: time.Now().Add(time.Hour)})
+	require.Nil(t, err)
+
+	_, err = db.RedeemReferralCode("MOVED234", Users[0])
+	require.Nil(t, err)
+
+	_, err = db.LinkAccount(&port.LinkedAccount{UserID: Users[1], Provider: "steam", ExternalID: "s-1"})
+	require.Nil(t, err)
+
+	period := &port.LeaderboardPeriod{Window: "daily", Key: "2026-10-19"}
+	results := make([]*port.LeaderboardEntry, 0)
+	for i, userID := range Users {
+		results = append(results, &port.LeaderboardEntry{
+			LeaderboardKey: port.LeaderboardKey{Score: 100 - i, UserID: userID},
+			Rank:           i + 1,
+		})
+	}
+
+	_, err = db.ViewFriendScores(Users[0], []*port.FriendScoreView{{FriendID: Users[1], Score: 50, ViewedAt: time.Now()}})
+	require.Nil(t, err)
+
+	require.Nil(t, db.ArchiveLeaderboard(period, results))
+	require.Nil(t, db.CompleteLeaderboardArchive(&port.LeaderboardArchive{Window: "daily", Period: "2026-10-19", Total: len(Users)}))
+
+	_, err = db.Reshard(target)
+	require.Nil(t, err)
+
+	ring := newHashRing(target)
+	for i, userID := range Users {
+		exists, err := target[ring.owner(userID)].UserExists(userID)
+		require.Nil(t, err)
+		assert.True(t, exists, userID)
+
+		// Revisions are kept when moving, the state was written once
+		state, err := db.GetGameState(userID)
+		require.Nil(t, err)
+		assert.Equal(t, GameStates[i].Document, state.Document)
+		assert.Equal(t, 1, state.Revision)
+
+		revisions, err := db.GetGameStateRevisions(userID)
+		require.Nil(t, err)
+		assert.Equal(t, 1, len(revisions))
+
+		// Save slots are kept as well
+		slot, err := db.GetSaveSlot(userID, slotID)
+		require.Nil(t, err)
+		assert.False(t, slot.Active)
+		assert.Equal(t, 10, slot.State.Score())
+	}
+
+	users, err := db.GetUsers()
+	require.Nil(t, err)
+	assert.Equal(t, len(Users), len(users))
+
+	friends, err := db.GetFriends(Users[0])
+	require.Nil(t, err)
+	assert.Equal(t, len(Friends[0]), len(friends))
+
+	flags, err := db.GetFlaggedUsers()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(flags))
+	assert.Equal(t, Users[2], flags[0].UserID)
+
+	// Friend requests are moved with the recipient
+	requests, err := db.GetSentFriendRequests(Users[1])
+	require.Nil(t, err)
+	require.Equal(t, 1, len(requests))
+	assert.Equal(t, Users[2], requests[0].ToID)
+
+	// Blocks are moved with the blocker
+	blocked, err := db.IsBlocked(Users[2], Users[3])
+	require.Nil(t, err)
+	assert.True(t, blocked)
+
+	// Referral codes and referrals are moved with the referrer
+	code, err := db.RedeemReferralCode("MOVED234", Users[1])
+	require.Nil(t, err)
+	assert.Equal(t, 2, code.Redemptions)
+
+	referrals, err := db.GetReferrals(Users[3])
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(referrals))
+
+	// Linked accounts are moved with the user
+	accounts, err := db.FindLinkedAccounts("steam", []string{"s-1"})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(accounts))
+	assert.Equal(t, Users[1], accounts[0].UserID)
+
+	// Archived leaderboard results are moved with the user, the archive is
+	// still known after adding shards
+	_, err = db.GetLeaderboardArchive(period)
+	require.Nil(t, err)
+
+	archived, err := db.GetArchivedLeaderboard(period, 0, 10)
+	require.Nil(t, err)
+	require.Equal(t, len(Users), len(archived))
+	for i, entry := range archived {
+		assert.Equal(t, Users[i], entry.UserID)
+		assert.Equal(t, i+1, entry.Rank)
+	}
+
+	// Friend scores seen on the friends leaderboard are moved with the viewer
+	views, err := db.ViewFriendScores(Users[0], nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(views))
+	assert.Equal(t, 50, views[0].Score)
+
+	// Games are moved as well
+	_, err = target[ring.owner(gameID)].GetGame(gameID)
+	assert.Nil(t, err)
+
+	game, err := db.SetGameResult(gameID, Users[1], 10)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(game.Participants))
+}
+
+func TestReshardConflict(t *testing.T) {
+	db, shards := newShardedSimulators(t, 2)
+
+	// An unfinished resharding onto three shards
+	db.Lock()
+	db.target = newHashRing(map[string]port.Datastore{"shard0": nil, "shard1": nil, "shard2": nil})
+	db.targetShards = map[string]port.Datastore{"shard0": shards["shard0"], "shard1": shards["shard1"], "shard2": NewDatastoreSimulator()}
+	db.moved = make(map[string]bool)
+	db.Unlock()
+
+	target, running := db.ReshardStatus()
+	assert.Len(t, target, 3)
+	assert.False(t, running)
+
+	// Other shards are refused until resumed
+	_, err := db.Reshard(map[string]port.Datastore{"shard0": shards["shard0"]})
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrConflict.Code(), err.Code())
+
+	_, err = db.Reshard(target)
+	require.Nil(t, err)
+
+	target, _ = db.ReshardStatus()
+	assert.Nil(t, target)
+	assert.Len(t, db.Shards(), 3)
+}
+
+func TestParseShardURIs(t *testing.T) {
+	uris, err := ParseShardURIs(" a=postgresql://u:p@host/db?x=1 , b=postgresql://other/db,")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]string{"a": "postgresql://u:p@host/db?x=1", "b": "postgresql://other/db"}, uris)
+
+	uris, err = ParseShardURIs("")
+	require.Nil(t, err)
+	assert.Empty(t, uris)
+
+	for _, s := range []string{"a", "=uri", "a=", "a=x,a=y"} {
+		_, err := ParseShardURIs(s)
+		assert.NotNil(t, err, s)
+	}
+}
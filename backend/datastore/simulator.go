@@ -3,6 +3,7 @@ package datastore
 import (
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/valsgaard/interview-case/backend/common"
 	"github.com/valsgaard/interview-case/backend/endpoints/port"
@@ -11,21 +12,162 @@ import (
 type datastoreSim struct {
 	sync.Mutex
 
-	Users map[string]*datastoreUser
+	Users  map[string]*datastoreUser
+	Games  map[string]*port.Game
+	names  *nameIndex
+	scores *scoreIndex
+
+	// Summaries of the archived periods of the windowed leaderboards, by
+	// window and period
+	archives map[string]*port.LeaderboardArchive
+
+	retention HistoryRetention
 }
 
 var _ port.Datastore = &datastoreSim{}
 
 // NewDatastoreSimulator creates an in-memory datastore, used for testing
 func NewDatastoreSimulator() port.Datastore {
-	return &datastoreSim{Users: make(map[string]*datastoreUser)}
+	return &datastoreSim{
+		Users:  make(map[string]*datastoreUser),
+		Games:  make(map[string]*port.Game),
+		names:  newNameIndex(),
+		scores: newScoreIndex(),
+
+		archives: make(map[string]*port.LeaderboardArchive),
+
+		retention: DefaultHistoryRetention,
+	}
+}
+
+// reindex rebuilds the indexes, after Users have been replaced
+func (db *datastoreSim) reindex() {
+	db.names = newNameIndex()
+	db.scores = newScoreIndex()
+	for _, user := range db.Users {
+		db.names.add(user.userID, user.name)
+		db.scores.set(user.userID, user.gameState.Score())
+	}
 }
 
 type datastoreUser struct {
 	userID    string
 	name      string
-	gameState port.GameState
+	gameState *port.GameState
 	friendIDs []string
+	runs      []port.ScoreRun
+
+	// Revisions of the game state, oldest first
+	revisions []port.StateRevision
+
+	// Save slots in the order they're created, the game state of the user
+	// is the state of the active slot
+	slots      []*datastoreSlot
+	activeSlot string
+
+	// Set while the user is flagged for review
+	flag *port.UserFlag
+
+	// Friend requests received, oldest first
+	friendRequests []port.FriendRequest
+
+	// Users blocked by the user, oldest first
+	blocks []port.UserBlock
+
+	// Referral code of the user, and the users referred, oldest first
+	referralCode *port.ReferralCode
+	referrals    []port.Referral
+
+	// Accounts on external platforms, in the order they're linked
+	accounts []port.LinkedAccount
+
+	// Archived results of the windowed leaderboards, in the order they're archived
+	results []port.LeaderboardResult
+
+	// High scores of the friends as last seen on the friends leaderboard
+	views []port.FriendScoreView
+}
+
+type datastoreSlot struct {
+	slotID     string
+	name       string
+	gameState  *port.GameState // Nil while the slot is active
+	lastDevice string
+	playTime   int
+	createdAt  time.Time
+	updatedAt  time.Time
+}
+
+// ensureSlots creates the default slot of users without slots, holding the
+// current game state
+func (user *datastoreUser) ensureSlots() {
+	if len(user.slots) > 0 {
+		return
+	}
+
+	now := time.Now()
+	user.slots = []*datastoreSlot{{
+		slotID:    user.userID,
+		name:      port.DefaultSaveSlotName,
+		createdAt: now,
+		updatedAt: now,
+	}}
+	user.activeSlot = user.userID
+}
+
+// slot returns the slot of the user with the given ID, or its index if
+// the slot doesn't exist
+func (user *datastoreUser) slot(slotID string) (*datastoreSlot, int) {
+	user.ensureSlots()
+	for i, slot := range user.slots {
+		if slot.slotID == slotID {
+			return slot, i
+		}
+	}
+
+	return nil, -1
+}
+
+// saveSlot returns the value object of a slot, with its state upgraded
+func (user *datastoreUser) saveSlot(slot *datastoreSlot) (*port.SaveSlot, common.Error) {
+	result := &port.SaveSlot{
+		SlotID:     slot.slotID,
+		Name:       slot.name,
+		Active:     slot.slotID == user.activeSlot,
+		LastDevice: slot.lastDevice,
+		PlayTime:   slot.playTime,
+		CreatedAt:  slot.createdAt,
+		UpdatedAt:  slot.updatedAt,
+	}
+
+	state := slot.gameState
+	if result.Active {
+		state = user.gameState
+	}
+
+	result.State = *state.Copy()
+	if err := result.State.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// record adds the current game state of the user as a revision, and drops
+// the revisions no longer retained. The high score is indexed as well.
+func (db *datastoreSim) record(user *datastoreUser, change port.StateChange) {
+	db.scores.set(user.userID, user.gameState.Score())
+
+	now := time.Now()
+	user.revisions = append(user.revisions, port.StateRevision{
+		Revision:  user.gameState.Revision,
+		State:     *user.gameState.Copy(),
+		Author:    change.Author,
+		Source:    change.Source,
+		CreatedAt: now,
+	})
+
+	user.revisions = db.retention.retain(user.revisions, now)
 }
 
 // NewUser ...
@@ -39,16 +181,16 @@ func (db *datastoreSim) NewUser(id, name string) (*port.User, common.Error) {
 
 	// Create new user
 	newUser := &datastoreUser{
-		userID: id,
-		name:   name,
-		gameState: port.GameState{
-			GamesPlayed: 0,
-			Score:       0,
-		},
+		userID:    id,
+		name:      name,
+		gameState: port.NewGameState(0, 0),
 		friendIDs: []string{},
 	}
+	newUser.ensureSlots()
 
 	db.Users[id] = newUser
+	db.names.add(id, name)
+	db.scores.set(id, 0)
 
 	return &port.User{
 		UserID: newUser.userID,
@@ -75,13 +217,234 @@ func (db *datastoreSim) GetUsers() ([]*port.User, common.Error) {
 	return users, nil
 }
 
+// ListUsers ...
+func (db *datastoreSim) ListUsers(query *port.ListQuery) ([]*port.User, common.Error) {
+	keys := make([]port.ListKey, 0, len(db.Users))
+	for _, user := range db.Users {
+		if query.ViewerID != "" && user.hasBlocked(query.ViewerID) {
+			continue
+		}
+
+		keys = append(keys, port.ListKey{UserID: user.userID, Name: user.name})
+	}
+
+	users := make([]*port.User, 0, query.Limit)
+	for _, key := range listPage(keys, query) {
+		users = append(users, &port.User{UserID: key.UserID, Name: key.Name})
+	}
+
+	return users, nil
+}
+
 func (db *datastoreSim) UserExists(id string) (bool, common.Error) {
 	_, ok := db.Users[id]
 	return ok, nil
 }
 
+// SearchUsersByPrefix ...
+func (db *datastoreSim) SearchUsersByPrefix(prefix, viewerID string, offset, limit int) ([]*port.UserMatch, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return pageMatches(db.hideBlockers(db.names.prefix(prefix), viewerID), offset, limit), nil
+}
+
+// SearchUsersFuzzy ...
+func (db *datastoreSim) SearchUsersFuzzy(query, viewerID string, offset, limit int) ([]*port.UserMatch, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return pageMatches(db.hideBlockers(db.names.fuzzy(query), viewerID), offset, limit), nil
+}
+
+// hideBlockers leaves out the matches who blocked the viewer
+func (db *datastoreSim) hideBlockers(matches []*port.UserMatch, viewerID string) []*port.UserMatch {
+	if viewerID == "" {
+		return matches
+	}
+
+	visible := make([]*port.UserMatch, 0, len(matches))
+	for _, match := range matches {
+		if user, ok := db.Users[match.UserID]; ok && user.hasBlocked(viewerID) {
+			continue
+		}
+
+		visible = append(visible, match)
+	}
+
+	return visible
+}
+
+// hasBlocked tells if the user has blocked the other user
+func (user *datastoreUser) hasBlocked(otherID string) bool {
+	for _, block := range user.blocks {
+		if block.BlockedID == otherID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// blockedEither tells if either user has blocked the other, among the users
+// stored here
+func (db *datastoreSim) blockedEither(userID, otherID string) bool {
+	if user, ok := db.Users[userID]; ok && user.hasBlocked(otherID) {
+		return true
+	}
+
+	if other, ok := db.Users[otherID]; ok && other.hasBlocked(userID) {
+		return true
+	}
+
+	return false
+}
+
+// BlockUser removes the friendship and the pending friend requests of the
+// users, where they're stored here
+func (db *datastoreSim) BlockUser(userID, blockedID string) (bool, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if user.hasBlocked(blockedID) {
+		return false, nil
+	}
+
+	user.blocks = append(user.blocks, port.UserBlock{
+		UserID:    userID,
+		BlockedID: blockedID,
+		CreatedAt: time.Now(),
+	})
+
+	user.removeFriend(blockedID)
+	user.removeRequest(blockedID)
+	if blocked, ok := db.Users[blockedID]; ok {
+		blocked.removeFriend(userID)
+		blocked.removeRequest(userID)
+	}
+
+	return true, nil
+}
+
+// UnblockUser ...
+func (db *datastoreSim) UnblockUser(userID, blockedID string) (bool, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	for i, block := range user.blocks {
+		if block.BlockedID == blockedID {
+			user.blocks = append(user.blocks[:i:i], user.blocks[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GetBlockedUsers ...
+func (db *datastoreSim) GetBlockedUsers(userID string) ([]*port.UserBlock, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	blocks := make([]*port.UserBlock, 0, len(user.blocks))
+	for _, block := range user.blocks {
+		block := block
+		blocks = append(blocks, &block)
+	}
+
+	return blocks, nil
+}
+
+// IsBlocked doesn't require the users to be stored here, see blockedEither
+func (db *datastoreSim) IsBlocked(userID, otherID string) (bool, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.blockedEither(userID, otherID), nil
+}
+
+// FlagUser ...
+func (db *datastoreSim) FlagUser(userID, reason string) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	now := time.Now()
+	if user.flag == nil {
+		user.flag = &port.UserFlag{UserID: userID, FirstFlaggedAt: now}
+	}
+
+	user.flag.Reason = reason
+	user.flag.Count++
+	user.flag.LastFlaggedAt = now
+	return nil
+}
+
+type flagByLastFlagged []*port.UserFlag
+
+func (a flagByLastFlagged) Len() int      { return len(a) }
+func (a flagByLastFlagged) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a flagByLastFlagged) Less(i, j int) bool {
+	if !a[i].LastFlaggedAt.Equal(a[j].LastFlaggedAt) {
+		return a[i].LastFlaggedAt.After(a[j].LastFlaggedAt)
+	}
+	return a[i].UserID < a[j].UserID
+}
+
+// GetFlaggedUsers ...
+func (db *datastoreSim) GetFlaggedUsers() ([]*port.UserFlag, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	flags := make([]*port.UserFlag, 0)
+	for _, user := range db.Users {
+		if user.flag != nil {
+			flag := *user.flag
+			flags = append(flags, &flag)
+		}
+	}
+
+	// Most recently flagged first
+	sort.Sort(flagByLastFlagged(flags))
+
+	return flags, nil
+}
+
+// ClearUserFlag ...
+func (db *datastoreSim) ClearUserFlag(userID string) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	user.flag = nil
+	return nil
+}
+
 // UpdateGameState ...
-func (db *datastoreSim) UpdateGameState(userID string, gamesPlayed, score int) common.Error {
+func (db *datastoreSim) UpdateGameState(userID string, state *port.GameState, change port.StateChange) common.Error {
 	db.Lock()
 	defer db.Unlock()
 
@@ -90,8 +453,11 @@ func (db *datastoreSim) UpdateGameState(userID string, gamesPlayed, score int) c
 		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
 	}
 
-	user.gameState.GamesPlayed = gamesPlayed
-	user.gameState.Score = score
+	updated := state.Copy()
+	updated.KeepServerFields(user.gameState)
+	updated.Revision = user.gameState.Revision + 1
+	user.gameState = updated
+	db.record(user, change)
 	return nil
 }
 
@@ -102,58 +468,1583 @@ func (db *datastoreSim) GetGameState(userID string) (*port.GameState, common.Err
 		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
 	}
 
-	return &port.GameState{
-		GamesPlayed: user.gameState.GamesPlayed,
-		Score:       user.gameState.Score,
-	}, nil
+	// Stored documents are upgraded on read, and kept as they are
+	state := user.gameState.Copy()
+	if err := state.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	return state, nil
 }
 
-// UpdateFriends ...
-func (db *datastoreSim) UpdateFriends(userID string, friends []string) common.Error {
+// SubmitScore ...
+func (db *datastoreSim) SubmitScore(userID string, score int, change port.StateChange) (*port.ScoreResult, common.Error) {
 	db.Lock()
 	defer db.Unlock()
 
 	user, ok := db.Users[userID]
 	if !ok {
-		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
 	}
 
-	user.friendIDs = friends
-	return nil
+	result := &port.ScoreResult{Replayed: change.Key != "" && hasRunKey(user.runs, change.Key)}
+	if !result.Replayed {
+		result.PersonalBest = score > user.gameState.Score()
+
+		user.gameState.SetGamesPlayed(user.gameState.GamesPlayed() + 1)
+		user.gameState.Revision++
+		if result.PersonalBest {
+			user.gameState.SetScore(score)
+		}
+
+		user.runs = append(user.runs, port.ScoreRun{Score: score, SubmittedAt: time.Now(), Key: change.Key})
+		db.record(user, change)
+	}
+
+	state := user.gameState.Copy()
+	if err := state.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	result.GameState = *state
+	return result, nil
 }
 
-type friendByUserID []*port.Friend
+// hasRunKey tells whether a run was submitted with the key
+func hasRunKey(runs []port.ScoreRun, key string) bool {
+	for _, run := range runs {
+		if run.Key == key {
+			return true
+		}
+	}
 
-func (a friendByUserID) Len() int           { return len(a) }
-func (a friendByUserID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a friendByUserID) Less(i, j int) bool { return a[i].UserID < a[j].UserID }
+	return false
+}
+
+// ModifyGameState ...
+func (db *datastoreSim) ModifyGameState(userID string, change port.StateChange, modify port.GameStateModifier) (*port.GameState, common.Error) {
+	db.Lock()
+	defer db.Unlock()
 
-// GetFriends ...
-func (db *datastoreSim) GetFriends(userID string) ([]*port.Friend, common.Error) {
 	user, ok := db.Users[userID]
 	if !ok {
 		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
 	}
 
-	friends := make([]*port.Friend, 0, len(user.friendIDs))
-	for _, friendID := range user.friendIDs {
-		friend, ok := db.Users[friendID]
-		if !ok {
-			// return nil, errors.New("Invalid userID")
-			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID in friends")
+	state := user.gameState.Copy()
+	if err := state.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	if err := modify(state); err != nil {
+		return nil, err
+	}
+
+	state.KeepServerFields(user.gameState)
+	state.Revision = user.gameState.Revision + 1
+	user.gameState = state.Copy()
+	db.record(user, change)
+	return state, nil
+}
+
+// CompareAndSwapGameState ...
+func (db *datastoreSim) CompareAndSwapGameState(userID string, revision int, state *port.GameState, change port.StateChange) (int, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return 0, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if user.gameState.Revision != revision {
+		return 0, common.NewError(port.ErrConflict, "Game state revision doesn't match").
+			WithField("revision", user.gameState.Revision)
+	}
+
+	swapped := state.Copy()
+	swapped.KeepServerFields(user.gameState)
+	swapped.Revision = revision + 1
+	user.gameState = swapped
+	db.record(user, change)
+	return user.gameState.Revision, nil
+}
+
+// GetGameStateRevisions ...
+func (db *datastoreSim) GetGameStateRevisions(userID string) ([]*port.StateRevision, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	// Newest first
+	revisions := make([]*port.StateRevision, 0, len(user.revisions))
+	for i := len(user.revisions) - 1; i >= 0; i-- {
+		revisions = append(revisions, copyRevision(&user.revisions[i]))
+	}
+
+	return revisions, nil
+}
+
+// GetGameStateRevision ...
+func (db *datastoreSim) GetGameStateRevision(userID string, revision int) (*port.StateRevision, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	for i := range user.revisions {
+		if user.revisions[i].Revision == revision {
+			return copyRevision(&user.revisions[i]), nil
 		}
+	}
 
-		friends = append(friends, &port.Friend{
-			UserID:    friend.userID,
-			Name:      friend.name,
-			HighScore: friend.gameState.Score,
-		})
+	return nil, common.NewError(port.ErrInvalidKey, "Invalid revision")
+}
+
+// NewSaveSlot ...
+func (db *datastoreSim) NewSaveSlot(userID string, slot *port.SaveSlot, maxSlots int) (*port.SaveSlot, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
 	}
 
-	// Due to the randomization of maps, we'll have to sort it
-	sort.Sort(friendByUserID(friends))
+	user.ensureSlots()
+	for _, existing := range user.slots {
+		if existing.slotID == slot.SlotID || existing.name == slot.Name {
+			return nil, common.NewError(port.ErrEntryExists, "Save slot already exists")
+		}
+	}
 
-	return friends, nil
+	if len(user.slots) >= maxSlots {
+		return nil, common.NewError(port.ErrLimitReached, "Too many save slots").
+			WithField("max", maxSlots)
+	}
+
+	now := time.Now()
+	created := &datastoreSlot{
+		slotID:     slot.SlotID,
+		name:       slot.Name,
+		gameState:  slot.State.Copy(),
+		lastDevice: slot.LastDevice,
+		playTime:   slot.PlayTime,
+		createdAt:  now,
+		updatedAt:  now,
+	}
+	created.gameState.Revision = 0
+
+	user.slots = append(user.slots, created)
+	return user.saveSlot(created)
+}
+
+// GetSaveSlots ...
+func (db *datastoreSim) GetSaveSlots(userID string) ([]*port.SaveSlot, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	user.ensureSlots()
+	slots := make([]*port.SaveSlot, 0, len(user.slots))
+	for _, slot := range user.slots {
+		result, err := user.saveSlot(slot)
+		if err != nil {
+			return nil, err
+		}
+
+		slots = append(slots, result)
+	}
+
+	return slots, nil
+}
+
+// GetSaveSlot ...
+func (db *datastoreSim) GetSaveSlot(userID, slotID string) (*port.SaveSlot, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	slot, _ := user.slot(slotID)
+	if slot == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid SlotID")
+	}
+
+	return user.saveSlot(slot)
+}
+
+// UpdateSaveSlot ...
+func (db *datastoreSim) UpdateSaveSlot(userID, slotID string, update *port.SaveSlotUpdate) (*port.SaveSlot, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	slot, _ := user.slot(slotID)
+	if slot == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid SlotID")
+	}
+
+	if update.Name != nil {
+		for _, existing := range user.slots {
+			if existing != slot && existing.name == *update.Name {
+				return nil, common.NewError(port.ErrEntryExists, "Save slot name already exists")
+			}
+		}
+
+		slot.name = *update.Name
+	}
+
+	if update.LastDevice != nil {
+		slot.lastDevice = *update.LastDevice
+	}
+
+	if update.PlayTime != nil {
+		slot.playTime = *update.PlayTime
+	}
+
+	slot.updatedAt = time.Now()
+	return user.saveSlot(slot)
+}
+
+// DeleteSaveSlot ...
+func (db *datastoreSim) DeleteSaveSlot(userID, slotID string) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	slot, i := user.slot(slotID)
+	if slot == nil {
+		return common.NewError(port.ErrInvalidKey, "Invalid SlotID")
+	}
+
+	if slot.slotID == user.activeSlot {
+		return common.NewError(port.ErrEntryInUse, "Save slot is active")
+	}
+
+	user.slots = append(user.slots[:i], user.slots[i+1:]...)
+	return nil
+}
+
+// ActivateSaveSlot ...
+func (db *datastoreSim) ActivateSaveSlot(userID, slotID string, change port.StateChange) (*port.GameState, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	target, _ := user.slot(slotID)
+	if target == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid SlotID")
+	}
+
+	// The state of the previously active slot is kept in the slot, and the
+	// state of the activated slot becomes a new revision of the user. Games
+	// played and the best score are the users, whichever slot is active.
+	if target.slotID != user.activeSlot {
+		now := time.Now()
+		active, _ := user.slot(user.activeSlot)
+		active.gameState = user.gameState.Copy()
+		active.gameState.Revision = 0
+		active.updatedAt = now
+
+		state := target.gameState
+		state.KeepServerFields(user.gameState)
+		state.Revision = user.gameState.Revision + 1
+		user.gameState = state
+		user.activeSlot = target.slotID
+		target.gameState = nil
+		target.updatedAt = now
+
+		db.record(user, change)
+	}
+
+	state := user.gameState.Copy()
+	if err := state.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// NewGame ...
+func (db *datastoreSim) NewGame(gameID string, userIDs []string) (*port.Game, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if _, ok := db.Games[gameID]; ok {
+		return nil, common.NewError(port.ErrEntryExists, "Game already exists")
+	}
+
+	game := &port.Game{
+		GameID:       gameID,
+		Participants: make([]*port.Participant, 0, len(userIDs)),
+	}
+
+	for _, userID := range userIDs {
+		game.Participants = append(game.Participants, &port.Participant{
+			UserID: userID,
+			Status: port.GamePlaying,
+		})
+	}
+
+	setGameStatus(game)
+	db.Games[gameID] = game
+
+	return copyGame(game), nil
+}
+
+// GetGame returns a stored game, or the personal game of a user
+func (db *datastoreSim) GetGame(gameID string) (*port.Game, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	if game, ok := db.Games[gameID]; ok {
+		return copyGame(game), nil
+	}
+
+	user, ok := db.Users[gameID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid GameID")
+	}
+
+	// Stored documents are upgraded on read, and kept as they are
+	state := user.gameState.Copy()
+	if err := state.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	return personalGame(gameID, state), nil
+}
+
+// SetGameResult ...
+func (db *datastoreSim) SetGameResult(gameID, userID string, score int) (*port.Game, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	game, ok := db.Games[gameID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid GameID")
+	}
+
+	for _, p := range game.Participants {
+		if p.UserID != userID {
+			continue
+		}
+
+		if p.Status == port.GameFinished {
+			return nil, common.NewError(port.ErrEntryExists, "Result already submitted")
+		}
+
+		p.Status = port.GameFinished
+		p.Score = score
+		setGameStatus(game)
+
+		return copyGame(game), nil
+	}
+
+	return nil, common.NewError(port.ErrInvalidKey, "User isn't participating in the game")
+}
+
+// UpdateFriends replaces the friend list, and the user in the lists of the
+// added and removed friends
+func (db *datastoreSim) UpdateFriends(userID string, friends []string) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	for _, friendID := range friends {
+		if db.blockedEither(userID, friendID) {
+			return common.NewError(port.ErrBlocked, "User is blocked")
+		}
+	}
+
+	// The friendship is replaced for both users
+	kept := make(map[string]bool, len(friends))
+	for _, friendID := range friends {
+		kept[friendID] = true
+		if friend, ok := db.Users[friendID]; ok && friendID != userID {
+			friend.addFriend(userID)
+		}
+	}
+
+	for _, friendID := range user.friendIDs {
+		if friend, ok := db.Users[friendID]; ok && !kept[friendID] {
+			friend.removeFriend(userID)
+		}
+	}
+
+	user.friendIDs = friends
+	return nil
+}
+
+// AddFriend adds the friendship to both users
+func (db *datastoreSim) AddFriend(userID, friendID string) (bool, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if db.blockedEither(userID, friendID) {
+		return false, common.NewError(port.ErrBlocked, "User is blocked")
+	}
+
+	if friend, ok := db.Users[friendID]; ok {
+		friend.addFriend(userID)
+	}
+
+	return user.addFriend(friendID), nil
+}
+
+// addFriend appends the friend, unless already present
+func (user *datastoreUser) addFriend(friendID string) bool {
+	for _, id := range user.friendIDs {
+		if id == friendID {
+			return false
+		}
+	}
+
+	// The list may be shared with the caller of UpdateFriends
+	friendIDs := make([]string, len(user.friendIDs), len(user.friendIDs)+1)
+	copy(friendIDs, user.friendIDs)
+	user.friendIDs = append(friendIDs, friendID)
+	return true
+}
+
+// RemoveFriend removes the friendship from both users
+func (db *datastoreSim) RemoveFriend(userID, friendID string) (bool, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if friend, ok := db.Users[friendID]; ok {
+		friend.removeFriend(userID)
+	}
+
+	return user.removeFriend(friendID), nil
+}
+
+// removeFriend removes the friend, if present
+func (user *datastoreUser) removeFriend(friendID string) bool {
+	friendIDs := make([]string, 0, len(user.friendIDs))
+	for _, id := range user.friendIDs {
+		if id != friendID {
+			friendIDs = append(friendIDs, id)
+		}
+	}
+
+	removed := len(friendIDs) < len(user.friendIDs)
+	user.friendIDs = friendIDs
+	return removed
+}
+
+type friendByUserID []*port.Friend
+
+func (a friendByUserID) Len() int           { return len(a) }
+func (a friendByUserID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a friendByUserID) Less(i, j int) bool { return a[i].UserID < a[j].UserID }
+
+// GetFriends ...
+func (db *datastoreSim) GetFriends(userID string) ([]*port.Friend, common.Error) {
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	friends := make([]*port.Friend, 0, len(user.friendIDs))
+	for _, friendID := range user.friendIDs {
+		friend, ok := db.Users[friendID]
+		if !ok {
+			// return nil, errors.New("Invalid userID")
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID in friends")
+		}
+
+		if friend.hasBlocked(userID) {
+			continue
+		}
+
+		friends = append(friends, friend.friend())
+	}
+
+	// Due to the randomization of maps, we'll have to sort it
+	sort.Sort(friendByUserID(friends))
+
+	return friends, nil
+}
+
+// ListFriends ...
+func (db *datastoreSim) ListFriends(userID string, query *port.ListQuery) ([]*port.Friend, common.Error) {
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	keys := make([]port.ListKey, 0, len(user.friendIDs))
+	for _, friendID := range user.friendIDs {
+		friend, ok := db.Users[friendID]
+		if !ok || friend.hasBlocked(userID) {
+			continue
+		}
+
+		keys = append(keys, port.ListKey{
+			UserID: friend.userID,
+			Name:   friend.name,
+			Score:  friend.gameState.Score(),
+		})
+	}
+
+	friends := make([]*port.Friend, 0, query.Limit)
+	for _, key := range listPage(keys, query) {
+		friends = append(friends, db.Users[key.UserID].friend())
+	}
+
+	return friends, nil
+}
+
+// GetFriendIDs ...
+func (db *datastoreSim) GetFriendIDs(userID string) ([]string, common.Error) {
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	friendIDs := make([]string, len(user.friendIDs))
+	copy(friendIDs, user.friendIDs)
+
+	return friendIDs, nil
+}
+
+// GetFriendsByID ...
+func (db *datastoreSim) GetFriendsByID(userIDs []string, viewerID string) ([]*port.Friend, common.Error) {
+	friends := make([]*port.Friend, 0, len(userIDs))
+	for _, userID := range userIDs {
+		user, ok := db.Users[userID]
+		if !ok || (viewerID != "" && user.hasBlocked(viewerID)) {
+			continue
+		}
+
+		friends = append(friends, user.friend())
+	}
+
+	sort.Sort(friendByUserID(friends))
+
+	return friends, nil
+}
+
+// GetFriendSuggestions walks the friends of the friends of the user, counting
+// the mutual friends of each
+func (db *datastoreSim) GetFriendSuggestions(userID string, limit int) ([]*port.FriendSuggestion, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	friends := make(map[string]bool, len(user.friendIDs))
+	for _, friendID := range user.friendIDs {
+		friends[friendID] = true
+	}
+
+	mutual := make(map[string]int)
+	for _, friendID := range user.friendIDs {
+		friend, ok := db.Users[friendID]
+		if !ok || friend.hasBlocked(userID) {
+			continue
+		}
+
+		for _, candidateID := range friend.friendIDs {
+			if candidateID != userID && !friends[candidateID] {
+				mutual[candidateID]++
+			}
+		}
+	}
+
+	suggestions := make([]*port.FriendSuggestion, 0, len(mutual))
+	for candidateID, count := range mutual {
+		candidate, ok := db.Users[candidateID]
+		if !ok || db.blockedEither(userID, candidateID) {
+			continue
+		}
+
+		suggestions = append(suggestions, &port.FriendSuggestion{
+			Friend:        *candidate.friend(),
+			MutualFriends: count,
+		})
+	}
+
+	sort.Sort(suggestionByRank(suggestions))
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+
+	return suggestions, nil
+}
+
+// ViewFriendScores ...
+func (db *datastoreSim) ViewFriendScores(userID string, views []*port.FriendScoreView) ([]*port.FriendScoreView, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	previous := make([]*port.FriendScoreView, 0, len(user.views))
+	for i := range user.views {
+		view := user.views[i]
+		previous = append(previous, &view)
+	}
+
+	user.views = make([]port.FriendScoreView, 0, len(views))
+	for _, view := range views {
+		user.views = append(user.views, *view)
+	}
+
+	return previous, nil
+}
+
+// friend returns the user as a friend of other users
+func (user *datastoreUser) friend() *port.Friend {
+	friend := &port.Friend{
+		UserID:    user.userID,
+		Name:      user.name,
+		HighScore: user.gameState.Score(),
+	}
+
+	if n := len(user.revisions); n > 0 {
+		lastActiveAt := user.revisions[n-1].CreatedAt
+		friend.LastActiveAt = &lastActiveAt
+	}
+
+	return friend
+}
+
+type suggestionByRank []*port.FriendSuggestion
+
+func (a suggestionByRank) Len() int      { return len(a) }
+func (a suggestionByRank) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a suggestionByRank) Less(i, j int) bool {
+	if a[i].MutualFriends != a[j].MutualFriends {
+		return a[i].MutualFriends > a[j].MutualFriends
+	}
+
+	x, y := a[i].LastActiveAt, a[j].LastActiveAt
+	switch {
+	case x != nil && y == nil:
+		return true
+	case x == nil && y != nil:
+		return false
+	case x != nil && !x.Equal(*y):
+		return x.After(*y)
+	}
+
+	return a[i].UserID < a[j].UserID
+}
+
+// pendingRequests drops the expired friend requests of the user, and
+// returns the rest
+func (user *datastoreUser) pendingRequests() []port.FriendRequest {
+	now := time.Now()
+	pending := make([]port.FriendRequest, 0, len(user.friendRequests))
+	for _, request := range user.friendRequests {
+		if request.ExpiresAt.After(now) {
+			pending = append(pending, request)
+		}
+	}
+
+	user.friendRequests = pending
+	return pending
+}
+
+// removeRequest removes the pending friend request from the given user,
+// telling if there was one
+func (user *datastoreUser) removeRequest(fromID string) bool {
+	for i, request := range user.pendingRequests() {
+		if request.FromID == fromID {
+			user.friendRequests = append(user.friendRequests[:i], user.friendRequests[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewFriendRequest ...
+func (db *datastoreSim) NewFriendRequest(request *port.FriendRequest, maxPending int) (*port.FriendRequest, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[request.ToID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if db.blockedEither(request.FromID, request.ToID) {
+		return nil, common.NewError(port.ErrBlocked, "User is blocked")
+	}
+
+	// Requests between the users don't count towards the limits
+	sent := 0
+	for _, other := range db.Users {
+		for _, pending := range other.pendingRequests() {
+			if pending.FromID == request.FromID && pending.ToID != request.ToID {
+				sent++
+			}
+		}
+	}
+
+	if sent >= maxPending {
+		return nil, common.NewError(port.ErrLimitReached, "Too many pending friend requests sent").
+			WithField("max", maxPending)
+	}
+
+	received := 0
+	for _, pending := range user.pendingRequests() {
+		if pending.FromID != request.FromID {
+			received++
+		}
+	}
+
+	if received >= maxPending {
+		return nil, common.NewError(port.ErrLimitReached, "Too many pending friend requests received by friend").
+			WithField("max", maxPending)
+	}
+
+	for _, pending := range user.pendingRequests() {
+		if pending.FromID == request.FromID {
+			return nil, common.NewError(port.ErrEntryExists, "Friend request already pending")
+		}
+	}
+
+	created := *request
+	created.CreatedAt = time.Now()
+	user.friendRequests = append(user.friendRequests, created)
+
+	return &created, nil
+}
+
+// GetFriendRequests ...
+func (db *datastoreSim) GetFriendRequests(userID string) ([]*port.FriendRequest, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	requests := make([]*port.FriendRequest, 0, len(user.friendRequests))
+	for _, request := range user.pendingRequests() {
+		request := request
+		requests = append(requests, &request)
+	}
+
+	return requests, nil
+}
+
+type requestByCreated []*port.FriendRequest
+
+func (a requestByCreated) Len() int      { return len(a) }
+func (a requestByCreated) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a requestByCreated) Less(i, j int) bool {
+	if !a[i].CreatedAt.Equal(a[j].CreatedAt) {
+		return a[i].CreatedAt.Before(a[j].CreatedAt)
+	}
+	return a[i].ToID < a[j].ToID
+}
+
+// GetSentFriendRequests doesn't require the sender to be stored here, as
+// requests are stored with their recipient
+func (db *datastoreSim) GetSentFriendRequests(userID string) ([]*port.FriendRequest, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	requests := make([]*port.FriendRequest, 0)
+	for _, user := range db.Users {
+		for _, request := range user.pendingRequests() {
+			if request.FromID == userID {
+				request := request
+				requests = append(requests, &request)
+			}
+		}
+	}
+
+	// Oldest first
+	sort.Sort(requestByCreated(requests))
+
+	return requests, nil
+}
+
+// AcceptFriendRequest ...
+func (db *datastoreSim) AcceptFriendRequest(fromID, toID string) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[toID]
+	if !ok || !user.removeRequest(fromID) {
+		return common.NewError(port.ErrInvalidKey, "No pending friend request")
+	}
+
+	user.addFriend(fromID)
+
+	// The sender may be stored elsewhere, see port.Datastore
+	if sender, ok := db.Users[fromID]; ok {
+		sender.addFriend(toID)
+		sender.removeRequest(toID)
+	}
+
+	return nil
+}
+
+// DeleteFriendRequest ...
+func (db *datastoreSim) DeleteFriendRequest(fromID, toID string) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[toID]
+	if !ok || !user.removeRequest(fromID) {
+		return common.NewError(port.ErrInvalidKey, "No pending friend request")
+	}
+
+	return nil
+}
+
+// NewReferralCode replaces the code of the user, resetting the redemptions
+func (db *datastoreSim) NewReferralCode(code *port.ReferralCode) (*port.ReferralCode, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[code.UserID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if owner := db.referrer(code.Code); owner != nil && owner != user {
+		return nil, common.NewError(port.ErrEntryExists, "Referral code already exists")
+	}
+
+	created := *code
+	created.Redemptions = 0
+	created.CreatedAt = time.Now()
+	user.referralCode = &created
+
+	copied := created
+	return &copied, nil
+}
+
+// referrer returns the user with the given referral code
+func (db *datastoreSim) referrer(code string) *datastoreUser {
+	for _, user := range db.Users {
+		if user.referralCode != nil && user.referralCode.Code == code {
+			return user
+		}
+	}
+
+	return nil
+}
+
+// GetReferralCode ...
+func (db *datastoreSim) GetReferralCode(userID string) (*port.ReferralCode, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok || user.referralCode == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "No referral code")
+	}
+
+	code := *user.referralCode
+	return &code, nil
+}
+
+// LookupReferralCode ...
+func (db *datastoreSim) LookupReferralCode(code string) (*port.ReferralCode, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	referrer := db.referrer(code)
+	if referrer == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid referral code")
+	}
+
+	referralCode := *referrer.referralCode
+	return &referralCode, nil
+}
+
+// RedeemReferralCode counts the redemption and records the referral of the
+// user, if the code is still available
+func (db *datastoreSim) RedeemReferralCode(code, userID string) (*port.ReferralCode, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	referrer := db.referrer(code)
+	if referrer == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid referral code")
+	}
+
+	referralCode := referrer.referralCode
+	now := time.Now()
+	if !now.Before(referralCode.ExpiresAt) {
+		return nil, common.NewError(port.ErrUnavailable, "Referral code expired")
+	}
+
+	if referralCode.Redemptions >= referralCode.MaxRedemptions {
+		return nil, common.NewError(port.ErrUnavailable, "Referral code used up")
+	}
+
+	for _, referral := range referrer.referrals {
+		if referral.UserID == userID {
+			return nil, common.NewError(port.ErrEntryExists, "User already referred")
+		}
+	}
+
+	referralCode.Redemptions++
+	referrer.referrals = append(referrer.referrals, port.Referral{
+		ReferrerID: referrer.userID,
+		UserID:     userID,
+		Code:       code,
+		CreatedAt:  now,
+	})
+
+	redeemed := *referralCode
+	return &redeemed, nil
+}
+
+// GetReferrals ...
+func (db *datastoreSim) GetReferrals(userID string) ([]*port.Referral, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	referrals := make([]*port.Referral, 0, len(user.referrals))
+	for _, referral := range user.referrals {
+		referral := referral
+		referrals = append(referrals, &referral)
+	}
+
+	return referrals, nil
+}
+
+// LinkAccount replaces the account the user linked of the same provider
+func (db *datastoreSim) LinkAccount(account *port.LinkedAccount) (*port.LinkedAccount, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[account.UserID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	for _, other := range db.Users {
+		if other == user {
+			continue
+		}
+
+		for _, linked := range other.accounts {
+			if linked.Provider == account.Provider && linked.ExternalID == account.ExternalID {
+				return nil, common.NewError(port.ErrEntryExists, "Account linked to another user")
+			}
+		}
+	}
+
+	linked := *account
+	linked.LinkedAt = time.Now()
+
+	accounts := make([]port.LinkedAccount, 0, len(user.accounts)+1)
+	for _, existing := range user.accounts {
+		if existing.Provider != account.Provider {
+			accounts = append(accounts, existing)
+		}
+	}
+	user.accounts = append(accounts, linked)
+
+	return &linked, nil
+}
+
+// UnlinkAccount ...
+func (db *datastoreSim) UnlinkAccount(userID, provider string) (bool, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	accounts := make([]port.LinkedAccount, 0, len(user.accounts))
+	for _, account := range user.accounts {
+		if account.Provider != provider {
+			accounts = append(accounts, account)
+		}
+	}
+
+	unlinked := len(accounts) < len(user.accounts)
+	user.accounts = accounts
+	return unlinked, nil
+}
+
+type accountByProvider []*port.LinkedAccount
+
+func (a accountByProvider) Len() int           { return len(a) }
+func (a accountByProvider) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a accountByProvider) Less(i, j int) bool { return a[i].Provider < a[j].Provider }
+
+// GetLinkedAccounts ...
+func (db *datastoreSim) GetLinkedAccounts(userID string) ([]*port.LinkedAccount, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	accounts := make([]*port.LinkedAccount, 0, len(user.accounts))
+	for _, account := range user.accounts {
+		account := account
+		accounts = append(accounts, &account)
+	}
+
+	sort.Sort(accountByProvider(accounts))
+
+	return accounts, nil
+}
+
+type accountByUserID []*port.LinkedAccount
+
+func (a accountByUserID) Len() int           { return len(a) }
+func (a accountByUserID) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a accountByUserID) Less(i, j int) bool { return a[i].UserID < a[j].UserID }
+
+// FindLinkedAccounts returns the accounts of the provider linked by the users
+// stored here, ordered by UserID
+func (db *datastoreSim) FindLinkedAccounts(provider string, externalIDs []string) ([]*port.LinkedAccount, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	wanted := make(map[string]bool, len(externalIDs))
+	for _, externalID := range externalIDs {
+		wanted[externalID] = true
+	}
+
+	accounts := make([]*port.LinkedAccount, 0)
+	for _, user := range db.Users {
+		for _, account := range user.accounts {
+			if account.Provider == provider && wanted[account.ExternalID] {
+				account := account
+				accounts = append(accounts, &account)
+			}
+		}
+	}
+
+	sort.Sort(accountByUserID(accounts))
+
+	return accounts, nil
+}
+
+// GetLeaderboard ...
+func (db *datastoreSim) GetLeaderboard(offset, limit int, viewerID string) ([]*port.LeaderboardEntry, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	entries := db.indexedEntries(db.scores.list.slice(offset, limit), offset)
+	return db.hideRankedBlockers(entries, viewerID), nil
+}
+
+// GetLeaderboardAround returns the entries within the radius of the user.
+// Users who blocked the viewer are unknown to the viewer.
+func (db *datastoreSim) GetLeaderboardAround(userID string, radius int, viewerID string) ([]*port.LeaderboardEntry, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	key, ok := db.scores.key(userID)
+	if !ok || (viewerID != "" && db.Users[userID].hasBlocked(viewerID)) {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	start := db.scores.list.ahead(key) - radius
+	keys := db.scores.list.slice(start, 2*radius+1)
+	if start < 0 {
+		start = 0
+	}
+
+	return db.hideRankedBlockers(db.indexedEntries(keys, start), viewerID), nil
+}
+
+// GetLeaderboardSize ...
+func (db *datastoreSim) GetLeaderboardSize() (int, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.scores.list.length, nil
+}
+
+// GetLeaderboardRange ...
+func (db *datastoreSim) GetLeaderboardRange(key port.LeaderboardKey, before, after int) ([]*port.LeaderboardEntry, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	start := db.scores.list.ahead(key) - before
+	keys := db.scores.list.slice(start, before+after)
+	if start < 0 {
+		start = 0
+	}
+
+	return db.indexedEntries(keys, start), nil
+}
+
+// CountLeaderboardAhead ...
+func (db *datastoreSim) CountLeaderboardAhead(key port.LeaderboardKey) (int, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.scores.list.ahead(key), nil
+}
+
+// GetScoreDistribution ...
+func (db *datastoreSim) GetScoreDistribution() (*port.ScoreDistribution, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return db.scores.sketch.distribution(), nil
+}
+
+// hideRankedBlockers leaves out the ranked entries of users who blocked the viewer
+func (db *datastoreSim) hideRankedBlockers(entries []*port.LeaderboardEntry, viewerID string) []*port.LeaderboardEntry {
+	if viewerID == "" {
+		return entries
+	}
+
+	visible := make([]*port.LeaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		if user, ok := db.Users[entry.UserID]; ok && user.hasBlocked(viewerID) {
+			continue
+		}
+
+		visible = append(visible, entry)
+	}
+
+	return visible
+}
+
+// indexedEntries ranks the consecutive keys of the score index, starting at
+// the position
+func (db *datastoreSim) indexedEntries(keys []port.LeaderboardKey, position int) []*port.LeaderboardEntry {
+	entries := db.leaderboardEntries(keys)
+	if len(entries) > 0 {
+		higher := db.scores.list.ahead(port.LeaderboardKey{Score: keys[0].Score})
+		rankEntries(entries, position, higher)
+	}
+
+	return entries
+}
+
+// leaderboardEntries names the users of the keys, without ranks
+func (db *datastoreSim) leaderboardEntries(keys []port.LeaderboardKey) []*port.LeaderboardEntry {
+	entries := make([]*port.LeaderboardEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, &port.LeaderboardEntry{
+			LeaderboardKey: key,
+			Name:           db.Users[key.UserID].name,
+		})
+	}
+
+	return entries
+}
+
+// GetPeriodLeaderboard ranks the users by their best run within the period
+func (db *datastoreSim) GetPeriodLeaderboard(period *port.LeaderboardPeriod, offset, limit int) ([]*port.LeaderboardEntry, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	keys := db.periodKeys(period)
+	page := db.leaderboardEntries(pageKeys(keys, offset, limit))
+	if len(page) > 0 {
+		higher := sort.Search(len(keys), func(i int) bool {
+			return keys[i].Score <= page[0].Score
+		})
+
+		rankEntries(page, offset, higher)
+	}
+
+	return page, nil
+}
+
+// GetPeriodLeaderboardSize ...
+func (db *datastoreSim) GetPeriodLeaderboardSize(period *port.LeaderboardPeriod) (int, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	return len(db.periodKeys(period)), nil
+}
+
+// periodKeys returns the best run within the period of every user who
+// submitted one, in leaderboard order
+func (db *datastoreSim) periodKeys(period *port.LeaderboardPeriod) []port.LeaderboardKey {
+	keys := make([]port.LeaderboardKey, 0)
+	for _, user := range db.Users {
+		best, found := 0, false
+		for _, run := range user.runs {
+			if run.SubmittedAt.Before(period.Start) || !run.SubmittedAt.Before(period.End) {
+				continue
+			}
+
+			if !found || run.Score > best {
+				best, found = run.Score, true
+			}
+		}
+
+		if found {
+			keys = append(keys, port.LeaderboardKey{Score: best, UserID: user.userID})
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return leaderboardKeyLess(keys[i], keys[j])
+	})
+
+	return keys
+}
+
+// ArchiveLeaderboard replaces the results of the users in the period, users
+// not stored here are skipped
+func (db *datastoreSim) ArchiveLeaderboard(period *port.LeaderboardPeriod, entries []*port.LeaderboardEntry) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	for _, entry := range entries {
+		user, ok := db.Users[entry.UserID]
+		if !ok {
+			continue
+		}
+
+		result := port.LeaderboardResult{
+			Window: period.Window,
+			Period: period.Key,
+			Score:  entry.Score,
+			Rank:   entry.Rank,
+		}
+
+		if i := user.result(period); i >= 0 {
+			user.results[i] = result
+		} else {
+			user.results = append(user.results, result)
+		}
+	}
+
+	return nil
+}
+
+// CompleteLeaderboardArchive ...
+func (db *datastoreSim) CompleteLeaderboardArchive(archive *port.LeaderboardArchive) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	stored := *archive
+	if stored.ArchivedAt.IsZero() {
+		stored.ArchivedAt = time.Now()
+	}
+
+	db.archives[archive.Window+"/"+archive.Period] = &stored
+	return nil
+}
+
+// GetLeaderboardArchive ...
+func (db *datastoreSim) GetLeaderboardArchive(period *port.LeaderboardPeriod) (*port.LeaderboardArchive, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	archive, ok := db.archives[period.Window+"/"+period.Key]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Period not archived")
+	}
+
+	found := *archive
+	return &found, nil
+}
+
+// GetArchivedLeaderboard ...
+func (db *datastoreSim) GetArchivedLeaderboard(period *port.LeaderboardPeriod, offset, limit int) ([]*port.LeaderboardEntry, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	ranks := make(map[string]int)
+	keys := make([]port.LeaderboardKey, 0)
+	for _, user := range db.Users {
+		if i := user.result(period); i >= 0 {
+			keys = append(keys, port.LeaderboardKey{Score: user.results[i].Score, UserID: user.userID})
+			ranks[user.userID] = user.results[i].Rank
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return leaderboardKeyLess(keys[i], keys[j])
+	})
+
+	entries := db.leaderboardEntries(pageKeys(keys, offset, limit))
+	for _, entry := range entries {
+		entry.Rank = ranks[entry.UserID]
+	}
+
+	return entries, nil
+}
+
+// result returns the index of the archived result of the period, -1 if
+// there's none
+func (user *datastoreUser) result(period *port.LeaderboardPeriod) int {
+	for i, result := range user.results {
+		if result.Window == period.Window && result.Period == period.Key {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ExportUser ...
+func (db *datastoreSim) ExportUser(userID string) (*port.UserRecord, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	user, ok := db.Users[userID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	record := &port.UserRecord{
+		User:      port.User{UserID: user.userID, Name: user.name},
+		GameState: *user.gameState.Copy(),
+		FriendIDs: make([]string, len(user.friendIDs)),
+		Runs:      make([]port.ScoreRun, len(user.runs)),
+		Revisions: make([]port.StateRevision, 0, len(user.revisions)),
+	}
+	copy(record.FriendIDs, user.friendIDs)
+	copy(record.Runs, user.runs)
+
+	for i := range user.revisions {
+		record.Revisions = append(record.Revisions, *copyRevision(&user.revisions[i]))
+	}
+
+	if user.flag != nil {
+		flag := *user.flag
+		record.Flag = &flag
+	}
+
+	pending := user.pendingRequests()
+	record.FriendRequests = make([]port.FriendRequest, len(pending))
+	copy(record.FriendRequests, pending)
+
+	record.Blocks = make([]port.UserBlock, len(user.blocks))
+	copy(record.Blocks, user.blocks)
+
+	if user.referralCode != nil {
+		code := *user.referralCode
+		record.ReferralCode = &code
+	}
+
+	record.Referrals = make([]port.Referral, len(user.referrals))
+	copy(record.Referrals, user.referrals)
+
+	record.LinkedAccounts = make([]port.LinkedAccount, len(user.accounts))
+	copy(record.LinkedAccounts, user.accounts)
+
+	record.LeaderboardResults = make([]port.LeaderboardResult, len(user.results))
+	copy(record.LeaderboardResults, user.results)
+
+	record.FriendScoreViews = make([]port.FriendScoreView, len(user.views))
+	copy(record.FriendScoreViews, user.views)
+
+	user.ensureSlots()
+	record.Slots = make([]port.SaveSlot, 0, len(user.slots))
+	for _, slot := range user.slots {
+		state := slot.gameState
+		if slot.slotID == user.activeSlot {
+			state = user.gameState
+		}
+
+		record.Slots = append(record.Slots, port.SaveSlot{
+			SlotID:     slot.slotID,
+			Name:       slot.name,
+			Active:     slot.slotID == user.activeSlot,
+			State:      *state.Copy(),
+			LastDevice: slot.lastDevice,
+			PlayTime:   slot.playTime,
+			CreatedAt:  slot.createdAt,
+			UpdatedAt:  slot.updatedAt,
+		})
+	}
+
+	return record, nil
+}
+
+// ImportUser ...
+func (db *datastoreSim) ImportUser(record *port.UserRecord) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	friendIDs := make([]string, len(record.FriendIDs))
+	copy(friendIDs, record.FriendIDs)
+
+	runs := make([]port.ScoreRun, len(record.Runs))
+	copy(runs, record.Runs)
+
+	revisions := make([]port.StateRevision, 0, len(record.Revisions))
+	for i := range record.Revisions {
+		revisions = append(revisions, *copyRevision(&record.Revisions[i]))
+	}
+
+	user := &datastoreUser{
+		userID:    record.User.UserID,
+		name:      record.User.Name,
+		gameState: record.GameState.Copy(),
+		friendIDs: friendIDs,
+		runs:      runs,
+		revisions: revisions,
+	}
+
+	// The state of the active slot is the game state of the user
+	for i := range record.Slots {
+		slot := &datastoreSlot{
+			slotID:     record.Slots[i].SlotID,
+			name:       record.Slots[i].Name,
+			lastDevice: record.Slots[i].LastDevice,
+			playTime:   record.Slots[i].PlayTime,
+			createdAt:  record.Slots[i].CreatedAt,
+			updatedAt:  record.Slots[i].UpdatedAt,
+		}
+
+		if record.Slots[i].Active {
+			user.activeSlot = slot.slotID
+		} else {
+			slot.gameState = record.Slots[i].State.Copy()
+		}
+
+		user.slots = append(user.slots, slot)
+	}
+	user.ensureSlots()
+
+	if record.Flag != nil {
+		flag := *record.Flag
+		user.flag = &flag
+	}
+
+	user.friendRequests = make([]port.FriendRequest, len(record.FriendRequests))
+	copy(user.friendRequests, record.FriendRequests)
+
+	user.blocks = make([]port.UserBlock, len(record.Blocks))
+	copy(user.blocks, record.Blocks)
+
+	if record.ReferralCode != nil {
+		code := *record.ReferralCode
+		user.referralCode = &code
+	}
+
+	user.referrals = make([]port.Referral, len(record.Referrals))
+	copy(user.referrals, record.Referrals)
+
+	user.accounts = make([]port.LinkedAccount, len(record.LinkedAccounts))
+	copy(user.accounts, record.LinkedAccounts)
+
+	user.results = make([]port.LeaderboardResult, len(record.LeaderboardResults))
+	copy(user.results, record.LeaderboardResults)
+
+	user.views = make([]port.FriendScoreView, len(record.FriendScoreViews))
+	copy(user.views, record.FriendScoreViews)
+
+	db.Users[record.User.UserID] = user
+	db.names.add(record.User.UserID, record.User.Name)
+	db.scores.set(record.User.UserID, user.gameState.Score())
+
+	return nil
+}
+
+// GetGameIDs ...
+func (db *datastoreSim) GetGameIDs() ([]string, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	gameIDs := make([]string, 0, len(db.Games))
+	for gameID := range db.Games {
+		gameIDs = append(gameIDs, gameID)
+	}
+
+	sort.Strings(gameIDs)
+
+	return gameIDs, nil
+}
+
+// ExportGame exports a stored game, personal games are exported with their user
+func (db *datastoreSim) ExportGame(gameID string) (*port.Game, common.Error) {
+	db.Lock()
+	defer db.Unlock()
+
+	game, ok := db.Games[gameID]
+	if !ok {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid GameID")
+	}
+
+	return copyGame(game), nil
+}
+
+// ImportGame ...
+func (db *datastoreSim) ImportGame(game *port.Game) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	db.Games[game.GameID] = copyGame(game)
+	return nil
+}
+
+// DeleteGame ...
+func (db *datastoreSim) DeleteGame(gameID string) common.Error {
+	db.Lock()
+	defer db.Unlock()
+
+	delete(db.Games, gameID)
+	return nil
 }
 
 func (db *datastoreSim) DeleteUser(userID string) common.Error {
@@ -161,5 +2052,7 @@ func (db *datastoreSim) DeleteUser(userID string) common.Error {
 	defer db.Unlock()
 
 	delete(db.Users, userID)
+	db.names.remove(userID)
+	db.scores.remove(userID)
 	return nil
 }
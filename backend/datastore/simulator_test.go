@@ -1,5 +1,9 @@
 package datastore
 
+import (
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
 // Preare the state of the Simulator adapter for each test itereration
 // Note that the preperation overwrites everything, and requires no teardown
 func (suite *DatastoreTestSuite) prepareSimulatorState() {
@@ -8,7 +12,7 @@ func (suite *DatastoreTestSuite) prepareSimulatorState() {
 		Users[0]: &datastoreUser{
 			userID:    Users[0],
 			name:      UserNames[0],
-			gameState: GameStates[0],
+			gameState: GameStates[0].Copy(),
 			friendIDs: []string{
 				Users[1],
 				Users[2],
@@ -19,22 +23,30 @@ func (suite *DatastoreTestSuite) prepareSimulatorState() {
 		Users[1]: &datastoreUser{
 			userID:    Users[1],
 			name:      UserNames[1],
-			gameState: GameStates[1],
+			gameState: GameStates[1].Copy(),
 			friendIDs: []string{},
 		},
 
 		Users[2]: &datastoreUser{
 			userID:    Users[2],
 			name:      UserNames[2],
-			gameState: GameStates[2],
+			gameState: GameStates[2].Copy(),
 			friendIDs: []string{},
 		},
 
 		Users[3]: &datastoreUser{
 			userID:    Users[3],
 			name:      UserNames[3],
-			gameState: GameStates[3],
+			gameState: GameStates[3].Copy(),
 			friendIDs: []string{},
 		},
 	}
+
+	db.Games = make(map[string]*port.Game)
+	for i := range Games {
+		db.Games[GameIDs[i]] = copyGame(&Games[i])
+	}
+
+	db.archives = make(map[string]*port.LeaderboardArchive)
+	db.reindex()
 }
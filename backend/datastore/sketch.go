@@ -0,0 +1,94 @@
+package datastore
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// The score sketch counts the high scores of all users in buckets. Scores
+// below scoreSketchExact have a bucket each, higher scores share buckets a
+// 16th of their power of two wide, so any score is within about 6% of the
+// bounds of its bucket. The buckets are mirrored by score_bucket of the SQL
+// schema.
+const (
+	scoreSketchSubBits = 4
+	scoreSketchExact   = 1 << scoreSketchSubBits
+)
+
+// scoreBucket returns the bucket of a score, negative scores are counted as 0
+func scoreBucket(score int) int {
+	if score < scoreSketchExact {
+		if score < 0 {
+			return 0
+		}
+
+		return score
+	}
+
+	shift := bits.Len(uint(score)) - 1 - scoreSketchSubBits
+	return scoreSketchExact + shift*scoreSketchExact + (score>>uint(shift))&(scoreSketchExact-1)
+}
+
+// scoreBucketRange returns the scores of a bucket, from min up to but not
+// including max
+func scoreBucketRange(bucket int) (int, int) {
+	if bucket < scoreSketchExact {
+		return bucket, bucket + 1
+	}
+
+	shift := uint((bucket - scoreSketchExact) / scoreSketchExact)
+	sub := (bucket - scoreSketchExact) % scoreSketchExact
+	return (scoreSketchExact + sub) << shift, (scoreSketchExact + sub + 1) << shift
+}
+
+// scoreSketch counts scores by bucket, as they're added and removed
+type scoreSketch struct {
+	counts map[int]int
+}
+
+func newScoreSketch() *scoreSketch {
+	return &scoreSketch{
+		counts: make(map[int]int),
+	}
+}
+
+// add counts n more of the score, a negative n removes them
+func (s *scoreSketch) add(score, n int) {
+	bucket := scoreBucket(score)
+
+	s.counts[bucket] += n
+	if s.counts[bucket] == 0 {
+		delete(s.counts, bucket)
+	}
+}
+
+// distribution returns the counted buckets in order
+func (s *scoreSketch) distribution() *port.ScoreDistribution {
+	return newScoreDistribution(s.counts)
+}
+
+// newScoreDistribution returns the distribution of the bucket counts,
+// leaving out empty buckets
+func newScoreDistribution(counts map[int]int) *port.ScoreDistribution {
+	distribution := &port.ScoreDistribution{
+		Buckets: make([]port.ScoreBucket, 0, len(counts)),
+	}
+
+	for bucket, count := range counts {
+		if count <= 0 {
+			continue
+		}
+
+		min, max := scoreBucketRange(bucket)
+		distribution.Buckets = append(distribution.Buckets, port.ScoreBucket{Min: min, Max: max, Count: count})
+		distribution.Total += count
+	}
+
+	sort.Slice(distribution.Buckets, func(i, j int) bool {
+		return distribution.Buckets[i].Min < distribution.Buckets[j].Min
+	})
+
+	return distribution
+}
@@ -0,0 +1,52 @@
+package datastore
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func TestScoreBucket(t *testing.T) {
+	// Buckets follow each other without gaps, and contain their scores
+	previous, max := -1, 0
+	for score := 0; score < 1<<20; score++ {
+		bucket := scoreBucket(score)
+		if bucket != previous {
+			require.Equal(t, previous+1, bucket, "score %d", score)
+
+			var min int
+			min, max = scoreBucketRange(bucket)
+			require.Equal(t, score, min, "bucket %d", bucket)
+			previous = bucket
+		}
+
+		require.True(t, score < max, "score %d", score)
+
+		// Buckets are at most a 16th of their scores wide
+		assert.True(t, (max-1-score)*16 <= score, "score %d", score)
+	}
+
+	assert.Equal(t, 0, scoreBucket(-5))
+}
+
+func TestScoreSketch(t *testing.T) {
+	sketch := newScoreSketch()
+	for _, score := range []int{0, 3, 3, 50, 51, 110, 110} {
+		sketch.add(score, 1)
+	}
+
+	sketch.add(110, -1)
+	sketch.add(3, -2)
+
+	assert.Equal(t, &port.ScoreDistribution{
+		Total: 4,
+		Buckets: []port.ScoreBucket{
+			{Min: 0, Max: 1, Count: 1},
+			{Min: 50, Max: 52, Count: 2},
+			{Min: 108, Max: 112, Count: 1},
+		},
+	}, sketch.distribution())
+}
@@ -0,0 +1,165 @@
+package datastore
+
+import (
+	"math/rand"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Levels of the skip list, enough for a few million entries
+const (
+	skipListMaxLevel    = 24
+	skipListProbability = 0.25
+)
+
+// skipList is an ordered index of leaderboard keys. Every link knows the
+// number of entries it skips, so the position of a key and the key at a
+// position are found in logarithmic time.
+type skipList struct {
+	head   *skipNode
+	level  int
+	length int
+	random *rand.Rand
+}
+
+type skipNode struct {
+	key   port.LeaderboardKey
+	next  []*skipNode
+	spans []int // Number of entries passed by following next
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		head: &skipNode{
+			next:  make([]*skipNode, skipListMaxLevel),
+			spans: make([]int, skipListMaxLevel),
+		},
+		level:  1,
+		random: rand.New(rand.NewSource(1)),
+	}
+}
+
+func (l *skipList) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && l.random.Float64() < skipListProbability {
+		level++
+	}
+
+	return level
+}
+
+// search returns the last node before the key on every level, and its
+// position, counting the head as position 0
+func (l *skipList) search(key port.LeaderboardKey) ([]*skipNode, []int) {
+	update := make([]*skipNode, skipListMaxLevel)
+	positions := make([]int, skipListMaxLevel)
+
+	node, position := l.head, 0
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && leaderboardKeyLess(node.next[i].key, key) {
+			position += node.spans[i]
+			node = node.next[i]
+		}
+
+		update[i], positions[i] = node, position
+	}
+
+	return update, positions
+}
+
+// insert adds a key, which mustn't be in the list already
+func (l *skipList) insert(key port.LeaderboardKey) {
+	update, positions := l.search(key)
+
+	level := l.randomLevel()
+	for i := l.level; i < level; i++ {
+		update[i], positions[i] = l.head, 0
+		l.head.spans[i] = l.length
+	}
+
+	if level > l.level {
+		l.level = level
+	}
+
+	node := &skipNode{
+		key:   key,
+		next:  make([]*skipNode, level),
+		spans: make([]int, level),
+	}
+
+	// The node is at position positions[0]+1, splitting the spans it's inserted in
+	for i := 0; i < level; i++ {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+
+		node.spans[i] = update[i].spans[i] - (positions[0] - positions[i])
+		update[i].spans[i] = positions[0] - positions[i] + 1
+	}
+
+	// Spans of the higher levels now pass one more entry
+	for i := level; i < l.level; i++ {
+		update[i].spans[i]++
+	}
+
+	l.length++
+}
+
+// remove drops a key, returning false if it isn't in the list
+func (l *skipList) remove(key port.LeaderboardKey) bool {
+	update, _ := l.search(key)
+
+	node := update[0].next[0]
+	if node == nil || node.key != key {
+		return false
+	}
+
+	for i := 0; i < l.level; i++ {
+		if update[i].next[i] == node {
+			update[i].spans[i] += node.spans[i] - 1
+			update[i].next[i] = node.next[i]
+		} else {
+			update[i].spans[i]--
+		}
+	}
+
+	for l.level > 1 && l.head.next[l.level-1] == nil {
+		l.level--
+	}
+
+	l.length--
+	return true
+}
+
+// ahead returns the number of keys ordered before the key
+func (l *skipList) ahead(key port.LeaderboardKey) int {
+	_, positions := l.search(key)
+	return positions[0]
+}
+
+// slice returns up to limit keys, starting at the 0-based position
+func (l *skipList) slice(offset, limit int) []port.LeaderboardKey {
+	if offset < 0 {
+		limit += offset
+		offset = 0
+	}
+
+	if offset >= l.length || limit <= 0 {
+		return []port.LeaderboardKey{}
+	}
+
+	// Find the node at the position, positions start at 1 after the head
+	node, position := l.head, 0
+	for i := l.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && position+node.spans[i] <= offset+1 {
+			position += node.spans[i]
+			node = node.next[i]
+		}
+	}
+
+	keys := make([]port.LeaderboardKey, 0, limit)
+	for ; node != nil && len(keys) < limit; node = node.next[0] {
+		keys = append(keys, node.key)
+	}
+
+	return keys
+}
@@ -0,0 +1,93 @@
+package datastore
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func TestSkipList(t *testing.T) {
+	list := newSkipList()
+	random := rand.New(rand.NewSource(7))
+
+	// The expected content, kept in leaderboard order
+	keys := make([]port.LeaderboardKey, 0)
+	for i := 0; i < 2000; i++ {
+		key := port.LeaderboardKey{Score: random.Intn(100), UserID: fmt.Sprintf("user%04d", i)}
+		list.insert(key)
+		keys = append(keys, key)
+	}
+
+	// Remove every third key
+	for i := 0; i < len(keys); i += 3 {
+		require.True(t, list.remove(keys[i]))
+	}
+
+	kept := make([]port.LeaderboardKey, 0)
+	for i := range keys {
+		if i%3 != 0 {
+			kept = append(kept, keys[i])
+		}
+	}
+
+	assert.False(t, list.remove(keys[0]))
+
+	sort.Slice(kept, func(i, j int) bool {
+		return leaderboardKeyLess(kept[i], kept[j])
+	})
+
+	require.Equal(t, len(kept), list.length)
+	assert.Equal(t, kept, list.slice(0, len(kept)))
+
+	for i, key := range kept {
+		assert.Equal(t, i, list.ahead(key))
+	}
+
+	tests := []struct {
+		Name         string
+		Offset       int
+		Limit        int
+		ExpectedKeys []port.LeaderboardKey
+	}{
+		{
+			Name:         "Top",
+			Offset:       0,
+			Limit:        10,
+			ExpectedKeys: kept[:10],
+		}, {
+			Name:         "Middle",
+			Offset:       500,
+			Limit:        3,
+			ExpectedKeys: kept[500:503],
+		}, {
+			Name:         "End",
+			Offset:       len(kept) - 2,
+			Limit:        10,
+			ExpectedKeys: kept[len(kept)-2:],
+		}, {
+			Name:         "BeforeStart",
+			Offset:       -2,
+			Limit:        5,
+			ExpectedKeys: kept[:3],
+		}, {
+			Name:         "AfterEnd",
+			Offset:       len(kept),
+			Limit:        5,
+			ExpectedKeys: []port.LeaderboardKey{},
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			assert.Equal(t, test.ExpectedKeys, list.slice(test.Offset, test.Limit))
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
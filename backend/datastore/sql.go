@@ -1,8 +1,15 @@
 package datastore
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/jackc/pgx"
 
 	"github.com/valsgaard/interview-case/backend/common"
@@ -10,170 +17,4024 @@ import (
 )
 
 type sqlDatabase struct {
-	connection         *pgx.ConnPool
-	preparedStatements map[string]*pgx.PreparedStatement
+	*sqlCluster
+
+	log *logrus.Entry
+}
+
+var _ port.Datastore = &sqlDatabase{}
+var _ port.Traceable = &sqlDatabase{}
+
+// sqlCluster is the state shared by all copies of the adapter
+type sqlCluster struct {
+	sync.Mutex
+
+	primary  *sqlPool
+	replicas []*sqlPool
+
+	config     SQLConfig
+	statements map[string]string
+
+	// Time of the latest write per user, and when they were last pruned
+	writes map[string]time.Time
+	pruned time.Time
+
+	nextReplica int
+}
+
+// sqlPool is a connection pool to a single server
+type sqlPool struct {
+	sync.Mutex
+
+	name       string
+	connection *pgx.ConnPool
+	prepared   map[string]bool
+
+	// Replica health, unused for the primary. Only one reader checks the
+	// lag at a time, the others use the health of the previous check.
+	checked  time.Time
+	checking bool
+	healthy  bool
+}
+
+// SQLConfig contains the settings of the PostgreSQL adapter
+type SQLConfig struct {
+	// MaxConnections per server, defaults to 5
+	MaxConnections int
+
+	Replication SQLReplication
+	Tracing     SQLTracing
+	History     HistoryRetention
+}
+
+// SQLReplication contains the settings for routing reads to replicas
+type SQLReplication struct {
+	// Stickiness is how long a users reads go to the primary, after the
+	// user has written, so they can read their own writes
+	Stickiness time.Duration
+
+	// MaxLag is the replication lag at which a replica is no longer used
+	MaxLag time.Duration
+
+	// CheckInterval is how often the lag of a replica is checked
+	CheckInterval time.Duration
+}
+
+// SQLTracing contains the settings for timing and logging of queries
+type SQLTracing struct {
+	// SlowQuery is the duration at which a query is logged as a warning,
+	// zero disables the slow query log
+	SlowQuery time.Duration
+
+	// Explain adds the query plan to the slow query log, it's only used
+	// when the log is at debug level
+	Explain bool
+}
+
+// Prepare registers a statement and prepares it on the primary. Replicas
+// prepare their statements once they're first used.
+func (db *sqlDatabase) Prepare(name, query string) common.Error {
+	db.Lock()
+	db.statements[name] = query
+	db.Unlock()
+
+	if err := db.prepare(db.primary, name); err != nil {
+		return common.NewError(err, "")
+	}
+
+	return nil
+}
+
+// prepare a registered statement on the given pool
+func (db *sqlDatabase) prepare(pool *sqlPool, name string) error {
+	pool.Lock()
+	defer pool.Unlock()
+
+	if pool.prepared[name] {
+		return nil
+	}
+
+	db.Lock()
+	query := db.statements[name]
+	db.Unlock()
+
+	if _, err := pool.connection.Prepare(name, query); err != nil {
+		return err
+	}
+
+	pool.prepared[name] = true
+	return nil
+}
+
+// NewSQLConnection creates a connection to a PostgreSQL primary server,
+// and optionally to a set of read replicas
+func NewSQLConnection(primaryURI string, replicaURIs []string, config SQLConfig, log *logrus.Entry) (port.Datastore, common.Error) {
+	if config.MaxConnections < 2 {
+		config.MaxConnections = 5 // pgx default
+	}
+
+	primary, err := newSQLPool("primary", primaryURI, config.MaxConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]*sqlPool, 0, len(replicaURIs))
+	for i, uri := range replicaURIs {
+		replica, err := newSQLPool(fmt.Sprintf("replica%d", i), uri, config.MaxConnections)
+		if err != nil {
+			return nil, err
+		}
+
+		replicas = append(replicas, replica)
+	}
+
+	db := &sqlDatabase{
+		sqlCluster: &sqlCluster{
+			primary:    primary,
+			replicas:   replicas,
+			config:     config,
+			statements: make(map[string]string),
+			writes:     make(map[string]time.Time),
+		},
+		log: log,
+	}
+
+	return db, nil
+}
+
+func newSQLPool(name, uri string, maxConnections int) (*sqlPool, common.Error) {
+	config, err := pgx.ParseURI(uri)
+	if err != nil {
+		return nil, common.NewError(err, "").WithField("pool", name)
+	}
+
+	poolConfig := pgx.ConnPoolConfig{
+		ConnConfig:     config,
+		MaxConnections: maxConnections,
+	}
+
+	conn, err := pgx.NewConnPool(poolConfig)
+	if err != nil {
+		return nil, common.NewError(err, "").WithField("pool", name)
+	}
+
+	return &sqlPool{
+		name:       name,
+		connection: conn,
+		prepared:   make(map[string]bool),
+		healthy:    true,
+	}, nil
+}
+
+// WithLog returns a copy of the adapter, which logs to the given log
+func (db *sqlDatabase) WithLog(log *logrus.Entry) port.Datastore {
+	scoped := *db
+	scoped.log = log
+	return &scoped
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Routing                                                             **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// read runs fn on a replica, falling back to the primary if the replica
+// fails. The userID is used to let users read their own writes.
+func (db *sqlDatabase) read(userID string, fn func(pool *sqlPool) error) error {
+	pool := db.reader(userID)
+
+	err := fn(pool)
+	if pool == db.primary || !replicaFailure(err) {
+		return err
+	}
+
+	db.failed(pool, err)
+	return fn(db.primary)
+}
+
+// write runs fn on the primary, and marks the user as recently written
+func (db *sqlDatabase) write(userID string, fn func(pool *sqlPool) error) error {
+	if err := fn(db.primary); err != nil {
+		return err
+	}
+
+	db.wrote(userID)
+	return nil
+}
+
+// reader selects the pool to read the given users data from
+func (db *sqlDatabase) reader(userID string) *sqlPool {
+	if len(db.replicas) == 0 {
+		return db.primary
+	}
+
+	db.Lock()
+	written, ok := db.writes[userID]
+	if ok && time.Since(written) < db.config.Replication.Stickiness {
+		db.Unlock()
+		return db.primary
+	}
+
+	start := db.nextReplica
+	db.nextReplica = (db.nextReplica + 1) % len(db.replicas)
+	db.Unlock()
+
+	for i := range db.replicas {
+		replica := db.replicas[(start+i)%len(db.replicas)]
+		if db.available(replica) {
+			return replica
+		}
+	}
+
+	return db.primary
+}
+
+// wrote records a write by the given user, and prunes expired writes
+func (db *sqlDatabase) wrote(userID string) {
+	if len(db.replicas) == 0 || userID == "" {
+		return
+	}
+
+	db.Lock()
+	defer db.Unlock()
+
+	now := time.Now()
+	db.writes[userID] = now
+
+	if now.Sub(db.pruned) < db.config.Replication.Stickiness {
+		return
+	}
+
+	for id, written := range db.writes {
+		if now.Sub(written) >= db.config.Replication.Stickiness {
+			delete(db.writes, id)
+		}
+	}
+
+	db.pruned = now
+}
+
+// available checks if the replica is healthy and within the allowed lag. The
+// lag is queried without holding the lock of the replica, so a slow replica
+// doesn't block the readers of it.
+func (db *sqlDatabase) available(replica *sqlPool) bool {
+	replica.Lock()
+	if replica.checking || time.Since(replica.checked) < db.config.Replication.CheckInterval {
+		healthy := replica.healthy
+		replica.Unlock()
+		return healthy
+	}
+
+	replica.checking = true
+	replica.Unlock()
+
+	// A replica which has replayed everything it received isn't lagging,
+	// even if the primary hasn't written in a while
+	var lag float64
+	err := replica.connection.QueryRow(`SELECT CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+	END;`).Scan(&lag)
+
+	healthy := err == nil && time.Duration(lag*float64(time.Second)) <= db.config.Replication.MaxLag
+
+	replica.Lock()
+	replica.checking = false
+	replica.checked = time.Now()
+	replica.healthy = healthy
+	replica.Unlock()
+
+	if !healthy {
+		log := db.log.WithFields(logrus.Fields{"pool": replica.name, "lag": lag})
+		if err != nil {
+			log = log.WithField("error", err.Error())
+		}
+
+		log.Warn("Replica unavailable")
+	}
+
+	return healthy
+}
+
+// failed marks a replica as unavailable until its next check
+func (db *sqlDatabase) failed(replica *sqlPool, err error) {
+	replica.Lock()
+	replica.healthy = false
+	replica.checked = time.Now()
+	replica.Unlock()
+
+	db.log.WithFields(logrus.Fields{
+		"pool":  replica.name,
+		"error": err.Error(),
+	}).Warn("Replica failed, falling back to primary")
+}
+
+// replicaFailure tells if an error is caused by the replica, rather than
+// the query itself. Only lost connections, timeouts and queries canceled by
+// the replica count, errors encoding or scanning values don't.
+func replicaFailure(err error) bool {
+	switch err {
+	case nil:
+		return false
+	case pgx.ErrDeadConn, pgx.ErrAcquireTimeout, pgx.ErrClosedPool, io.EOF, io.ErrUnexpectedEOF:
+		return true
+	}
+
+	switch err := err.(type) {
+	case pgx.PgError:
+		// Connection exceptions, shutdowns and conflicts with recovery
+		return strings.HasPrefix(err.Code, "08") || strings.HasPrefix(err.Code, "57P") || err.Code == "40001"
+	case net.Error:
+		return true
+	}
+
+	return false
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Queries                                                             **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// queryRow runs a prepared statement on the pool, scanning a single row
+func (db *sqlDatabase) queryRow(pool *sqlPool, name string, args []interface{}, dest ...interface{}) error {
+	if err := db.prepare(pool, name); err != nil {
+		return err
+	}
+
+	t := db.trace(pool, name, args...)
+	err := pool.connection.QueryRow(name, args...).Scan(dest...)
+	t.done(rowCount(err), err)
+
+	return err
+}
+
+// query runs a prepared statement on the pool, calling scan for each row
+func (db *sqlDatabase) query(pool *sqlPool, name string, args []interface{}, scan func(rows *pgx.Rows) error) error {
+	if err := db.prepare(pool, name); err != nil {
+		return err
+	}
+
+	t := db.trace(pool, name, args...)
+	rows, err := pool.connection.Query(name, args...)
+	if err != nil {
+		t.done(0, err)
+		return err
+	}
+	defer rows.Close()
+
+	var n int64
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			t.done(n, err)
+			return err
+		}
+
+		n++
+	}
+
+	t.done(n, rows.Err())
+	return rows.Err()
+}
+
+// exec runs a prepared statement on the pool, without returning rows
+func (db *sqlDatabase) exec(pool *sqlPool, name string, args ...interface{}) error {
+	if err := db.prepare(pool, name); err != nil {
+		return err
+	}
+
+	t := db.trace(pool, name, args...)
+	tag, err := pool.connection.Exec(name, args...)
+	t.done(tag.RowsAffected(), err)
+
+	return err
+}
+
+// transaction runs fn in a transaction on the primary, committed if fn
+// succeeds and rolled back otherwise. The statements used by fn must be
+// registered by Prepare first, as preparing involves every connection of the
+// primary. The user is marked as recently written once committed.
+func (db *sqlDatabase) transaction(userID string, fn func(tx *pgx.Tx) error) error {
+	tx, err := db.primary.connection.Begin()
+	if err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	db.wrote(userID)
+	return nil
+}
+
+// queryRowTx runs a statement prepared for the transaction, scanning a
+// single row
+func (db *sqlDatabase) queryRowTx(tx *pgx.Tx, name string, args []interface{}, dest ...interface{}) error {
+	t := db.trace(db.primary, name, args...)
+	err := tx.QueryRow(name, args...).Scan(dest...)
+	t.done(rowCount(err), err)
+
+	return err
+}
+
+// sqlLockUsers locks the rows of the given users stored here, in order, and
+// counts them. Rows are locked until the end of the transaction, so limits
+// on the entries of the users can be checked by the following statements.
+const sqlLockUsers = `SELECT count(*) FROM (
+		SELECT id FROM users WHERE id = ANY($1::uuid[]) ORDER BY id FOR NO KEY UPDATE
+	) locked;`
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Lists                                                               **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// listSQL extends a query on the users table with filters and keyset
+// pagination. The query must end in a WHERE clause, using the given args.
+// Returns a statement name unique to the shape of the query.
+func listSQL(name, query string, args []interface{}, list *port.ListQuery) (string, string, []interface{}) {
+	field := port.SortByID
+	column := "id"
+	switch list.Sort {
+	case port.SortByName:
+		field = list.Sort
+		column = `name COLLATE "C"`
+
+	case port.SortByScore:
+		field = list.Sort
+		column = "score"
+	}
+
+	// Pages before an entry are read backwards from it
+	direction := "first"
+	descending := list.Descending
+	key := list.After
+	if list.Before != nil {
+		direction = "before"
+		descending = !descending
+		key = list.Before
+	} else if key != nil {
+		direction = "after"
+	}
+
+	order, op := "ASC", ">"
+	if descending {
+		order, op = "DESC", "<"
+	}
+
+	// Filters
+	var prefix string
+	if list.NamePrefix != "" {
+		prefix = escapeLike(strings.ToLower(list.NamePrefix)) + "%"
+	}
+
+	var minScore interface{}
+	if list.MinScore != nil {
+		minScore = *list.MinScore
+	}
+
+	args = append(args, prefix, minScore, sqlViewer(list.ViewerID))
+	conditions := []string{
+		fmt.Sprintf(`($%d::text = '' OR lower(name) LIKE $%[1]d)`, len(args)-2),
+		fmt.Sprintf(`($%d::int IS NULL OR score >= $%[1]d)`, len(args)-1),
+		sqlHideBlockers(len(args)),
+	}
+
+	// Keyset
+	if key != nil {
+		switch field {
+		case port.SortByName:
+			args = append(args, key.Name, key.UserID)
+			conditions = append(conditions, fmt.Sprintf(`(%s, id) %s ($%d::text, $%d::uuid)`, column, op, len(args)-1, len(args)))
+
+		case port.SortByScore:
+			args = append(args, key.Score, key.UserID)
+			conditions = append(conditions, fmt.Sprintf(`(%s, id) %s ($%d::int, $%d::uuid)`, column, op, len(args)-1, len(args)))
+
+		default:
+			args = append(args, key.UserID)
+			conditions = append(conditions, fmt.Sprintf(`id %s $%d::uuid`, op, len(args)))
+		}
+	}
+
+	args = append(args, list.Limit)
+	q := fmt.Sprintf(`%s AND %s ORDER BY %s %s, id %s LIMIT $%d;`,
+		query, strings.Join(conditions, " AND "), column, order, order, len(args))
+
+	return fmt.Sprintf("%s:%s:%s:%s", name, field, order, direction), q, args
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Tracing                                                             **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+type queryTrace struct {
+	db    *sqlDatabase
+	pool  *sqlPool
+	name  string
+	args  []interface{}
+	start time.Time
+}
+
+// trace starts timing the prepared statement with the given name
+func (db *sqlDatabase) trace(pool *sqlPool, name string, args ...interface{}) *queryTrace {
+	return &queryTrace{
+		db:    db,
+		pool:  pool,
+		name:  name,
+		args:  args,
+		start: time.Now(),
+	}
+}
+
+// done logs the duration, row count and error of the traced query
+func (t *queryTrace) done(rows int64, err error) {
+	duration := time.Since(t.start)
+	tracing := t.db.config.Tracing
+
+	log := t.db.log.WithFields(logrus.Fields{
+		"pool":      t.pool.name,
+		"statement": t.name,
+		"duration":  duration.String(),
+		"rows":      rows,
+	})
+
+	if err != nil {
+		log = log.WithField("error", err.Error())
+	}
+
+	if tracing.SlowQuery <= 0 || duration < tracing.SlowQuery {
+		log.Debug("Query")
+		return
+	}
+
+	if tracing.Explain && t.db.log.Logger.Level >= logrus.DebugLevel {
+		log = log.WithField("plan", t.db.explain(t.pool, t.name, t.args))
+	}
+
+	log.Warn("Slow query")
+}
+
+// explain retrieves the query plan of a prepared statement
+func (db *sqlDatabase) explain(pool *sqlPool, name string, args []interface{}) string {
+	db.Lock()
+	query, ok := db.statements[name]
+	db.Unlock()
+
+	if !ok {
+		return ""
+	}
+
+	rows, err := pool.connection.Query("EXPLAIN "+query, args...)
+	if err != nil {
+		return err.Error()
+	}
+	defer rows.Close()
+
+	plan := make([]string, 0)
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err.Error()
+		}
+
+		plan = append(plan, line)
+	}
+
+	return strings.Join(plan, "\n")
+}
+
+// rowCount returns the number of rows read by a QueryRow
+func rowCount(err error) int64 {
+	if err != nil {
+		return 0
+	}
+
+	return 1
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Users                                                               **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// NewUser ...
+func (db *sqlDatabase) NewUser(id, name string) (*port.User, common.Error) {
+	qName := "newUser"
+	q := `WITH created AS (
+			INSERT INTO users (id, name, active_slot) VALUES($1, $2, $1) RETURNING id, name
+		), slot AS (
+			INSERT INTO save_slots (user_id, id, name) SELECT id, id, $3 FROM created
+		)
+		SELECT id, name FROM created;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	// Users are created with the default slot active
+	user := new(port.User)
+	err := db.write(id, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{id, name, port.DefaultSaveSlotName}, &user.UserID, &user.Name)
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return user, nil
+}
+
+// GetUsers ...
+func (db *sqlDatabase) GetUsers() ([]*port.User, common.Error) {
+	qName := "getUser"
+	q := `SELECT id, name FROM users ORDER BY id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var users []*port.User
+	err := db.read("", func(pool *sqlPool) error {
+		users = make([]*port.User, 0)
+		return db.query(pool, qName, nil, func(rows *pgx.Rows) error {
+			user := new(port.User)
+			if err := rows.Scan(&user.UserID, &user.Name); err != nil {
+				return err
+			}
+
+			users = append(users, user)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return users, nil
+}
+
+// ListUsers ...
+func (db *sqlDatabase) ListUsers(query *port.ListQuery) ([]*port.User, common.Error) {
+	qName, q, args := listSQL("listUsers", `SELECT id, name FROM users WHERE TRUE`, nil, query)
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var users []*port.User
+	err := db.read("", func(pool *sqlPool) error {
+		users = make([]*port.User, 0, query.Limit)
+		return db.query(pool, qName, args, func(rows *pgx.Rows) error {
+			user := new(port.User)
+			if err := rows.Scan(&user.UserID, &user.Name); err != nil {
+				return err
+			}
+
+			users = append(users, user)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Pages before an entry are read backwards
+	if query.Before != nil {
+		for i, j := 0, len(users)-1; i < j; i, j = i+1, j-1 {
+			users[i], users[j] = users[j], users[i]
+		}
+	}
+
+	return users, nil
+}
+
+func (db *sqlDatabase) UserExists(id string) (bool, common.Error) {
+	qName := "userExists"
+	q := `SELECT EXISTS(SELECT 1 FROM users WHERE id=$1);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return false, err
+	}
+
+	var check bool
+	err := db.read(id, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{id}, &check)
+	})
+
+	if err != nil {
+		return false, common.NewError(err, "")
+	}
+
+	return check, nil
+}
+
+// SearchUsersByPrefix ...
+func (db *sqlDatabase) SearchUsersByPrefix(prefix, viewerID string, offset, limit int) ([]*port.UserMatch, common.Error) {
+	qName := "searchUsersByPrefix"
+	q := fmt.Sprintf(`SELECT id, name,
+			CASE WHEN lower(name) LIKE $1 THEN %f ELSE %f END::float8 AS rank
+		FROM users
+		WHERE (lower(name) LIKE $1 OR to_tsvector('simple', name) @@ to_tsquery('simple', $2)) AND %s
+		ORDER BY rank DESC, lower(name), id
+		LIMIT $3 OFFSET $4;`, rankNamePrefix, rankWordPrefix, sqlHideBlockers(5))
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	// Every word of the query must be the start of a word in the name
+	words := nameWords(prefix)
+	for i := range words {
+		words[i] += ":*"
+	}
+
+	args := []interface{}{
+		escapeLike(strings.ToLower(prefix)) + "%",
+		strings.Join(words, " & "),
+		limit,
+		offset,
+		sqlViewer(viewerID),
+	}
+
+	return db.searchUsers(qName, args)
+}
+
+// SearchUsersFuzzy ...
+func (db *sqlDatabase) SearchUsersFuzzy(query, viewerID string, offset, limit int) ([]*port.UserMatch, common.Error) {
+	qName := "searchUsersFuzzy"
+	q := `SELECT id, name, similarity(name, $1)::float8 AS rank
+		FROM users
+		WHERE name % $1 AND ` + sqlHideBlockers(4) + `
+		ORDER BY rank DESC, lower(name), id
+		LIMIT $2 OFFSET $3;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	return db.searchUsers(qName, []interface{}{query, limit, offset, sqlViewer(viewerID)})
+}
+
+func (db *sqlDatabase) searchUsers(qName string, args []interface{}) ([]*port.UserMatch, common.Error) {
+	var matches []*port.UserMatch
+	err := db.read("", func(pool *sqlPool) error {
+		matches = make([]*port.UserMatch, 0)
+		return db.query(pool, qName, args, func(rows *pgx.Rows) error {
+			match := new(port.UserMatch)
+			if err := rows.Scan(&match.UserID, &match.Name, &match.Rank); err != nil {
+				return err
+			}
+
+			matches = append(matches, match)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return matches, nil
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// sqlHideBlockers is the condition leaving out rows of the users table who
+// blocked the viewer, given by the numbered parameter. A NULL viewer sees
+// every user.
+func sqlHideBlockers(param int) string {
+	return fmt.Sprintf(`($%d::uuid IS NULL OR NOT EXISTS (
+		SELECT 1 FROM user_blocks b WHERE b.user_id = users.id AND b.blocked_id = $%[1]d))`, param)
+}
+
+// sqlViewer is the parameter of sqlHideBlockers for the given viewer
+func sqlViewer(viewerID string) interface{} {
+	if viewerID == "" {
+		return nil
+	}
+
+	return viewerID
+}
+
+// sqlBlockedEither is the condition telling if either of the users given by
+// the numbered parameters blocked the other
+func sqlBlockedEither(a, b int) string {
+	return fmt.Sprintf(`EXISTS (SELECT 1 FROM user_blocks
+		WHERE (user_id = $%d AND blocked_id = $%d) OR (user_id = $%[2]d AND blocked_id = $%[1]d))`, a, b)
+}
+
+// sqlLastActive is the column of the time of the latest game state revision
+// of a row of the users table
+const sqlLastActive = `(SELECT max(created_at) FROM state_revisions r WHERE r.user_id = users.id) AS last_active`
+
+// BlockUser removes the friendship and the pending friend requests of the
+// users in the same statement, where they're stored here
+func (db *sqlDatabase) BlockUser(userID, blockedID string) (bool, common.Error) {
+	qName := "blockUser"
+	q := `WITH blocked AS (
+			INSERT INTO user_blocks (user_id, blocked_id) VALUES($1, $2)
+			ON CONFLICT (user_id, blocked_id) DO NOTHING
+			RETURNING user_id, blocked_id
+		), unfriended AS (
+			UPDATE users u SET friends = array_remove(u.friends,
+				CASE WHEN u.id = b.user_id THEN b.blocked_id ELSE b.user_id END)
+			FROM blocked b WHERE u.id IN (b.user_id, b.blocked_id)
+		), requests AS (
+			DELETE FROM friend_requests r USING blocked b
+			WHERE (r.to_id = b.user_id AND r.from_id = b.blocked_id)
+			OR (r.to_id = b.blocked_id AND r.from_id = b.user_id)
+		)
+		SELECT count(*) FROM blocked;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return false, err
+	}
+
+	var blocked int
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID, blockedID}, &blocked)
+	})
+
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23503" {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err != nil {
+		return false, common.NewError(err, "")
+	}
+
+	return blocked > 0, nil
+}
+
+// UnblockUser removes the block, telling unknown users apart from users
+// which didn't block the other
+func (db *sqlDatabase) UnblockUser(userID, blockedID string) (bool, common.Error) {
+	qName := "unblockUser"
+	q := `WITH deleted AS (
+			DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id = $2 RETURNING blocked_id
+		)
+		SELECT (SELECT count(*) FROM deleted), EXISTS(SELECT 1 FROM users WHERE id = $1);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return false, err
+	}
+
+	var deleted int
+	var exists bool
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID, blockedID}, &deleted, &exists)
+	})
+
+	if err != nil {
+		return false, common.NewError(err, "")
+	}
+
+	if !exists {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	return deleted > 0, nil
+}
+
+// GetBlockedUsers ...
+func (db *sqlDatabase) GetBlockedUsers(userID string) ([]*port.UserBlock, common.Error) {
+	qName := "getBlockedUsers"
+	q := `SELECT user_id, blocked_id, created_at FROM user_blocks
+		WHERE user_id = $1 ORDER BY created_at, blocked_id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var blocks []*port.UserBlock
+	err := db.read(userID, func(pool *sqlPool) error {
+		blocks = make([]*port.UserBlock, 0)
+		return db.query(pool, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+			block, err := scanBlock(rows)
+			if err != nil {
+				return err
+			}
+
+			blocks = append(blocks, block)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Users without blocks may not exist
+	if len(blocks) == 0 {
+		exists, err := db.UserExists(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+	}
+
+	return blocks, nil
+}
+
+// IsBlocked doesn't require the users to be stored here, as blocks are
+// stored with the blocker
+func (db *sqlDatabase) IsBlocked(userID, otherID string) (bool, common.Error) {
+	qName := "isBlocked"
+	q := `SELECT ` + sqlBlockedEither(1, 2) + `;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return false, err
+	}
+
+	var blocked bool
+	err := db.read(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID, otherID}, &blocked)
+	})
+
+	if err != nil {
+		return false, common.NewError(err, "")
+	}
+
+	return blocked, nil
+}
+
+// scanBlock reads a block from a row of user_id, blocked_id and created_at
+func scanBlock(rows *pgx.Rows) (*port.UserBlock, error) {
+	block := new(port.UserBlock)
+	err := rows.Scan(
+		&block.UserID,
+		&block.BlockedID,
+		&block.CreatedAt,
+	)
+
+	return block, err
+}
+
+// FlagUser flags the user for review, counting how often it's flagged
+func (db *sqlDatabase) FlagUser(userID, reason string) common.Error {
+	qName := "flagUser"
+	q := `INSERT INTO user_flags (user_id, reason) VALUES($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET (reason, count, last_flagged_at) =
+		(EXCLUDED.reason, user_flags.count + 1, now());`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.exec(pool, qName, userID, reason)
+	})
+
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23503" {
+		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	return nil
+}
+
+// GetFlaggedUsers ...
+func (db *sqlDatabase) GetFlaggedUsers() ([]*port.UserFlag, common.Error) {
+	qName := "getFlaggedUsers"
+	q := `SELECT user_id, reason, count, first_flagged_at, last_flagged_at FROM user_flags
+		ORDER BY last_flagged_at DESC, user_id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var flags []*port.UserFlag
+	err := db.read("", func(pool *sqlPool) error {
+		flags = make([]*port.UserFlag, 0)
+		return db.query(pool, qName, nil, func(rows *pgx.Rows) error {
+			flag, err := scanFlag(rows)
+			if err != nil {
+				return err
+			}
+
+			flags = append(flags, flag)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return flags, nil
+}
+
+// ClearUserFlag removes the flag, telling unknown users apart from users
+// which aren't flagged
+func (db *sqlDatabase) ClearUserFlag(userID string) common.Error {
+	qName := "clearUserFlag"
+	q := `WITH deleted AS (DELETE FROM user_flags WHERE user_id = $1)
+		SELECT EXISTS(SELECT 1 FROM users WHERE id = $1);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	var exists bool
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID}, &exists)
+	})
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	if !exists {
+		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	return nil
+}
+
+// scanFlag reads a flag from a row of user_id, reason, count,
+// first_flagged_at and last_flagged_at
+func scanFlag(rows *pgx.Rows) (*port.UserFlag, error) {
+	flag := new(port.UserFlag)
+	err := rows.Scan(
+		&flag.UserID,
+		&flag.Reason,
+		&flag.Count,
+		&flag.FirstFlaggedAt,
+		&flag.LastFlaggedAt,
+	)
+
+	return flag, err
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Game State                                                          **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// sqlRecordRevision is the part of a statement recording the revision of
+// a row returned by the updated CTE, with the well-known fields of its
+// columns. The author and source are given by the parameters.
+const sqlRecordRevision = `INSERT INTO state_revisions (user_id, revision, state, author, source)
+	SELECT id, state_revision, state || jsonb_build_object('gamesPlayed', games_played, 'score', score), %s, %s
+	FROM updated`
+
+// sqlRunKey is the key parameter of a run, NULL for runs without a key
+func sqlRunKey(key string) interface{} {
+	if key == "" {
+		return nil
+	}
+
+	return key
+}
+
+// UpdateGameState stores the document. The well-known fields are kept in
+// their own columns as they're sorted and filtered by, and only written by
+// submitted scores, so the stored values are kept.
+func (db *sqlDatabase) UpdateGameState(userID string, state *port.GameState, change port.StateChange) common.Error {
+	qName := "updateGameState"
+	q := `WITH updated AS (
+			UPDATE users SET (state, state_revision) = ($1, state_revision + 1)
+			WHERE id = $2
+			RETURNING id, state, games_played, score, state_revision
+		), history AS (
+			` + fmt.Sprintf(sqlRecordRevision, "$3", "$4") + `
+		)
+		SELECT state_revision FROM updated;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	var revision int
+	err := db.write(userID, func(pool *sqlPool) error {
+		err := db.queryRow(pool, qName,
+			[]interface{}{state, userID, change.Author, change.Source},
+			&revision,
+		)
+
+		if err == nil {
+			db.pruneRevisions(pool, userID, revision)
+		}
+		return err
+	})
+
+	if err == pgx.ErrNoRows {
+		return common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	return nil
+}
+
+// GetGameState ...
+func (db *sqlDatabase) GetGameState(userID string) (*port.GameState, common.Error) {
+	qName := "getGameState"
+	q := `SELECT state, games_played, score, state_revision FROM users WHERE id = $1;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	gameState := new(port.GameState)
+	var gamesPlayed, score int
+	err := db.read(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID}, gameState, &gamesPlayed, &score, &gameState.Revision)
+	})
+
+	if err == pgx.ErrNoRows {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if err := loadGameState(gameState, gamesPlayed, score); err != nil {
+		return nil, err
+	}
+
+	return gameState, nil
+}
+
+// loadGameState completes a stored document with the well-known fields kept
+// in their own columns, and upgrades it to the current version
+func loadGameState(state *port.GameState, gamesPlayed, score int) common.Error {
+	if state.Document == nil {
+		state.Document = make(map[string]interface{})
+	}
+
+	state.SetGamesPlayed(gamesPlayed)
+	state.SetScore(score)
+
+	return state.Upgrade()
+}
+
+// SubmitScore increments games played, keeps the best score and records the
+// run in a single statement. The old row is locked, so concurrent runs of the
+// same user are applied one at a time. A run with a key already recorded
+// leaves the state as it is. Concurrent runs with the same key are refused by
+// the unique index of the keys, and the refused run is submitted again to
+// find the run it raced as already counted.
+func (db *sqlDatabase) SubmitScore(userID string, score int, change port.StateChange) (*port.ScoreResult, common.Error) {
+	qName := "submitScore"
+	q := `WITH replayed AS (
+			SELECT 1 FROM score_runs WHERE user_id = $1 AND run_key = $5::text
+		), updated AS (
+			UPDATE users u SET (games_played, score, state_revision) =
+				(u.games_played + 1, GREATEST(u.score, $2::int), u.state_revision + 1)
+			FROM (SELECT id, score FROM users WHERE id = $1 FOR UPDATE) old
+			WHERE u.id = old.id AND NOT EXISTS (SELECT 1 FROM replayed)
+			RETURNING u.id, u.state, u.games_played, u.score, u.state_revision, $2::int > old.score AS best
+		), run AS (
+			INSERT INTO score_runs (user_id, score, run_key) SELECT $1, $2::int, $5::text FROM updated
+		), history AS (
+			` + fmt.Sprintf(sqlRecordRevision, "$3", "$4") + `
+		)
+		SELECT state, games_played, score, state_revision, best, false FROM updated
+		UNION ALL
+		SELECT state, games_played, score, state_revision, false, true FROM users
+		WHERE id = $1 AND EXISTS (SELECT 1 FROM replayed);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	result := new(port.ScoreResult)
+	var gamesPlayed, best int
+	err := db.write(userID, func(pool *sqlPool) error {
+		submit := func() error {
+			return db.queryRow(pool, qName, []interface{}{userID, score, change.Author, change.Source, sqlRunKey(change.Key)},
+				&result.GameState,
+				&gamesPlayed,
+				&best,
+				&result.GameState.Revision,
+				&result.PersonalBest,
+				&result.Replayed,
+			)
+		}
+
+		err := submit()
+		if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23505" {
+			err = submit()
+		}
+
+		if err == nil && !result.Replayed {
+			db.pruneRevisions(pool, userID, result.GameState.Revision)
+		}
+		return err
+	})
+
+	if err == pgx.ErrNoRows {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if err := loadGameState(&result.GameState, gamesPlayed, best); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// sqlModifyAttempts is how many times a modification is retried, when the
+// stored state changes between it being read and written
+const sqlModifyAttempts = 5
+
+// ModifyGameState reads the state from the primary and swaps in the modified
+// state, if the revision is unchanged since it was read. Otherwise the
+// modification is retried against the newer state.
+func (db *sqlDatabase) ModifyGameState(userID string, change port.StateChange, modify port.GameStateModifier) (*port.GameState, common.Error) {
+	qName := "modifyGameState"
+	q := `SELECT state, games_played, score, state_revision FROM users WHERE id = $1;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; attempt < sqlModifyAttempts; attempt++ {
+		state := new(port.GameState)
+		var gamesPlayed, score int
+		err := db.queryRow(db.primary, qName, []interface{}{userID}, state, &gamesPlayed, &score, &state.Revision)
+
+		if err == pgx.ErrNoRows {
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+
+		if err != nil {
+			return nil, common.NewError(err, "")
+		}
+
+		if err := loadGameState(state, gamesPlayed, score); err != nil {
+			return nil, err
+		}
+
+		if err := modify(state); err != nil {
+			return nil, err
+		}
+
+		// The swap keeps the stored well-known fields, which are unchanged
+		// while the revision is
+		state.SetGamesPlayed(gamesPlayed)
+		state.SetScore(score)
+
+		revision, cerr := db.CompareAndSwapGameState(userID, state.Revision, state, change)
+		if cerr != nil {
+			if cerr.Code() == port.ErrConflict.Code() {
+				continue
+			}
+
+			return nil, cerr
+		}
+
+		state.Revision = revision
+		return state, nil
+	}
+
+	return nil, common.NewError(port.ErrConflict, "Game state kept changing").
+		WithField("attempts", sqlModifyAttempts)
+}
+
+// CompareAndSwapGameState stores the state if the stored revision matches,
+// keeping the stored well-known fields the same way as UpdateGameState.
+// Rows which aren't updated are told apart by whether the user exists.
+func (db *sqlDatabase) CompareAndSwapGameState(userID string, revision int, state *port.GameState, change port.StateChange) (int, common.Error) {
+	qName := "compareAndSwapGameState"
+	q := `WITH updated AS (
+			UPDATE users SET (state, state_revision) = ($1, state_revision + 1)
+			WHERE id = $2 AND state_revision = $3
+			RETURNING id, state, games_played, score, state_revision
+		), history AS (
+			` + fmt.Sprintf(sqlRecordRevision, "$4", "$5") + `
+		)
+		SELECT state_revision FROM updated;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return 0, err
+	}
+
+	var swapped int
+	err := db.write(userID, func(pool *sqlPool) error {
+		err := db.queryRow(pool, qName,
+			[]interface{}{state, userID, revision, change.Author, change.Source},
+			&swapped,
+		)
+
+		if err == nil {
+			db.pruneRevisions(pool, userID, swapped)
+		}
+		return err
+	})
+
+	if err == pgx.ErrNoRows {
+		exists, err := db.UserExists(userID)
+		if err != nil {
+			return 0, err
+		}
+
+		if !exists {
+			return 0, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+
+		return 0, common.NewError(port.ErrConflict, "Game state revision doesn't match")
+	}
+
+	if err != nil {
+		return 0, common.NewError(err, "")
+	}
+
+	return swapped, nil
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Game State History                                                  **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// pruneRevisions drops the revisions of a user no longer retained, keeping
+// the current revision. The state is already written, so failures are only
+// logged and the revisions are pruned by the next write instead.
+func (db *sqlDatabase) pruneRevisions(pool *sqlPool, userID string, current int) {
+	qName := "pruneRevisions"
+	q := `DELETE FROM state_revisions
+		WHERE user_id = $1 AND revision < $2::int AND (
+			($3::int > 0 AND revision <= $2::int - $3::int) OR
+			($4::bigint > 0 AND created_at < now() - $4::bigint * interval '1 second')
+		);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		db.log.WithField("error", err.Error()).Warn("Unable to prune game state revisions")
+		return
+	}
+
+	err := db.exec(pool, qName, sqlPruneArgs(db.config.History, userID, current)...)
+	if err != nil {
+		db.log.WithField("error", err.Error()).Warn("Unable to prune game state revisions")
+	}
+}
+
+// sqlPruneArgs returns the arguments of pruning the revisions of a user. The
+// maximum age is in whole seconds, as a fractional one isn't a bigint.
+func sqlPruneArgs(retention HistoryRetention, userID string, current int) []interface{} {
+	return []interface{}{userID, current, retention.MaxRevisions, int64(retention.MaxAge / time.Second)}
+}
+
+// GetGameStateRevisions ...
+func (db *sqlDatabase) GetGameStateRevisions(userID string) ([]*port.StateRevision, common.Error) {
+	qName := "getGameStateRevisions"
+	q := `SELECT revision, state, author, source, created_at FROM state_revisions
+		WHERE user_id = $1 ORDER BY revision DESC;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var revisions []*port.StateRevision
+	err := db.read(userID, func(pool *sqlPool) error {
+		revisions = make([]*port.StateRevision, 0)
+		return db.query(pool, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+			revision, err := scanRevision(rows)
+			if err != nil {
+				return err
+			}
+
+			revisions = append(revisions, revision)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Users without revisions may not exist
+	if len(revisions) == 0 {
+		exists, err := db.UserExists(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+	}
+
+	for _, revision := range revisions {
+		if err := revision.State.Upgrade(); err != nil {
+			return nil, err
+		}
+	}
+
+	return revisions, nil
+}
+
+// GetGameStateRevision ...
+func (db *sqlDatabase) GetGameStateRevision(userID string, revision int) (*port.StateRevision, common.Error) {
+	qName := "getGameStateRevision"
+	q := `SELECT revision, state, author, source, created_at FROM state_revisions
+		WHERE user_id = $1 AND revision = $2;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	result := new(port.StateRevision)
+	err := db.read(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID, revision},
+			&result.Revision,
+			&result.State,
+			&result.Author,
+			&result.Source,
+			&result.CreatedAt,
+		)
+	})
+
+	if err == pgx.ErrNoRows {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid revision")
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	result.State.Revision = result.Revision
+	if err := result.State.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// scanRevision reads a revision from a row of revision, state, author,
+// source and created_at
+func scanRevision(rows *pgx.Rows) (*port.StateRevision, error) {
+	revision := new(port.StateRevision)
+	err := rows.Scan(
+		&revision.Revision,
+		&revision.State,
+		&revision.Author,
+		&revision.Source,
+		&revision.CreatedAt,
+	)
+
+	revision.State.Revision = revision.Revision
+	return revision, err
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Save Slots                                                          **
+**   The state of the active slot is kept in the users row, the other    **
+**   slots keep their state, with the well-known fields, in their row    **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// sqlSlotColumns are the columns of a slot read by scanSlot, from save_slots
+// s joined with users u
+const sqlSlotColumns = `s.id, s.name, s.id = u.active_slot,
+	CASE WHEN s.id = u.active_slot
+		THEN u.state || jsonb_build_object('gamesPlayed', u.games_played, 'score', u.score)
+		ELSE s.state END,
+	CASE WHEN s.id = u.active_slot THEN u.state_revision ELSE 0 END,
+	s.last_device, s.play_time, s.created_at, s.updated_at`
+
+// NewSaveSlot ...
+func (db *sqlDatabase) NewSaveSlot(userID string, slot *port.SaveSlot, maxSlots int) (*port.SaveSlot, common.Error) {
+	if err := db.Prepare("lockUsers", sqlLockUsers); err != nil {
+		return nil, err
+	}
+
+	qName := "newSaveSlot"
+	q := `INSERT INTO save_slots (user_id, id, name, state, last_device, play_time)
+		SELECT $1, $2, $3, $4, $5, $6 WHERE (SELECT count(*) FROM save_slots WHERE user_id = $1) < $7
+		RETURNING created_at, updated_at;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	created := *slot
+	created.Active = false
+	created.State = *slot.State.Copy()
+	created.State.Revision = 0
+
+	// The user is locked while counting its slots, so concurrent slots can't
+	// exceed the limit
+	var locked int
+	err := db.transaction(userID, func(tx *pgx.Tx) error {
+		if err := db.queryRowTx(tx, "lockUsers", []interface{}{[]string{userID}}, &locked); err != nil || locked == 0 {
+			return err
+		}
+
+		return db.queryRowTx(tx, qName,
+			[]interface{}{userID, slot.SlotID, slot.Name, &created.State, slot.LastDevice, slot.PlayTime, maxSlots},
+			&created.CreatedAt,
+			&created.UpdatedAt,
+		)
+	})
+
+	if locked == 0 && err == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err == pgx.ErrNoRows {
+		return nil, common.NewError(port.ErrLimitReached, "Too many save slots").
+			WithField("max", maxSlots)
+	}
+
+	if pgErr, ok := err.(pgx.PgError); ok {
+		switch pgErr.Code {
+		case "23505":
+			return nil, common.NewError(port.ErrEntryExists, "Save slot already exists")
+		case "23503":
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if err := created.State.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// GetSaveSlots ...
+func (db *sqlDatabase) GetSaveSlots(userID string) ([]*port.SaveSlot, common.Error) {
+	qName := "getSaveSlots"
+	q := `SELECT ` + sqlSlotColumns + `
+		FROM save_slots s JOIN users u ON u.id = s.user_id
+		WHERE s.user_id = $1 ORDER BY s.created_at, s.id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var slots []*port.SaveSlot
+	err := db.read(userID, func(pool *sqlPool) error {
+		slots = make([]*port.SaveSlot, 0)
+		return db.query(pool, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+			slot, err := scanSlot(rows)
+			if err != nil {
+				return err
+			}
+
+			slots = append(slots, slot)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Every user has a slot, so users without slots don't exist
+	if len(slots) == 0 {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	for _, slot := range slots {
+		if err := slot.State.Upgrade(); err != nil {
+			return nil, err
+		}
+	}
+
+	return slots, nil
+}
+
+// GetSaveSlot ...
+func (db *sqlDatabase) GetSaveSlot(userID, slotID string) (*port.SaveSlot, common.Error) {
+	qName := "getSaveSlot"
+	q := `SELECT ` + sqlSlotColumns + `
+		FROM save_slots s JOIN users u ON u.id = s.user_id
+		WHERE s.user_id = $1 AND s.id = $2;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var slot *port.SaveSlot
+	err := db.read(userID, func(pool *sqlPool) error {
+		return db.query(pool, qName, []interface{}{userID, slotID}, func(rows *pgx.Rows) error {
+			var err error
+			slot, err = scanSlot(rows)
+			return err
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if slot == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid SlotID")
+	}
+
+	if err := slot.State.Upgrade(); err != nil {
+		return nil, err
+	}
+
+	return slot, nil
+}
+
+// UpdateSaveSlot changes the metadata given, keeping the rest
+func (db *sqlDatabase) UpdateSaveSlot(userID, slotID string, update *port.SaveSlotUpdate) (*port.SaveSlot, common.Error) {
+	qName := "updateSaveSlot"
+	q := `UPDATE save_slots SET (name, last_device, play_time, updated_at) =
+			(COALESCE($3, name), COALESCE($4, last_device), COALESCE($5, play_time), now())
+		WHERE user_id = $1 AND id = $2 RETURNING id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var updated string
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName,
+			[]interface{}{userID, slotID, update.Name, update.LastDevice, update.PlayTime},
+			&updated,
+		)
+	})
+
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23505" {
+		return nil, common.NewError(port.ErrEntryExists, "Save slot name already exists")
+	}
+
+	if err == pgx.ErrNoRows {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid SlotID")
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Reads of the user go to the primary, after the write
+	return db.GetSaveSlot(userID, slotID)
+}
+
+// DeleteSaveSlot deletes the slot, unless it's active. The user row is
+// locked, so the slot can't be activated while it's deleted.
+func (db *sqlDatabase) DeleteSaveSlot(userID, slotID string) common.Error {
+	qName := "deleteSaveSlot"
+	q := `WITH slot AS (
+			SELECT s.id, s.id = u.active_slot AS active
+			FROM save_slots s JOIN users u ON u.id = s.user_id
+			WHERE s.user_id = $1 AND s.id = $2
+			FOR UPDATE OF u
+		), deleted AS (
+			DELETE FROM save_slots s USING slot
+			WHERE s.user_id = $1 AND s.id = slot.id AND NOT slot.active
+		)
+		SELECT active FROM slot;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	var active bool
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID, slotID}, &active)
+	})
+
+	if err == pgx.ErrNoRows {
+		return common.NewError(port.ErrInvalidKey, "Invalid SlotID")
+	}
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	if active {
+		return common.NewError(port.ErrEntryInUse, "Save slot is active")
+	}
+
+	return nil
+}
+
+// ActivateSaveSlot swaps the state of the user into the active slot, and the
+// state of the slot into the user, recording it as a new revision. Games
+// played and the best score stay with the user. The user row is locked, so
+// concurrent activations are applied one at a time.
+func (db *sqlDatabase) ActivateSaveSlot(userID, slotID string, change port.StateChange) (*port.GameState, common.Error) {
+	qName := "activateSaveSlot"
+	q := `WITH old AS (
+			SELECT id, active_slot, state || jsonb_build_object('gamesPlayed', games_played, 'score', score) AS state
+			FROM users WHERE id = $1 FOR UPDATE
+		), target AS (
+			SELECT s.id, s.state FROM save_slots s, old
+			WHERE s.user_id = old.id AND s.id = $2 AND s.id IS DISTINCT FROM old.active_slot
+		), saved AS (
+			UPDATE save_slots s SET (state, updated_at) = (old.state, now())
+			FROM old, target
+			WHERE s.user_id = old.id AND s.id = old.active_slot
+		), loaded AS (
+			UPDATE save_slots s SET updated_at = now()
+			FROM target
+			WHERE s.user_id = $1 AND s.id = target.id
+		), updated AS (
+			UPDATE users u SET (state, state_revision, active_slot) = (target.state, u.state_revision + 1, target.id)
+			FROM target
+			WHERE u.id = $1
+			RETURNING u.id, u.state, u.games_played, u.score, u.state_revision
+		), history AS (
+			` + fmt.Sprintf(sqlRecordRevision, "$3", "$4") + `
+		)
+		SELECT state, games_played, score, state_revision FROM updated;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	state := new(port.GameState)
+	var gamesPlayed, score int
+	err := db.write(userID, func(pool *sqlPool) error {
+		err := db.queryRow(pool, qName, []interface{}{userID, slotID, change.Author, change.Source},
+			state,
+			&gamesPlayed,
+			&score,
+			&state.Revision,
+		)
+
+		if err == nil {
+			db.pruneRevisions(pool, userID, state.Revision)
+		}
+		return err
+	})
+
+	// Nothing is updated for unknown slots, and slots already active
+	if err == pgx.ErrNoRows {
+		slot, err := db.GetSaveSlot(userID, slotID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slot.Active {
+			return nil, common.NewError(port.ErrConflict, "Save slot changed while being activated")
+		}
+
+		return &slot.State, nil
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if err := loadGameState(state, gamesPlayed, score); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// scanSlot reads a slot from a row of sqlSlotColumns
+func scanSlot(rows *pgx.Rows) (*port.SaveSlot, error) {
+	slot := new(port.SaveSlot)
+	err := rows.Scan(
+		&slot.SlotID,
+		&slot.Name,
+		&slot.Active,
+		&slot.State,
+		&slot.State.Revision,
+		&slot.LastDevice,
+		&slot.PlayTime,
+		&slot.CreatedAt,
+		&slot.UpdatedAt,
+	)
+
+	return slot, err
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Games                                                               **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// NewGame ...
+func (db *sqlDatabase) NewGame(gameID string, userIDs []string) (*port.Game, common.Error) {
+	qName := "newGame"
+	q := `WITH game AS (INSERT INTO games (id) VALUES ($1) RETURNING id)
+		INSERT INTO game_participants (game_id, position, user_id, status)
+		SELECT game.id, p.position, p.user_id, $3
+		FROM game, unnest($2::uuid[]) WITH ORDINALITY AS p(user_id, position);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	err := db.write(gameID, func(pool *sqlPool) error {
+		return db.exec(pool, qName, gameID, userIDs, port.GamePlaying)
+	})
+
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23505" {
+		return nil, common.NewError(port.ErrEntryExists, "Game already exists")
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	game := &port.Game{
+		GameID:       gameID,
+		Participants: make([]*port.Participant, 0, len(userIDs)),
+	}
+
+	for _, userID := range userIDs {
+		game.Participants = append(game.Participants, &port.Participant{
+			UserID: userID,
+			Status: port.GamePlaying,
+		})
+	}
+
+	setGameStatus(game)
+	return game, nil
+}
+
+// GetGame returns a stored game, or the personal game of a user
+func (db *sqlDatabase) GetGame(gameID string) (*port.Game, common.Error) {
+	var game *port.Game
+	err := db.read(gameID, func(pool *sqlPool) error {
+		var err error
+		game, err = db.loadGame(pool, gameID)
+		return err
+	})
+
+	if err == pgx.ErrNoRows {
+		state, err := db.GetGameState(gameID)
+		if err != nil {
+			if err.Code() == port.ErrInvalidKey.Code() {
+				return nil, common.NewError(port.ErrInvalidKey, "Invalid GameID")
+			}
+
+			return nil, err
+		}
+
+		return personalGame(gameID, state), nil
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return game, nil
+}
+
+// SetGameResult finishes a participant with the given score. Only playing
+// participants are updated, so a result can't be submitted twice.
+func (db *sqlDatabase) SetGameResult(gameID, userID string, score int) (*port.Game, common.Error) {
+	qName := "setGameResult"
+	q := `UPDATE game_participants SET (status, score) = ($3, $4)
+		WHERE game_id = $1 AND user_id = $2 AND status <> $3 RETURNING user_id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var game *port.Game
+	var updated string
+	err := db.write(gameID, func(pool *sqlPool) error {
+		err := db.queryRow(pool, qName, []interface{}{gameID, userID, port.GameFinished, score}, &updated)
+		if err != nil && err != pgx.ErrNoRows {
+			return err
+		}
+
+		game, err = db.loadGame(pool, gameID)
+		return err
+	})
+
+	if err == pgx.ErrNoRows {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid GameID")
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if updated != "" {
+		return game, nil
+	}
+
+	// Tell why nothing was updated
+	for _, p := range game.Participants {
+		if p.UserID == userID {
+			return nil, common.NewError(port.ErrEntryExists, "Result already submitted")
+		}
+	}
+
+	return nil, common.NewError(port.ErrInvalidKey, "User isn't participating in the game")
+}
+
+// loadGame reads a game and its participants from the pool, returning
+// pgx.ErrNoRows if it doesn't exist
+func (db *sqlDatabase) loadGame(pool *sqlPool, gameID string) (*port.Game, error) {
+	qName := "loadGame"
+	q := `SELECT user_id::text, status, score FROM game_participants WHERE game_id = $1 ORDER BY position;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	game := &port.Game{
+		GameID:       gameID,
+		Participants: make([]*port.Participant, 0),
+	}
+
+	err := db.query(pool, qName, []interface{}{gameID}, func(rows *pgx.Rows) error {
+		p := new(port.Participant)
+		if err := rows.Scan(&p.UserID, &p.Status, &p.Score); err != nil {
+			return err
+		}
+
+		game.Participants = append(game.Participants, p)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Games are always created with participants
+	if len(game.Participants) == 0 {
+		return nil, pgx.ErrNoRows
+	}
+
+	setGameStatus(game)
+	return game, nil
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Friends                                                             **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// UpdateFriends replaces the friend list, and the user in the lists of the
+// added and removed friends, unless the user and any of the friends blocked
+// the other. The row of the user is locked, so concurrent replaces are
+// applied one at a time.
+func (db *sqlDatabase) UpdateFriends(userID string, friends []string) common.Error {
+	qName := "updateFriends"
+	q := `WITH blocks AS (
+			SELECT EXISTS (SELECT 1 FROM user_blocks
+				WHERE (user_id = $2 AND blocked_id = ANY($1::uuid[])) OR (blocked_id = $2 AND user_id = ANY($1::uuid[]))
+			) AS blocked
+		), old AS (
+			SELECT id, friends FROM users WHERE id = $2 FOR UPDATE
+		), updated AS (
+			UPDATE users u SET friends = $1 FROM blocks, old WHERE u.id = old.id AND NOT blocks.blocked
+		), added AS (
+			UPDATE users u SET friends = array_append(u.friends, old.id) FROM blocks, old
+			WHERE u.id = ANY($1::uuid[]) AND u.id <> old.id AND NOT old.id = ANY(u.friends) AND NOT blocks.blocked
+		), removed AS (
+			UPDATE users u SET friends = array_remove(u.friends, old.id) FROM blocks, old
+			WHERE u.id = ANY(old.friends) AND u.id <> old.id AND NOT u.id = ANY($1::uuid[]) AND NOT blocks.blocked
+		)
+		SELECT blocked FROM blocks;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	var blocked bool
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{friends, userID}, &blocked)
+	})
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	if blocked {
+		return common.NewError(port.ErrBlocked, "User is blocked")
+	}
+
+	return nil
+}
+
+// AddFriend appends the friend to the user and the user to the friend, unless
+// already present or either user blocked the other. The row is locked so
+// concurrent adds of the same friend can't both append it, and the friend
+// list of the friend is checked again once its row is locked by the update.
+func (db *sqlDatabase) AddFriend(userID, friendID string) (bool, common.Error) {
+	qName := "addFriend"
+	q := `WITH old AS (
+			SELECT id, $2::uuid = ANY(friends) AS present, ` + sqlBlockedEither(1, 2) + ` AS blocked
+			FROM users WHERE id = $1 FOR UPDATE
+		), updated AS (
+			UPDATE users u SET friends = array_append(u.friends,
+				CASE WHEN u.id = old.id THEN $2::uuid ELSE old.id END)
+			FROM old WHERE u.id IN (old.id, $2::uuid) AND NOT old.blocked
+				AND NOT (CASE WHEN u.id = old.id THEN $2::uuid ELSE old.id END) = ANY(u.friends)
+		)
+		SELECT NOT present, blocked FROM old;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return false, err
+	}
+
+	var added, blocked bool
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID, friendID}, &added, &blocked)
+	})
+
+	if err == pgx.ErrNoRows {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err != nil {
+		return false, common.NewError(err, "")
+	}
+
+	if blocked {
+		return false, common.NewError(port.ErrBlocked, "User is blocked")
+	}
+
+	return added, nil
+}
+
+// RemoveFriend removes the friendship from both users, reporting whether the
+// friend was present in the friend list of the user
+func (db *sqlDatabase) RemoveFriend(userID, friendID string) (bool, common.Error) {
+	qName := "removeFriend"
+	q := `WITH old AS (
+			SELECT id, $2::uuid = ANY(friends) AS present FROM users WHERE id = $1 FOR UPDATE
+		), unfriended AS (
+			UPDATE users u SET friends = array_remove(u.friends,
+				CASE WHEN u.id = old.id THEN $2::uuid ELSE old.id END)
+			FROM old WHERE u.id IN (old.id, $2::uuid)
+		)
+		SELECT present FROM old;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return false, err
+	}
+
+	var removed bool
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID, friendID}, &removed)
+	})
+
+	if err == pgx.ErrNoRows {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err != nil {
+		return false, common.NewError(err, "")
+	}
+
+	return removed, nil
+}
+
+// GetFriends ...
+func (db *sqlDatabase) GetFriends(userID string) ([]*port.Friend, common.Error) {
+	qName := "getFriends"
+	q := `SELECT id, name, score, ` + sqlLastActive + ` FROM users
+		WHERE id = ANY((SELECT unnest(friends) FROM users WHERE id = $1)) AND ` + sqlHideBlockers(1) + ` ORDER BY id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var friends []*port.Friend
+	err := db.read(userID, func(pool *sqlPool) error {
+		friends = make([]*port.Friend, 0)
+		return db.query(pool, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+			friend := new(port.Friend)
+			if err := rows.Scan(&friend.UserID, &friend.Name, &friend.HighScore, &friend.LastActiveAt); err != nil {
+				return err
+			}
+
+			friends = append(friends, friend)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return friends, nil
+}
+
+// ListFriends ...
+func (db *sqlDatabase) ListFriends(userID string, query *port.ListQuery) ([]*port.Friend, common.Error) {
+	// Friends who blocked the user are left out
+	list := *query
+	list.ViewerID = userID
+
+	qName, q, args := listSQL(
+		"listFriends",
+		`SELECT id, name, score, `+sqlLastActive+` FROM users
+		WHERE id = ANY((SELECT unnest(friends) FROM users WHERE id = $1))`,
+		[]interface{}{userID},
+		&list,
+	)
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var friends []*port.Friend
+	err := db.read(userID, func(pool *sqlPool) error {
+		friends = make([]*port.Friend, 0, query.Limit)
+		return db.query(pool, qName, args, func(rows *pgx.Rows) error {
+			friend := new(port.Friend)
+			if err := rows.Scan(&friend.UserID, &friend.Name, &friend.HighScore, &friend.LastActiveAt); err != nil {
+				return err
+			}
+
+			friends = append(friends, friend)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Pages before an entry are read backwards
+	if query.Before != nil {
+		for i, j := 0, len(friends)-1; i < j; i, j = i+1, j-1 {
+			friends[i], friends[j] = friends[j], friends[i]
+		}
+	}
+
+	return friends, nil
+}
+
+// GetFriendIDs ...
+func (db *sqlDatabase) GetFriendIDs(userID string) ([]string, common.Error) {
+	qName := "getFriendIDs"
+	q := `SELECT friends::text[] FROM users WHERE id = $1;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var friendIDs []string
+	err := db.read(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID}, &friendIDs)
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return friendIDs, nil
+}
+
+// GetFriendsByID ...
+func (db *sqlDatabase) GetFriendsByID(userIDs []string, viewerID string) ([]*port.Friend, common.Error) {
+	qName := "getFriendsByID"
+	q := `SELECT id, name, score, ` + sqlLastActive + ` FROM users
+		WHERE id = ANY($1) AND ` + sqlHideBlockers(2) + ` ORDER BY id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var friends []*port.Friend
+	err := db.read("", func(pool *sqlPool) error {
+		friends = make([]*port.Friend, 0, len(userIDs))
+		return db.query(pool, qName, []interface{}{userIDs, sqlViewer(viewerID)}, func(rows *pgx.Rows) error {
+			friend := new(port.Friend)
+			if err := rows.Scan(&friend.UserID, &friend.Name, &friend.HighScore, &friend.LastActiveAt); err != nil {
+				return err
+			}
+
+			friends = append(friends, friend)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return friends, nil
+}
+
+// GetFriendSuggestions walks the friend graph two levels out from the user in
+// a recursive query, counting the friends of the user leading to each
+// candidate. Friends who blocked the user aren't walked.
+func (db *sqlDatabase) GetFriendSuggestions(userID string, limit int) ([]*port.FriendSuggestion, common.Error) {
+	qName := "getFriendSuggestions"
+	q := `WITH RECURSIVE graph (id, depth, via) AS (
+			SELECT f.id, 1, users.id FROM users CROSS JOIN LATERAL unnest(users.friends) AS f(id)
+			WHERE users.id = $1
+			UNION ALL
+			SELECT f.id, graph.depth + 1, graph.id FROM graph
+			JOIN users ON users.id = graph.id
+			CROSS JOIN LATERAL unnest(users.friends) AS f(id)
+			WHERE graph.depth < 2 AND ` + sqlHideBlockers(1) + `
+		)
+		SELECT users.id, users.name, users.score, ` + sqlLastActive + `, count(DISTINCT graph.via) AS mutual
+		FROM graph JOIN users ON users.id = graph.id
+		WHERE graph.depth = 2 AND users.id <> $1
+			AND users.id NOT IN (SELECT id FROM graph WHERE depth = 1)
+			AND NOT EXISTS (SELECT 1 FROM user_blocks b
+				WHERE (b.user_id = $1 AND b.blocked_id = users.id) OR (b.user_id = users.id AND b.blocked_id = $1))
+		GROUP BY users.id
+		ORDER BY mutual DESC, last_active DESC NULLS LAST, users.id
+		LIMIT $2;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var suggestions []*port.FriendSuggestion
+	err := db.read(userID, func(pool *sqlPool) error {
+		suggestions = make([]*port.FriendSuggestion, 0, limit)
+		return db.query(pool, qName, []interface{}{userID, limit}, func(rows *pgx.Rows) error {
+			suggestion := new(port.FriendSuggestion)
+			if err := rows.Scan(
+				&suggestion.UserID,
+				&suggestion.Name,
+				&suggestion.HighScore,
+				&suggestion.LastActiveAt,
+				&suggestion.MutualFriends,
+			); err != nil {
+				return err
+			}
+
+			suggestions = append(suggestions, suggestion)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Users without suggestions may not exist
+	if len(suggestions) == 0 {
+		exists, err := db.UserExists(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+	}
+
+	return suggestions, nil
+}
+
+// ViewFriendScores replaces the scores in the same statement reading the
+// previous scores, as the statement reads the rows from before it changes them
+func (db *sqlDatabase) ViewFriendScores(userID string, views []*port.FriendScoreView) ([]*port.FriendScoreView, common.Error) {
+	qName := "viewFriendScores"
+	q := `WITH viewer AS (
+			SELECT id FROM users WHERE id = $1
+		), cleared AS (
+			DELETE FROM friend_score_views WHERE user_id = $1 AND friend_id <> ALL($2::uuid[])
+		), viewed AS (
+			INSERT INTO friend_score_views (user_id, friend_id, score, viewed_at)
+			SELECT viewer.id, v.friend_id, v.score, v.viewed_at
+			FROM viewer, unnest($2::uuid[], $3::int[], $4::timestamptz[]) AS v(friend_id, score, viewed_at)
+			ON CONFLICT (user_id, friend_id) DO UPDATE SET (score, viewed_at) = (EXCLUDED.score, EXCLUDED.viewed_at)
+		)
+		SELECT friend_id, score, viewed_at FROM friend_score_views WHERE user_id = $1 ORDER BY friend_id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	friendIDs := make([]string, 0, len(views))
+	scores := make([]int32, 0, len(views))
+	viewed := make([]time.Time, 0, len(views))
+	for _, view := range views {
+		friendIDs = append(friendIDs, view.FriendID)
+		scores = append(scores, int32(view.Score))
+		viewed = append(viewed, view.ViewedAt)
+	}
+
+	var previous []*port.FriendScoreView
+	err := db.write(userID, func(pool *sqlPool) error {
+		previous = make([]*port.FriendScoreView, 0)
+		return db.query(pool, qName, []interface{}{userID, friendIDs, scores, viewed}, func(rows *pgx.Rows) error {
+			view := new(port.FriendScoreView)
+			if err := rows.Scan(&view.FriendID, &view.Score, &view.ViewedAt); err != nil {
+				return err
+			}
+
+			previous = append(previous, view)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Users without previous scores may not exist
+	if len(previous) == 0 {
+		exists, err := db.UserExists(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+	}
+
+	return previous, nil
+}
+
+// NewFriendRequest stores the request, replacing an expired request from
+// the same sender. Other expired requests of the recipient are pruned.
+// Requests which aren't stored are told apart by whether a user is blocked.
+// Both users are locked while counting their pending requests, so
+// concurrent requests can't exceed the limits.
+func (db *sqlDatabase) NewFriendRequest(request *port.FriendRequest, maxPending int) (*port.FriendRequest, common.Error) {
+	if err := db.Prepare("lockUsers", sqlLockUsers); err != nil {
+		return nil, err
+	}
+
+	// Requests between the users don't count towards the limits
+	countName := "countFriendRequests"
+	count := `SELECT
+		(SELECT count(*) FROM friend_requests WHERE to_id = $1 AND from_id <> $2 AND expires_at > now()),
+		(SELECT count(*) FROM friend_requests WHERE from_id = $2 AND to_id <> $1 AND expires_at > now());`
+
+	if err := db.Prepare(countName, count); err != nil {
+		return nil, err
+	}
+
+	qName := "newFriendRequest"
+	q := `WITH pruned AS (
+			DELETE FROM friend_requests WHERE to_id = $1 AND from_id <> $2 AND expires_at <= now()
+		)
+		INSERT INTO friend_requests (to_id, from_id, expires_at)
+		SELECT $1, $2, $3 WHERE NOT ` + sqlBlockedEither(1, 2) + `
+		ON CONFLICT (to_id, from_id) DO UPDATE SET (created_at, expires_at) = (now(), EXCLUDED.expires_at)
+		WHERE friend_requests.expires_at <= now()
+		RETURNING from_id, to_id, created_at, expires_at;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var locked, received, sent int
+	created := new(port.FriendRequest)
+	err := db.transaction(request.ToID, func(tx *pgx.Tx) error {
+		userIDs := []string{request.ToID, request.FromID}
+		if err := db.queryRowTx(tx, "lockUsers", []interface{}{userIDs}, &locked); err != nil || locked == 0 {
+			return err
+		}
+
+		err := db.queryRowTx(tx, countName, []interface{}{request.ToID, request.FromID}, &received, &sent)
+		if err != nil || received >= maxPending || sent >= maxPending {
+			return err
+		}
+
+		return db.queryRowTx(tx, qName, []interface{}{request.ToID, request.FromID, request.ExpiresAt},
+			&created.FromID,
+			&created.ToID,
+			&created.CreatedAt,
+			&created.ExpiresAt,
+		)
+	})
+
+	if err == nil {
+		switch {
+		case locked == 0:
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		case sent >= maxPending:
+			return nil, common.NewError(port.ErrLimitReached, "Too many pending friend requests sent").
+				WithField("max", maxPending)
+		case received >= maxPending:
+			return nil, common.NewError(port.ErrLimitReached, "Too many pending friend requests received by friend").
+				WithField("max", maxPending)
+		}
+	}
+
+	// Pending requests aren't replaced
+	if err == pgx.ErrNoRows {
+		blocked, err := db.IsBlocked(request.FromID, request.ToID)
+		if err != nil {
+			return nil, err
+		}
+
+		if blocked {
+			return nil, common.NewError(port.ErrBlocked, "User is blocked")
+		}
+
+		return nil, common.NewError(port.ErrEntryExists, "Friend request already pending")
+	}
+
+	if pgErr, ok := err.(pgx.PgError); ok && pgErr.Code == "23503" {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return created, nil
+}
+
+// GetFriendRequests ...
+func (db *sqlDatabase) GetFriendRequests(userID string) ([]*port.FriendRequest, common.Error) {
+	qName := "getFriendRequests"
+	q := `SELECT from_id, to_id, created_at, expires_at FROM friend_requests
+		WHERE to_id = $1 AND expires_at > now() ORDER BY created_at, from_id;`
+
+	requests, err := db.friendRequests(userID, qName, q)
+	if err != nil {
+		return nil, err
+	}
+
+	// Users without requests may not exist
+	if len(requests) == 0 {
+		exists, err := db.UserExists(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+	}
+
+	return requests, nil
+}
+
+// GetSentFriendRequests doesn't require the sender to be stored here, as
+// requests are stored with their recipient
+func (db *sqlDatabase) GetSentFriendRequests(userID string) ([]*port.FriendRequest, common.Error) {
+	qName := "getSentFriendRequests"
+	q := `SELECT from_id, to_id, created_at, expires_at FROM friend_requests
+		WHERE from_id = $1 AND expires_at > now() ORDER BY created_at, to_id;`
+
+	return db.friendRequests(userID, qName, q)
+}
+
+// friendRequests reads the pending friend requests selected by a query of
+// the given user
+func (db *sqlDatabase) friendRequests(userID, qName, q string) ([]*port.FriendRequest, common.Error) {
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var requests []*port.FriendRequest
+	err := db.read(userID, func(pool *sqlPool) error {
+		requests = make([]*port.FriendRequest, 0)
+		return db.query(pool, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+			request, err := scanFriendRequest(rows)
+			if err != nil {
+				return err
+			}
+
+			requests = append(requests, request)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return requests, nil
+}
+
+// AcceptFriendRequest deletes the pending request, and adds each user as a
+// friend of the other in a single statement. A request the other way is
+// deleted as well, as it's accepted by the friendship.
+func (db *sqlDatabase) AcceptFriendRequest(fromID, toID string) common.Error {
+	qName := "acceptFriendRequest"
+	q := `WITH accepted AS (
+			DELETE FROM friend_requests WHERE to_id = $2 AND from_id = $1 AND expires_at > now()
+			RETURNING from_id, to_id
+		), recipient AS (
+			UPDATE users u SET friends = array_append(u.friends, a.from_id)
+			FROM accepted a WHERE u.id = a.to_id AND NOT (a.from_id = ANY(u.friends))
+		), sender AS (
+			UPDATE users u SET friends = array_append(u.friends, a.to_id)
+			FROM accepted a WHERE u.id = a.from_id AND NOT (a.to_id = ANY(u.friends))
+		), reverse AS (
+			DELETE FROM friend_requests r USING accepted a
+			WHERE r.to_id = a.from_id AND r.from_id = a.to_id
+		)
+		SELECT count(*) FROM accepted;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	var accepted int
+	err := db.write(toID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{fromID, toID}, &accepted)
+	})
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	if accepted == 0 {
+		return common.NewError(port.ErrInvalidKey, "No pending friend request")
+	}
+
+	return nil
+}
+
+// DeleteFriendRequest ...
+func (db *sqlDatabase) DeleteFriendRequest(fromID, toID string) common.Error {
+	qName := "deleteFriendRequest"
+	q := `WITH deleted AS (
+			DELETE FROM friend_requests WHERE to_id = $2 AND from_id = $1 AND expires_at > now()
+			RETURNING from_id
+		)
+		SELECT count(*) FROM deleted;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	var deleted int
+	err := db.write(toID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{fromID, toID}, &deleted)
+	})
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	if deleted == 0 {
+		return common.NewError(port.ErrInvalidKey, "No pending friend request")
+	}
+
+	return nil
+}
+
+// scanFriendRequest reads a friend request from a row of from_id, to_id,
+// created_at and expires_at
+func scanFriendRequest(rows *pgx.Rows) (*port.FriendRequest, error) {
+	request := new(port.FriendRequest)
+	err := rows.Scan(
+		&request.FromID,
+		&request.ToID,
+		&request.CreatedAt,
+		&request.ExpiresAt,
+	)
+
+	return request, err
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Referrals                                                           **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// sqlReferralCodeColumns are the columns read by scanReferralCode
+const sqlReferralCodeColumns = `code, user_id, max_redemptions, redemptions, created_at, expires_at`
+
+// NewReferralCode replaces the code of the user, resetting the redemptions
+func (db *sqlDatabase) NewReferralCode(code *port.ReferralCode) (*port.ReferralCode, common.Error) {
+	qName := "newReferralCode"
+	q := `INSERT INTO referral_codes (code, user_id, max_redemptions, expires_at) VALUES($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET (code, max_redemptions, redemptions, created_at, expires_at) =
+		(EXCLUDED.code, EXCLUDED.max_redemptions, 0, now(), EXCLUDED.expires_at)
+		RETURNING ` + sqlReferralCodeColumns + `;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var created *port.ReferralCode
+	err := db.write(code.UserID, func(pool *sqlPool) error {
+		args := []interface{}{code.Code, code.UserID, code.MaxRedemptions, code.ExpiresAt}
+		return db.query(pool, qName, args, func(rows *pgx.Rows) error {
+			var err error
+			created, err = scanReferralCode(rows)
+			return err
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return created, nil
+}
+
+// GetReferralCode ...
+func (db *sqlDatabase) GetReferralCode(userID string) (*port.ReferralCode, common.Error) {
+	qName := "getReferralCode"
+	q := `SELECT ` + sqlReferralCodeColumns + ` FROM referral_codes WHERE user_id = $1;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var code *port.ReferralCode
+	err := db.read(userID, func(pool *sqlPool) error {
+		return db.query(pool, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+			var err error
+			code, err = scanReferralCode(rows)
+			return err
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if code == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "No referral code")
+	}
+
+	return code, nil
+}
+
+// RedeemReferralCode counts the redemption and records the referral of the
+// user in the same statement, if the code is still available. Codes which
+// aren't redeemed are told apart by reading them again.
+func (db *sqlDatabase) RedeemReferralCode(code, userID string) (*port.ReferralCode, common.Error) {
+	qName := "redeemReferralCode"
+	q := `WITH redeemed AS (
+			UPDATE referral_codes SET redemptions = redemptions + 1
+			WHERE code = $1 AND expires_at > now() AND redemptions < max_redemptions
+			RETURNING ` + sqlReferralCodeColumns + `
+		), referral AS (
+			INSERT INTO referrals (referrer_id, user_id, code)
+			SELECT user_id, $2, code FROM redeemed
+		)
+		SELECT ` + sqlReferralCodeColumns + ` FROM redeemed;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var redeemed *port.ReferralCode
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.query(pool, qName, []interface{}{code, userID}, func(rows *pgx.Rows) error {
+			var err error
+			redeemed, err = scanReferralCode(rows)
+			return err
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if redeemed != nil {
+		return redeemed, nil
+	}
+
+	current, err := db.lookupReferralCode(db.primary, code)
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	switch {
+	case current == nil:
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid referral code")
+	case !time.Now().Before(current.ExpiresAt):
+		return nil, common.NewError(port.ErrUnavailable, "Referral code expired")
+	default:
+		return nil, common.NewError(port.ErrUnavailable, "Referral code used up")
+	}
+}
+
+// LookupReferralCode ...
+func (db *sqlDatabase) LookupReferralCode(code string) (*port.ReferralCode, common.Error) {
+	var found *port.ReferralCode
+	err := db.read("", func(pool *sqlPool) error {
+		var err error
+		found, err = db.lookupReferralCode(pool, code)
+		return err
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	if found == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid referral code")
+	}
+
+	return found, nil
+}
+
+// lookupReferralCode reads the referral code from the pool, nil if unknown
+func (db *sqlDatabase) lookupReferralCode(pool *sqlPool, code string) (*port.ReferralCode, error) {
+	qName := "lookupReferralCode"
+	q := `SELECT ` + sqlReferralCodeColumns + ` FROM referral_codes WHERE code = $1;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var found *port.ReferralCode
+	err := db.query(pool, qName, []interface{}{code}, func(rows *pgx.Rows) error {
+		var err error
+		found, err = scanReferralCode(rows)
+		return err
+	})
+
+	return found, err
+}
+
+// GetReferrals ...
+func (db *sqlDatabase) GetReferrals(userID string) ([]*port.Referral, common.Error) {
+	qName := "getReferrals"
+	q := `SELECT referrer_id, user_id, code, created_at FROM referrals
+		WHERE referrer_id = $1 ORDER BY created_at, user_id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var referrals []*port.Referral
+	err := db.read(userID, func(pool *sqlPool) error {
+		referrals = make([]*port.Referral, 0)
+		return db.query(pool, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+			referral, err := scanReferral(rows)
+			if err != nil {
+				return err
+			}
+
+			referrals = append(referrals, referral)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Users without referrals may not exist
+	if len(referrals) == 0 {
+		exists, err := db.UserExists(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+	}
+
+	return referrals, nil
+}
+
+// scanReferralCode reads a referral code from a row of sqlReferralCodeColumns
+func scanReferralCode(rows *pgx.Rows) (*port.ReferralCode, error) {
+	code := new(port.ReferralCode)
+	err := rows.Scan(
+		&code.Code,
+		&code.UserID,
+		&code.MaxRedemptions,
+		&code.Redemptions,
+		&code.CreatedAt,
+		&code.ExpiresAt,
+	)
+
+	return code, err
+}
+
+// scanReferral reads a referral from a row of referrer_id, user_id, code and
+// created_at
+func scanReferral(rows *pgx.Rows) (*port.Referral, error) {
+	referral := new(port.Referral)
+	err := rows.Scan(
+		&referral.ReferrerID,
+		&referral.UserID,
+		&referral.Code,
+		&referral.CreatedAt,
+	)
+
+	return referral, err
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Linked accounts                                                     **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// LinkAccount replaces the account the user linked of the same provider
+func (db *sqlDatabase) LinkAccount(account *port.LinkedAccount) (*port.LinkedAccount, common.Error) {
+	qName := "linkAccount"
+	q := `INSERT INTO linked_accounts (user_id, provider, external_id) VALUES($1, $2, $3)
+		ON CONFLICT (user_id, provider) DO UPDATE SET (external_id, linked_at) = (EXCLUDED.external_id, now())
+		RETURNING user_id, provider, external_id, linked_at;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var linked *port.LinkedAccount
+	err := db.write(account.UserID, func(pool *sqlPool) error {
+		args := []interface{}{account.UserID, account.Provider, account.ExternalID}
+		return db.query(pool, qName, args, func(rows *pgx.Rows) error {
+			var err error
+			linked, err = scanLinkedAccount(rows)
+			return err
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return linked, nil
+}
+
+// UnlinkAccount ...
+func (db *sqlDatabase) UnlinkAccount(userID, provider string) (bool, common.Error) {
+	qName := "unlinkAccount"
+	q := `WITH deleted AS (
+			DELETE FROM linked_accounts WHERE user_id = $1 AND provider = $2 RETURNING provider
+		)
+		SELECT (SELECT count(*) FROM deleted), EXISTS(SELECT 1 FROM users WHERE id = $1);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return false, err
+	}
+
+	var deleted int
+	var exists bool
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{userID, provider}, &deleted, &exists)
+	})
+
+	if err != nil {
+		return false, common.NewError(err, "")
+	}
+
+	if !exists {
+		return false, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
+	return deleted > 0, nil
+}
+
+// GetLinkedAccounts ...
+func (db *sqlDatabase) GetLinkedAccounts(userID string) ([]*port.LinkedAccount, common.Error) {
+	qName := "getLinkedAccounts"
+	q := `SELECT user_id, provider, external_id, linked_at FROM linked_accounts
+		WHERE user_id = $1 ORDER BY provider;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var accounts []*port.LinkedAccount
+	err := db.read(userID, func(pool *sqlPool) error {
+		accounts = make([]*port.LinkedAccount, 0)
+		return db.query(pool, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+			account, err := scanLinkedAccount(rows)
+			if err != nil {
+				return err
+			}
+
+			accounts = append(accounts, account)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	// Users without accounts may not exist
+	if len(accounts) == 0 {
+		exists, err := db.UserExists(userID)
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+		}
+	}
+
+	return accounts, nil
+}
+
+// FindLinkedAccounts ...
+func (db *sqlDatabase) FindLinkedAccounts(provider string, externalIDs []string) ([]*port.LinkedAccount, common.Error) {
+	qName := "findLinkedAccounts"
+	q := `SELECT user_id, provider, external_id, linked_at FROM linked_accounts
+		WHERE provider = $1 AND external_id = ANY($2) ORDER BY user_id;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var accounts []*port.LinkedAccount
+	err := db.read("", func(pool *sqlPool) error {
+		accounts = make([]*port.LinkedAccount, 0)
+		return db.query(pool, qName, []interface{}{provider, externalIDs}, func(rows *pgx.Rows) error {
+			account, err := scanLinkedAccount(rows)
+			if err != nil {
+				return err
+			}
+
+			accounts = append(accounts, account)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return accounts, nil
+}
+
+// scanLinkedAccount reads a linked account from a row of user_id, provider,
+// external_id and linked_at
+func scanLinkedAccount(rows *pgx.Rows) (*port.LinkedAccount, error) {
+	account := new(port.LinkedAccount)
+	err := rows.Scan(
+		&account.UserID,
+		&account.Provider,
+		&account.ExternalID,
+		&account.LinkedAt,
+	)
+
+	return account, err
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Leaderboard                                                         **
+**   Pages are read from users_leaderboard_idx, ordered by (-score, id)  **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// GetLeaderboard ...
+func (db *sqlDatabase) GetLeaderboard(offset, limit int, viewerID string) ([]*port.LeaderboardEntry, common.Error) {
+	qName := "getLeaderboard"
+	q := `WITH page AS (
+			SELECT id, name, score, NOT ` + sqlHideBlockers(3) + ` AS hidden
+			FROM users ORDER BY -score, id OFFSET $1 LIMIT $2
+		)
+		SELECT id, name, score, $1::bigint,
+			(SELECT count(*) FROM users WHERE score > (SELECT max(score) FROM page)),
+			hidden
+		FROM page ORDER BY -score, id;`
+
+	return db.leaderboard(qName, q, []interface{}{offset, limit, sqlViewer(viewerID)})
+}
+
+// GetLeaderboardAround ...
+func (db *sqlDatabase) GetLeaderboardAround(userID string, radius int, viewerID string) ([]*port.LeaderboardEntry, common.Error) {
+	qName := "getLeaderboardAround"
+	q := `WITH target AS (
+			SELECT -score AS key, id FROM users WHERE id = $1
+		), before AS (
+			SELECT users.id, users.name, users.score, NOT ` + sqlHideBlockers(3) + ` AS hidden
+			FROM users, target t WHERE (-users.score, users.id) < (t.key, t.id)
+			ORDER BY -users.score DESC, users.id DESC LIMIT $2
+		), after AS (
+			SELECT users.id, users.name, users.score, NOT ` + sqlHideBlockers(3) + ` AS hidden
+			FROM users, target t WHERE (-users.score, users.id) >= (t.key, t.id)
+			ORDER BY -users.score, users.id LIMIT $2 + 1
+		), page AS (
+			SELECT * FROM before UNION ALL SELECT * FROM after
+		)
+		SELECT id, name, score,
+			(SELECT count(*) FROM users u, target t WHERE (-u.score, u.id) < (t.key, t.id)) - (SELECT count(*) FROM before),
+			(SELECT count(*) FROM users WHERE score > (SELECT max(score) FROM page)),
+			hidden
+		FROM page ORDER BY -score, id;`
+
+	entries, err := db.leaderboard(qName, q, []interface{}{userID, radius, sqlViewer(viewerID)})
+	if err != nil {
+		return nil, err
+	}
+
+	// The user is a part of the page, unless unknown or hidden from the viewer
+	for _, entry := range entries {
+		if entry.UserID == userID {
+			return entries, nil
+		}
+	}
+
+	return nil, common.NewError(port.ErrInvalidKey, "Invalid UserID")
+}
+
+// GetLeaderboardSize ...
+func (db *sqlDatabase) GetLeaderboardSize() (int, common.Error) {
+	qName := "getLeaderboardSize"
+	q := `SELECT count(*) FROM users;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return 0, err
+	}
+
+	var size int
+	err := db.read("", func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, nil, &size)
+	})
+
+	if err != nil {
+		return 0, common.NewError(err, "")
+	}
+
+	return size, nil
+}
+
+// GetLeaderboardRange ...
+func (db *sqlDatabase) GetLeaderboardRange(key port.LeaderboardKey, before, after int) ([]*port.LeaderboardEntry, common.Error) {
+	qName := "getLeaderboardRange"
+	q := `WITH before AS (
+			SELECT id, name, score FROM users
+			WHERE (-score, id) < (-$1::int, $2::uuid) ORDER BY -score DESC, id DESC LIMIT $3
+		), after AS (
+			SELECT id, name, score FROM users
+			WHERE (-score, id) >= (-$1::int, $2::uuid) ORDER BY -score, id LIMIT $4
+		), page AS (
+			SELECT * FROM before UNION ALL SELECT * FROM after
+		)
+		SELECT id, name, score,
+			(SELECT count(*) FROM users WHERE (-score, id) < (-$1::int, $2::uuid)) - (SELECT count(*) FROM before),
+			(SELECT count(*) FROM users WHERE score > (SELECT max(score) FROM page)),
+			false
+		FROM page ORDER BY -score, id;`
+
+	return db.leaderboard(qName, q, []interface{}{key.Score, sqlLeaderboardID(key), before, after})
+}
+
+// CountLeaderboardAhead ...
+func (db *sqlDatabase) CountLeaderboardAhead(key port.LeaderboardKey) (int, common.Error) {
+	qName := "countLeaderboardAhead"
+	q := `SELECT count(*) FROM users WHERE (-score, id) < (-$1::int, $2::uuid);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return 0, err
+	}
+
+	var count int
+	err := db.read("", func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{key.Score, sqlLeaderboardID(key)}, &count)
+	})
+
+	if err != nil {
+		return 0, common.NewError(err, "")
+	}
+
+	return count, nil
+}
+
+// GetScoreDistribution reads the buckets counted by the trigger on users,
+// adding up the stripes of each bucket
+func (db *sqlDatabase) GetScoreDistribution() (*port.ScoreDistribution, common.Error) {
+	qName := "getScoreDistribution"
+	q := `SELECT bucket, sum(count)::bigint FROM score_buckets GROUP BY bucket HAVING sum(count) > 0;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var counts map[int]int
+	err := db.read("", func(pool *sqlPool) error {
+		counts = make(map[int]int)
+		return db.query(pool, qName, nil, func(rows *pgx.Rows) error {
+			var bucket, count int
+			if err := rows.Scan(&bucket, &count); err != nil {
+				return err
+			}
+
+			counts[bucket] = count
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return newScoreDistribution(counts), nil
+}
+
+// leaderboard reads and ranks a page of the leaderboard, from rows of id, name,
+// score, the number of entries before the page, the number of entries with a
+// higher score than the first and whether the entry is hidden. Hidden entries
+// are ranked before they're left out.
+func (db *sqlDatabase) leaderboard(qName, q string, args []interface{}) ([]*port.LeaderboardEntry, common.Error) {
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	var entries []*port.LeaderboardEntry
+	var hidden map[*port.LeaderboardEntry]bool
+	var position, higher int
+	err := db.read("", func(pool *sqlPool) error {
+		entries = make([]*port.LeaderboardEntry, 0)
+		hidden = make(map[*port.LeaderboardEntry]bool)
+		return db.query(pool, qName, args, func(rows *pgx.Rows) error {
+			entry := new(port.LeaderboardEntry)
+			var isHidden bool
+			if err := rows.Scan(&entry.UserID, &entry.Name, &entry.Score, &position, &higher, &isHidden); err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+			hidden[entry] = isHidden
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	rankEntries(entries, position, higher)
+
+	visible := make([]*port.LeaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !hidden[entry] {
+			visible = append(visible, entry)
+		}
+	}
+
+	return visible, nil
+}
+
+// sqlLeaderboardID returns the id of a leaderboard key, the nil UUID is
+// ordered before every other id the same way as an empty UserID
+func sqlLeaderboardID(key port.LeaderboardKey) string {
+	if key.UserID == "" {
+		return "00000000-0000-0000-0000-000000000000"
+	}
+
+	return key.UserID
+}
+
+// GetPeriodLeaderboard ...
+func (db *sqlDatabase) GetPeriodLeaderboard(period *port.LeaderboardPeriod, offset, limit int) ([]*port.LeaderboardEntry, common.Error) {
+	qName := "getPeriodLeaderboard"
+	q := `WITH best AS (
+			SELECT user_id AS id, max(score) AS score FROM score_runs
+			WHERE submitted_at >= $1 AND submitted_at < $2 GROUP BY user_id
+		), page AS (
+			SELECT b.id, u.name, b.score FROM best b JOIN users u ON u.id = b.id
+			ORDER BY -b.score, b.id OFFSET $3 LIMIT $4
+		)
+		SELECT id, name, score, $3::bigint,
+			(SELECT count(*) FROM best WHERE score > (SELECT max(score) FROM page)),
+			false
+		FROM page ORDER BY -score, id;`
+
+	return db.leaderboard(qName, q, []interface{}{period.Start, period.End, offset, limit})
+}
+
+// GetPeriodLeaderboardSize ...
+func (db *sqlDatabase) GetPeriodLeaderboardSize(period *port.LeaderboardPeriod) (int, common.Error) {
+	qName := "getPeriodLeaderboardSize"
+	q := `SELECT count(DISTINCT user_id) FROM score_runs WHERE submitted_at >= $1 AND submitted_at < $2;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return 0, err
+	}
+
+	var size int
+	err := db.read("", func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{period.Start, period.End}, &size)
+	})
+
+	if err != nil {
+		return 0, common.NewError(err, "")
+	}
+
+	return size, nil
+}
+
+// ArchiveLeaderboard replaces the results of the users in the period, users
+// not stored here are skipped by the join
+func (db *sqlDatabase) ArchiveLeaderboard(period *port.LeaderboardPeriod, entries []*port.LeaderboardEntry) common.Error {
+	qName := "archiveLeaderboard"
+	q := `INSERT INTO leaderboard_results (user_id, window_name, period, score, rank)
+		SELECT u.id, $1, $2, e.score, e.rank
+		FROM unnest($3::uuid[], $4::int[], $5::int[]) AS e(user_id, score, rank)
+		JOIN users u ON u.id = e.user_id
+		ON CONFLICT (user_id, window_name, period) DO UPDATE SET (score, rank) =
+		(EXCLUDED.score, EXCLUDED.rank);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	userIDs := make([]string, 0, len(entries))
+	scores := make([]int32, 0, len(entries))
+	ranks := make([]int32, 0, len(entries))
+	for _, entry := range entries {
+		userIDs = append(userIDs, entry.UserID)
+		scores = append(scores, int32(entry.Score))
+		ranks = append(ranks, int32(entry.Rank))
+	}
+
+	err := db.write("", func(pool *sqlPool) error {
+		return db.exec(pool, qName, period.Window, period.Key, userIDs, scores, ranks)
+	})
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	return nil
 }
 
-var _ port.Datastore = &sqlDatabase{}
+// CompleteLeaderboardArchive ...
+func (db *sqlDatabase) CompleteLeaderboardArchive(archive *port.LeaderboardArchive) common.Error {
+	qName := "completeLeaderboardArchive"
+	q := `INSERT INTO leaderboard_archives (window_name, period, total, archived_at)
+		VALUES ($1, $2, $3, COALESCE($4, now()))
+		ON CONFLICT (window_name, period) DO UPDATE SET (total, archived_at) =
+		(EXCLUDED.total, EXCLUDED.archived_at);`
 
-func (db *sqlDatabase) Prepare(name, query string) common.Error {
-	_, ok := db.preparedStatements[name]
-	if ok {
-		return nil
+	if err := db.Prepare(qName, q); err != nil {
+		return err
 	}
 
-	ps, err := db.connection.Prepare(name, query)
+	var archivedAt *time.Time
+	if !archive.ArchivedAt.IsZero() {
+		archivedAt = &archive.ArchivedAt
+	}
+
+	err := db.write("", func(pool *sqlPool) error {
+		return db.exec(pool, qName, archive.Window, archive.Period, archive.Total, archivedAt)
+	})
+
 	if err != nil {
 		return common.NewError(err, "")
 	}
 
-	db.preparedStatements[name] = ps
 	return nil
 }
 
-// NewSQLConnection creates a connection to a PostgreSQL server
-func NewSQLConnection(uri string, maxConnections int) (port.Datastore, common.Error) {
-	config, err := pgx.ParseURI(uri)
+// GetLeaderboardArchive ...
+func (db *sqlDatabase) GetLeaderboardArchive(period *port.LeaderboardPeriod) (*port.LeaderboardArchive, common.Error) {
+	qName := "getLeaderboardArchive"
+	q := `SELECT window_name, period, total, archived_at FROM leaderboard_archives
+		WHERE window_name = $1 AND period = $2;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	archive := new(port.LeaderboardArchive)
+	err := db.read("", func(pool *sqlPool) error {
+		return db.queryRow(pool, qName, []interface{}{period.Window, period.Key},
+			&archive.Window,
+			&archive.Period,
+			&archive.Total,
+			&archive.ArchivedAt,
+		)
+	})
+
+	if err == pgx.ErrNoRows {
+		return nil, common.NewError(port.ErrInvalidKey, "Period not archived")
+	}
+
 	if err != nil {
-		fmt.Println("Hi!")
 		return nil, common.NewError(err, "")
 	}
 
-	if maxConnections < 2 {
-		maxConnections = 5 // pgx default
+	return archive, nil
+}
+
+// GetArchivedLeaderboard reads the results with the ranks they're archived with
+func (db *sqlDatabase) GetArchivedLeaderboard(period *port.LeaderboardPeriod, offset, limit int) ([]*port.LeaderboardEntry, common.Error) {
+	qName := "getArchivedLeaderboard"
+	q := `SELECT r.user_id, u.name, r.score, r.rank
+		FROM leaderboard_results r JOIN users u ON u.id = r.user_id
+		WHERE r.window_name = $1 AND r.period = $2
+		ORDER BY -r.score, r.user_id OFFSET $3 LIMIT $4;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
 	}
 
-	poolConfig := pgx.ConnPoolConfig{
-		ConnConfig:     config,
-		MaxConnections: maxConnections,
+	var entries []*port.LeaderboardEntry
+	err := db.read("", func(pool *sqlPool) error {
+		entries = make([]*port.LeaderboardEntry, 0)
+		args := []interface{}{period.Window, period.Key, offset, limit}
+		return db.query(pool, qName, args, func(rows *pgx.Rows) error {
+			entry := new(port.LeaderboardEntry)
+			if err := rows.Scan(&entry.UserID, &entry.Name, &entry.Score, &entry.Rank); err != nil {
+				return err
+			}
+
+			entries = append(entries, entry)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
 	}
 
-	conn, err := pgx.NewConnPool(poolConfig)
+	return entries, nil
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Moving users                                                        **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// ExportUser ...
+func (db *sqlDatabase) ExportUser(userID string) (*port.UserRecord, common.Error) {
+	qName := "exportUser"
+	q := `SELECT id, name, state, games_played, score, state_revision, friends::text[]
+		FROM users WHERE id = $1;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	// Always read from the primary, as the record is about to be moved
+	record := new(port.UserRecord)
+	var gamesPlayed, score int
+	err := db.queryRow(db.primary, qName, []interface{}{userID},
+		&record.User.UserID,
+		&record.User.Name,
+		&record.GameState,
+		&gamesPlayed,
+		&score,
+		&record.GameState.Revision,
+		&record.FriendIDs,
+	)
+
 	if err != nil {
-		fmt.Println("Hi2!")
 		return nil, common.NewError(err, "")
 	}
 
-	db := &sqlDatabase{
-		connection:         conn,
-		preparedStatements: make(map[string]*pgx.PreparedStatement),
+	if err := loadGameState(&record.GameState, gamesPlayed, score); err != nil {
+		return nil, err
 	}
 
-	return db, nil
-}
+	qName = "exportUserRuns"
+	q = `SELECT score, submitted_at, coalesce(run_key, '') FROM score_runs WHERE user_id = $1 ORDER BY submitted_at, id;`
 
-// NewUser ...
-func (db *sqlDatabase) NewUser(id, name string) (*port.User, common.Error) {
-	qName := "newUser"
-	q := `INSERT INTO users (id, name) VALUES($1, $2) RETURNING id, name;`
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	record.Runs = make([]port.ScoreRun, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		run := port.ScoreRun{}
+		if err := rows.Scan(&run.Score, &run.SubmittedAt, &run.Key); err != nil {
+			return err
+		}
+
+		record.Runs = append(record.Runs, run)
+		return nil
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	qName = "exportUserRevisions"
+	q = `SELECT revision, state, author, source, created_at FROM state_revisions
+		WHERE user_id = $1 ORDER BY revision;`
 
 	if err := db.Prepare(qName, q); err != nil {
 		return nil, err
 	}
 
-	user := new(port.User)
-	err := db.connection.QueryRow(qName, id, name).
-		Scan(&user.UserID, &user.Name)
+	record.Revisions = make([]port.StateRevision, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return err
+		}
+
+		record.Revisions = append(record.Revisions, *revision)
+		return nil
+	})
 
 	if err != nil {
 		return nil, common.NewError(err, "")
 	}
 
-	return user, nil
-}
+	qName = "exportUserSlots"
+	q = `SELECT ` + sqlSlotColumns + `
+		FROM save_slots s JOIN users u ON u.id = s.user_id
+		WHERE s.user_id = $1 ORDER BY s.created_at, s.id;`
 
-// GetUsers ...
-func (db *sqlDatabase) GetUsers() ([]*port.User, common.Error) {
-	qName := "getUser"
-	q := `SELECT id, name FROM users ORDER BY id;`
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	record.Slots = make([]port.SaveSlot, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		slot, err := scanSlot(rows)
+		if err != nil {
+			return err
+		}
+
+		record.Slots = append(record.Slots, *slot)
+		return nil
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	qName = "exportUserFlag"
+	q = `SELECT user_id, reason, count, first_flagged_at, last_flagged_at FROM user_flags
+		WHERE user_id = $1;`
 
 	if err := db.Prepare(qName, q); err != nil {
 		return nil, err
 	}
 
-	rows, err := db.connection.Query(qName)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		var err error
+		record.Flag, err = scanFlag(rows)
+		return err
+	})
+
 	if err != nil {
 		return nil, common.NewError(err, "")
 	}
 
-	users := make([]*port.User, 0)
-	for rows.Next() {
-		user := new(port.User)
+	qName = "exportUserFriendRequests"
+	q = `SELECT from_id, to_id, created_at, expires_at FROM friend_requests
+		WHERE to_id = $1 AND expires_at > now() ORDER BY created_at, from_id;`
 
-		if err := rows.Scan(&user.UserID, &user.Name); err != nil {
-			return nil, common.NewError(err, "")
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	record.FriendRequests = make([]port.FriendRequest, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		request, err := scanFriendRequest(rows)
+		if err != nil {
+			return err
 		}
 
-		users = append(users, user)
+		record.FriendRequests = append(record.FriendRequests, *request)
+		return nil
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
 	}
 
-	return users, nil
-}
+	qName = "exportUserBlocks"
+	q = `SELECT user_id, blocked_id, created_at FROM user_blocks
+		WHERE user_id = $1 ORDER BY created_at, blocked_id;`
 
-func (db *sqlDatabase) UserExists(id string) (bool, common.Error) {
-	qName := "userExists"
-	q := `SELECT EXISTS(SELECT 1 FROM users WHERE id=$1);`
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	record.Blocks = make([]port.UserBlock, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		block, err := scanBlock(rows)
+		if err != nil {
+			return err
+		}
+
+		record.Blocks = append(record.Blocks, *block)
+		return nil
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	qName = "exportUserReferralCode"
+	q = `SELECT ` + sqlReferralCodeColumns + ` FROM referral_codes WHERE user_id = $1;`
 
 	if err := db.Prepare(qName, q); err != nil {
-		return false, err
+		return nil, err
 	}
 
-	var check bool
-	err := db.connection.QueryRow(qName, id).
-		Scan(&check)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		var err error
+		record.ReferralCode, err = scanReferralCode(rows)
+		return err
+	})
 
 	if err != nil {
-		return false, common.NewError(err, "")
+		return nil, common.NewError(err, "")
 	}
 
-	return check, nil
-}
+	qName = "exportUserReferrals"
+	q = `SELECT referrer_id, user_id, code, created_at FROM referrals
+		WHERE referrer_id = $1 ORDER BY created_at, user_id;`
 
-// UpdateGameState ...
-func (db *sqlDatabase) UpdateGameState(userID string, gamesPlayed, score int) common.Error {
-	qName := "updateGameState"
-	q := `UPDATE users SET (games_played, score) = ($1, $2) WHERE id = $3;`
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	record.Referrals = make([]port.Referral, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		referral, err := scanReferral(rows)
+		if err != nil {
+			return err
+		}
+
+		record.Referrals = append(record.Referrals, *referral)
+		return nil
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	qName = "exportUserLinkedAccounts"
+	q = `SELECT user_id, provider, external_id, linked_at FROM linked_accounts
+		WHERE user_id = $1 ORDER BY linked_at, provider;`
 
 	if err := db.Prepare(qName, q); err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err := db.connection.Exec(qName, gamesPlayed, score, userID)
+	record.LinkedAccounts = make([]port.LinkedAccount, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		account, err := scanLinkedAccount(rows)
+		if err != nil {
+			return err
+		}
+
+		record.LinkedAccounts = append(record.LinkedAccounts, *account)
+		return nil
+	})
+
 	if err != nil {
-		return common.NewError(err, "")
+		return nil, common.NewError(err, "")
 	}
 
-	return nil
-}
+	qName = "exportUserLeaderboardResults"
+	q = `SELECT window_name, period, score, rank FROM leaderboard_results
+		WHERE user_id = $1 ORDER BY window_name, period;`
 
-// GetGameState ...
-func (db *sqlDatabase) GetGameState(userID string) (*port.GameState, common.Error) {
-	qName := "getGameState"
-	q := `SELECT (games_played, score) FROM users WHERE id = $1;`
+	if err := db.Prepare(qName, q); err != nil {
+		return nil, err
+	}
+
+	record.LeaderboardResults = make([]port.LeaderboardResult, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		result := port.LeaderboardResult{}
+		if err := rows.Scan(&result.Window, &result.Period, &result.Score, &result.Rank); err != nil {
+			return err
+		}
+
+		record.LeaderboardResults = append(record.LeaderboardResults, result)
+		return nil
+	})
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	qName = "exportUserFriendScoreViews"
+	q = `SELECT friend_id, score, viewed_at FROM friend_score_views WHERE user_id = $1 ORDER BY friend_id;`
 
 	if err := db.Prepare(qName, q); err != nil {
 		return nil, err
 	}
 
-	gameState := new(port.GameState)
-	err := db.connection.QueryRow(qName, userID).Scan(&gameState.GamesPlayed, &gameState.Score)
+	record.FriendScoreViews = make([]port.FriendScoreView, 0)
+	err = db.query(db.primary, qName, []interface{}{userID}, func(rows *pgx.Rows) error {
+		view := port.FriendScoreView{}
+		if err := rows.Scan(&view.FriendID, &view.Score, &view.ViewedAt); err != nil {
+			return err
+		}
+
+		record.FriendScoreViews = append(record.FriendScoreViews, view)
+		return nil
+	})
+
 	if err != nil {
 		return nil, common.NewError(err, "")
 	}
 
-	return gameState, nil
+	return record, nil
 }
 
-// UpdateFriends ...
-func (db *sqlDatabase) UpdateFriends(userID string, friends []string) common.Error {
-	qName := "updateFriends"
-	q := `UPDATE users SET (friends) = ($1) WHERE id = $2;`
+// ImportUser ...
+func (db *sqlDatabase) ImportUser(record *port.UserRecord) common.Error {
+	qName := "importUser"
+	q := `WITH imported AS (
+			INSERT INTO users (id, name, state, games_played, score, friends, state_revision, active_slot)
+			VALUES($1, $2, $3, $4, $5, $6, $9, $22)
+			ON CONFLICT (id) DO UPDATE SET (name, state, games_played, score, friends, state_revision, active_slot) =
+			(EXCLUDED.name, EXCLUDED.state, EXCLUDED.games_played, EXCLUDED.score, EXCLUDED.friends,
+			EXCLUDED.state_revision, EXCLUDED.active_slot)
+			RETURNING id
+		), cleared AS (
+			DELETE FROM score_runs WHERE user_id = $1
+		), revisionsCleared AS (
+			DELETE FROM state_revisions WHERE user_id = $1 AND revision <> ALL($10::int[])
+		), revisions AS (
+			INSERT INTO state_revisions (user_id, revision, state, author, source, created_at)
+			SELECT imported.id, h.revision, h.state::jsonb, h.author, h.source, h.created_at
+			FROM imported, unnest($10::int[], $11::text[], $12::text[], $13::text[], $14::timestamptz[])
+				AS h(revision, state, author, source, created_at)
+			ON CONFLICT (user_id, revision) DO UPDATE SET (state, author, source, created_at) =
+			(EXCLUDED.state, EXCLUDED.author, EXCLUDED.source, EXCLUDED.created_at)
+		), slotsCleared AS (
+			DELETE FROM save_slots WHERE user_id = $1 AND id <> ALL($15::uuid[])
+		), slots AS (
+			INSERT INTO save_slots (user_id, id, name, state, last_device, play_time, created_at, updated_at)
+			SELECT imported.id, s.id, s.name, s.state::jsonb, s.last_device, s.play_time, s.created_at, s.updated_at
+			FROM imported, unnest($15::uuid[], $16::text[], $17::text[], $18::text[], $19::int[],
+				$20::timestamptz[], $21::timestamptz[])
+				AS s(id, name, state, last_device, play_time, created_at, updated_at)
+			ON CONFLICT (user_id, id) DO UPDATE SET (name, state, last_device, play_time, created_at, updated_at) =
+			(EXCLUDED.name, EXCLUDED.state, EXCLUDED.last_device, EXCLUDED.play_time, EXCLUDED.created_at,
+			EXCLUDED.updated_at)
+		), flagCleared AS (
+			DELETE FROM user_flags WHERE user_id = $1 AND $23::int = 0
+		), flag AS (
+			INSERT INTO user_flags (user_id, reason, count, first_flagged_at, last_flagged_at)
+			SELECT imported.id, $24, $23, $25, $26 FROM imported WHERE $23::int > 0
+			ON CONFLICT (user_id) DO UPDATE SET (reason, count, first_flagged_at, last_flagged_at) =
+			(EXCLUDED.reason, EXCLUDED.count, EXCLUDED.first_flagged_at, EXCLUDED.last_flagged_at)
+		), requestsCleared AS (
+			DELETE FROM friend_requests WHERE to_id = $1 AND from_id <> ALL($27::uuid[])
+		), requests AS (
+			INSERT INTO friend_requests (to_id, from_id, created_at, expires_at)
+			SELECT imported.id, f.from_id, f.created_at, f.expires_at
+			FROM imported, unnest($27::uuid[], $28::timestamptz[], $29::timestamptz[])
+				AS f(from_id, created_at, expires_at)
+			ON CONFLICT (to_id, from_id) DO UPDATE SET (created_at, expires_at) =
+			(EXCLUDED.created_at, EXCLUDED.expires_at)
+		), blocksCleared AS (
+			DELETE FROM user_blocks WHERE user_id = $1 AND blocked_id <> ALL($30::uuid[])
+		), blocks AS (
+			INSERT INTO user_blocks (user_id, blocked_id, created_at)
+			SELECT imported.id, b.blocked_id, b.created_at
+			FROM imported, unnest($30::uuid[], $31::timestamptz[]) AS b(blocked_id, created_at)
+			ON CONFLICT (user_id, blocked_id) DO UPDATE SET created_at = EXCLUDED.created_at
+		), referralCodeCleared AS (
+			DELETE FROM referral_codes WHERE user_id = $1 AND $32::text = ''
+		), referralCode AS (
+			INSERT INTO referral_codes (code, user_id, max_redemptions, redemptions, created_at, expires_at)
+			SELECT $32, imported.id, $33, $34, $35, $36 FROM imported WHERE $32::text <> ''
+			ON CONFLICT (user_id) DO UPDATE SET (code, max_redemptions, redemptions, created_at, expires_at) =
+			(EXCLUDED.code, EXCLUDED.max_redemptions, EXCLUDED.redemptions, EXCLUDED.created_at, EXCLUDED.expires_at)
+		), referralsCleared AS (
+			DELETE FROM referrals WHERE referrer_id = $1 AND user_id <> ALL($37::uuid[])
+		), referred AS (
+			INSERT INTO referrals (referrer_id, user_id, code, created_at)
+			SELECT imported.id, r.user_id, r.code, r.created_at
+			FROM imported, unnest($37::uuid[], $38::text[], $39::timestamptz[]) AS r(user_id, code, created_at)
+			ON CONFLICT (referrer_id, user_id) DO UPDATE SET (code, created_at) = (EXCLUDED.code, EXCLUDED.created_at)
+		), accountsCleared AS (
+			DELETE FROM linked_accounts WHERE user_id = $1 AND provider <> ALL($40::text[])
+		), accounts AS (
+			INSERT INTO linked_accounts (user_id, provider, external_id, linked_at)
+			SELECT imported.id, a.provider, a.external_id, a.linked_at
+			FROM imported, unnest($40::text[], $41::text[], $42::timestamptz[]) AS a(provider, external_id, linked_at)
+			ON CONFLICT (user_id, provider) DO UPDATE SET (external_id, linked_at) =
+			(EXCLUDED.external_id, EXCLUDED.linked_at)
+		), resultsCleared AS (
+			DELETE FROM leaderboard_results WHERE user_id = $1
+				AND (window_name, period) NOT IN (SELECT * FROM unnest($43::text[], $44::text[]))
+		), results AS (
+			INSERT INTO leaderboard_results (user_id, window_name, period, score, rank)
+			SELECT imported.id, r.window_name, r.period, r.score, r.rank
+			FROM imported, unnest($43::text[], $44::text[], $45::int[], $46::int[]) AS r(window_name, period, score, rank)
+			ON CONFLICT (user_id, window_name, period) DO UPDATE SET (score, rank) = (EXCLUDED.score, EXCLUDED.rank)
+		), viewsCleared AS (
+			DELETE FROM friend_score_views WHERE user_id = $1 AND friend_id <> ALL($47::uuid[])
+		), views AS (
+			INSERT INTO friend_score_views (user_id, friend_id, score, viewed_at)
+			SELECT imported.id, v.friend_id, v.score, v.viewed_at
+			FROM imported, unnest($47::uuid[], $48::int[], $49::timestamptz[]) AS v(friend_id, score, viewed_at)
+			ON CONFLICT (user_id, friend_id) DO UPDATE SET (score, viewed_at) = (EXCLUDED.score, EXCLUDED.viewed_at)
+		)
+		INSERT INTO score_runs (user_id, score, submitted_at, run_key)
+		SELECT imported.id, r.score, r.submitted_at, nullif(r.run_key, '')
+		FROM imported, unnest($7::int[], $8::timestamptz[], $50::text[]) AS r(score, submitted_at, run_key);`
 
 	if err := db.Prepare(qName, q); err != nil {
 		return err
 	}
 
-	_, err := db.connection.Exec(qName, friends, userID)
+	// Runs and revisions are replaced, so importing the same record twice is
+	// harmless
+	scores := make([]int32, len(record.Runs))
+	submitted := make([]time.Time, len(record.Runs))
+	keys := make([]string, len(record.Runs))
+	for i, run := range record.Runs {
+		scores[i] = int32(run.Score)
+		submitted[i] = run.SubmittedAt
+		keys[i] = run.Key
+	}
+
+	history := struct {
+		revisions []int32
+		states    []string
+		authors   []string
+		sources   []string
+		created   []time.Time
+	}{
+		revisions: make([]int32, 0, len(record.Revisions)),
+		states:    make([]string, 0, len(record.Revisions)),
+		authors:   make([]string, 0, len(record.Revisions)),
+		sources:   make([]string, 0, len(record.Revisions)),
+		created:   make([]time.Time, 0, len(record.Revisions)),
+	}
+
+	for _, revision := range record.Revisions {
+		state, err := json.Marshal(revision.State)
+		if err != nil {
+			return common.NewError(port.ErrInvalidDocument, err.Error())
+		}
+
+		history.revisions = append(history.revisions, int32(revision.Revision))
+		history.states = append(history.states, string(state))
+		history.authors = append(history.authors, revision.Author)
+		history.sources = append(history.sources, revision.Source)
+		history.created = append(history.created, revision.CreatedAt)
+	}
+
+	// Records without slots are given the default slot
+	slots := record.Slots
+	if len(slots) == 0 {
+		now := time.Now()
+		slots = []port.SaveSlot{{
+			SlotID:    record.User.UserID,
+			Name:      port.DefaultSaveSlotName,
+			Active:    true,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}}
+	}
+
+	saves := struct {
+		ids        []string
+		names      []string
+		states     []string
+		devices    []string
+		playTimes  []int32
+		created    []time.Time
+		updated    []time.Time
+		activeSlot string
+	}{
+		ids:       make([]string, 0, len(slots)),
+		names:     make([]string, 0, len(slots)),
+		states:    make([]string, 0, len(slots)),
+		devices:   make([]string, 0, len(slots)),
+		playTimes: make([]int32, 0, len(slots)),
+		created:   make([]time.Time, 0, len(slots)),
+		updated:   make([]time.Time, 0, len(slots)),
+	}
+
+	for _, slot := range slots {
+		// The state of the active slot is the game state of the user
+		state := []byte(`{"version": 1}`)
+		if slot.Active {
+			saves.activeSlot = slot.SlotID
+		} else {
+			var err error
+			if state, err = json.Marshal(slot.State); err != nil {
+				return common.NewError(port.ErrInvalidDocument, err.Error())
+			}
+		}
+
+		saves.ids = append(saves.ids, slot.SlotID)
+		saves.names = append(saves.names, slot.Name)
+		saves.states = append(saves.states, string(state))
+		saves.devices = append(saves.devices, slot.LastDevice)
+		saves.playTimes = append(saves.playTimes, int32(slot.PlayTime))
+		saves.created = append(saves.created, slot.CreatedAt)
+		saves.updated = append(saves.updated, slot.UpdatedAt)
+	}
+
+	// Records without a flag clear the flag of the user
+	flag := record.Flag
+	if flag == nil {
+		flag = new(port.UserFlag)
+	}
+
+	requests := struct {
+		fromIDs []string
+		created []time.Time
+		expires []time.Time
+	}{
+		fromIDs: make([]string, 0, len(record.FriendRequests)),
+		created: make([]time.Time, 0, len(record.FriendRequests)),
+		expires: make([]time.Time, 0, len(record.FriendRequests)),
+	}
+
+	for _, request := range record.FriendRequests {
+		requests.fromIDs = append(requests.fromIDs, request.FromID)
+		requests.created = append(requests.created, request.CreatedAt)
+		requests.expires = append(requests.expires, request.ExpiresAt)
+	}
+
+	blocks := struct {
+		blockedIDs []string
+		created    []time.Time
+	}{
+		blockedIDs: make([]string, 0, len(record.Blocks)),
+		created:    make([]time.Time, 0, len(record.Blocks)),
+	}
+
+	for _, block := range record.Blocks {
+		blocks.blockedIDs = append(blocks.blockedIDs, block.BlockedID)
+		blocks.created = append(blocks.created, block.CreatedAt)
+	}
+
+	// Records without a referral code clear the code of the user
+	code := record.ReferralCode
+	if code == nil {
+		code = new(port.ReferralCode)
+	}
+
+	referrals := struct {
+		userIDs []string
+		codes   []string
+		created []time.Time
+	}{
+		userIDs: make([]string, 0, len(record.Referrals)),
+		codes:   make([]string, 0, len(record.Referrals)),
+		created: make([]time.Time, 0, len(record.Referrals)),
+	}
+
+	for _, referral := range record.Referrals {
+		referrals.userIDs = append(referrals.userIDs, referral.UserID)
+		referrals.codes = append(referrals.codes, referral.Code)
+		referrals.created = append(referrals.created, referral.CreatedAt)
+	}
+
+	accounts := struct {
+		providers   []string
+		externalIDs []string
+		linked      []time.Time
+	}{
+		providers:   make([]string, 0, len(record.LinkedAccounts)),
+		externalIDs: make([]string, 0, len(record.LinkedAccounts)),
+		linked:      make([]time.Time, 0, len(record.LinkedAccounts)),
+	}
+
+	for _, account := range record.LinkedAccounts {
+		accounts.providers = append(accounts.providers, account.Provider)
+		accounts.externalIDs = append(accounts.externalIDs, account.ExternalID)
+		accounts.linked = append(accounts.linked, account.LinkedAt)
+	}
+
+	results := struct {
+		windows []string
+		periods []string
+		scores  []int32
+		ranks   []int32
+	}{
+		windows: make([]string, 0, len(record.LeaderboardResults)),
+		periods: make([]string, 0, len(record.LeaderboardResults)),
+		scores:  make([]int32, 0, len(record.LeaderboardResults)),
+		ranks:   make([]int32, 0, len(record.LeaderboardResults)),
+	}
+
+	for _, result := range record.LeaderboardResults {
+		results.windows = append(results.windows, result.Window)
+		results.periods = append(results.periods, result.Period)
+		results.scores = append(results.scores, int32(result.Score))
+		results.ranks = append(results.ranks, int32(result.Rank))
+	}
+
+	views := struct {
+		friendIDs []string
+		scores    []int32
+		viewed    []time.Time
+	}{
+		friendIDs: make([]string, 0, len(record.FriendScoreViews)),
+		scores:    make([]int32, 0, len(record.FriendScoreViews)),
+		viewed:    make([]time.Time, 0, len(record.FriendScoreViews)),
+	}
+
+	for _, view := range record.FriendScoreViews {
+		views.friendIDs = append(views.friendIDs, view.FriendID)
+		views.scores = append(views.scores, int32(view.Score))
+		views.viewed = append(views.viewed, view.ViewedAt)
+	}
+
+	err := db.write(record.User.UserID, func(pool *sqlPool) error {
+		return db.exec(pool, qName,
+			record.User.UserID,
+			record.User.Name,
+			&record.GameState,
+			record.GameState.GamesPlayed(),
+			record.GameState.Score(),
+			record.FriendIDs,
+			scores,
+			submitted,
+			record.GameState.Revision,
+			history.revisions,
+			history.states,
+			history.authors,
+			history.sources,
+			history.created,
+			saves.ids,
+			saves.names,
+			saves.states,
+			saves.devices,
+			saves.playTimes,
+			saves.created,
+			saves.updated,
+			saves.activeSlot,
+			flag.Count,
+			flag.Reason,
+			flag.FirstFlaggedAt,
+			flag.LastFlaggedAt,
+			requests.fromIDs,
+			requests.created,
+			requests.expires,
+			blocks.blockedIDs,
+			blocks.created,
+			code.Code,
+			code.MaxRedemptions,
+			code.Redemptions,
+			code.CreatedAt,
+			code.ExpiresAt,
+			referrals.userIDs,
+			referrals.codes,
+			referrals.created,
+			accounts.providers,
+			accounts.externalIDs,
+			accounts.linked,
+			results.windows,
+			results.periods,
+			results.scores,
+			results.ranks,
+			views.friendIDs,
+			views.scores,
+			views.viewed,
+			keys,
+		)
+	})
+
 	if err != nil {
 		return common.NewError(err, "")
 	}
@@ -181,44 +4042,127 @@ func (db *sqlDatabase) UpdateFriends(userID string, friends []string) common.Err
 	return nil
 }
 
-// GetFriends ...
-func (db *sqlDatabase) GetFriends(userID string) ([]*port.Friend, common.Error) {
-	qName := "getFriends"
-	q := `SELECT id, name, score FROM users WHERE id = ANY((SELECT unnest(friends) FROM users WHERE id = $1)) ORDER BY id;`
+// GetGameIDs ...
+func (db *sqlDatabase) GetGameIDs() ([]string, common.Error) {
+	qName := "getGameIDs"
+	q := `SELECT id::text FROM games ORDER BY id;`
 
 	if err := db.Prepare(qName, q); err != nil {
 		return nil, err
 	}
 
-	rows, err := db.connection.Query(qName, userID)
+	// Always read from the primary, as the games are about to be moved
+	gameIDs := make([]string, 0)
+	err := db.query(db.primary, qName, nil, func(rows *pgx.Rows) error {
+		var gameID string
+		if err := rows.Scan(&gameID); err != nil {
+			return err
+		}
+
+		gameIDs = append(gameIDs, gameID)
+		return nil
+	})
+
 	if err != nil {
 		return nil, common.NewError(err, "")
 	}
 
-	friends := make([]*port.Friend, 0)
-	for rows.Next() {
-		friend := new(port.Friend)
+	return gameIDs, nil
+}
 
-		if err := rows.Scan(&friend.UserID, &friend.Name, &friend.HighScore); err != nil {
-			return nil, common.NewError(err, "")
-		}
+// ExportGame ...
+func (db *sqlDatabase) ExportGame(gameID string) (*port.Game, common.Error) {
+	game, err := db.loadGame(db.primary, gameID)
+	if err == pgx.ErrNoRows {
+		return nil, common.NewError(port.ErrInvalidKey, "Invalid GameID")
+	}
+
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	return game, nil
+}
+
+// ImportGame ...
+func (db *sqlDatabase) ImportGame(game *port.Game) common.Error {
+	qName := "importGame"
+	q := `WITH game AS (
+			INSERT INTO games (id) VALUES ($1)
+			ON CONFLICT (id) DO UPDATE SET id = EXCLUDED.id
+			RETURNING id
+		)
+		INSERT INTO game_participants (game_id, position, user_id, status, score)
+		SELECT game.id, p.position, p.user_id, p.status, p.score
+		FROM game, unnest($2::uuid[], $3::text[], $4::int[]) WITH ORDINALITY AS p(user_id, status, score, position)
+		ON CONFLICT (game_id, user_id) DO UPDATE SET (position, status, score) =
+		(EXCLUDED.position, EXCLUDED.status, EXCLUDED.score);`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
 
-		friends = append(friends, friend)
+	userIDs := make([]string, len(game.Participants))
+	statuses := make([]string, len(game.Participants))
+	scores := make([]int32, len(game.Participants))
+	for i, p := range game.Participants {
+		userIDs[i] = p.UserID
+		statuses[i] = p.Status
+		scores[i] = int32(p.Score)
 	}
 
-	return friends, nil
+	err := db.write(game.GameID, func(pool *sqlPool) error {
+		return db.exec(pool, qName, game.GameID, userIDs, statuses, scores)
+	})
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	return nil
 }
 
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Testing                                                             **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
 // DeleteUser ...
 func (db *sqlDatabase) DeleteUser(userID string) common.Error {
 	qName := "deleteUser"
-	q := `DELETE users WHERE id = $1;`
+	q := `DELETE FROM users WHERE id = $1;`
+
+	if err := db.Prepare(qName, q); err != nil {
+		return err
+	}
+
+	err := db.write(userID, func(pool *sqlPool) error {
+		return db.exec(pool, qName, userID)
+	})
+
+	if err != nil {
+		return common.NewError(err, "")
+	}
+
+	return nil
+}
+
+// DeleteGame ...
+func (db *sqlDatabase) DeleteGame(gameID string) common.Error {
+	qName := "deleteGame"
+	q := `DELETE FROM games WHERE id = $1;`
 
 	if err := db.Prepare(qName, q); err != nil {
 		return err
 	}
 
-	_, err := db.connection.Exec(qName, userID)
+	err := db.write(gameID, func(pool *sqlPool) error {
+		return db.exec(pool, qName, gameID)
+	})
+
 	if err != nil {
 		return common.NewError(err, "")
 	}
@@ -0,0 +1,236 @@
+package datastore
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jackc/pgx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+)
+
+func TestQueryTrace(t *testing.T) {
+	tests := []struct {
+		Name          string
+		SlowQuery     time.Duration
+		Elapsed       time.Duration
+		Rows          int64
+		Err           error
+		ExpectedLevel string
+	}{
+		{
+			Name:          "Fast",
+			SlowQuery:     time.Second,
+			Elapsed:       0,
+			Rows:          3,
+			ExpectedLevel: "debug",
+		}, {
+			Name:          "Slow",
+			SlowQuery:     time.Millisecond,
+			Elapsed:       time.Second,
+			Rows:          1,
+			ExpectedLevel: "warning",
+		}, {
+			Name:          "Disabled",
+			SlowQuery:     0,
+			Elapsed:       time.Second,
+			Rows:          1,
+			ExpectedLevel: "debug",
+		}, {
+			Name:          "Error",
+			SlowQuery:     time.Second,
+			Elapsed:       0,
+			Rows:          0,
+			Err:           errors.New("no rows in result set"),
+			ExpectedLevel: "debug",
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			log := common.NewLog("test", buf)
+			log.Logger.Level = logrus.DebugLevel
+
+			db := &sqlDatabase{
+				sqlCluster: &sqlCluster{
+					config: SQLConfig{
+						Tracing: SQLTracing{SlowQuery: test.SlowQuery},
+					},
+				},
+				log: log,
+			}
+
+			trace := db.trace(&sqlPool{name: "primary"}, "getUser", "id")
+			trace.start = trace.start.Add(-test.Elapsed)
+			trace.done(test.Rows, test.Err)
+
+			entry := make(map[string]interface{})
+			require.Nil(t, json.Unmarshal(buf.Bytes(), &entry))
+
+			assert.Equal(t, test.ExpectedLevel, entry["level"])
+			assert.Equal(t, "primary", entry["pool"])
+			assert.Equal(t, "getUser", entry["statement"])
+			assert.Equal(t, float64(test.Rows), entry["rows"])
+			assert.NotEmpty(t, entry["duration"])
+
+			if test.Err != nil {
+				assert.Equal(t, test.Err.Error(), entry["error"])
+			} else {
+				assert.NotContains(t, entry, "error")
+			}
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
+
+func TestReplicaRouting(t *testing.T) {
+	tests := []struct {
+		Name            string
+		UserID          string
+		WrittenAgo      time.Duration
+		ReplicaHealthy  bool
+		Checking        bool
+		ExpectedPrimary bool
+	}{
+		{
+			Name:            "Replica",
+			UserID:          "aee6feba-043b-4ba4-a7a4-9d6705595049",
+			WrittenAgo:      time.Hour,
+			ReplicaHealthy:  true,
+			ExpectedPrimary: false,
+		}, {
+			Name:            "ReadOwnWrite",
+			UserID:          "aee6feba-043b-4ba4-a7a4-9d6705595049",
+			WrittenAgo:      time.Millisecond,
+			ReplicaHealthy:  true,
+			ExpectedPrimary: true,
+		}, {
+			Name:            "OtherUsersWrite",
+			UserID:          "bee6feba-043b-4ba4-a7a4-9d6705595049",
+			WrittenAgo:      time.Millisecond,
+			ReplicaHealthy:  true,
+			ExpectedPrimary: false,
+		}, {
+			Name:            "UnhealthyReplica",
+			UserID:          "aee6feba-043b-4ba4-a7a4-9d6705595049",
+			WrittenAgo:      time.Hour,
+			ReplicaHealthy:  false,
+			ExpectedPrimary: true,
+		}, {
+			Name:            "CheckInProgress",
+			UserID:          "aee6feba-043b-4ba4-a7a4-9d6705595049",
+			WrittenAgo:      time.Hour,
+			ReplicaHealthy:  true,
+			Checking:        true,
+			ExpectedPrimary: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			primary := &sqlPool{name: "primary"}
+			// A replica being checked by another reader is due for a check,
+			// but keeps the health of the previous check
+			replica := &sqlPool{
+				name:     "replica0",
+				checked:  time.Now(),
+				checking: test.Checking,
+				healthy:  test.ReplicaHealthy,
+			}
+
+			if test.Checking {
+				replica.checked = time.Now().Add(-2 * time.Hour)
+			}
+
+			db := &sqlDatabase{
+				sqlCluster: &sqlCluster{
+					primary:  primary,
+					replicas: []*sqlPool{replica},
+					config: SQLConfig{
+						Replication: SQLReplication{
+							Stickiness:    time.Second,
+							CheckInterval: time.Hour,
+						},
+					},
+					writes: map[string]time.Time{
+						"aee6feba-043b-4ba4-a7a4-9d6705595049": time.Now().Add(-test.WrittenAgo),
+					},
+				},
+				log: common.NewLog("test", new(bytes.Buffer)),
+			}
+
+			pool := db.reader(test.UserID)
+			assert.Equal(t, test.ExpectedPrimary, pool == primary)
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
+
+func TestReplicaFailure(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Err      error
+		Expected bool
+	}{
+		{Name: "None", Err: nil, Expected: false},
+		{Name: "NoRows", Err: pgx.ErrNoRows, Expected: false},
+		{Name: "DeadConn", Err: pgx.ErrDeadConn, Expected: true},
+		{Name: "AcquireTimeout", Err: pgx.ErrAcquireTimeout, Expected: true},
+		{Name: "EOF", Err: io.ErrUnexpectedEOF, Expected: true},
+		{Name: "Network", Err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset")}, Expected: true},
+		{Name: "ConnectionException", Err: pgx.PgError{Code: "08006"}, Expected: true},
+		{Name: "Shutdown", Err: pgx.PgError{Code: "57P01"}, Expected: true},
+		{Name: "RecoveryConflict", Err: pgx.PgError{Code: "40001"}, Expected: true},
+		{Name: "UniqueViolation", Err: pgx.PgError{Code: "23505"}, Expected: false},
+		{Name: "Encode", Err: errors.New("Cannot encode float64 into oid 23"), Expected: false},
+		{Name: "Scan", Err: errors.New("can't scan into dest[0]"), Expected: false},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			assert.Equal(t, test.Expected, replicaFailure(test.Err))
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
+
+func TestPruneArgs(t *testing.T) {
+	tests := []struct {
+		Name         string
+		Retention    HistoryRetention
+		ExpectedArgs []interface{}
+	}{
+		{
+			Name:         "Default",
+			Retention:    DefaultHistoryRetention,
+			ExpectedArgs: []interface{}{"user", 5, 100, int64(90 * 24 * 60 * 60)},
+		}, {
+			Name:         "FractionalAge",
+			Retention:    HistoryRetention{MaxRevisions: 10, MaxAge: 1500 * time.Millisecond},
+			ExpectedArgs: []interface{}{"user", 5, 10, int64(1)},
+		}, {
+			Name:         "Unlimited",
+			Retention:    HistoryRetention{},
+			ExpectedArgs: []interface{}{"user", 5, 0, int64(0)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			// The arguments match the int and bigint parameters exactly
+			assert.Equal(t, test.ExpectedArgs, sqlPruneArgs(test.Retention, "user", 5))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package endpoints
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type AccountLinkInput struct {
+	UserID   string
+	Provider string
+	Token    string `json:"token"` // Access token of the account, issued by the platform
+}
+
+// LinkedAccount is the output of AccountLink and a part of AccountsGetOutput, describing
+// the account of a user on an external platform
+type LinkedAccount struct {
+	Provider   string    `json:"provider"`
+	ExternalID string    `json:"externalId"`
+	LinkedAt   time.Time `json:"linkedAt"`
+}
+
+// newLinkedAccountOutput converts a linked account
+func newLinkedAccountOutput(account *port.LinkedAccount) *LinkedAccount {
+	return &LinkedAccount{
+		Provider:   account.Provider,
+		ExternalID: account.ExternalID,
+		LinkedAt:   account.LinkedAt,
+	}
+}
+
+// NewAccountLink is a HandlerFunc processing the request to link the account of a user
+// on an external platform, replacing the account linked before. The account is the one
+// the access token was issued to, as verified by the provider, and must not be linked by
+// another user.
+func NewAccountLink(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(AccountLinkInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.UserID = mux.Vars(r)["id"]
+	input.Provider = mux.Vars(r)["provider"]
+	input.Token = strings.TrimSpace(input.Token)
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	provider, err := readSocialGraphProvider(r, input.Provider)
+	if err != nil {
+		return err
+	}
+
+	if input.Token == "" {
+		return common.NewError(ErrBadRequest, "Missing token")
+	}
+
+	externalID, err := provider.VerifyToken(input.Token)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return common.NewError(ErrBadRequest, "Invalid token").
+				SetInternal(err)
+		}
+
+		return err.SetStatusCode(http.StatusBadGateway)
+	}
+
+	// Process data storage
+	account, err := port.GetDatastore(ctx).LinkAccount(&port.LinkedAccount{
+		UserID:     input.UserID,
+		Provider:   provider.Name(),
+		ExternalID: externalID,
+	})
+
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+		case port.ErrEntryExists.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newLinkedAccountOutput(account))
+}
+
+// readSocialGraphProvider returns the configured provider of the platform
+func readSocialGraphProvider(r *http.Request, name string) (port.SocialGraphProvider, common.Error) {
+	provider := port.GetSocialGraphProvider(r.Context(), name)
+	if provider == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "Unknown provider").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	return provider, nil
+}
@@ -0,0 +1,131 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestAccountLink() {
+	// Links are applied in order
+	tests := []struct {
+		Name               string
+		UserID             string
+		Provider           string
+		Body               string
+		ExpectedStatusCode int
+		ExpectedExternalID string
+	}{
+		{
+			Name:               "Link",
+			UserID:             suite.Users[0],
+			Provider:           socialGraphProvider,
+			Body:               `{"token": "token:ext-0"}`,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedExternalID: "ext-0",
+		}, {
+			Name:               "Replace",
+			UserID:             suite.Users[0],
+			Provider:           socialGraphProvider,
+			Body:               `{"token": " token:ext-9 "}`,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedExternalID: "ext-9",
+		}, {
+			Name:               "LinkedByOther",
+			UserID:             suite.Users[1],
+			Provider:           socialGraphProvider,
+			Body:               `{"token": "token:ext-9"}`,
+			ExpectedStatusCode: http.StatusConflict,
+		}, {
+			Name:               "UnknownAccount",
+			UserID:             suite.Users[1],
+			Provider:           socialGraphProvider,
+			Body:               `{"token": "token:ext-5"}`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "ExternalID",
+			UserID:             suite.Users[1],
+			Provider:           socialGraphProvider,
+			Body:               `{"token": "ext-1"}`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "MissingToken",
+			UserID:             suite.Users[1],
+			Provider:           socialGraphProvider,
+			Body:               `{}`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidJSON",
+			UserID:             suite.Users[1],
+			Provider:           socialGraphProvider,
+			Body:               `{"token": `,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownProvider",
+			UserID:             suite.Users[1],
+			Provider:           "flaf",
+			Body:               `{"token": "token:ext-1"}`,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Provider:           socialGraphProvider,
+			Body:               `{"token": "token:ext-1"}`,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			Provider:           socialGraphProvider,
+			Body:               `{"token": "token:ext-1"}`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/accounts/" + test.Provider
+			req, err := http.NewRequest("PUT", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "provider": test.Provider})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewAccountLink)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body and the stored account
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(LinkedAccount)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.Provider, output.Provider)
+				assert.Equal(t, test.ExpectedExternalID, output.ExternalID)
+				assert.False(t, output.LinkedAt.IsZero())
+
+				accounts, err := port.GetDatastore(suite.ParentCtx).GetLinkedAccounts(test.UserID)
+				require.Nil(t, err)
+				require.Equal(t, 1, len(accounts))
+				assert.Equal(t, test.ExpectedExternalID, accounts[0].ExternalID)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
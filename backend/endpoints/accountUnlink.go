@@ -0,0 +1,44 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type AccountUnlinkInput struct {
+	UserID   string
+	Provider string
+}
+
+// NewAccountUnlink is a HandlerFunc processing the request to unlink the account of a
+// user on an external platform. Friends found through the account are kept.
+func NewAccountUnlink(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := AccountUnlinkInput{
+		UserID:   mux.Vars(r)["id"],
+		Provider: mux.Vars(r)["provider"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage, the provider doesn't have to be configured anymore
+	if _, err := port.GetDatastore(ctx).UnlinkAccount(input.UserID, input.Provider); err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
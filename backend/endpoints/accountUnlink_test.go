@@ -0,0 +1,84 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestAccountUnlink() {
+	// Unlinks are applied in order, user 0 starts with a linked account
+	suite.linkAccount(suite.Users[0], "ext-0")
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		Provider           string
+		ExpectedStatusCode int
+	}{
+		{
+			Name:               "Unlink",
+			UserID:             suite.Users[0],
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "NotLinked",
+			UserID:             suite.Users[0],
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "UnknownProvider",
+			UserID:             suite.Users[0],
+			Provider:           "flaf",
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/accounts/" + test.Provider
+			req, err := http.NewRequest("DELETE", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "provider": test.Provider})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewAccountUnlink)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and that no account is left
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			if test.ExpectedStatusCode == http.StatusOK {
+				accounts, err := port.GetDatastore(suite.ParentCtx).GetLinkedAccounts(test.UserID)
+				require.Nil(t, err)
+				assert.Equal(t, 0, len(accounts))
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
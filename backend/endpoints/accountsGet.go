@@ -0,0 +1,55 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type AccountsGetInput struct {
+	UserID string
+}
+
+type AccountsGetOutput struct {
+	Accounts []*LinkedAccount `json:"accounts"`
+}
+
+// NewAccountsGet is a HandlerFunc processing the request to list the accounts a user
+// linked on external platforms, ordered by provider.
+func NewAccountsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := AccountsGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	accounts, err := port.GetDatastore(ctx).GetLinkedAccounts(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	output := &AccountsGetOutput{
+		Accounts: make([]*LinkedAccount, 0, len(accounts)),
+	}
+
+	for _, account := range accounts {
+		output.Accounts = append(output.Accounts, newLinkedAccountOutput(account))
+	}
+
+	return common.SuccessResponseJSON(rw, output)
+}
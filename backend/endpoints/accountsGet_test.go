@@ -0,0 +1,84 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+)
+
+func (suite *EndpointsTestSuite) TestAccountsGet() {
+	suite.linkAccount(suite.Users[0], "ext-0")
+
+	tests := []struct {
+		Name                string
+		UserID              string
+		ExpectedStatusCode  int
+		ExpectedExternalIDs []string
+	}{
+		{
+			Name:                "Get",
+			UserID:              suite.Users[0],
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedExternalIDs: []string{"ext-0"},
+		}, {
+			Name:                "NotLinked",
+			UserID:              suite.Users[1],
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedExternalIDs: []string{},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/accounts"
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewAccountsGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(AccountsGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				externalIDs := make([]string, 0)
+				for _, account := range output.Accounts {
+					assert.Equal(t, socialGraphProvider, account.Provider)
+					externalIDs = append(externalIDs, account.ExternalID)
+				}
+
+				assert.Equal(t, test.ExpectedExternalIDs, externalIDs)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
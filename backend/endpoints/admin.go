@@ -0,0 +1,44 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/valsgaard/interview-case/backend/common"
+)
+
+type contextKey int
+
+const (
+	contextKeyAdminToken contextKey = iota
+	contextKeySigningKeys
+	contextKeyLeaderboardSchedule
+)
+
+// SetAdminToken sets the token granting access to admin endpoints in the
+// context. Without a token, admin endpoints can't be accessed.
+func SetAdminToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, contextKeyAdminToken, token)
+}
+
+// AdminOnly wraps a HandlerFunc, which is only called for requests carrying
+// the admin token as bearer token
+func AdminOnly(fn func(rw http.ResponseWriter, r *http.Request) common.Error) func(rw http.ResponseWriter, r *http.Request) common.Error {
+	return func(rw http.ResponseWriter, r *http.Request) common.Error {
+		header := r.Header.Get("Authorization")
+		if !strings.HasPrefix(header, "Bearer ") {
+			rw.Header().Set("WWW-Authenticate", "Bearer")
+			return common.NewError(ErrUnauthorized, "Missing bearer token")
+		}
+
+		token, _ := r.Context().Value(contextKeyAdminToken).(string)
+		given := strings.TrimPrefix(header, "Bearer ")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(given)) != 1 {
+			return common.NewError(ErrForbidden, "Invalid admin token")
+		}
+
+		return fn(rw, r)
+	}
+}
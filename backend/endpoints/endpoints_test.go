@@ -5,15 +5,20 @@ package endpoints_test
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 
 	"github.com/valsgaard/interview-case/backend/common"
 	"github.com/valsgaard/interview-case/backend/datastore"
+	"github.com/valsgaard/interview-case/backend/endpoints"
 	"github.com/valsgaard/interview-case/backend/endpoints/port"
+	"github.com/valsgaard/interview-case/backend/socialgraph"
 )
 
 type EndpointsTestSuite struct {
@@ -21,6 +26,9 @@ type EndpointsTestSuite struct {
 	ParentCtx context.Context
 
 	Users []string
+
+	// Graph file of the fake social graph provider
+	SocialGraphPath string
 }
 
 func TestEndpointsSuite(t *testing.T) {
@@ -46,10 +54,18 @@ func (suite *EndpointsTestSuite) SetupSuite() {
 		"cee6feba-043b-4ba4-a7a4-9d6705595049",
 		"dee6feba-043b-4ba4-a7a4-9d6705595049",
 	}
+
+	f, err := ioutil.TempFile("", "socialgraph")
+	suite.Require().Nil(err)
+	defer f.Close()
+
+	_, err = f.WriteString(socialGraph)
+	suite.Require().Nil(err)
+	suite.SocialGraphPath = f.Name()
 }
 
 func (suite *EndpointsTestSuite) TearDownSuite() {
-
+	os.Remove(suite.SocialGraphPath)
 }
 
 func (suite *EndpointsTestSuite) SetupTest() {
@@ -58,28 +74,26 @@ func (suite *EndpointsTestSuite) SetupTest() {
 	ctx := context.Background()
 	ctx = port.SetDatastore(ctx, datastore)
 	ctx = common.SetLog(ctx, common.NewLog("test", os.Stdout))
+	ctx = endpoints.SetAdminToken(ctx, adminToken)
+
+	provider, err := socialgraph.NewFileProvider(socialGraphProvider, suite.SocialGraphPath)
+	suite.Require().Nil(err)
+	ctx = port.SetSocialGraphProviders(ctx, provider)
 	suite.ParentCtx = ctx
 
 	for i := range suite.Users {
 		datastore.NewUser(suite.Users[i], fmt.Sprintf("bot%d", i))
 	}
 
-	// Set updated friends
-	port.GetDatastore(suite.ParentCtx).UpdateFriends(
-		suite.Users[0],
-		[]string{
-			suite.Users[1],
-			suite.Users[2],
-			suite.Users[3],
-		},
-	)
+	// Set friends of user 0, the other users start without friends
+	suite.setFriends(suite.Users[0], []string{
+		suite.Users[1],
+		suite.Users[2],
+		suite.Users[3],
+	})
 
 	// Set game state
-	port.GetDatastore(suite.ParentCtx).UpdateGameState(
-		suite.Users[0],
-		10,
-		110,
-	)
+	suite.setGameState(suite.Users[0], port.NewGameState(10, 110))
 }
 
 func (suite *EndpointsTestSuite) TearDownTest() {
@@ -88,3 +102,114 @@ func (suite *EndpointsTestSuite) TearDownTest() {
 		port.GetDatastore(suite.ParentCtx).DeleteUser(suite.Users[i])
 	}
 }
+
+// adminToken is the bearer token of admin endpoints during tests
+const adminToken = "e2c0a1d7-admin"
+
+// socialGraph is the graph of the fake social graph provider. External account
+// "ext-N" is meant to be linked by user N, "ext-0" is a friend of everyone.
+const (
+	socialGraphProvider = "fake"
+	socialGraph         = `{"ext-0": ["ext-1", "ext-2", "ext-3"], "ext-9": []}`
+)
+
+// setGameState writes the game state of a user with its games played and best
+// score, which only SubmitScore changes, by exporting and importing the user
+func (suite *EndpointsTestSuite) setGameState(userID string, state *port.GameState) {
+	db := port.GetDatastore(suite.ParentCtx)
+
+	record, err := db.ExportUser(userID)
+	suite.Require().Nil(err)
+
+	revision := record.GameState.Revision
+	record.GameState = *state.Copy()
+	record.GameState.Revision = revision + 1
+	record.Revisions = append(record.Revisions, port.StateRevision{
+		Revision:  record.GameState.Revision,
+		State:     *record.GameState.Copy(),
+		Author:    userID,
+		Source:    port.StateSourceUpdate,
+		CreatedAt: time.Now(),
+	})
+	suite.Require().Nil(db.ImportUser(record))
+}
+
+// setFriends stores the friend list of a user as it is, by exporting and
+// importing the user, where replacing it would add the user to the friends
+func (suite *EndpointsTestSuite) setFriends(userID string, friendIDs []string) {
+	db := port.GetDatastore(suite.ParentCtx)
+
+	record, err := db.ExportUser(userID)
+	suite.Require().Nil(err)
+
+	record.FriendIDs = friendIDs
+	suite.Require().Nil(db.ImportUser(record))
+}
+
+// stateLevel returns the level of the progress of a game state, zero if missing
+func stateLevel(state *port.GameState) int {
+	progress, _ := state.Document[port.GameStateFieldProgress].(map[string]interface{})
+	number, _ := progress["level"].(json.Number)
+	level, _ := number.Int64()
+	return int(level)
+}
+
+// linkAccount links the account of the fake social graph provider to a user
+func (suite *EndpointsTestSuite) linkAccount(userID, externalID string) {
+	_, err := port.GetDatastore(suite.ParentCtx).LinkAccount(&port.LinkedAccount{
+		UserID:     userID,
+		Provider:   socialGraphProvider,
+		ExternalID: externalID,
+	})
+	suite.Require().Nil(err)
+}
+
+// errorResponse is an error response with validation errors as details
+type errorResponse struct {
+	ErrorCode    string               `json:"errorCode"`
+	ErrorMessage string               `json:"errorMessage"`
+	Details      []common.SchemaError `json:"details"`
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// slotID is the save slot created by newSaveSlot
+const slotID = "a1e6feba-043b-4ba4-a7a4-9d6705595049"
+
+// newSaveSlot creates an inactive save slot for a user, with a game state of
+// the given score
+func (suite *EndpointsTestSuite) newSaveSlot(userID, name string, score int) {
+	state := port.NewGameState(1, score)
+	state.Document[port.GameStateFieldProgress] = map[string]interface{}{"level": json.Number("1")}
+
+	_, err := port.GetDatastore(suite.ParentCtx).NewSaveSlot(userID, &port.SaveSlot{
+		SlotID: slotID,
+		Name:   name,
+		State:  *state,
+	}, 8)
+	suite.Require().Nil(err)
+}
+
+// newFriendRequest sends a pending friend request between two users
+func (suite *EndpointsTestSuite) newFriendRequest(fromID, toID string) {
+	_, err := port.GetDatastore(suite.ParentCtx).NewFriendRequest(&port.FriendRequest{
+		FromID:    fromID,
+		ToID:      toID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}, 50)
+	suite.Require().Nil(err)
+}
+
+// newReferralCode creates the referral code of a user, expiring after the
+// given duration
+func (suite *EndpointsTestSuite) newReferralCode(userID, code string, maxRedemptions int, ttl time.Duration) {
+	_, err := port.GetDatastore(suite.ParentCtx).NewReferralCode(&port.ReferralCode{
+		Code:           code,
+		UserID:         userID,
+		MaxRedemptions: maxRedemptions,
+		ExpiresAt:      time.Now().Add(ttl),
+	})
+	suite.Require().Nil(err)
+}
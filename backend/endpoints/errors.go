@@ -9,3 +9,35 @@ import (
 // ErrBadRequest indicates that the request has an invalid input
 var ErrBadRequest = common.PrepareError("EE001", "Bad request, input entries are invalid, malformed or missing").
 	SetStatusCode(http.StatusBadRequest)
+
+// ErrUnsupportedMediaType indicates that the request body is of a content type the endpoint doesn't accept
+var ErrUnsupportedMediaType = common.PrepareError("EE002", "Unsupported media type").
+	SetStatusCode(http.StatusUnsupportedMediaType)
+
+// ErrPreconditionFailed indicates that the entity was modified since the revision given by If-Match
+var ErrPreconditionFailed = common.PrepareError("EE003", "Precondition failed").
+	SetStatusCode(http.StatusPreconditionFailed)
+
+// ErrPatchTestFailed indicates that a test operation of a patch doesn't hold for the current state
+var ErrPatchTestFailed = common.PrepareError("EE004", "Patch test failed").
+	SetStatusCode(http.StatusConflict)
+
+// ErrUnauthorized indicates that the request lacks the credentials required by the endpoint
+var ErrUnauthorized = common.PrepareError("EE005", "Unauthorized").
+	SetStatusCode(http.StatusUnauthorized)
+
+// ErrForbidden indicates that the given credentials don't grant access to the endpoint
+var ErrForbidden = common.PrepareError("EE006", "Forbidden").
+	SetStatusCode(http.StatusForbidden)
+
+// ErrSyncBaseUnavailable indicates that the revision a sync is based on is no longer retained
+var ErrSyncBaseUnavailable = common.PrepareError("EE007", "Sync base revision unavailable").
+	SetStatusCode(http.StatusConflict)
+
+// ErrSignatureRequired indicates that an update lacks the signature of the game state it's derived from
+var ErrSignatureRequired = common.PrepareError("EE008", "Game state signature required").
+	SetStatusCode(http.StatusPreconditionRequired)
+
+// ErrInvalidSignature indicates that the signature of a game state doesn't match any state issued to the user
+var ErrInvalidSignature = common.PrepareError("EE009", "Invalid game state signature").
+	SetStatusCode(http.StatusForbidden)
@@ -0,0 +1,40 @@
+package endpoints
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/valsgaard/interview-case/backend/common"
+)
+
+// revisionETag formats a revision as a strong entity tag
+func revisionETag(revision int) string {
+	return `"` + strconv.Itoa(revision) + `"`
+}
+
+// readIfMatch reads the revision required by the If-Match header. Requests
+// without the header, or matching any revision with "*", aren't conditional.
+func readIfMatch(r *http.Request) (*int, common.Error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	// If-Match uses the strong comparison, so weak tags never match
+	if strings.HasPrefix(header, "W/") {
+		return nil, common.NewError(ErrPreconditionFailed, "Weak entity tags can't be matched")
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return nil, common.NewError(ErrBadRequest, "Invalid If-Match header, expected a single entity tag")
+	}
+
+	revision, stderr := strconv.Atoi(header[1 : len(header)-1])
+	if stderr != nil {
+		// Tags not issued by the backend never match
+		return nil, common.NewError(ErrPreconditionFailed, "Unknown entity tag")
+	}
+
+	return &revision, nil
+}
@@ -0,0 +1,76 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type FriendAddInput struct {
+	UserID   string
+	FriendID string
+}
+
+// NewFriendAdd is a HandlerFunc processing the admin request to add a single friend to
+// the friend list of a user, and the user to the friend list of the friend, without a
+// friend request. Adding a friend which is already in the list does nothing.
+func NewFriendAdd(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendAddInput{
+		UserID:   mux.Vars(r)["id"],
+		FriendID: mux.Vars(r)["friendId"],
+	}
+
+	// Validate input
+	if err := validateFriendKey(input.UserID, input.FriendID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	exists, err := datastore.UserExists(input.FriendID)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	if !exists {
+		return common.NewError(port.ErrInvalidKey, "Unknown FriendID").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	if _, err := datastore.AddFriend(input.UserID, input.FriendID); err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+		case port.ErrBlocked.Code():
+			return err.SetStatusCode(http.StatusForbidden)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
+
+func validateFriendKey(userID, friendID string) common.Error {
+	if _, stderr := uuid.FromString(userID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if _, stderr := uuid.FromString(friendID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid FriendID").
+			SetInternal(stderr)
+	}
+
+	if userID == friendID {
+		return common.NewError(ErrBadRequest, "A user can't be their own friend")
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendAdd() {
+	// Adds are applied in order, user 1 starts without friends
+	tests := []struct {
+		Name               string
+		UserID             string
+		FriendID           string
+		ExpectedStatusCode int
+		ExpectedFriends    []string
+	}{
+		{
+			Name:               "Add",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{suite.Users[2]},
+		}, {
+			Name:               "AlreadyFriend",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{suite.Users[2]},
+		}, {
+			Name:               "AddAnother",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[0],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{suite.Users[2], suite.Users[0]},
+		}, {
+			Name:               "Self",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedFriends:    []string{suite.Users[2], suite.Users[0]},
+		}, {
+			Name:               "UnknownFriend",
+			UserID:             suite.Users[1],
+			FriendID:           "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedFriends:    []string{suite.Users[2], suite.Users[0]},
+		}, {
+			Name:               "InvalidFriendID",
+			UserID:             suite.Users[1],
+			FriendID:           "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedFriends:    []string{suite.Users[2], suite.Users[0]},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/admin/user/" + test.UserID + "/friends/" + test.FriendID
+			req, err := http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "friendId": test.FriendID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, AdminOnly(NewFriendAdd))
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and the resulting friend list
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			if test.ExpectedFriends != nil {
+				friendIDs, err := port.GetDatastore(suite.ParentCtx).GetFriendIDs(test.UserID)
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedFriends, friendIDs)
+			}
+
+			// The user is added to the friend list of the friend as well
+			if test.ExpectedStatusCode == http.StatusOK {
+				friendIDs, err := port.GetDatastore(suite.ParentCtx).GetFriendIDs(test.FriendID)
+				require.Nil(t, err)
+				assert.Contains(t, friendIDs, test.UserID)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,153 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type FriendInviteInput struct {
+	UserID   string
+	FriendID string
+}
+
+// FriendInviteOutput tells whether the users are friends, or the friend request
+// waiting for the friend to accept it
+type FriendInviteOutput struct {
+	Friends bool           `json:"friends"`
+	Request *FriendRequest `json:"request,omitempty"`
+}
+
+// NewFriendInvite is a HandlerFunc processing the request of a user to add a single friend.
+// Users only become friends of each other by accepting friend requests, so a friend request
+// is sent unless the friend already sent one to the user, which is accepted instead. Adding
+// a friend which is already a friend, or was already sent a request, does nothing.
+func NewFriendInvite(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendInviteInput{
+		UserID:   mux.Vars(r)["id"],
+		FriendID: mux.Vars(r)["friendId"],
+	}
+
+	// Validate input
+	if err := validateFriendKey(input.UserID, input.FriendID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	exists, err := datastore.UserExists(input.FriendID)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	if !exists {
+		return common.NewError(port.ErrInvalidKey, "Unknown FriendID").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	friendIDs, err := datastore.GetFriendIDs(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	for _, friendID := range friendIDs {
+		if friendID == input.FriendID {
+			return common.SuccessResponseJSON(rw, &FriendInviteOutput{Friends: true})
+		}
+	}
+
+	request, err := inviteFriend(ctx, input.UserID, input.FriendID)
+	if err != nil {
+		return err
+	}
+
+	// Response
+	if request == nil {
+		return common.SuccessResponseJSON(rw, &FriendInviteOutput{Friends: true})
+	}
+
+	return common.SuccessResponseJSON(rw, &FriendInviteOutput{Request: newFriendRequestOutput(request)})
+}
+
+// inviteFriend accepts the pending friend request sent by the friend to the user, or sends
+// one from the user to the friend. The request is returned while pending, being the one
+// already sent if any.
+func inviteFriend(ctx context.Context, userID, friendID string) (*port.FriendRequest, common.Error) {
+	datastore := port.GetDatastore(ctx)
+
+	received, err := datastore.GetFriendRequests(userID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return nil, err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	for _, request := range received {
+		if request.FromID != friendID {
+			continue
+		}
+
+		if err := datastore.AcceptFriendRequest(friendID, userID); err != nil {
+			if err.Code() == port.ErrInvalidKey.Code() {
+				return nil, err.SetStatusCode(http.StatusNotFound)
+			}
+
+			return nil, err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		return nil, nil
+	}
+
+	request, err := datastore.NewFriendRequest(&port.FriendRequest{
+		FromID:    userID,
+		ToID:      friendID,
+		ExpiresAt: time.Now().Add(friendRequestTTL),
+	}, maxPendingFriendRequests)
+
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return nil, err.SetStatusCode(http.StatusNotFound)
+		case port.ErrEntryExists.Code():
+			return sentFriendRequest(ctx, userID, friendID)
+		case port.ErrBlocked.Code():
+			return nil, err.SetStatusCode(http.StatusForbidden)
+		case port.ErrLimitReached.Code():
+			return nil, common.NewError(ErrBadRequest, err.Message()).
+				WithField("max", maxPendingFriendRequests)
+		}
+
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	return request, nil
+}
+
+// sentFriendRequest returns the pending friend request sent by the user to the friend
+func sentFriendRequest(ctx context.Context, userID, friendID string) (*port.FriendRequest, common.Error) {
+	sent, err := port.GetDatastore(ctx).GetSentFriendRequests(userID)
+	if err != nil {
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	for _, request := range sent {
+		if request.ToID == friendID {
+			return request, nil
+		}
+	}
+
+	return nil, common.NewError(port.ErrConflict, "Friend request was removed while sending it").
+		SetStatusCode(http.StatusConflict)
+}
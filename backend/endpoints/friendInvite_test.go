@@ -0,0 +1,140 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendInvite() {
+	// User 3 blocked user 1
+	datastore := port.GetDatastore(suite.ParentCtx)
+	_, err := datastore.BlockUser(suite.Users[3], suite.Users[1])
+	suite.Require().Nil(err)
+
+	// Invites are applied in order, user 1 and 2 start without friends
+	tests := []struct {
+		Name               string
+		UserID             string
+		FriendID           string
+		ExpectedStatusCode int
+		ExpectedFriends    bool
+		ExpectedRequest    bool
+	}{
+		{
+			Name:               "Invite",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedRequest:    true,
+		}, {
+			Name:               "AlreadyInvited",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedRequest:    true,
+		}, {
+			Name:               "Accept",
+			UserID:             suite.Users[2],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    true,
+		}, {
+			Name:               "AlreadyFriends",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    true,
+		}, {
+			Name:               "Blocked",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[3],
+			ExpectedStatusCode: http.StatusForbidden,
+		}, {
+			Name:               "Self",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownFriend",
+			UserID:             suite.Users[1],
+			FriendID:           "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidFriendID",
+			UserID:             suite.Users[1],
+			FriendID:           "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/friends/" + test.FriendID
+			req, err := http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "friendId": test.FriendID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendInvite)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+			if test.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			// Check the response body is what we expect
+			output := new(FriendInviteOutput)
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+			assert.Equal(t, test.ExpectedFriends, output.Friends)
+
+			if test.ExpectedRequest {
+				require.NotNil(t, output.Request)
+				assert.Equal(t, test.UserID, output.Request.FromID)
+				assert.Equal(t, test.FriendID, output.Request.ToID)
+
+				requests, err := datastore.GetFriendRequests(test.FriendID)
+				require.Nil(t, err)
+				require.Equal(t, 1, len(requests))
+				assert.Equal(t, test.UserID, requests[0].FromID)
+			} else {
+				assert.Nil(t, output.Request)
+			}
+
+			// Friends are friends of each other
+			if test.ExpectedFriends {
+				friendIDs, err := datastore.GetFriendIDs(test.UserID)
+				require.Nil(t, err)
+				assert.Equal(t, []string{test.FriendID}, friendIDs)
+
+				friendIDs, err = datastore.GetFriendIDs(test.FriendID)
+				require.Nil(t, err)
+				assert.Equal(t, []string{test.UserID}, friendIDs)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
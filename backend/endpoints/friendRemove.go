@@ -0,0 +1,43 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type FriendRemoveInput struct {
+	UserID   string
+	FriendID string
+}
+
+// NewFriendRemove is a HandlerFunc processing the request to remove a single friend from
+// the friend list of a user, and the user from the friend list of the friend. Removing a
+// friend which isn't in the list does nothing.
+func NewFriendRemove(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendRemoveInput{
+		UserID:   mux.Vars(r)["id"],
+		FriendID: mux.Vars(r)["friendId"],
+	}
+
+	// Validate input
+	if err := validateFriendKey(input.UserID, input.FriendID); err != nil {
+		return err
+	}
+
+	// Process data storage, the friend doesn't have to exist anymore
+	if _, err := port.GetDatastore(ctx).RemoveFriend(input.UserID, input.FriendID); err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
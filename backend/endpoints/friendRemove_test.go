@@ -0,0 +1,93 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendRemove() {
+	// Removes are applied in order, user 0 starts with users 1 to 3 as friends
+	tests := []struct {
+		Name               string
+		UserID             string
+		FriendID           string
+		ExpectedStatusCode int
+		ExpectedFriends    []string
+	}{
+		{
+			Name:               "Remove",
+			UserID:             suite.Users[0],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{suite.Users[1], suite.Users[3]},
+		}, {
+			Name:               "NotFriend",
+			UserID:             suite.Users[0],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{suite.Users[1], suite.Users[3]},
+		}, {
+			Name:               "UnknownFriend",
+			UserID:             suite.Users[0],
+			FriendID:           "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{suite.Users[1], suite.Users[3]},
+		}, {
+			Name:               "Self",
+			UserID:             suite.Users[0],
+			FriendID:           suite.Users[0],
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedFriends:    []string{suite.Users[1], suite.Users[3]},
+		}, {
+			Name:               "InvalidFriendID",
+			UserID:             suite.Users[0],
+			FriendID:           "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedFriends:    []string{suite.Users[1], suite.Users[3]},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/friends/" + test.FriendID
+			req, err := http.NewRequest("DELETE", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "friendId": test.FriendID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendRemove)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and the resulting friend list
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			if test.ExpectedFriends != nil {
+				friendIDs, err := port.GetDatastore(suite.ParentCtx).GetFriendIDs(test.UserID)
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedFriends, friendIDs)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
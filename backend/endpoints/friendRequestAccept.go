@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type FriendRequestAcceptInput struct {
+	UserID   string
+	FriendID string // Sender of the request
+}
+
+// NewFriendRequestAccept is a HandlerFunc processing the request to accept a pending
+// friend request received by a user, making the users friends of each other.
+func NewFriendRequestAccept(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendRequestAcceptInput{
+		UserID:   mux.Vars(r)["id"],
+		FriendID: mux.Vars(r)["friendId"],
+	}
+
+	// Validate input
+	if err := validateFriendKey(input.UserID, input.FriendID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	if err := port.GetDatastore(ctx).AcceptFriendRequest(input.FriendID, input.UserID); err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
@@ -0,0 +1,92 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendRequestAccept() {
+	// Both users sent a request, accepting one accepts both
+	suite.newFriendRequest(suite.Users[1], suite.Users[2])
+	suite.newFriendRequest(suite.Users[2], suite.Users[1])
+
+	// Accepts are applied in order
+	tests := []struct {
+		Name               string
+		UserID             string
+		FriendID           string
+		ExpectedStatusCode int
+	}{
+		{
+			Name:               "Accept",
+			UserID:             suite.Users[2],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "Accepted",
+			UserID:             suite.Users[2],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "AcceptedOtherWay",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "NoRequest",
+			UserID:             suite.Users[3],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidFriendID",
+			UserID:             suite.Users[2],
+			FriendID:           "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/friends/requests/" + test.FriendID + "/accept"
+			req, err := http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "friendId": test.FriendID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendRequestAccept)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// The friendship is symmetric, and no requests are left
+	datastore := port.GetDatastore(suite.ParentCtx)
+	for _, pair := range [][2]string{{suite.Users[1], suite.Users[2]}, {suite.Users[2], suite.Users[1]}} {
+		friendIDs, err := datastore.GetFriendIDs(pair[0])
+		require.Nil(suite.T(), err)
+		assert.Equal(suite.T(), []string{pair[1]}, friendIDs)
+
+		requests, err := datastore.GetFriendRequests(pair[0])
+		require.Nil(suite.T(), err)
+		assert.Equal(suite.T(), 0, len(requests))
+	}
+}
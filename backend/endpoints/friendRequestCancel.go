@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type FriendRequestCancelInput struct {
+	UserID   string
+	FriendID string // Recipient of the request
+}
+
+// NewFriendRequestCancel is a HandlerFunc processing the request to cancel a pending
+// friend request sent by a user.
+func NewFriendRequestCancel(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendRequestCancelInput{
+		UserID:   mux.Vars(r)["id"],
+		FriendID: mux.Vars(r)["friendId"],
+	}
+
+	// Validate input
+	if err := validateFriendKey(input.UserID, input.FriendID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	if err := port.GetDatastore(ctx).DeleteFriendRequest(input.UserID, input.FriendID); err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
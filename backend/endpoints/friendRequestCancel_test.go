@@ -0,0 +1,82 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendRequestCancel() {
+	suite.newFriendRequest(suite.Users[1], suite.Users[2])
+
+	// Cancels are applied in order
+	tests := []struct {
+		Name               string
+		UserID             string
+		FriendID           string
+		ExpectedStatusCode int
+		ExpectedPending    int
+	}{
+		{
+			Name:               "Recipient",
+			UserID:             suite.Users[2],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedPending:    1,
+		}, {
+			Name:               "Cancel",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedPending:    0,
+		}, {
+			Name:               "Cancelled",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedPending:    0,
+		}, {
+			Name:               "InvalidFriendID",
+			UserID:             suite.Users[1],
+			FriendID:           "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedPending:    0,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/friends/requests/" + test.FriendID
+			req, err := http.NewRequest("DELETE", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "friendId": test.FriendID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendRequestCancel)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and the requests left
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			requests, err := port.GetDatastore(suite.ParentCtx).GetFriendRequests(suite.Users[2])
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedPending, len(requests))
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
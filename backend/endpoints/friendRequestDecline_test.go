@@ -0,0 +1,78 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendRequestDecline() {
+	suite.newFriendRequest(suite.Users[1], suite.Users[2])
+
+	// Declines are applied in order
+	tests := []struct {
+		Name               string
+		UserID             string
+		FriendID           string
+		ExpectedStatusCode int
+	}{
+		{
+			Name:               "Sender",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "Decline",
+			UserID:             suite.Users[2],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "Declined",
+			UserID:             suite.Users[2],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/friends/requests/" + test.FriendID + "/decline"
+			req, err := http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "friendId": test.FriendID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendRequestDecline)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// Declining doesn't make the users friends
+	friendIDs, err := port.GetDatastore(suite.ParentCtx).GetFriendIDs(suite.Users[2])
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 0, len(friendIDs))
+}
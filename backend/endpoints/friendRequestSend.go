@@ -0,0 +1,90 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Limits of friend requests
+const (
+	friendRequestTTL         = 14 * 24 * time.Hour
+	maxPendingFriendRequests = 50 // Received and sent, each
+)
+
+type FriendRequestSendInput struct {
+	UserID   string
+	FriendID string
+}
+
+// NewFriendRequestSend is a HandlerFunc processing the request to send a friend request
+// from a user to another. The users become friends of each other once it's accepted.
+func NewFriendRequestSend(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendRequestSendInput{
+		UserID:   mux.Vars(r)["id"],
+		FriendID: mux.Vars(r)["friendId"],
+	}
+
+	// Validate input
+	if err := validateFriendKey(input.UserID, input.FriendID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	exists, err := datastore.UserExists(input.FriendID)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	if !exists {
+		return common.NewError(port.ErrInvalidKey, "Unknown FriendID").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	friendIDs, err := datastore.GetFriendIDs(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	for _, friendID := range friendIDs {
+		if friendID == input.FriendID {
+			return common.NewError(port.ErrEntryExists, "Already friends").
+				SetStatusCode(http.StatusConflict)
+		}
+	}
+
+	request, err := datastore.NewFriendRequest(&port.FriendRequest{
+		FromID:    input.UserID,
+		ToID:      input.FriendID,
+		ExpiresAt: time.Now().Add(friendRequestTTL),
+	}, maxPendingFriendRequests)
+
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+		case port.ErrEntryExists.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		case port.ErrBlocked.Code():
+			return err.SetStatusCode(http.StatusForbidden)
+		case port.ErrLimitReached.Code():
+			return common.NewError(ErrBadRequest, err.Message()).
+				WithField("max", maxPendingFriendRequests)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newFriendRequestOutput(request))
+}
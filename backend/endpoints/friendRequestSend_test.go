@@ -0,0 +1,100 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendRequestSend() {
+	// Requests are sent in order, user 0 starts with users 1 to 3 as friends
+	tests := []struct {
+		Name               string
+		UserID             string
+		FriendID           string
+		ExpectedStatusCode int
+	}{
+		{
+			Name:               "Send",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "AlreadyPending",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[2],
+			ExpectedStatusCode: http.StatusConflict,
+		}, {
+			Name:               "AlreadyFriends",
+			UserID:             suite.Users[0],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusConflict,
+		}, {
+			Name:               "Self",
+			UserID:             suite.Users[1],
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownFriend",
+			UserID:             suite.Users[1],
+			FriendID:           "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			FriendID:           suite.Users[1],
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidFriendID",
+			UserID:             suite.Users[1],
+			FriendID:           "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/friends/requests/" + test.FriendID
+			req, err := http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "friendId": test.FriendID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendRequestSend)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect, and the request is pending
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(FriendRequest)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.UserID, output.FromID)
+				assert.Equal(t, test.FriendID, output.ToID)
+
+				requests, err := port.GetDatastore(suite.ParentCtx).GetFriendRequests(test.FriendID)
+				require.Nil(t, err)
+				require.Equal(t, 1, len(requests))
+				assert.Equal(t, test.UserID, requests[0].FromID)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,88 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type FriendRequestsGetInput struct {
+	UserID string
+}
+
+type FriendRequestsGetOutput struct {
+	Incoming []*FriendRequest `json:"incoming"`
+	Outgoing []*FriendRequest `json:"outgoing"`
+}
+
+// FriendRequest is a part of FriendRequestsGetOutput and describes a pending friend request
+type FriendRequest struct {
+	FromID    string    `json:"fromId"`
+	ToID      string    `json:"toId"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// newFriendRequestOutput converts a friend request
+func newFriendRequestOutput(request *port.FriendRequest) *FriendRequest {
+	return &FriendRequest{
+		FromID:    request.FromID,
+		ToID:      request.ToID,
+		CreatedAt: request.CreatedAt,
+		ExpiresAt: request.ExpiresAt,
+	}
+}
+
+// newFriendRequestsOutput converts a list of friend requests
+func newFriendRequestsOutput(requests []*port.FriendRequest) []*FriendRequest {
+	output := make([]*FriendRequest, 0, len(requests))
+	for _, request := range requests {
+		output = append(output, newFriendRequestOutput(request))
+	}
+
+	return output
+}
+
+// NewFriendRequestsGet is a HandlerFunc processing the request to list the pending friend
+// requests received and sent by a user, oldest first. Expired requests aren't listed.
+func NewFriendRequestsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendRequestsGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	incoming, err := datastore.GetFriendRequests(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	outgoing, err := datastore.GetSentFriendRequests(input.UserID)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	output := &FriendRequestsGetOutput{
+		Incoming: newFriendRequestsOutput(incoming),
+		Outgoing: newFriendRequestsOutput(outgoing),
+	}
+
+	return common.SuccessResponseJSON(rw, output)
+}
@@ -0,0 +1,97 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+)
+
+func (suite *EndpointsTestSuite) TestFriendRequestsGet() {
+	suite.newFriendRequest(suite.Users[1], suite.Users[0])
+	suite.newFriendRequest(suite.Users[2], suite.Users[0])
+	suite.newFriendRequest(suite.Users[0], suite.Users[3])
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		ExpectedStatusCode int
+		ExpectedIncoming   []string // Senders
+		ExpectedOutgoing   []string // Recipients
+	}{
+		{
+			Name:               "Get",
+			UserID:             suite.Users[0],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedIncoming:   []string{suite.Users[1], suite.Users[2]},
+			ExpectedOutgoing:   []string{suite.Users[3]},
+		}, {
+			Name:               "Recipient",
+			UserID:             suite.Users[3],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedIncoming:   []string{suite.Users[0]},
+			ExpectedOutgoing:   []string{},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/friends/requests"
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendRequestsGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(FriendRequestsGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				incoming := make([]string, 0)
+				for _, request := range output.Incoming {
+					assert.Equal(t, test.UserID, request.ToID)
+					assert.True(t, request.ExpiresAt.After(request.CreatedAt))
+					incoming = append(incoming, request.FromID)
+				}
+
+				outgoing := make([]string, 0)
+				for _, request := range output.Outgoing {
+					assert.Equal(t, test.UserID, request.FromID)
+					outgoing = append(outgoing, request.ToID)
+				}
+
+				assert.Equal(t, test.ExpectedIncoming, incoming)
+				assert.Equal(t, test.ExpectedOutgoing, outgoing)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,90 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Number of friend suggestions
+const (
+	suggestionsDefaultLimit = 10
+	suggestionsMaxLimit     = 50
+)
+
+type FriendSuggestionsGetInput struct {
+	UserID string
+	Limit  int
+}
+
+type FriendSuggestionsGetOutput struct {
+	Suggestions []*FriendSuggestion `json:"suggestions"`
+}
+
+// FriendSuggestion is a part of FriendSuggestionsGetOutput and describes a friend of
+// the friends of a user
+type FriendSuggestion struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	Highscore     int        `json:"highscore"`
+	MutualFriends int        `json:"mutualFriends"`
+	LastActiveAt  *time.Time `json:"lastActiveAt,omitempty"`
+}
+
+// NewFriendSuggestionsGet is a HandlerFunc processing the request to suggest friends to
+// a user. The friends of the friends are ranked by their number of mutual friends with
+// the user, then by recent activity. Friends and blocked users aren't suggested.
+func NewFriendSuggestionsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendSuggestionsGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	var err common.Error
+	if input.Limit, err = common.ReadQueryInt(r, "limit", suggestionsDefaultLimit); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid limit").
+			SetInternal(err)
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if input.Limit < 1 || input.Limit > suggestionsMaxLimit {
+		return common.NewError(ErrBadRequest, "Invalid limit")
+	}
+
+	// Process data storage
+	suggestions, err := port.GetDatastore(ctx).GetFriendSuggestions(input.UserID, input.Limit)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	output := &FriendSuggestionsGetOutput{
+		Suggestions: make([]*FriendSuggestion, 0, len(suggestions)),
+	}
+
+	for _, suggestion := range suggestions {
+		output.Suggestions = append(output.Suggestions, &FriendSuggestion{
+			ID:            suggestion.UserID,
+			Name:          suggestion.Name,
+			Highscore:     suggestion.HighScore,
+			MutualFriends: suggestion.MutualFriends,
+			LastActiveAt:  suggestion.LastActiveAt,
+		})
+	}
+
+	return common.SuccessResponseJSON(rw, output)
+}
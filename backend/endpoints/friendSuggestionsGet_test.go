@@ -0,0 +1,123 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendSuggestionsGet() {
+	// User 1 is a friend of user 2, who is a friend of user 0 and 3. User 0 is a friend
+	// of every user, and the only one who changed their game state.
+	datastore := port.GetDatastore(suite.ParentCtx)
+	suite.setFriends(suite.Users[1], []string{suite.Users[2]})
+	suite.setFriends(suite.Users[2], []string{suite.Users[0], suite.Users[3]})
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		Query              url.Values
+		Block              bool // User 3 blocks user 1
+		ExpectedStatusCode int
+		ExpectedUsers      []string
+		ExpectedMutual     []int
+	}{
+		{
+			Name:               "Suggestions",
+			UserID:             suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[3]},
+			ExpectedMutual:     []int{1, 1},
+		}, {
+			Name:               "NoFriends",
+			UserID:             suite.Users[3],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{},
+			ExpectedMutual:     []int{},
+		}, {
+			Name:               "Limit",
+			UserID:             suite.Users[1],
+			Query:              url.Values{"limit": {"1"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0]},
+			ExpectedMutual:     []int{1},
+		}, {
+			Name:               "Blocked",
+			UserID:             suite.Users[1],
+			Block:              true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0]},
+			ExpectedMutual:     []int{1},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidLimit",
+			UserID:             suite.Users[1],
+			Query:              url.Values{"limit": {"1000"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			if test.Block {
+				_, err := datastore.BlockUser(suite.Users[3], suite.Users[1])
+				require.Nil(t, err)
+
+				defer datastore.UnblockUser(suite.Users[3], suite.Users[1])
+			}
+
+			path := "/user/" + test.UserID + "/friends/suggestions?" + test.Query.Encode()
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendSuggestionsGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(FriendSuggestionsGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				users := make([]string, 0)
+				mutual := make([]int, 0)
+				for _, suggestion := range output.Suggestions {
+					users = append(users, suggestion.ID)
+					mutual = append(mutual, suggestion.MutualFriends)
+				}
+
+				assert.Equal(t, test.ExpectedUsers, users)
+				assert.Equal(t, test.ExpectedMutual, mutual)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
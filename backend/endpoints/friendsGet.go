@@ -16,6 +16,7 @@ type FriendsGetInput struct {
 
 type FriendsGetOutput struct {
 	Friends []*Friend `json:"friends"`
+	Links   Links     `json:"links"`
 }
 
 // Friend is a part of FriendsGetOutput and contains details about a 'friend'
@@ -25,7 +26,7 @@ type Friend struct {
 	Highscore int    `json:"highscore"`
 }
 
-// NewFriendsGet is a HandlerFunc processing the request to retrieve a users friend list.
+// NewFriendsGet is a HandlerFunc processing the request to retrieve a page of a users friend list.
 func NewFriendsGet(rw http.ResponseWriter, r *http.Request) common.Error {
 	ctx := r.Context()
 	input := FriendsGetInput{
@@ -37,23 +38,51 @@ func NewFriendsGet(rw http.ResponseWriter, r *http.Request) common.Error {
 			SetInternal(stderr)
 	}
 
-	// Process data storage
-	friends, err := port.GetDatastore(ctx).GetFriends(input.UserID)
+	query, err := readListQuery(r, port.SortByName, port.SortByScore)
+	if err != nil {
+		return err
+	}
+
+	// Filters
+	query.NamePrefix = r.URL.Query().Get("name")
+	if r.URL.Query().Get("minScore") != "" {
+		minScore, err := common.ReadQueryInt(r, "minScore", 0)
+		if err != nil {
+			return common.NewError(ErrBadRequest, "Invalid minScore").
+				SetInternal(err)
+		}
+
+		query.MinScore = &minScore
+	}
+
+	// Process data storage, fetching an extra friend to see if there's more
+	query.Limit++
+	friends, err := port.GetDatastore(ctx).ListFriends(input.UserID, query)
+	query.Limit--
+
 	if err != nil {
 		return err.SetStatusCode(http.StatusBadRequest)
 	}
 
+	start, end, more := trimPage(query, len(friends))
+	friends = friends[start:end]
+
 	// Prepare output
 	output := new(FriendsGetOutput)
 	output.Friends = make([]*Friend, 0, len(friends))
+	keys := make([]port.ListKey, 0, len(friends))
 	for _, f := range friends {
 		output.Friends = append(output.Friends, &Friend{
 			ID:        f.UserID,
 			Name:      f.Name,
 			Highscore: f.HighScore,
 		})
+
+		keys = append(keys, port.ListKey{UserID: f.UserID, Name: f.Name, Score: f.HighScore})
 	}
 
+	output.Links = listLinks(r, query, keys, more)
+
 	// Response
 	return common.SuccessResponseJSON(rw, output)
 }
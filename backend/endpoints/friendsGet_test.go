@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -17,6 +18,7 @@ func (suite *EndpointsTestSuite) TestFriendsGet() {
 	tests := []struct {
 		Name               string
 		UserID             string
+		Query              url.Values
 		ExpectedSuccess    bool
 		ExpectedStatusCode int
 		ExpectedUsers      []string
@@ -31,6 +33,30 @@ func (suite *EndpointsTestSuite) TestFriendsGet() {
 			ExpectedUsers:      []string{suite.Users[1], suite.Users[2], suite.Users[3]},
 			ExpectedNames:      []string{"bot1", "bot2", "bot3"},
 			ExpectedHighscores: []int{0, 0, 0},
+		}, {
+			Name:               "SortNameDescending",
+			UserID:             suite.Users[0],
+			Query:              url.Values{"sort": {"-name"}, "limit": {"2"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[3], suite.Users[2]},
+			ExpectedNames:      []string{"bot3", "bot2"},
+			ExpectedHighscores: []int{0, 0},
+		}, {
+			Name:               "FilterMinScore",
+			UserID:             suite.Users[0],
+			Query:              url.Values{"minScore": {"1"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{},
+			ExpectedNames:      []string{},
+			ExpectedHighscores: []int{},
+		}, {
+			Name:               "InvalidMinScore",
+			UserID:             suite.Users[0],
+			Query:              url.Values{"minScore": {"high"}},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
 		}, {
 			Name:               "MissingUserID",
 			UserID:             "",
@@ -44,7 +70,7 @@ func (suite *EndpointsTestSuite) TestFriendsGet() {
 
 	for _, test := range tests {
 		fn := func(t *testing.T) {
-			path := "/user/" + test.UserID + "/friends"
+			path := "/user/" + test.UserID + "/friends?" + test.Query.Encode()
 			req, err := http.NewRequest("GET", path, nil)
 			if err != nil {
 				t.Fatal(err)
@@ -0,0 +1,135 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type FriendsInviteInput struct {
+	UserID  string
+	Friends []string `json:"friends"`
+}
+
+// FriendsInviteOutput lists the friends of the user, and the friend requests waiting for
+// the other friends given to accept them
+type FriendsInviteOutput struct {
+	Friends  []string         `json:"friends"`
+	Requests []*FriendRequest `json:"requests"`
+}
+
+// NewFriendsInvite is a HandlerFunc processing the request of a user to replace their friend
+// list. Friends left out are removed, and the user removed from their friend lists. Users
+// only become friends of each other by accepting friend requests, so the friends added are
+// sent friend requests, as when adding a single friend. Every friend is checked before any
+// is added or removed.
+func NewFriendsInvite(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(FriendsInviteInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.UserID = mux.Vars(r)["id"]
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if len(input.Friends) > maxPendingFriendRequests {
+		return common.NewError(ErrBadRequest, "Too many friends").
+			WithField("max", maxPendingFriendRequests)
+	}
+
+	datastore := port.GetDatastore(ctx)
+	friendIDs, err := datastore.GetFriendIDs(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	kept := make(map[string]bool, len(input.Friends))
+	for _, friendID := range input.Friends {
+		if err := validateFriendKey(input.UserID, friendID); err != nil {
+			return err
+		}
+
+		exists, err := datastore.UserExists(friendID)
+		if err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		if !exists {
+			return common.NewError(port.ErrInvalidKey, "Unknown FriendID").
+				WithField("friendId", friendID).
+				SetStatusCode(http.StatusNotFound)
+		}
+
+		blocked, err := datastore.IsBlocked(input.UserID, friendID)
+		if err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		if blocked {
+			return common.NewError(port.ErrBlocked, "User is blocked").
+				WithField("friendId", friendID).
+				SetStatusCode(http.StatusForbidden)
+		}
+
+		kept[friendID] = true
+	}
+
+	// Process data storage. Friends left out are removed first, then the
+	// others are invited, unless they're already friends.
+	friends := make(map[string]bool, len(friendIDs))
+	for _, friendID := range friendIDs {
+		friends[friendID] = true
+		if kept[friendID] {
+			continue
+		}
+
+		if _, err := datastore.RemoveFriend(input.UserID, friendID); err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+	}
+
+	requests := make([]*port.FriendRequest, 0)
+	for _, friendID := range input.Friends {
+		if friends[friendID] {
+			continue
+		}
+
+		request, err := inviteFriend(ctx, input.UserID, friendID)
+		if err != nil {
+			return err
+		}
+
+		friends[friendID] = true
+		if request != nil {
+			requests = append(requests, request)
+		}
+	}
+
+	friendIDs, err = datastore.GetFriendIDs(input.UserID)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, &FriendsInviteOutput{
+		Friends:  friendIDs,
+		Requests: newFriendRequestsOutput(requests),
+	})
+}
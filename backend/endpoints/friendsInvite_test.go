@@ -0,0 +1,145 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendsInvite() {
+	// User 3 blocked user 1
+	datastore := port.GetDatastore(suite.ParentCtx)
+	_, err := datastore.BlockUser(suite.Users[3], suite.Users[1])
+	suite.Require().Nil(err)
+
+	// Updates are applied in order, user 1 and 2 start without friends
+	tests := []struct {
+		Name               string
+		UserID             string
+		Friends            []string
+		ExpectedStatusCode int
+		ExpectedFriends    []string
+		ExpectedRequests   []string // Recipients of the pending requests
+		ExpectedUnfriended []string
+	}{
+		{
+			Name:               "Invite",
+			UserID:             suite.Users[1],
+			Friends:            []string{suite.Users[0], suite.Users[2]},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{},
+			ExpectedRequests:   []string{suite.Users[0], suite.Users[2]},
+		}, {
+			Name:               "Accept",
+			UserID:             suite.Users[2],
+			Friends:            []string{suite.Users[1]},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{suite.Users[1]},
+			ExpectedRequests:   []string{},
+		}, {
+			Name:               "Replace",
+			UserID:             suite.Users[1],
+			Friends:            []string{suite.Users[0]},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFriends:    []string{},
+			ExpectedRequests:   []string{suite.Users[0]},
+			ExpectedUnfriended: []string{suite.Users[2]},
+		}, {
+			Name:               "Blocked",
+			UserID:             suite.Users[1],
+			Friends:            []string{suite.Users[3]},
+			ExpectedStatusCode: http.StatusForbidden,
+		}, {
+			Name:               "Self",
+			UserID:             suite.Users[1],
+			Friends:            []string{suite.Users[1]},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownFriend",
+			UserID:             suite.Users[1],
+			Friends:            []string{"fee6feba-043b-4ba4-a7a4-9d6705595049"},
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Friends:            []string{suite.Users[1]},
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			Friends:            []string{suite.Users[1]},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			b, err := json.Marshal(&FriendsInviteInput{
+				Friends: test.Friends,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := "/user/" + test.UserID + "/friends"
+			req, err := http.NewRequest("PUT", path, bytes.NewBuffer(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendsInvite)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+			if test.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			// Check the response body is what we expect
+			output := new(FriendsInviteOutput)
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+			assert.Equal(t, test.ExpectedFriends, output.Friends)
+
+			recipients := make([]string, 0)
+			for _, request := range output.Requests {
+				assert.Equal(t, test.UserID, request.FromID)
+				recipients = append(recipients, request.ToID)
+			}
+
+			assert.Equal(t, test.ExpectedRequests, recipients)
+
+			// Friends are friends of each other, and removed friends are
+			// removed from both lists
+			for _, friendID := range test.ExpectedFriends {
+				friendIDs, err := datastore.GetFriendIDs(friendID)
+				require.Nil(t, err)
+				assert.Contains(t, friendIDs, test.UserID)
+			}
+
+			for _, friendID := range test.ExpectedUnfriended {
+				friendIDs, err := datastore.GetFriendIDs(friendID)
+				require.Nil(t, err)
+				assert.NotContains(t, friendIDs, test.UserID)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,137 @@
+package endpoints
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type FriendsLeaderboardGetInput struct {
+	UserID string
+}
+
+type FriendsLeaderboardGetOutput struct {
+	Entries      []*FriendsLeaderboardEntry `json:"entries"`
+	LastViewedAt *time.Time                 `json:"lastViewedAt,omitempty"` // Omitted on the first view
+}
+
+// FriendsLeaderboardEntry is a part of FriendsLeaderboardGetOutput, and describes the user
+// or one of its friends. The delta is the change of the high score of a friend since the
+// user last viewed the leaderboard, omitted for the user and friends not seen before.
+type FriendsLeaderboardEntry struct {
+	LeaderboardEntry
+	Self  bool `json:"self"`
+	Delta *int `json:"delta,omitempty"`
+}
+
+// NewFriendsLeaderboardGet is a HandlerFunc processing the request to rank a user among
+// its friends by high score. Viewing the leaderboard records the high scores seen.
+func NewFriendsLeaderboardGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := FriendsLeaderboardGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	friends, err := datastore.GetFriends(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	self, err := datastore.GetFriendsByID([]string{input.UserID}, "")
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	if len(self) == 0 {
+		return common.NewError(port.ErrInvalidKey, "Invalid UserID").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	now := time.Now()
+	views := make([]*port.FriendScoreView, 0, len(friends))
+	for _, friend := range friends {
+		views = append(views, &port.FriendScoreView{
+			FriendID: friend.UserID,
+			Score:    friend.HighScore,
+			ViewedAt: now,
+		})
+	}
+
+	previous, err := datastore.ViewFriendScores(input.UserID, views)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Prepare output
+	output := new(FriendsLeaderboardGetOutput)
+	seen := make(map[string]int, len(previous))
+	for _, view := range previous {
+		seen[view.FriendID] = view.Score
+		if output.LastViewedAt == nil || view.ViewedAt.After(*output.LastViewedAt) {
+			viewedAt := view.ViewedAt
+			output.LastViewedAt = &viewedAt
+		}
+	}
+
+	output.Entries = make([]*FriendsLeaderboardEntry, 0, len(friends)+1)
+	for _, friend := range append(self, friends...) {
+		entry := &FriendsLeaderboardEntry{
+			LeaderboardEntry: LeaderboardEntry{
+				ID:        friend.UserID,
+				Name:      friend.Name,
+				Highscore: friend.HighScore,
+			},
+			Self: friend.UserID == input.UserID,
+		}
+
+		if score, ok := seen[friend.UserID]; ok && !entry.Self {
+			delta := friend.HighScore - score
+			entry.Delta = &delta
+		}
+
+		output.Entries = append(output.Entries, entry)
+	}
+
+	// Users with the same high score share the rank of the first of them
+	sort.Slice(output.Entries, func(i, j int) bool {
+		a, b := output.Entries[i], output.Entries[j]
+		if a.Highscore != b.Highscore {
+			return a.Highscore > b.Highscore
+		}
+
+		return a.ID < b.ID
+	})
+
+	for i, entry := range output.Entries {
+		if i > 0 && entry.Highscore == output.Entries[i-1].Highscore {
+			entry.Rank = output.Entries[i-1].Rank
+		} else {
+			entry.Rank = i + 1
+		}
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, output)
+}
@@ -0,0 +1,126 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestFriendsLeaderboardGet() {
+	// User 0 has a high score of 110, and friends 1, 2 and 3
+	for _, userID := range []string{suite.Users[1], suite.Users[3]} {
+		_, err := port.GetDatastore(suite.ParentCtx).SubmitScore(userID, 50, port.StateChange{Author: userID, Source: port.StateSourceScore})
+		require.Nil(suite.T(), err)
+	}
+
+	// The tests view the leaderboard in order, after submitting the scores
+	tests := []struct {
+		Name               string
+		ID                 string
+		Scores             map[string]int
+		ExpectedStatusCode int
+		ExpectedUsers      []string
+		ExpectedRanks      []int
+		ExpectedDeltas     []*int
+		ExpectedViewed     bool
+	}{
+		{
+			Name:               "FirstView",
+			ID:                 suite.Users[0],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[1], suite.Users[3], suite.Users[2]},
+			ExpectedRanks:      []int{1, 2, 2, 4},
+			ExpectedDeltas:     []*int{nil, nil, nil, nil},
+			ExpectedViewed:     false,
+		}, {
+			Name:               "Delta",
+			ID:                 suite.Users[0],
+			Scores:             map[string]int{suite.Users[2]: 70, suite.Users[1]: 40},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[2], suite.Users[1], suite.Users[3]},
+			ExpectedRanks:      []int{1, 2, 3, 3},
+			ExpectedDeltas:     []*int{nil, intPtr(70), intPtr(0), intPtr(0)},
+			ExpectedViewed:     true,
+		}, {
+			Name:               "Unchanged",
+			ID:                 suite.Users[0],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[2], suite.Users[1], suite.Users[3]},
+			ExpectedRanks:      []int{1, 2, 3, 3},
+			ExpectedDeltas:     []*int{nil, intPtr(0), intPtr(0), intPtr(0)},
+			ExpectedViewed:     true,
+		}, {
+			Name:               "WithoutFriends",
+			ID:                 suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[1]},
+			ExpectedRanks:      []int{1},
+			ExpectedDeltas:     []*int{nil},
+			ExpectedViewed:     false,
+		}, {
+			Name:               "UnknownUser",
+			ID:                 "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			ID:                 "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			for userID, score := range test.Scores {
+				_, err := port.GetDatastore(suite.ParentCtx).SubmitScore(userID, score, port.StateChange{Author: userID, Source: port.StateSourceScore})
+				require.Nil(t, err)
+			}
+
+			req, err := http.NewRequest("GET", "/user/"+test.ID+"/friends/leaderboard", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.ID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendsLeaderboardGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(FriendsLeaderboardGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				userIDs, ranks, deltas := make([]string, 0), make([]int, 0), make([]*int, 0)
+				for _, entry := range output.Entries {
+					userIDs = append(userIDs, entry.ID)
+					ranks = append(ranks, entry.Rank)
+					deltas = append(deltas, entry.Delta)
+					assert.Equal(t, entry.ID == test.ID, entry.Self)
+				}
+
+				assert.Equal(t, test.ExpectedUsers, userIDs)
+				assert.Equal(t, test.ExpectedRanks, ranks)
+				assert.Equal(t, test.ExpectedDeltas, deltas)
+				assert.Equal(t, test.ExpectedViewed, output.LastViewedAt != nil)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -14,7 +14,10 @@ type FriendsUpdateInput struct {
 	Friends []string `json:"friends"`
 }
 
-// NewFriendsUpdate is a HandlerFunc processing the request to update a users friend list.
+// NewFriendsUpdate is a HandlerFunc processing the admin request to replace a users friend
+// list, without friend requests. The user is added to the friend lists of the friends, and
+// removed from the lists of friends left out. Users blocking or blocked by the user can't
+// be friends.
 func NewFriendsUpdate(rw http.ResponseWriter, r *http.Request) common.Error {
 	ctx := r.Context()
 
@@ -40,6 +43,10 @@ func NewFriendsUpdate(rw http.ResponseWriter, r *http.Request) common.Error {
 	)
 
 	if err != nil {
+		if err.Code() == port.ErrBlocked.Code() {
+			return err.SetStatusCode(http.StatusForbidden)
+		}
+
 		return common.ErrorResponseJSON(
 			rw,
 			http.StatusBadRequest,
@@ -9,18 +9,25 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/valsgaard/interview-case/backend/common"
 	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
 )
 
 func (suite *EndpointsTestSuite) TestFriendsUpdate() {
+	// User 2 blocked user 3
+	_, err := port.GetDatastore(suite.ParentCtx).BlockUser(suite.Users[2], suite.Users[3])
+	suite.Require().Nil(err)
+
 	tests := []struct {
 		Name               string
 		UserID             string
 		Friends            []string
 		ExpectedSuccess    bool
 		ExpectedStatusCode int
+		ExpectedUnfriended []string
 	}{
 		{
 			Name:               "Update",
@@ -34,12 +41,19 @@ func (suite *EndpointsTestSuite) TestFriendsUpdate() {
 			Friends:            []string{suite.Users[0], suite.Users[2]},
 			ExpectedSuccess:    false,
 			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "Blocked",
+			UserID:             suite.Users[3],
+			Friends:            []string{suite.Users[0], suite.Users[2]},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusForbidden,
 		}, {
 			Name:               "EmptyInput",
 			UserID:             suite.Users[0],
 			Friends:            []string{},
 			ExpectedSuccess:    true,
 			ExpectedStatusCode: http.StatusOK,
+			ExpectedUnfriended: []string{suite.Users[1], suite.Users[2], suite.Users[3]},
 		},
 	}
 
@@ -52,23 +66,41 @@ func (suite *EndpointsTestSuite) TestFriendsUpdate() {
 				t.Fatal(err)
 			}
 
-			path := "/user/" + test.UserID + "/friends"
+			path := "/admin/user/" + test.UserID + "/friends"
 			req, err := http.NewRequest("PUT", path, bytes.NewBuffer(b))
 			if err != nil {
 				t.Fatal(err)
 			}
 
+			req.Header.Set("Authorization", "Bearer "+adminToken)
 			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
 
 			// Prepare recorder
 			rr := httptest.NewRecorder()
-			handler := common.NewHandlerFunc(suite.ParentCtx, NewFriendsUpdate)
+			handler := common.NewHandlerFunc(suite.ParentCtx, AdminOnly(NewFriendsUpdate))
 
 			// Call endpoint
 			handler.ServeHTTP(rr, req)
 
-			// Check the status code
+			// Check the status code, and that the friend lists of the friends
+			// are updated as well
 			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			if !test.ExpectedSuccess {
+				return
+			}
+
+			for _, friendID := range test.Friends {
+				friendIDs, err := port.GetDatastore(suite.ParentCtx).GetFriendIDs(friendID)
+				require.Nil(t, err)
+				assert.Contains(t, friendIDs, test.UserID)
+			}
+
+			for _, friendID := range test.ExpectedUnfriended {
+				friendIDs, err := port.GetDatastore(suite.ParentCtx).GetFriendIDs(friendID)
+				require.Nil(t, err)
+				assert.NotContains(t, friendIDs, test.UserID)
+			}
 		}
 
 		suite.T().Run(test.Name, fn)
@@ -0,0 +1,70 @@
+package endpoints
+
+import (
+	"net/http"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Maximum number of participants in a game
+const gameMaxParticipants = 16
+
+type GameCreateInput struct {
+	Participants []string `json:"participants"`
+}
+
+// NewGameCreate is a HandlerFunc processing the request to create a game
+// between one or more users.
+func NewGameCreate(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(GameCreateInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	// Validate participants
+	if len(input.Participants) < 1 || len(input.Participants) > gameMaxParticipants {
+		return common.NewError(ErrBadRequest, "Invalid number of participants")
+	}
+
+	seen := make(map[string]bool)
+	for _, userID := range input.Participants {
+		if _, stderr := uuid.FromString(userID); stderr != nil {
+			return common.NewError(ErrBadRequest, "Invalid UserID").
+				SetInternal(stderr)
+		}
+
+		if seen[userID] {
+			return common.NewError(ErrBadRequest, "Duplicate participant")
+		}
+		seen[userID] = true
+
+		exists, err := port.GetDatastore(ctx).UserExists(userID)
+		if err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		if !exists {
+			return common.NewError(ErrBadRequest, "Unknown participant").
+				WithField("userId", userID)
+		}
+	}
+
+	// Prepare GameID, the same way as UserIDs
+	gameID := uuid.NewV1().String()
+
+	// Process data storage
+	game, err := port.GetDatastore(ctx).NewGame(gameID, input.Participants)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newGameOutput(game))
+}
@@ -0,0 +1,99 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameCreate() {
+	tests := []struct {
+		Name               string
+		Participants       []string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+	}{
+		{
+			Name:               "Single",
+			Participants:       []string{suite.Users[0]},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "Multiple",
+			Participants:       []string{suite.Users[2], suite.Users[0], suite.Users[1]},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "NoParticipants",
+			Participants:       []string{},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "Duplicate",
+			Participants:       []string{suite.Users[0], suite.Users[0]},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidUserID",
+			Participants:       []string{"invalid"},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownUserID",
+			Participants:       []string{"fee6feba-043b-4ba4-a7a4-9d6705595049"},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			b, err := json.Marshal(&GameCreateInput{
+				Participants: test.Participants,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest("POST", "/games", bytes.NewBuffer(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameCreate)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			if assert.Equal(t, test.ExpectedStatusCode, rr.Code) && test.ExpectedSuccess {
+				output := new(Game)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, port.GamePlaying, output.Status)
+
+				require.Equal(t, len(test.Participants), len(output.Participants))
+				for i, p := range output.Participants {
+					assert.Equal(t, test.Participants[i], p.UserID)
+					assert.Equal(t, port.GamePlaying, p.Status)
+				}
+
+				// Check if the game was stored
+				_, err := port.GetDatastore(suite.ParentCtx).GetGame(output.ID)
+				assert.Nil(t, err)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,73 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type GameGetInput struct {
+	GameID string
+}
+
+// Game is the output of the game endpoints
+type Game struct {
+	ID           string         `json:"id"`
+	Status       string         `json:"status"`
+	Participants []*Participant `json:"participants"`
+}
+
+// Participant is a part of Game and contains the status and result of a user
+type Participant struct {
+	UserID string `json:"userId"`
+	Status string `json:"status"`
+	Score  int    `json:"score"`
+}
+
+func newGameOutput(game *port.Game) *Game {
+	output := &Game{
+		ID:           game.GameID,
+		Status:       game.Status,
+		Participants: make([]*Participant, 0, len(game.Participants)),
+	}
+
+	for _, p := range game.Participants {
+		output.Participants = append(output.Participants, &Participant{
+			UserID: p.UserID,
+			Status: p.Status,
+			Score:  p.Score,
+		})
+	}
+
+	return output
+}
+
+// NewGameGet is a HandlerFunc processing the request to retrieve a game.
+func NewGameGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := GameGetInput{
+		GameID: mux.Vars(r)["id"],
+	}
+
+	if _, stderr := uuid.FromString(input.GameID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid GameID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	game, err := port.GetDatastore(ctx).GetGame(input.GameID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newGameOutput(game))
+}
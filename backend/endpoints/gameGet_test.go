@@ -0,0 +1,87 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameGet() {
+	gameID := "a0e6feba-043b-4ba4-a7a4-9d6705595049"
+	_, err := port.GetDatastore(suite.ParentCtx).NewGame(gameID, suite.Users[:2])
+	require.Nil(suite.T(), err)
+
+	tests := []struct {
+		Name                 string
+		GameID               string
+		ExpectedSuccess      bool
+		ExpectedStatusCode   int
+		ExpectedParticipants []string
+	}{
+		{
+			Name:                 "Get",
+			GameID:               gameID,
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedParticipants: suite.Users[:2],
+		}, {
+			Name:                 "Personal",
+			GameID:               suite.Users[1],
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedParticipants: suite.Users[1:2],
+		}, {
+			Name:               "InvalidGameID",
+			GameID:             "invalid",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownGameID",
+			GameID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/games/"+test.GameID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.GameID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			if assert.Equal(t, test.ExpectedStatusCode, rr.Code) && test.ExpectedSuccess {
+				output := new(Game)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.GameID, output.ID)
+				assert.Equal(t, port.GamePlaying, output.Status)
+
+				require.Equal(t, len(test.ExpectedParticipants), len(output.Participants))
+				for i, p := range output.Participants {
+					assert.Equal(t, test.ExpectedParticipants[i], p.UserID)
+				}
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,111 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type GameResultInput struct {
+	GameID string
+	UserID string `json:"userId"`
+	Score  *int   `json:"score"`
+}
+
+type GameResultOutput struct {
+	Game         *Game `json:"game"`
+	PersonalBest bool  `json:"personalBest"` // Only reported when the score is first counted
+}
+
+// NewGameResult is a HandlerFunc processing the request to submit the result
+// of a participant in a game. The result is also counted in the users game
+// state, the same way as a submitted score. Submitting the same result again
+// is safe, so failed submissions can be retried.
+func NewGameResult(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(GameResultInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.GameID = mux.Vars(r)["id"]
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.GameID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid GameID").
+			SetInternal(stderr)
+	}
+
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if input.Score == nil {
+		return common.NewError(ErrBadRequest, "Missing score")
+	}
+
+	if *input.Score < 0 {
+		return common.NewError(ErrBadRequest, "Invalid score")
+	}
+
+	// Process data storage. The game and the user may be stored apart, so
+	// the result is recorded first, as it can only be submitted once. The
+	// score is counted with the game as key, so retrying a result recorded
+	// before counts the score if it wasn't, and only once.
+	datastore := port.GetDatastore(ctx)
+	game, err := datastore.SetGameResult(input.GameID, input.UserID, *input.Score)
+	if err != nil && err.Code() == port.ErrEntryExists.Code() {
+		game, err = recordedGameResult(datastore, input.GameID, input.UserID, *input.Score)
+	}
+
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+		case port.ErrEntryExists.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	result, err := datastore.SubmitScore(input.UserID, *input.Score, port.StateChange{
+		Author: input.UserID,
+		Source: port.StateSourceGame,
+		Key:    "game:" + input.GameID,
+	})
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, &GameResultOutput{
+		Game:         newGameOutput(game),
+		PersonalBest: result.PersonalBest,
+	})
+}
+
+// recordedGameResult returns the game, if the result of the participant is
+// already recorded with the same score
+func recordedGameResult(datastore port.Datastore, gameID, userID string, score int) (*port.Game, common.Error) {
+	game, err := datastore.GetGame(gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range game.Participants {
+		if p.UserID == userID && p.Status == port.GameFinished && p.Score == score {
+			return game, nil
+		}
+	}
+
+	return nil, common.NewError(port.ErrEntryExists, "Result already submitted")
+}
@@ -0,0 +1,148 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameResult() {
+	gameID := "a0e6feba-043b-4ba4-a7a4-9d6705595049"
+	_, err := port.GetDatastore(suite.ParentCtx).NewGame(gameID, suite.Users[:2])
+	require.Nil(suite.T(), err)
+
+	// A result recorded without its score being counted, as by a failed submission
+	unfinishedID := "a2e6feba-043b-4ba4-a7a4-9d6705595049"
+	_, err = port.GetDatastore(suite.ParentCtx).NewGame(unfinishedID, suite.Users[2:3])
+	require.Nil(suite.T(), err)
+
+	_, err = port.GetDatastore(suite.ParentCtx).SetGameResult(unfinishedID, suite.Users[2], 30)
+	require.Nil(suite.T(), err)
+
+	// Results are submitted in order, user 0 starts from a game state of 10 / 110
+	tests := []struct {
+		Name                 string
+		GameID               string
+		Body                 string
+		ExpectedSuccess      bool
+		ExpectedStatusCode   int
+		ExpectedStatus       string
+		ExpectedPersonalBest bool
+		ExpectedGamesPlayed  int
+	}{
+		{
+			Name:                 "First",
+			GameID:               gameID,
+			Body:                 `{"userId": "` + suite.Users[0] + `", "score": 150}`,
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedStatus:       port.GamePlaying,
+			ExpectedPersonalBest: true,
+			ExpectedGamesPlayed:  11,
+		}, {
+			Name:                 "Retry",
+			GameID:               gameID,
+			Body:                 `{"userId": "` + suite.Users[0] + `", "score": 150}`,
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedStatus:       port.GamePlaying,
+			ExpectedPersonalBest: false,
+			ExpectedGamesPlayed:  11,
+		}, {
+			Name:                 "RetryUncounted",
+			GameID:               unfinishedID,
+			Body:                 `{"userId": "` + suite.Users[2] + `", "score": 30}`,
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedStatus:       port.GameFinished,
+			ExpectedPersonalBest: true,
+			ExpectedGamesPlayed:  1,
+		}, {
+			Name:               "AlreadySubmitted",
+			GameID:             gameID,
+			Body:               `{"userId": "` + suite.Users[0] + `", "score": 200}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusConflict,
+		}, {
+			Name:               "NotParticipating",
+			GameID:             gameID,
+			Body:               `{"userId": "` + suite.Users[2] + `", "score": 10}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownGameID",
+			GameID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Body:               `{"userId": "` + suite.Users[1] + `", "score": 10}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "MissingScore",
+			GameID:             gameID,
+			Body:               `{"userId": "` + suite.Users[1] + `"}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidUserID",
+			GameID:             gameID,
+			Body:               `{"userId": "invalid", "score": 10}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:                 "Last",
+			GameID:               gameID,
+			Body:                 `{"userId": "` + suite.Users[1] + `", "score": 0}`,
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedStatus:       port.GameFinished,
+			ExpectedPersonalBest: false,
+			ExpectedGamesPlayed:  1,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/games/" + test.GameID + "/results"
+			req, err := http.NewRequest("POST", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.GameID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameResult)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			if assert.Equal(t, test.ExpectedStatusCode, rr.Code) && test.ExpectedSuccess {
+				output := new(GameResultOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.ExpectedStatus, output.Game.Status)
+				assert.Equal(t, test.ExpectedPersonalBest, output.PersonalBest)
+
+				input := new(GameResultInput)
+				require.Nil(t, json.Unmarshal([]byte(test.Body), input))
+
+				// Check if the result is counted in the users game state
+				state, err := port.GetDatastore(suite.ParentCtx).GetGameState(input.UserID)
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedGamesPlayed, state.GamesPlayed())
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -13,20 +13,23 @@ type GameStateGetInput struct {
 	UserID string
 }
 
-type GameStateGetOutput struct {
-	GamesPlayed int `json:"gamesPlayed"`
-	Score       int `json:"score"`
-}
-
-// NewGameStateGet is a HandlerFunc processing the request to retrieve a users game state.
+// NewGameStateGet is a HandlerFunc processing the request to retrieve a users game state,
+// the state of the personal game of the user, which game results are counted in. It's a
+// view of /games/{id}/state, kept for clients from before games. The state is returned
+// as a document of the current version, containing the well-known fields, and signed
+// when signing is enabled.
 func NewGameStateGet(rw http.ResponseWriter, r *http.Request) common.Error {
 	ctx := r.Context()
 	input := GameStateGetInput{
 		UserID: mux.Vars(r)["id"],
 	}
 
-	// Process data storage
-	gameState, err := port.GetDatastore(ctx).GetGameState(input.UserID)
+	// Process data storage, the personal game of a user has the ID of the user
+	game, err := port.GetDatastore(ctx).GetGame(input.UserID)
+	if err == nil && game.State == nil {
+		err = common.NewError(port.ErrInvalidKey, "Invalid UserID")
+	}
+
 	if err != nil {
 		return common.ErrorResponseJSON(
 			rw,
@@ -39,8 +42,17 @@ func NewGameStateGet(rw http.ResponseWriter, r *http.Request) common.Error {
 	}
 
 	// Response
-	return common.SuccessResponseJSON(rw, &GameStateGetOutput{
-		GamesPlayed: gameState.GamesPlayed,
-		Score:       gameState.Score,
-	})
+	return writeGameState(rw, r, game)
+}
+
+// writeGameState responds with the state of a personal game, tagged with the
+// revision for conditional writes and signed so updates derived from it can
+// be verified
+func writeGameState(rw http.ResponseWriter, r *http.Request, game *port.Game) common.Error {
+	rw.Header().Set("ETag", revisionETag(game.State.Revision))
+	if err := writeStateSignature(rw, r, game.GameID, game.State); err != nil {
+		return err
+	}
+
+	return common.SuccessResponseJSON(rw, game.State)
 }
@@ -12,6 +12,7 @@ import (
 
 	"github.com/valsgaard/interview-case/backend/common"
 	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
 )
 
 func (suite *EndpointsTestSuite) TestGameStateGet() {
@@ -22,6 +23,7 @@ func (suite *EndpointsTestSuite) TestGameStateGet() {
 		ExpectedStatusCode  int
 		ExpectedGamesPlayed int
 		ExpectedScore       int
+		ExpectedETag        string
 	}{
 		{
 			Name:                "Get",
@@ -30,6 +32,7 @@ func (suite *EndpointsTestSuite) TestGameStateGet() {
 			ExpectedStatusCode:  http.StatusOK,
 			ExpectedGamesPlayed: 10,
 			ExpectedScore:       110,
+			ExpectedETag:        `"1"`,
 		}, {
 			Name:                "GetZero",
 			UserID:              suite.Users[1],
@@ -37,6 +40,7 @@ func (suite *EndpointsTestSuite) TestGameStateGet() {
 			ExpectedStatusCode:  http.StatusOK,
 			ExpectedGamesPlayed: 0,
 			ExpectedScore:       0,
+			ExpectedETag:        `"0"`,
 		}, {
 			Name:                "MissingUserID",
 			UserID:              "",
@@ -69,13 +73,15 @@ func (suite *EndpointsTestSuite) TestGameStateGet() {
 
 			// Check the response body is what we expect.
 			if test.ExpectedSuccess {
-				v := new(GameStateGetOutput)
+				v := new(port.GameState)
 				if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
 					t.Fatal(err)
 				}
 
-				assert.Equal(t, test.ExpectedGamesPlayed, v.GamesPlayed)
-				assert.Equal(t, test.ExpectedScore, v.Score)
+				assert.Equal(t, port.GameStateVersion, v.Version)
+				assert.Equal(t, test.ExpectedGamesPlayed, v.GamesPlayed())
+				assert.Equal(t, test.ExpectedScore, v.Score())
+				assert.Equal(t, test.ExpectedETag, rr.Header().Get("ETag"))
 			}
 		}
 
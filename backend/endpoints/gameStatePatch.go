@@ -0,0 +1,174 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Media types of the patch documents accepted when patching game state
+const (
+	MediaTypeMergePatch = "application/merge-patch+json"
+	MediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+type GameStatePatchInput struct {
+	UserID   string
+	Revision *int
+
+	// Patch applies the patch to a document, without modifying it
+	Patch func(doc interface{}) (interface{}, error)
+}
+
+// NewGameStatePatch is a HandlerFunc processing the request to partially update a users
+// game state. The body is either a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902),
+// applied to the document of the current version without its version field. Every
+// operation is applied or none, and failed test operations leave the state unchanged.
+// Changes of games played and the best score are dropped, as only scores change them, and
+// signed patches changing them are rejected.
+// Given If-Match, the patch is only applied to that revision of the state.
+func NewGameStatePatch(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := &GameStatePatchInput{UserID: mux.Vars(r)["id"]}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	revision, err := readIfMatch(r)
+	if err != nil {
+		return err
+	}
+
+	// Updates must be derived from a state signed by the backend, and only
+	// replace that state
+	signed, err := verifyStateSignature(r, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	input.Revision, err = signedRevision(revision, signed)
+	if err != nil {
+		return err
+	}
+
+	body, stderr := ioutil.ReadAll(r.Body)
+	if stderr != nil {
+		return common.NewError(ErrBadRequest, "Unable to read request").
+			SetInternal(stderr)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case MediaTypeMergePatch:
+		patch, stderr := common.DecodeJSON(body)
+		if stderr != nil {
+			return common.NewError(ErrBadRequest, "Invalid JSON format").
+				SetInternal(stderr)
+		}
+
+		input.Patch = func(doc interface{}) (interface{}, error) {
+			return common.MergePatch(doc, patch), nil
+		}
+
+	case MediaTypeJSONPatch:
+		var ops []common.PatchOperation
+		if stderr := json.Unmarshal(body, &ops); stderr != nil {
+			return common.NewError(ErrBadRequest, "Invalid JSON format").
+				SetInternal(stderr)
+		}
+
+		input.Patch = func(doc interface{}) (interface{}, error) {
+			return common.ApplyPatch(doc, ops)
+		}
+
+	default:
+		return common.NewError(ErrUnsupportedMediaType, "Expected "+MediaTypeMergePatch+" or "+MediaTypeJSONPatch).
+			WithField("contentType", mediaType)
+	}
+
+	// Process data storage. The patch is applied to the stored state, which
+	// is only replaced if the result matches the schema.
+	change := port.StateChange{Author: input.UserID, Source: port.StateSourcePatch}
+	changedSigned := false
+	state, err := port.GetDatastore(ctx).ModifyGameState(input.UserID, change, func(state *port.GameState) common.Error {
+		if input.Revision != nil && *input.Revision != state.Revision {
+			return common.NewError(ErrPreconditionFailed, "Game state was modified since it was read").
+				WithField("revision", state.Revision)
+		}
+
+		doc, stderr := input.Patch(state.Document)
+		if stderr != nil {
+			patchErr, ok := stderr.(*common.PatchError)
+			if !ok {
+				return common.NewError(ErrBadRequest, "Invalid patch").
+					SetInternal(stderr)
+			}
+
+			if patchErr.TestFailed {
+				return common.NewError(ErrPatchTestFailed, "Patch test operation failed").
+					SetDetails([]*common.PatchError{patchErr})
+			}
+
+			return common.NewError(ErrBadRequest, "Invalid patch").
+				SetDetails([]*common.PatchError{patchErr})
+		}
+
+		document, ok := doc.(map[string]interface{})
+		if !ok {
+			return common.NewError(ErrBadRequest, "Game state must be an object")
+		}
+
+		state.Document = document
+		if err := state.Validate(); err != nil {
+			return err.SetStatusCode(http.StatusBadRequest)
+		}
+
+		// Users are flagged once the modification is abandoned
+		if signedFieldsChanged(signed, state) {
+			changedSigned = true
+			return common.NewError(ErrInvalidSignature, "Games played and the best score must match the signed game state")
+		}
+
+		return nil
+	})
+
+	if changedSigned {
+		return rejectSignedFields(ctx, input.UserID)
+	}
+
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+
+		case port.ErrConflict.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		}
+
+		if err.StatusCode() == 0 {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		return err
+	}
+
+	// Response
+	rw.Header().Set("ETag", revisionETag(state.Revision))
+	if err := writeStateSignature(rw, r, input.UserID, state); err != nil {
+		return err
+	}
+
+	return common.SuccessResponseJSON(rw, state)
+}
@@ -0,0 +1,253 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameStatePatch() {
+	// Patches are applied in order, to the state of the previous test. Patches
+	// of the games played and best score are dropped, only scores change them.
+	tests := []struct {
+		Name               string
+		UserID             string
+		ContentType        string
+		IfMatch            string
+		Body               string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedScore      int
+		ExpectedSettings   map[string]interface{}
+		ExpectedPaths      []string
+	}{
+		{
+			Name:               "MergePatch",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeMergePatch,
+			Body:               `{"settings": {"sound": false, "language": "da"}}`,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedScore:      110,
+			ExpectedSettings:   map[string]interface{}{"sound": false, "language": "da"},
+		}, {
+			Name:               "MergePatchRemove",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeMergePatch + "; charset=utf-8",
+			Body:               `{"settings": {"language": null}}`,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedScore:      110,
+			ExpectedSettings:   map[string]interface{}{"sound": false},
+		}, {
+			Name:        "JSONPatch",
+			UserID:      suite.Users[0],
+			ContentType: MediaTypeJSONPatch,
+			Body: `[
+				{"op": "test", "path": "/score", "value": 110},
+				{"op": "replace", "path": "/score", "value": 120},
+				{"op": "add", "path": "/progress/levels", "value": [1, 2]},
+				{"op": "add", "path": "/progress/levels/-", "value": 3},
+				{"op": "move", "from": "/settings/sound", "path": "/settings/music"}
+			]`,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedScore:      110,
+			ExpectedSettings:   map[string]interface{}{"music": false},
+		}, {
+			Name:               "IfMatch",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeMergePatch,
+			IfMatch:            `"4"`,
+			Body:               `{"score": 125}`,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedScore:      110,
+			ExpectedSettings:   map[string]interface{}{"music": false},
+		}, {
+			Name:               "IfMatchStale",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeMergePatch,
+			IfMatch:            `"4"`,
+			Body:               `{"score": 130}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusPreconditionFailed,
+		}, {
+			Name:        "TestFailed",
+			UserID:      suite.Users[0],
+			ContentType: MediaTypeJSONPatch,
+			Body: `[
+				{"op": "replace", "path": "/score", "value": 0},
+				{"op": "test", "path": "/gamesPlayed", "value": 1}
+			]`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedPaths:      []string{"/gamesPlayed"},
+		}, {
+			Name:               "InvalidPath",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeJSONPatch,
+			Body:               `[{"op": "remove", "path": "/progress/missing"}]`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedPaths:      []string{"/progress/missing"},
+		}, {
+			Name:               "InvalidOperation",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeJSONPatch,
+			Body:               `[{"op": "increment", "path": "/score"}]`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedPaths:      []string{"/score"},
+		}, {
+			Name:               "InvalidDocument",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeMergePatch,
+			Body:               `{"score": -1, "version": 3}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedPaths:      []string{"/score", "/version"},
+		}, {
+			Name:               "RemoveRequired",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeJSONPatch,
+			Body:               `[{"op": "remove", "path": "/settings"}]`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedPaths:      []string{"/settings"},
+		}, {
+			Name:               "InvalidJSON",
+			UserID:             suite.Users[0],
+			ContentType:        MediaTypeJSONPatch,
+			Body:               `{"op": "remove"`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnsupportedMediaType",
+			UserID:             suite.Users[0],
+			ContentType:        "application/json",
+			Body:               `{"score": 1}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusUnsupportedMediaType,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ContentType:        MediaTypeMergePatch,
+			Body:               `{"settings": {}}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ContentType:        MediaTypeMergePatch,
+			Body:               `{"score": 1}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/state"
+			req, err := http.NewRequest("PATCH", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Content-Type", test.ContentType)
+			if test.IfMatch != "" {
+				req.Header.Set("If-Match", test.IfMatch)
+			}
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameStatePatch)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the paths of the patch and validation errors
+			if test.ExpectedPaths != nil {
+				output := new(errorResponse)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				paths := make([]string, 0)
+				for _, detail := range output.Details {
+					paths = append(paths, detail.Path)
+				}
+
+				assert.Equal(t, test.ExpectedPaths, paths)
+			}
+
+			// Check the status code
+			if !assert.Equal(t, test.ExpectedStatusCode, rr.Code) || !test.ExpectedSuccess {
+				return
+			}
+
+			// Check the patched state is returned and stored
+			output := new(port.GameState)
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+			state, err := port.GetDatastore(suite.ParentCtx).GetGameState(test.UserID)
+			require.Nil(t, err)
+			assert.Equal(t, state.Document, output.Document)
+			assert.Equal(t, fmt.Sprintf(`"%d"`, state.Revision), rr.Header().Get("ETag"))
+			assert.Equal(t, test.ExpectedScore, state.Score())
+			assert.Equal(t, test.ExpectedSettings, state.Document[port.GameStateFieldSettings])
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// Failed patches leave the state unchanged
+	state, err := port.GetDatastore(suite.ParentCtx).GetGameState(suite.Users[0])
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), 110, state.Score())
+	assert.Equal(suite.T(), 10, state.GamesPlayed())
+	assert.Equal(suite.T(), map[string]interface{}{
+		"levels": []interface{}{json.Number("1"), json.Number("2"), json.Number("3")},
+	}, state.Document[port.GameStateFieldProgress])
+}
+
+func (suite *EndpointsTestSuite) TestGameStatePatchScore() {
+	db := port.GetDatastore(suite.ParentCtx)
+	before, err := db.GetLeaderboard(0, 10, "")
+	require.Nil(suite.T(), err)
+
+	// Patching the best score is accepted, and dropped
+	path := "/user/" + suite.Users[0] + "/state"
+	body := `[{"op": "replace", "path": "/score", "value": 1000}, {"op": "replace", "path": "/gamesPlayed", "value": 1}]`
+	req, reqErr := http.NewRequest("PATCH", path, bytes.NewBufferString(body))
+	require.Nil(suite.T(), reqErr)
+
+	req.Header.Set("Content-Type", MediaTypeJSONPatch)
+	req = mux.SetURLVars(req, map[string]string{"id": suite.Users[0]})
+
+	rr := httptest.NewRecorder()
+	handler := common.NewHandlerFunc(suite.ParentCtx, NewGameStatePatch)
+	handler.ServeHTTP(rr, req)
+	require.Equal(suite.T(), http.StatusOK, rr.Code)
+
+	output := new(port.GameState)
+	require.Nil(suite.T(), json.Unmarshal(rr.Body.Bytes(), output))
+	assert.Equal(suite.T(), 110, output.Score())
+	assert.Equal(suite.T(), 10, output.GamesPlayed())
+
+	// The leaderboard is unchanged
+	after, err := db.GetLeaderboard(0, 10, "")
+	require.Nil(suite.T(), err)
+	assert.Equal(suite.T(), before, after)
+	assert.Equal(suite.T(), 110, after[0].Score)
+}
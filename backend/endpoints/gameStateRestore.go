@@ -0,0 +1,83 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type GameStateRestoreInput struct {
+	UserID   string
+	Revision *int `json:"revision"`
+}
+
+// NewGameStateRestore is a HandlerFunc processing the admin request to restore a users
+// game state to an earlier revision. The restore is recorded as a new revision, so it
+// can be undone like any other change.
+func NewGameStateRestore(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(GameStateRestoreInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.UserID = mux.Vars(r)["id"]
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if input.Revision == nil {
+		return common.NewError(ErrBadRequest, "Missing revision")
+	}
+
+	// Process data storage
+	revision, err := port.GetDatastore(ctx).GetGameStateRevision(input.UserID, *input.Revision)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	change := port.StateChange{Author: port.StateAuthorAdmin, Source: port.StateSourceRestore}
+	state, err := port.GetDatastore(ctx).ModifyGameState(input.UserID, change, func(state *port.GameState) common.Error {
+		restored := revision.State.Copy()
+		state.Version = restored.Version
+		state.Document = restored.Document
+		return nil
+	})
+
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	common.Log(ctx).WithFields(logrus.Fields{
+		"userId":   input.UserID,
+		"restored": revision.Revision,
+		"revision": state.Revision,
+	}).Info("Game state restored")
+
+	// Response
+	rw.Header().Set("ETag", revisionETag(state.Revision))
+	if err := writeStateSignature(rw, r, input.UserID, state); err != nil {
+		return err
+	}
+
+	return common.SuccessResponseJSON(rw, state)
+}
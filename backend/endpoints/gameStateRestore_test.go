@@ -0,0 +1,120 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameStateRestore() {
+	// Revision 1 is written by the setup, revision 2 moves the progress
+	state := port.NewGameState(0, 0)
+	state.Document[port.GameStateFieldProgress] = map[string]interface{}{"level": json.Number("2")}
+	require.Nil(suite.T(), port.GetDatastore(suite.ParentCtx).UpdateGameState(suite.Users[0], state, port.StateChange{
+		Author: suite.Users[0],
+		Source: port.StateSourceUpdate,
+	}))
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		Token              string
+		Body               string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedETag       string
+		ExpectedLevel      int
+	}{
+		{
+			Name:               "MissingToken",
+			UserID:             suite.Users[0],
+			Body:               `{"revision": 1}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusUnauthorized,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "InvalidToken",
+			UserID:             suite.Users[0],
+			Token:              "flaf",
+			Body:               `{"revision": 1}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "MissingRevision",
+			UserID:             suite.Users[0],
+			Token:              adminToken,
+			Body:               `{}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "UnknownRevision",
+			UserID:             suite.Users[0],
+			Token:              adminToken,
+			Body:               `{"revision": 9}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "Restore",
+			UserID:             suite.Users[0],
+			Token:              adminToken,
+			Body:               `{"revision": 1}`,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedETag:       `"3"`,
+			ExpectedLevel:      0,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/admin/user/" + test.UserID + "/state/restore"
+			req, err := http.NewRequest("POST", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.Token)
+			}
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, AdminOnly(NewGameStateRestore))
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and that only restores change the state
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+			assert.Equal(t, test.ExpectedETag, rr.Header().Get("ETag"))
+
+			state, err := port.GetDatastore(suite.ParentCtx).GetGameState(test.UserID)
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedLevel, stateLevel(state))
+			assert.Equal(t, 110, state.Score())
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// The restore is recorded as a revision by the admin
+	revisions, err := port.GetDatastore(suite.ParentCtx).GetGameStateRevisions(suite.Users[0])
+	require.Nil(suite.T(), err)
+	require.Equal(suite.T(), 3, len(revisions))
+	assert.Equal(suite.T(), port.StateAuthorAdmin, revisions[0].Author)
+	assert.Equal(suite.T(), port.StateSourceRestore, revisions[0].Source)
+}
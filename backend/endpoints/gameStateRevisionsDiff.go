@@ -0,0 +1,78 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type GameStateRevisionsDiffInput struct {
+	UserID string
+	From   int
+	To     int
+}
+
+type GameStateRevisionsDiffOutput struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+
+	// Operations is a JSON Patch transforming the first revision into the second
+	Operations []common.PatchOperation `json:"operations"`
+}
+
+// NewGameStateRevisionsDiff is a HandlerFunc processing the request to compare two
+// revisions of a users game state. Both revisions are upgraded to the current version
+// before being compared, so migrations don't show as changes.
+func NewGameStateRevisionsDiff(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := GameStateRevisionsDiffInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if r.URL.Query().Get("from") == "" || r.URL.Query().Get("to") == "" {
+		return common.NewError(ErrBadRequest, "Missing revisions to compare")
+	}
+
+	var err common.Error
+	if input.From, err = common.ReadQueryInt(r, "from", 0); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid from revision").
+			SetInternal(err)
+	}
+
+	if input.To, err = common.ReadQueryInt(r, "to", 0); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid to revision").
+			SetInternal(err)
+	}
+
+	// Process data storage
+	states := make([]*port.GameState, 0, 2)
+	for _, revision := range []int{input.From, input.To} {
+		found, err := port.GetDatastore(ctx).GetGameStateRevision(input.UserID, revision)
+		if err != nil {
+			if err.Code() == port.ErrInvalidKey.Code() {
+				return err.SetStatusCode(http.StatusNotFound)
+			}
+
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		states = append(states, &found.State)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, &GameStateRevisionsDiffOutput{
+		From:       input.From,
+		To:         input.To,
+		Operations: common.DiffJSON(states[0].Document, states[1].Document),
+	})
+}
@@ -0,0 +1,110 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameStateRevisionsDiff() {
+	// Revision 1 is written by the setup, revision 2 changes the score and settings
+	state := port.NewGameState(10, 120)
+	state.Document[port.GameStateFieldSettings] = map[string]interface{}{"sound": false}
+	suite.setGameState(suite.Users[0], state)
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		Query              string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedOperations []string
+	}{
+		{
+			Name:               "Forward",
+			UserID:             suite.Users[0],
+			Query:              "?from=1&to=2",
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedOperations: []string{"replace /score 120", "add /settings/sound false"},
+		}, {
+			Name:               "Backward",
+			UserID:             suite.Users[0],
+			Query:              "?from=2&to=1",
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedOperations: []string{"replace /score 110", "remove /settings/sound "},
+		}, {
+			Name:               "Same",
+			UserID:             suite.Users[0],
+			Query:              "?from=2&to=2",
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedOperations: []string{},
+		}, {
+			Name:               "UnknownRevision",
+			UserID:             suite.Users[0],
+			Query:              "?from=1&to=9",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "MissingRevision",
+			UserID:             suite.Users[0],
+			Query:              "?from=1",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidRevision",
+			UserID:             suite.Users[0],
+			Query:              "?from=1&to=latest",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/state/revisions/diff" + test.Query
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameStateRevisionsDiff)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedSuccess {
+				output := new(GameStateRevisionsDiffOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				operations := make([]string, 0)
+				for _, op := range output.Operations {
+					operations = append(operations, op.Op+" "+op.Path+" "+string(op.Value))
+				}
+
+				assert.Equal(t, test.ExpectedOperations, operations)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
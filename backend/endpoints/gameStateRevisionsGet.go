@@ -0,0 +1,71 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type GameStateRevisionsGetInput struct {
+	UserID string
+}
+
+type GameStateRevisionsGetOutput struct {
+	Revisions []*GameStateRevision `json:"revisions"`
+}
+
+// GameStateRevision is a part of GameStateRevisionsGetOutput and describes
+// a single change of the game state
+type GameStateRevision struct {
+	Revision  int       `json:"revision"`
+	Author    string    `json:"author"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// NewGameStateRevisionsGet is a HandlerFunc processing the request to list the retained
+// revisions of a users game state, newest first. Revisions older than the retention of
+// the datastore are no longer listed.
+func NewGameStateRevisionsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := GameStateRevisionsGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	revisions, err := port.GetDatastore(ctx).GetGameStateRevisions(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	output := &GameStateRevisionsGetOutput{
+		Revisions: make([]*GameStateRevision, 0, len(revisions)),
+	}
+
+	for _, revision := range revisions {
+		output.Revisions = append(output.Revisions, &GameStateRevision{
+			Revision:  revision.Revision,
+			Author:    revision.Author,
+			Source:    revision.Source,
+			CreatedAt: revision.CreatedAt,
+		})
+	}
+
+	return common.SuccessResponseJSON(rw, output)
+}
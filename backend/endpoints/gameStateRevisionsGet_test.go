@@ -0,0 +1,101 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameStateRevisionsGet() {
+	// The state of user 0 is written by the setup, and by a run
+	_, err := port.GetDatastore(suite.ParentCtx).SubmitScore(suite.Users[0], 50, port.StateChange{
+		Author: suite.Users[0],
+		Source: port.StateSourceScore,
+	})
+	require.Nil(suite.T(), err)
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedRevisions  []int
+		ExpectedSources    []string
+	}{
+		{
+			Name:               "Get",
+			UserID:             suite.Users[0],
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedRevisions:  []int{2, 1},
+			ExpectedSources:    []string{port.StateSourceScore, port.StateSourceUpdate},
+		}, {
+			Name:               "NeverWritten",
+			UserID:             suite.Users[1],
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedRevisions:  []int{},
+			ExpectedSources:    []string{},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/state/revisions"
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameStateRevisionsGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedSuccess {
+				output := new(GameStateRevisionsGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				revisions := make([]int, 0)
+				sources := make([]string, 0)
+				for _, revision := range output.Revisions {
+					revisions = append(revisions, revision.Revision)
+					sources = append(sources, revision.Source)
+					assert.Equal(t, test.UserID, revision.Author)
+				}
+
+				assert.Equal(t, test.ExpectedRevisions, revisions)
+				assert.Equal(t, test.ExpectedSources, sources)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
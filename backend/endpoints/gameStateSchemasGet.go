@@ -0,0 +1,40 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type GameStateSchemasGetOutput struct {
+	// Current is the version new documents are stored as
+	Current  int                `json:"current"`
+	Versions []*GameStateSchema `json:"versions"`
+}
+
+// GameStateSchema is a part of GameStateSchemasGetOutput and contains the
+// schema of a game state version
+type GameStateSchema struct {
+	Version int            `json:"version"`
+	Schema  *common.Schema `json:"schema"`
+}
+
+// NewGameStateSchemasGet is a HandlerFunc processing the admin request to list the
+// game state versions accepted when writing, along with their schemas.
+func NewGameStateSchemasGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	output := &GameStateSchemasGetOutput{
+		Current:  port.GameStateVersion,
+		Versions: make([]*GameStateSchema, 0),
+	}
+
+	for _, version := range port.GameStateSchemaVersions() {
+		output.Versions = append(output.Versions, &GameStateSchema{
+			Version: version,
+			Schema:  port.GameStateSchema(version),
+		})
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, output)
+}
@@ -0,0 +1,45 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameStateSchemasGet() {
+	t := suite.T()
+
+	req, err := http.NewRequest("GET", "/admin/schemas/state", nil)
+	require.Nil(t, err)
+
+	// Prepare recorder
+	rr := httptest.NewRecorder()
+	handler := common.NewHandlerFunc(suite.ParentCtx, AdminOnly(NewGameStateSchemasGet))
+
+	// Call endpoint, which requires the admin token
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	output := new(GameStateSchemasGetOutput)
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+	assert.Equal(t, port.GameStateVersion, output.Current)
+
+	// Every version up to the current is accepted
+	require.Equal(t, port.GameStateVersion, len(output.Versions))
+	for i, v := range output.Versions {
+		assert.Equal(t, i+1, v.Version)
+		assert.NotNil(t, v.Schema)
+	}
+}
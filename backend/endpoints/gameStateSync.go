@@ -0,0 +1,190 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// maxSyncOperations is the number of operations a single sync may contain
+const maxSyncOperations = 256
+
+type GameStateSyncInput struct {
+	UserID       string
+	BaseRevision *int                      `json:"baseRevision"`
+	Operations   []*GameStateSyncOperation `json:"operations"`
+}
+
+// GameStateSyncOperation is a part of GameStateSyncInput and describes a field changed
+// while offline, with the value the client ended up with
+type GameStateSyncOperation struct {
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+	Time  time.Time       `json:"time"`
+}
+
+// NewGameStateSync is a HandlerFunc processing the request to upload the changes a client
+// made to the game state while offline. The changes are rebased onto the changes stored
+// since the base revision the client started from, where fields keep the value written
+// last. Games played and the best score are owned by the server, and changes of them are
+// dropped, or rejected when signed. The base revision 0 is the new game state.
+func NewGameStateSync(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(GameStateSyncInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.UserID = mux.Vars(r)["id"]
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if input.BaseRevision == nil || *input.BaseRevision < 0 {
+		return common.NewError(ErrBadRequest, "Invalid baseRevision")
+	}
+
+	if len(input.Operations) == 0 || len(input.Operations) > maxSyncOperations {
+		return common.NewError(ErrBadRequest, "Invalid number of operations").
+			WithField("max", maxSyncOperations)
+	}
+
+	ops := make([]port.SyncOperation, 0, len(input.Operations))
+	for _, op := range input.Operations {
+		if op == nil {
+			return common.NewError(ErrBadRequest, "Invalid operation")
+		}
+
+		value, stderr := common.DecodeJSON(op.Value)
+		if stderr != nil {
+			return common.NewError(ErrBadRequest, "Invalid operation value").
+				SetInternal(stderr)
+		}
+
+		ops = append(ops, port.SyncOperation{Path: op.Path, Value: value, Time: op.Time})
+	}
+
+	// Updates must be derived from a state signed by the backend, being the
+	// base the changes are rebased from
+	signed, err := verifyStateSignature(r, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	if signed != nil && signed.Revision != *input.BaseRevision {
+		return common.NewError(ErrPreconditionFailed, "baseRevision doesn't match the signed game state").
+			WithField("revision", signed.Revision)
+	}
+
+	// The games played and best score of the signed base are the ones issued,
+	// so signed changes of them are claims the backend never made
+	if signed != nil {
+		for _, op := range ops {
+			if port.GameStateServerField(op.Path) {
+				return rejectSignedFields(ctx, input.UserID)
+			}
+		}
+	}
+
+	// Process data storage. The revisions tell what the client started from,
+	// and when the fields were written since.
+	revisions, err := port.GetDatastore(ctx).GetGameStateRevisions(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	base := port.NewGameState(0, 0)
+	if *input.BaseRevision > 0 {
+		base = nil
+		for _, revision := range revisions {
+			if revision.Revision == *input.BaseRevision {
+				base = &revision.State
+			}
+		}
+
+		if base == nil {
+			return common.NewError(ErrSyncBaseUnavailable, "Base revision is no longer retained, the state must be fetched").
+				WithField("revision", *input.BaseRevision)
+		}
+	}
+
+	change := port.StateChange{Author: input.UserID, Source: port.StateSourceSync}
+	state, err := port.GetDatastore(ctx).ModifyGameState(input.UserID, change, func(state *port.GameState) common.Error {
+		written := func(pointer string) time.Time {
+			return fieldWrittenAt(revisions, state, pointer)
+		}
+
+		if err := state.Rebase(base, ops, written); err != nil {
+			return err.SetStatusCode(http.StatusBadRequest)
+		}
+
+		if err := state.Validate(); err != nil {
+			return err.SetStatusCode(http.StatusBadRequest)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+
+		case port.ErrConflict.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		}
+
+		if err.StatusCode() == 0 {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		return err
+	}
+
+	// Response
+	rw.Header().Set("ETag", revisionETag(state.Revision))
+	if err := writeStateSignature(rw, r, input.UserID, state); err != nil {
+		return err
+	}
+
+	return common.SuccessResponseJSON(rw, state)
+}
+
+// fieldWrittenAt returns when the field of the state was written, being the
+// oldest revision of the unbroken run of revisions with its current value. If
+// the state is newer than the revisions, it was just written.
+func fieldWrittenAt(revisions []*port.StateRevision, state *port.GameState, pointer string) time.Time {
+	if len(revisions) == 0 || revisions[0].Revision != state.Revision {
+		return time.Now()
+	}
+
+	current, exists := common.GetPointer(state.Document, pointer)
+
+	written := revisions[0].CreatedAt
+	for _, revision := range revisions {
+		value, ok := common.GetPointer(revision.State.Document, pointer)
+		if ok != exists || !common.EqualJSON(value, current) {
+			break
+		}
+
+		written = revision.CreatedAt
+	}
+
+	return written
+}
@@ -0,0 +1,170 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameStateSync() {
+	// Revision 1 is written by the setup, and the client goes offline. The
+	// server writes revision 2 in the meantime. The games played and best
+	// score are owned by the server, and not synced.
+	stored := port.NewGameState(12, 150)
+	stored.Document[port.GameStateFieldSettings] = map[string]interface{}{"sound": true}
+	suite.setGameState(suite.Users[0], stored)
+
+	before := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	after := time.Now().Add(time.Hour).Format(time.RFC3339)
+
+	// Syncs are applied in order
+	tests := []struct {
+		Name                string
+		UserID              string
+		Body                string
+		ExpectedSuccess     bool
+		ExpectedStatusCode  int
+		ExpectedETag        string
+		ExpectedGamesPlayed int
+		ExpectedScore       int
+		ExpectedSettings    map[string]interface{}
+	}{
+		{
+			Name:   "Merge",
+			UserID: suite.Users[0],
+			Body: fmt.Sprintf(`{"baseRevision": 1, "operations": [
+				{"path": "/score", "value": 130},
+				{"path": "/gamesPlayed", "value": 13},
+				{"path": "/settings/sound", "value": false, "time": %q},
+				{"path": "/settings/music", "value": true, "time": %q}
+			]}`, before, before),
+			ExpectedSuccess:     true,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedETag:        `"3"`,
+			ExpectedGamesPlayed: 12,
+			ExpectedScore:       150,
+			ExpectedSettings:    map[string]interface{}{"sound": true, "music": true},
+		}, {
+			Name:   "LastWriter",
+			UserID: suite.Users[0],
+			Body: fmt.Sprintf(`{"baseRevision": 1, "operations": [
+				{"path": "/score", "value": 200},
+				{"path": "/settings/sound", "value": false, "time": %q}
+			]}`, after),
+			ExpectedSuccess:     true,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedETag:        `"4"`,
+			ExpectedGamesPlayed: 12,
+			ExpectedScore:       150,
+			ExpectedSettings:    map[string]interface{}{"sound": false, "music": true},
+		}, {
+			Name:                "NewState",
+			UserID:              suite.Users[0],
+			Body:                `{"baseRevision": 0, "operations": [{"path": "/gamesPlayed", "value": 2}]}`,
+			ExpectedSuccess:     true,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedETag:        `"5"`,
+			ExpectedGamesPlayed: 12,
+			ExpectedScore:       150,
+			ExpectedSettings:    map[string]interface{}{"sound": false, "music": true},
+		}, {
+			Name:               "UnknownBase",
+			UserID:             suite.Users[0],
+			Body:               `{"baseRevision": 99, "operations": [{"path": "/gamesPlayed", "value": 2}]}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusConflict,
+		}, {
+			Name:                "ServerFields",
+			UserID:              suite.Users[0],
+			Body:                `{"baseRevision": 1, "operations": [{"path": "/score", "value": "high"}, {"path": "/gamesPlayed", "value": 99}]}`,
+			ExpectedSuccess:     true,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedETag:        `"6"`,
+			ExpectedGamesPlayed: 12,
+			ExpectedScore:       150,
+			ExpectedSettings:    map[string]interface{}{"sound": false, "music": true},
+		}, {
+			Name:               "Version",
+			UserID:             suite.Users[0],
+			Body:               `{"baseRevision": 1, "operations": [{"path": "/version", "value": 1}]}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidDocument",
+			UserID:             suite.Users[0],
+			Body:               fmt.Sprintf(`{"baseRevision": 1, "operations": [{"path": "/settings/sound", "value": {}, "time": %q}]}`, after),
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "MissingBase",
+			UserID:             suite.Users[0],
+			Body:               `{"operations": [{"path": "/gamesPlayed", "value": 2}]}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "MissingOperations",
+			UserID:             suite.Users[0],
+			Body:               `{"baseRevision": 1, "operations": []}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Body:               `{"baseRevision": 0, "operations": [{"path": "/gamesPlayed", "value": 2}]}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/state/sync"
+			req, err := http.NewRequest("POST", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameStateSync)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+			assert.Equal(t, test.ExpectedETag, rr.Header().Get("ETag"))
+
+			// Check the merged state is returned, and stored
+			if test.ExpectedSuccess {
+				output := new(port.GameState)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				state, err := port.GetDatastore(suite.ParentCtx).GetGameState(test.UserID)
+				require.Nil(t, err)
+
+				for _, s := range []*port.GameState{output, state} {
+					assert.Equal(t, test.ExpectedGamesPlayed, s.GamesPlayed())
+					assert.Equal(t, test.ExpectedScore, s.Score())
+					assert.Equal(t, test.ExpectedSettings, s.Document[port.GameStateFieldSettings])
+				}
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
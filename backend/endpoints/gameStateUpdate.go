@@ -10,18 +10,23 @@ import (
 )
 
 type GameStateUpdateInput struct {
-	UserID      string
-	GamesPlayed int `json:"gamesPlayed"`
-	Score       int `json:"score"`
+	UserID   string
+	Revision *int
+	State    *port.GameState
 }
 
 // NewGameStateUpdate is a HandlerFunc processing the request to update a users game state.
+// Documents without a version are from before game state was versioned. The games played
+// and best score of the user are kept, whatever the document holds. Given If-Match,
+// the state is only replaced if it's still of that revision. When signing is enabled, the
+// update must carry the signature and digest of the state it's derived from, only
+// replaces that state, and can't change the games played and best score it was issued.
 func NewGameStateUpdate(rw http.ResponseWriter, r *http.Request) common.Error {
 	ctx := r.Context()
 
 	// Parse input
-	input := new(GameStateUpdateInput)
-	if err := common.ReadJSONRequest(r, input); err != nil {
+	input := &GameStateUpdateInput{State: new(port.GameState)}
+	if err := common.ReadJSONRequest(r, input.State); err != nil {
 		return common.ErrorResponseJSON(
 			rw,
 			http.StatusBadRequest,
@@ -34,14 +39,65 @@ func NewGameStateUpdate(rw http.ResponseWriter, r *http.Request) common.Error {
 
 	input.UserID = mux.Vars(r)["id"]
 
-	// Process data storage
-	err := port.GetDatastore(ctx).UpdateGameState(
-		input.UserID,
-		input.GamesPlayed,
-		input.Score,
-	)
+	revision, err := readIfMatch(r)
+	if err != nil {
+		return err
+	}
+
+	// Updates must be derived from a state signed by the backend, and only
+	// replace that state
+	signed, err := verifyStateSignature(r, input.UserID)
+	if err != nil {
+		return err
+	}
+
+	input.Revision, err = signedRevision(revision, signed)
+	if err != nil {
+		return err
+	}
+
+	// Documents are validated against the schema of their version, and
+	// documents of older versions are upgraded before being stored
+	if err := input.State.Validate(); err != nil {
+		return err.SetStatusCode(http.StatusBadRequest)
+	}
+
+	if err := input.State.Upgrade(); err != nil {
+		return err.SetStatusCode(http.StatusBadRequest)
+	}
+
+	if err := verifySignedFields(ctx, input.UserID, signed, input.State); err != nil {
+		return err
+	}
+
+	// Process data storage. Conditional writes only replace the revision the
+	// client read, others overwrite any revision.
+	change := port.StateChange{Author: input.UserID, Source: port.StateSourceUpdate}
+	if input.Revision != nil {
+		var swapped int
+		swapped, err = port.GetDatastore(ctx).CompareAndSwapGameState(
+			input.UserID,
+			*input.Revision,
+			input.State,
+			change,
+		)
+
+		if err == nil {
+			rw.Header().Set("ETag", revisionETag(swapped))
+		}
+	} else {
+		err = port.GetDatastore(ctx).UpdateGameState(
+			input.UserID,
+			input.State,
+			change,
+		)
+	}
 
 	if err != nil {
+		if err.Code() == port.ErrConflict.Code() {
+			return common.NewError(ErrPreconditionFailed, "Game state was modified since it was read")
+		}
+
 		return common.ErrorResponseJSON(
 			rw,
 			http.StatusBadRequest,
@@ -3,6 +3,7 @@ package endpoints_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -17,50 +18,91 @@ import (
 )
 
 func (suite *EndpointsTestSuite) TestGameStateUpdate() {
+	// The games played and best score of the user are kept, only scores change them
 	tests := []struct {
-		Name               string
-		UserID             string
-		GamesPlayed        int
-		Score              int
-		ExpectedSuccess    bool
-		ExpectedStatusCode int
+		Name                string
+		UserID              string
+		Body                string
+		ExpectedSuccess     bool
+		ExpectedStatusCode  int
+		ExpectedGamesPlayed int
+		ExpectedScore       int
+		ExpectedSettings    map[string]interface{}
+		ExpectedPaths       []string
 	}{
 		{
-			Name:               "Update",
-			UserID:             suite.Users[0],
-			GamesPlayed:        2,
-			Score:              220,
-			ExpectedSuccess:    true,
-			ExpectedStatusCode: http.StatusOK,
+			Name:                "Update",
+			UserID:              suite.Users[0],
+			Body:                `{"gamesPlayed": 2, "score": 220}`,
+			ExpectedSuccess:     true,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedGamesPlayed: 10,
+			ExpectedScore:       110,
+			ExpectedSettings:    map[string]interface{}{},
 		}, {
 			Name:               "MissingUserID",
 			UserID:             "",
-			GamesPlayed:        2,
-			Score:              220,
+			Body:               `{"gamesPlayed": 2, "score": 220}`,
 			ExpectedSuccess:    false,
 			ExpectedStatusCode: http.StatusBadRequest,
 		}, {
-			Name:               "ZeroGamesPlayed",
+			Name:                "ZeroGamesPlayed",
+			UserID:              suite.Users[0],
+			Body:                `{"gamesPlayed": 0, "score": 1000}`,
+			ExpectedSuccess:     true,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedGamesPlayed: 10,
+			ExpectedScore:       110,
+			ExpectedSettings:    map[string]interface{}{},
+		}, {
+			Name:                "CurrentVersion",
+			UserID:              suite.Users[0],
+			Body:                `{"version": 2, "gamesPlayed": 3, "score": 30, "progress": {}, "settings": {"sound": false}}`,
+			ExpectedSuccess:     true,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedGamesPlayed: 10,
+			ExpectedScore:       110,
+			ExpectedSettings:    map[string]interface{}{"sound": false},
+		}, {
+			Name:               "UnsupportedVersion",
 			UserID:             suite.Users[0],
-			GamesPlayed:        0,
-			Score:              1000,
-			ExpectedSuccess:    true,
-			ExpectedStatusCode: http.StatusOK,
+			Body:               `{"version": 99, "gamesPlayed": 3, "score": 30}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidScore",
+			UserID:             suite.Users[0],
+			Body:               `{"gamesPlayed": 3, "score": "high"}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedPaths:      []string{"/score"},
+		}, {
+			Name:               "InvalidDocument",
+			UserID:             suite.Users[0],
+			Body:               `{"version": 2, "gamesPlayed": -1, "score": 30, "progress": {}, "settings": {"sound": {}}, "flaf": 1}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedPaths:      []string{"/flaf", "/gamesPlayed", "/settings/sound"},
+		}, {
+			Name:               "MissingFields",
+			UserID:             suite.Users[0],
+			Body:               `{"version": 2, "gamesPlayed": 1, "score": 30}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedPaths:      []string{"/progress", "/settings"},
+		}, {
+			Name:               "NotAnObject",
+			UserID:             suite.Users[0],
+			Body:               `[1, 2]`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
 		},
 	}
 
 	for _, test := range tests {
 		fn := func(t *testing.T) {
-			b, err := json.Marshal(&GameStateUpdateInput{
-				GamesPlayed: test.GamesPlayed,
-				Score:       test.Score,
-			})
-			if err != nil {
-				t.Fatal(err)
-			}
-
 			path := "/user/" + test.UserID + "/state"
-			req, err := http.NewRequest("PUT", path, bytes.NewBuffer(b))
+			req, err := http.NewRequest("PUT", path, bytes.NewBufferString(test.Body))
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -74,14 +116,119 @@ func (suite *EndpointsTestSuite) TestGameStateUpdate() {
 			// Call endpoint
 			handler.ServeHTTP(rr, req)
 
+			// Check the paths of the validation errors
+			if test.ExpectedPaths != nil {
+				output := new(errorResponse)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				paths := make([]string, 0)
+				for _, detail := range output.Details {
+					paths = append(paths, detail.Path)
+				}
+
+				assert.Equal(t, test.ExpectedPaths, paths)
+			}
+
 			// Check the status code
 			if assert.Equal(t, test.ExpectedStatusCode, rr.Code) && test.ExpectedSuccess {
-				// Check if state was properly updated
+				// Check if state was properly updated, and upgraded
 				state, err := port.GetDatastore(suite.ParentCtx).GetGameState(test.UserID)
 				require.Nil(t, err)
-				assert.Equal(t, test.GamesPlayed, state.GamesPlayed)
-				assert.Equal(t, test.Score, state.Score)
+				assert.Equal(t, port.GameStateVersion, state.Version)
+				assert.Equal(t, test.ExpectedGamesPlayed, state.GamesPlayed())
+				assert.Equal(t, test.ExpectedScore, state.Score())
+				assert.Equal(t, test.ExpectedSettings, state.Document[port.GameStateFieldSettings])
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *EndpointsTestSuite) TestGameStateUpdateConditional() {
+	// Updates are applied in order, the state starts at revision 1
+	tests := []struct {
+		Name               string
+		IfMatch            string
+		Level              int
+		ExpectedStatusCode int
+		ExpectedETag       string
+		ExpectedLevel      int
+	}{
+		{
+			Name:               "Current",
+			IfMatch:            `"1"`,
+			Level:              2,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedETag:       `"2"`,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "Stale",
+			IfMatch:            `"1"`,
+			Level:              3,
+			ExpectedStatusCode: http.StatusPreconditionFailed,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "Weak",
+			IfMatch:            `W/"2"`,
+			Level:              3,
+			ExpectedStatusCode: http.StatusPreconditionFailed,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "Unknown",
+			IfMatch:            `"abc"`,
+			Level:              3,
+			ExpectedStatusCode: http.StatusPreconditionFailed,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "Malformed",
+			IfMatch:            `2`,
+			Level:              3,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedLevel:      2,
+		}, {
+			Name:               "Any",
+			IfMatch:            `*`,
+			Level:              4,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedLevel:      4,
+		}, {
+			Name:               "AfterAny",
+			IfMatch:            `"3"`,
+			Level:              5,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedETag:       `"4"`,
+			ExpectedLevel:      5,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + suite.Users[0] + "/state"
+			body := fmt.Sprintf(`{"version": 2, "gamesPlayed": 1, "score": 999, "progress": {"level": %d}, "settings": {}}`, test.Level)
+			req, err := http.NewRequest("PUT", path, bytes.NewBufferString(body))
+			if err != nil {
+				t.Fatal(err)
 			}
+
+			req.Header.Set("If-Match", test.IfMatch)
+			req = mux.SetURLVars(req, map[string]string{"id": suite.Users[0]})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameStateUpdate)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and that failed updates aren't stored
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+			assert.Equal(t, test.ExpectedETag, rr.Header().Get("ETag"))
+
+			state, err := port.GetDatastore(suite.ParentCtx).GetGameState(suite.Users[0])
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedLevel, stateLevel(state))
+			assert.Equal(t, 110, state.Score())
 		}
 
 		suite.T().Run(test.Name, fn)
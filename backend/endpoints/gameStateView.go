@@ -0,0 +1,48 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type GameStateViewInput struct {
+	GameID string
+}
+
+// NewGameStateView is a HandlerFunc processing the request to retrieve the state of a game.
+// Only personal games have a state, being the game state of the user, returned as by
+// /user/{id}/state. Other games keep their results with the participants.
+func NewGameStateView(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := GameStateViewInput{
+		GameID: mux.Vars(r)["id"],
+	}
+
+	if _, stderr := uuid.FromString(input.GameID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid GameID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	game, err := port.GetDatastore(ctx).GetGame(input.GameID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	if game.State == nil {
+		return common.NewError(port.ErrInvalidKey, "Game has no state").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	// Response
+	return writeGameState(rw, r, game)
+}
@@ -0,0 +1,89 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestGameStateView() {
+	gameID := "a0e6feba-043b-4ba4-a7a4-9d6705595049"
+	_, err := port.GetDatastore(suite.ParentCtx).NewGame(gameID, suite.Users[:2])
+	require.Nil(suite.T(), err)
+
+	tests := []struct {
+		Name               string
+		GameID             string
+		ExpectedStatusCode int
+	}{
+		{
+			Name:               "Personal",
+			GameID:             suite.Users[0],
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "WithoutState",
+			GameID:             gameID,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownGameID",
+			GameID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidGameID",
+			GameID:             "invalid",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/games/"+test.GameID+"/state", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.GameID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewGameStateView)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+			if test.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			// The state of a personal game is the game state of the user, as
+			// returned by the route of the user
+			req, err = http.NewRequest("GET", "/user/"+test.GameID+"/state", nil)
+			require.Nil(t, err)
+			req = mux.SetURLVars(req, map[string]string{"id": test.GameID})
+
+			user := httptest.NewRecorder()
+			common.NewHandlerFunc(suite.ParentCtx, NewGameStateGet).ServeHTTP(user, req)
+			require.Equal(t, http.StatusOK, user.Code)
+
+			assert.Equal(t, user.Header().Get("ETag"), rr.Header().Get("ETag"))
+			assert.JSONEq(t, user.Body.String(), rr.Body.String())
+
+			output := new(port.GameState)
+			require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+			assert.Equal(t, 110, output.Score())
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
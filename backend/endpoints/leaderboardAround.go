@@ -0,0 +1,72 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Number of entries on either side of the user
+const (
+	leaderboardDefaultRadius = 5
+	leaderboardMaxRadius     = 25
+)
+
+type LeaderboardAroundInput struct {
+	UserID   string
+	Radius   int
+	ViewerID string
+}
+
+type LeaderboardAroundOutput struct {
+	Entries []*LeaderboardEntry `json:"entries"`
+}
+
+// NewLeaderboardAround is a HandlerFunc processing the request to get the entries of
+// the global leaderboard ranked around a user, the user included. Users who blocked
+// the viewer are left out, and can't be the user the entries are ranked around.
+func NewLeaderboardAround(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := LeaderboardAroundInput{
+		UserID: mux.Vars(r)["userId"],
+	}
+
+	var err common.Error
+	if input.Radius, err = common.ReadQueryInt(r, "radius", leaderboardDefaultRadius); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid radius").
+			SetInternal(err)
+	}
+
+	if input.ViewerID, err = readViewer(r); err != nil {
+		return err
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if input.Radius < 0 || input.Radius > leaderboardMaxRadius {
+		return common.NewError(ErrBadRequest, "Invalid radius")
+	}
+
+	// Process data storage
+	entries, err := port.GetDatastore(ctx).GetLeaderboardAround(input.UserID, input.Radius, input.ViewerID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, &LeaderboardAroundOutput{
+		Entries: newLeaderboardOutput(entries),
+	})
+}
@@ -0,0 +1,131 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestLeaderboardAround() {
+	// User 0 has a high score of 110, users 1 and 3 share the second rank
+	for _, userID := range []string{suite.Users[1], suite.Users[3]} {
+		_, err := port.GetDatastore(suite.ParentCtx).SubmitScore(userID, 50, port.StateChange{Author: userID, Source: port.StateSourceScore})
+		require.Nil(suite.T(), err)
+	}
+
+	// User 1 is hidden from user 2
+	_, err := port.GetDatastore(suite.ParentCtx).BlockUser(suite.Users[1], suite.Users[2])
+	require.Nil(suite.T(), err)
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		Query              url.Values
+		ExpectedStatusCode int
+		ExpectedUsers      []string
+		ExpectedRanks      []int
+	}{
+		{
+			Name:               "Around",
+			UserID:             suite.Users[3],
+			Query:              url.Values{"radius": {"1"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[1], suite.Users[3], suite.Users[2]},
+			ExpectedRanks:      []int{2, 2, 4},
+		}, {
+			Name:               "Top",
+			UserID:             suite.Users[0],
+			Query:              url.Values{"radius": {"1"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[1]},
+			ExpectedRanks:      []int{1, 2},
+		}, {
+			Name:               "DefaultRadius",
+			UserID:             suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[1], suite.Users[3], suite.Users[2]},
+			ExpectedRanks:      []int{1, 2, 2, 4},
+		}, {
+			Name:               "OnlyUser",
+			UserID:             suite.Users[2],
+			Query:              url.Values{"radius": {"0"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[2]},
+			ExpectedRanks:      []int{4},
+		}, {
+			Name:               "Viewer",
+			UserID:             suite.Users[3],
+			Query:              url.Values{"radius": {"1"}, "viewer": {suite.Users[2]}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[3], suite.Users[2]},
+			ExpectedRanks:      []int{2, 4},
+		}, {
+			Name:               "Blocker",
+			UserID:             suite.Users[1],
+			Query:              url.Values{"viewer": {suite.Users[2]}},
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidRadius",
+			UserID:             suite.Users[2],
+			Query:              url.Values{"radius": {"26"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/leaderboard/around/" + test.UserID + "?" + test.Query.Encode()
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"userId": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewLeaderboardAround)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(LeaderboardAroundOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				userIDs, ranks := make([]string, 0), make([]int, 0)
+				for _, entry := range output.Entries {
+					userIDs = append(userIDs, entry.ID)
+					ranks = append(ranks, entry.Rank)
+				}
+
+				assert.Equal(t, test.ExpectedUsers, userIDs)
+				assert.Equal(t, test.ExpectedRanks, ranks)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
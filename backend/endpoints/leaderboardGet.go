@@ -0,0 +1,103 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Leaderboard page size. Sharded datastores read the entries before the page
+// from every shard, so the offset is limited as well.
+const (
+	leaderboardDefaultLimit = 10
+	leaderboardMaxLimit     = 100
+	leaderboardMaxOffset    = 10000
+)
+
+type LeaderboardGetInput struct {
+	Offset   int
+	Limit    int
+	ViewerID string
+}
+
+type LeaderboardGetOutput struct {
+	Total   int                 `json:"total"`
+	Entries []*LeaderboardEntry `json:"entries"`
+}
+
+// LeaderboardEntry is a part of the leaderboard outputs, and describes a ranked user.
+// Users with the same high score share the rank of the first of them.
+type LeaderboardEntry struct {
+	Rank      int    `json:"rank"`
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Highscore int    `json:"highscore"`
+}
+
+// newLeaderboardOutput converts the entries of a leaderboard
+func newLeaderboardOutput(entries []*port.LeaderboardEntry) []*LeaderboardEntry {
+	output := make([]*LeaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		output = append(output, &LeaderboardEntry{
+			Rank:      entry.Rank,
+			ID:        entry.UserID,
+			Name:      entry.Name,
+			Highscore: entry.Score,
+		})
+	}
+
+	return output
+}
+
+// NewLeaderboardGet is a HandlerFunc processing the request to get a page of the
+// global leaderboard, ranking all users by their high score. Users who blocked the
+// viewer are left out of the page, which may then be shorter than the limit.
+func NewLeaderboardGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := LeaderboardGetInput{}
+
+	var err common.Error
+	if input.Offset, err = common.ReadQueryInt(r, "offset", 0); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid offset").
+			SetInternal(err)
+	}
+
+	if input.Limit, err = common.ReadQueryInt(r, "limit", leaderboardDefaultLimit); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid limit").
+			SetInternal(err)
+	}
+
+	if input.ViewerID, err = readViewer(r); err != nil {
+		return err
+	}
+
+	// Validate input
+	if input.Offset < 0 || input.Offset > leaderboardMaxOffset {
+		return common.NewError(ErrBadRequest, "Invalid offset")
+	}
+
+	if input.Limit < 1 || input.Limit > leaderboardMaxLimit {
+		return common.NewError(ErrBadRequest, "Invalid limit")
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	entries, err := datastore.GetLeaderboard(input.Offset, input.Limit, input.ViewerID)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	total, err := datastore.GetLeaderboardSize()
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, &LeaderboardGetOutput{
+		Total:   total,
+		Entries: newLeaderboardOutput(entries),
+	})
+}
@@ -0,0 +1,118 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestLeaderboardGet() {
+	// User 0 has a high score of 110, users 1 and 3 share the second rank
+	for _, userID := range []string{suite.Users[1], suite.Users[3]} {
+		_, err := port.GetDatastore(suite.ParentCtx).SubmitScore(userID, 50, port.StateChange{Author: userID, Source: port.StateSourceScore})
+		require.Nil(suite.T(), err)
+	}
+
+	// User 1 is hidden from user 2
+	_, err := port.GetDatastore(suite.ParentCtx).BlockUser(suite.Users[1], suite.Users[2])
+	require.Nil(suite.T(), err)
+
+	tests := []struct {
+		Name               string
+		Query              url.Values
+		ExpectedStatusCode int
+		ExpectedUsers      []string
+		ExpectedRanks      []int
+	}{
+		{
+			Name:               "Top",
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[1], suite.Users[3], suite.Users[2]},
+			ExpectedRanks:      []int{1, 2, 2, 4},
+		}, {
+			Name:               "Page",
+			Query:              url.Values{"offset": {"2"}, "limit": {"2"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[3], suite.Users[2]},
+			ExpectedRanks:      []int{2, 4},
+		}, {
+			Name:               "Viewer",
+			Query:              url.Values{"viewer": {suite.Users[2]}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[3], suite.Users[2]},
+			ExpectedRanks:      []int{1, 2, 4},
+		}, {
+			Name:               "InvalidViewer",
+			Query:              url.Values{"viewer": {"flaf"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "AfterEnd",
+			Query:              url.Values{"offset": {"10"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{},
+			ExpectedRanks:      []int{},
+		}, {
+			Name:               "NegativeOffset",
+			Query:              url.Values{"offset": {"-1"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "LargeOffset",
+			Query:              url.Values{"offset": {"10001"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidLimit",
+			Query:              url.Values{"limit": {"101"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "MalformedLimit",
+			Query:              url.Values{"limit": {"flaf"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/leaderboard?"+test.Query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewLeaderboardGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(LeaderboardGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				userIDs, ranks := make([]string, 0), make([]int, 0)
+				for _, entry := range output.Entries {
+					userIDs = append(userIDs, entry.ID)
+					ranks = append(ranks, entry.Rank)
+				}
+
+				assert.Equal(t, test.ExpectedUsers, userIDs)
+				assert.Equal(t, test.ExpectedRanks, ranks)
+				assert.Equal(t, len(suite.Users), output.Total)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
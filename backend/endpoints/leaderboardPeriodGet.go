@@ -0,0 +1,119 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type LeaderboardPeriodGetInput struct {
+	Window string
+	Period string
+	Offset int
+	Limit  int
+}
+
+type LeaderboardPeriodGetOutput struct {
+	Window   string              `json:"window"`
+	Period   string              `json:"period"`
+	Start    time.Time           `json:"start"`
+	End      time.Time           `json:"end"`
+	Archived bool                `json:"archived"`
+	Total    int                 `json:"total"`
+	Entries  []*LeaderboardEntry `json:"entries"`
+}
+
+// NewLeaderboardPeriodGet is a HandlerFunc processing the request to get a page of a
+// windowed leaderboard, ranking users by their best run within a period. The current
+// period is ranked from the runs, past periods are read from their archive once archived.
+func NewLeaderboardPeriodGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := LeaderboardPeriodGetInput{
+		Window: mux.Vars(r)["window"],
+		Period: r.URL.Query().Get("period"),
+	}
+
+	var err common.Error
+	if input.Offset, err = common.ReadQueryInt(r, "offset", 0); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid offset").
+			SetInternal(err)
+	}
+
+	if input.Limit, err = common.ReadQueryInt(r, "limit", leaderboardDefaultLimit); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid limit").
+			SetInternal(err)
+	}
+
+	// Validate input
+	schedule := leaderboardSchedule(ctx)
+	now := time.Now()
+
+	period, stderr := schedule.Period(input.Window, now)
+	if stderr != nil {
+		return common.NewError(port.ErrInvalidKey, "Unknown window").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	if input.Period != "" {
+		if period, stderr = schedule.ParsePeriod(input.Window, input.Period); stderr != nil {
+			return common.NewError(ErrBadRequest, "Invalid period").
+				SetInternal(stderr)
+		}
+
+		if period.Start.After(now) {
+			return common.NewError(ErrBadRequest, "Invalid period, the period hasn't started")
+		}
+	}
+
+	if input.Offset < 0 || input.Offset > leaderboardMaxOffset {
+		return common.NewError(ErrBadRequest, "Invalid offset")
+	}
+
+	if input.Limit < 1 || input.Limit > leaderboardMaxLimit {
+		return common.NewError(ErrBadRequest, "Invalid limit")
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	output := &LeaderboardPeriodGetOutput{
+		Window: period.Window,
+		Period: period.Key,
+		Start:  period.Start,
+		End:    period.End,
+	}
+
+	var entries []*port.LeaderboardEntry
+	if period.End.After(now) {
+		// The period is still running
+	} else if archive, err := datastore.GetLeaderboardArchive(period); err == nil {
+		output.Archived = true
+		output.Total = archive.Total
+		if entries, err = datastore.GetArchivedLeaderboard(period, input.Offset, input.Limit); err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+	} else if err.Code() != port.ErrInvalidKey.Code() {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Periods not yet archived are ranked from the runs
+	if !output.Archived {
+		if entries, err = datastore.GetPeriodLeaderboard(period, input.Offset, input.Limit); err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		if output.Total, err = datastore.GetPeriodLeaderboardSize(period); err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+	}
+
+	output.Entries = newLeaderboardOutput(entries)
+
+	// Response
+	return common.SuccessResponseJSON(rw, output)
+}
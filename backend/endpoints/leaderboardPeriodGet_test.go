@@ -0,0 +1,190 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestLeaderboardPeriodGet() {
+	now := time.Now().UTC()
+	today := now.Format("2006-01-02")
+	if now.Hour() == 23 && now.Minute() == 59 {
+		suite.T().Skip("Too close to the reset of the daily leaderboard")
+	}
+
+	// User 0 has a high score of 110 without any runs, users 1 and 3 submit runs today
+	for i, userID := range []string{suite.Users[1], suite.Users[3]} {
+		_, err := port.GetDatastore(suite.ParentCtx).SubmitScore(userID, 50+i*20, port.StateChange{Author: userID, Source: port.StateSourceScore})
+		require.Nil(suite.T(), err)
+	}
+
+	// An archived period, ranked differently from the current runs
+	archived, stderr := DefaultLeaderboardSchedule.ParsePeriod(WindowDaily, "2020-01-01")
+	require.Nil(suite.T(), stderr)
+
+	err := port.GetDatastore(suite.ParentCtx).ArchiveLeaderboard(archived, []*port.LeaderboardEntry{
+		{LeaderboardKey: port.LeaderboardKey{Score: 40, UserID: suite.Users[0]}, Rank: 1},
+		{LeaderboardKey: port.LeaderboardKey{Score: 30, UserID: suite.Users[2]}, Rank: 2},
+	})
+	require.Nil(suite.T(), err)
+
+	err = port.GetDatastore(suite.ParentCtx).CompleteLeaderboardArchive(&port.LeaderboardArchive{
+		Window: WindowDaily,
+		Period: archived.Key,
+		Total:  2,
+	})
+	require.Nil(suite.T(), err)
+
+	// A day of the current week, which isn't the first day of a week
+	notWeekStart := now.AddDate(0, 0, -1)
+	if notWeekStart.Weekday() == DefaultLeaderboardSchedule.WeekStart {
+		notWeekStart = now.AddDate(0, 0, -2)
+	}
+
+	tests := []struct {
+		Name               string
+		Window             string
+		Query              url.Values
+		ExpectedStatusCode int
+		ExpectedPeriod     string
+		ExpectedArchived   bool
+		ExpectedTotal      int
+		ExpectedUsers      []string
+		ExpectedRanks      []int
+	}{
+		{
+			Name:               "Daily",
+			Window:             WindowDaily,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedPeriod:     today,
+			ExpectedTotal:      2,
+			ExpectedUsers:      []string{suite.Users[3], suite.Users[1]},
+			ExpectedRanks:      []int{1, 2},
+		}, {
+			Name:               "Monthly",
+			Window:             WindowMonthly,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedPeriod:     now.Format("2006-01"),
+			ExpectedTotal:      2,
+			ExpectedUsers:      []string{suite.Users[3], suite.Users[1]},
+			ExpectedRanks:      []int{1, 2},
+		}, {
+			Name:               "Page",
+			Window:             WindowWeekly,
+			Query:              url.Values{"offset": {"1"}, "limit": {"1"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedTotal:      2,
+			ExpectedUsers:      []string{suite.Users[1]},
+			ExpectedRanks:      []int{2},
+		}, {
+			Name:               "CurrentPeriod",
+			Window:             WindowDaily,
+			Query:              url.Values{"period": {today}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedPeriod:     today,
+			ExpectedTotal:      2,
+			ExpectedUsers:      []string{suite.Users[3], suite.Users[1]},
+			ExpectedRanks:      []int{1, 2},
+		}, {
+			Name:               "PastPeriod",
+			Window:             WindowDaily,
+			Query:              url.Values{"period": {"2020-01-02"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedPeriod:     "2020-01-02",
+			ExpectedTotal:      0,
+			ExpectedUsers:      []string{},
+			ExpectedRanks:      []int{},
+		}, {
+			Name:               "ArchivedPeriod",
+			Window:             WindowDaily,
+			Query:              url.Values{"period": {"2020-01-01"}},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedPeriod:     "2020-01-01",
+			ExpectedArchived:   true,
+			ExpectedTotal:      2,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[2]},
+			ExpectedRanks:      []int{1, 2},
+		}, {
+			Name:               "FuturePeriod",
+			Window:             WindowDaily,
+			Query:              url.Values{"period": {now.AddDate(0, 0, 1).Format("2006-01-02")}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "NotWeekStart",
+			Window:             WindowWeekly,
+			Query:              url.Values{"period": {notWeekStart.Format("2006-01-02")}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "MalformedPeriod",
+			Window:             WindowMonthly,
+			Query:              url.Values{"period": {"2020-01-01"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownWindow",
+			Window:             "yearly",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidLimit",
+			Window:             WindowDaily,
+			Query:              url.Values{"limit": {"0"}},
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/leaderboard/"+test.Window+"?"+test.Query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"window": test.Window})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewLeaderboardPeriodGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(LeaderboardPeriodGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				userIDs, ranks := make([]string, 0), make([]int, 0)
+				for _, entry := range output.Entries {
+					userIDs = append(userIDs, entry.ID)
+					ranks = append(ranks, entry.Rank)
+				}
+
+				assert.Equal(t, test.Window, output.Window)
+				if test.ExpectedPeriod != "" {
+					assert.Equal(t, test.ExpectedPeriod, output.Period)
+				}
+
+				assert.Equal(t, test.ExpectedArchived, output.Archived)
+				assert.Equal(t, test.ExpectedTotal, output.Total)
+				assert.Equal(t, test.ExpectedUsers, userIDs)
+				assert.Equal(t, test.ExpectedRanks, ranks)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,248 @@
+package endpoints
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Windows of the windowed leaderboards
+const (
+	WindowDaily   = "daily"
+	WindowWeekly  = "weekly"
+	WindowMonthly = "monthly"
+)
+
+// LeaderboardWindows lists the windows of the windowed leaderboards
+var LeaderboardWindows = []string{WindowDaily, WindowWeekly, WindowMonthly}
+
+// Number of results archived at a time
+const leaderboardArchiveBatch = 1000
+
+// Period keys, the first day of the period or the month
+const (
+	periodKeyDay   = "2006-01-02"
+	periodKeyMonth = "2006-01"
+)
+
+// LeaderboardSchedule decides when the periods of the windowed leaderboards
+// start. Every period starts at the reset time on the first day of the
+// period, in the time zone of the location.
+type LeaderboardSchedule struct {
+	Location  *time.Location
+	Reset     time.Duration // Time of day
+	WeekStart time.Weekday
+}
+
+// DefaultLeaderboardSchedule resets at midnight UTC, with weeks starting on Monday
+var DefaultLeaderboardSchedule = LeaderboardSchedule{
+	Location:  time.UTC,
+	Reset:     0,
+	WeekStart: time.Monday,
+}
+
+// ParseLeaderboardSchedule parses a schedule from a time zone name, a reset
+// time as "15:04" and the name of the first day of the week. Empty values
+// are left at their default.
+func ParseLeaderboardSchedule(zone, reset, weekStart string) (LeaderboardSchedule, error) {
+	schedule := DefaultLeaderboardSchedule
+
+	if zone = strings.TrimSpace(zone); zone != "" {
+		location, err := time.LoadLocation(zone)
+		if err != nil {
+			return schedule, fmt.Errorf("invalid leaderboard time zone %q: %v", zone, err)
+		}
+
+		schedule.Location = location
+	}
+
+	if reset = strings.TrimSpace(reset); reset != "" {
+		t, err := time.Parse("15:04", reset)
+		if err != nil {
+			return schedule, fmt.Errorf("invalid leaderboard reset time %q, expected HH:MM", reset)
+		}
+
+		schedule.Reset = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+
+	if weekStart = strings.TrimSpace(weekStart); weekStart != "" {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(day.String(), weekStart) {
+				schedule.WeekStart, found = day, true
+			}
+		}
+
+		if !found {
+			return schedule, fmt.Errorf("invalid leaderboard week start %q", weekStart)
+		}
+	}
+
+	return schedule, nil
+}
+
+// SetLeaderboardSchedule sets the schedule of the windowed leaderboards in
+// the context. Without a schedule, the default schedule is used.
+func SetLeaderboardSchedule(ctx context.Context, schedule LeaderboardSchedule) context.Context {
+	return context.WithValue(ctx, contextKeyLeaderboardSchedule, schedule)
+}
+
+func leaderboardSchedule(ctx context.Context) LeaderboardSchedule {
+	schedule, ok := ctx.Value(contextKeyLeaderboardSchedule).(LeaderboardSchedule)
+	if !ok {
+		return DefaultLeaderboardSchedule
+	}
+
+	return schedule
+}
+
+// Period returns the period of the window containing the time
+func (s LeaderboardSchedule) Period(window string, t time.Time) (*port.LeaderboardPeriod, error) {
+	// The day of the time, days start at the reset time
+	t = t.In(s.Location)
+	day := date(t.Year(), t.Month(), t.Day())
+	if t.Before(s.start(day)) {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	switch window {
+	case WindowDaily:
+	case WindowWeekly:
+		day = day.AddDate(0, 0, -((int(day.Weekday()) - int(s.WeekStart) + 7) % 7))
+	case WindowMonthly:
+		day = date(day.Year(), day.Month(), 1)
+	default:
+		return nil, fmt.Errorf("unknown leaderboard window %q", window)
+	}
+
+	return s.period(window, day), nil
+}
+
+// ParsePeriod returns the period of the window known by the key
+func (s LeaderboardSchedule) ParsePeriod(window, key string) (*port.LeaderboardPeriod, error) {
+	layout := periodKeyDay
+	switch window {
+	case WindowDaily, WindowWeekly:
+	case WindowMonthly:
+		layout = periodKeyMonth
+	default:
+		return nil, fmt.Errorf("unknown leaderboard window %q", window)
+	}
+
+	day, err := time.Parse(layout, key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s period %q", window, key)
+	}
+
+	if window == WindowWeekly && day.Weekday() != s.WeekStart {
+		return nil, fmt.Errorf("invalid %s period %q, weeks start on %s", window, key, s.WeekStart)
+	}
+
+	return s.period(window, day), nil
+}
+
+// period returns the period of the window starting on the day
+func (s LeaderboardSchedule) period(window string, day time.Time) *port.LeaderboardPeriod {
+	period := &port.LeaderboardPeriod{
+		Window: window,
+		Key:    day.Format(periodKeyDay),
+		Start:  s.start(day),
+	}
+
+	switch window {
+	case WindowDaily:
+		period.End = s.start(day.AddDate(0, 0, 1))
+	case WindowWeekly:
+		period.End = s.start(day.AddDate(0, 0, 7))
+	case WindowMonthly:
+		period.Key = day.Format(periodKeyMonth)
+		period.End = s.start(day.AddDate(0, 1, 0))
+	}
+
+	return period
+}
+
+// start returns the time the day starts, the reset time on the day in the
+// location. Days are counted in the calendar, so daylight saving time moves
+// the start of the day along with the clock.
+func (s LeaderboardSchedule) start(day time.Time) time.Time {
+	reset := int(s.Reset / time.Minute)
+	return time.Date(day.Year(), day.Month(), day.Day(), reset/60, reset%60, 0, 0, s.Location)
+}
+
+// date returns the calendar day, as midnight UTC
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// ArchiveLeaderboards archives the previous period of every window, unless
+// already archived. The results are stored with the rank they ended with,
+// and the archive is complete once every result is stored. Periods before the
+// previous are left to be ranked from the runs.
+func ArchiveLeaderboards(ctx context.Context, now time.Time) common.Error {
+	schedule := leaderboardSchedule(ctx)
+	datastore := port.GetDatastore(ctx)
+
+	for _, window := range LeaderboardWindows {
+		current, stderr := schedule.Period(window, now)
+		if stderr != nil {
+			return common.NewError(stderr, "")
+		}
+
+		previous, stderr := schedule.Period(window, current.Start.Add(-time.Nanosecond))
+		if stderr != nil {
+			return common.NewError(stderr, "")
+		}
+
+		_, err := datastore.GetLeaderboardArchive(previous)
+		if err == nil {
+			continue
+		}
+
+		if err.Code() != port.ErrInvalidKey.Code() {
+			return err
+		}
+
+		total := 0
+		for {
+			entries, err := datastore.GetPeriodLeaderboard(previous, total, leaderboardArchiveBatch)
+			if err != nil {
+				return err
+			}
+
+			if len(entries) == 0 {
+				break
+			}
+
+			if err := datastore.ArchiveLeaderboard(previous, entries); err != nil {
+				return err
+			}
+
+			total += len(entries)
+		}
+
+		err = datastore.CompleteLeaderboardArchive(&port.LeaderboardArchive{
+			Window: window,
+			Period: previous.Key,
+			Total:  total,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		common.Log(ctx).WithFields(logrus.Fields{
+			"window": window,
+			"period": previous.Key,
+			"total":  total,
+		}).Info("Archived leaderboard")
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package endpoints_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func TestParseLeaderboardSchedule(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Zone              string
+		Reset             string
+		WeekStart         string
+		ExpectedSuccess   bool
+		ExpectedZone      string
+		ExpectedReset     time.Duration
+		ExpectedWeekStart time.Weekday
+	}{
+		{
+			Name:              "Schedule",
+			Zone:              "Europe/Copenhagen",
+			Reset:             "04:30",
+			WeekStart:         "sunday",
+			ExpectedSuccess:   true,
+			ExpectedZone:      "Europe/Copenhagen",
+			ExpectedReset:     4*time.Hour + 30*time.Minute,
+			ExpectedWeekStart: time.Sunday,
+		}, {
+			Name:              "Default",
+			ExpectedSuccess:   true,
+			ExpectedZone:      "UTC",
+			ExpectedReset:     0,
+			ExpectedWeekStart: time.Monday,
+		}, {
+			Name:            "UnknownZone",
+			Zone:            "Europe/Atlantis",
+			ExpectedSuccess: false,
+		}, {
+			Name:            "InvalidReset",
+			Reset:           "25:00",
+			ExpectedSuccess: false,
+		}, {
+			Name:            "InvalidWeekStart",
+			WeekStart:       "someday",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			schedule, err := ParseLeaderboardSchedule(test.Zone, test.Reset, test.WeekStart)
+			if !test.ExpectedSuccess {
+				assert.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedZone, schedule.Location.String())
+			assert.Equal(t, test.ExpectedReset, schedule.Reset)
+			assert.Equal(t, test.ExpectedWeekStart, schedule.WeekStart)
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
+
+func TestLeaderboardSchedulePeriod(t *testing.T) {
+	// Resets at 04:00 in Copenhagen, 02:00 UTC in summer and 03:00 UTC in winter
+	schedule, err := ParseLeaderboardSchedule("Europe/Copenhagen", "04:00", "monday")
+	require.Nil(t, err)
+
+	utc := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		Name          string
+		Window        string
+		Time          time.Time
+		ExpectedKey   string
+		ExpectedStart time.Time
+		ExpectedEnd   time.Time
+	}{
+		{
+			Name:          "Daily",
+			Window:        WindowDaily,
+			Time:          utc(time.October, 19, 10, 0),
+			ExpectedKey:   "2026-10-19",
+			ExpectedStart: utc(time.October, 19, 2, 0),
+			ExpectedEnd:   utc(time.October, 20, 2, 0),
+		}, {
+			Name:          "BeforeReset",
+			Window:        WindowDaily,
+			Time:          utc(time.October, 19, 1, 59),
+			ExpectedKey:   "2026-10-18",
+			ExpectedStart: utc(time.October, 18, 2, 0),
+			ExpectedEnd:   utc(time.October, 19, 2, 0),
+		}, {
+			Name:          "DaylightSavingTime",
+			Window:        WindowDaily,
+			Time:          utc(time.October, 24, 12, 0),
+			ExpectedKey:   "2026-10-24",
+			ExpectedStart: utc(time.October, 24, 2, 0),
+			ExpectedEnd:   utc(time.October, 25, 3, 0),
+		}, {
+			Name:          "Weekly",
+			Window:        WindowWeekly,
+			Time:          utc(time.October, 18, 12, 0),
+			ExpectedKey:   "2026-10-12",
+			ExpectedStart: utc(time.October, 12, 2, 0),
+			ExpectedEnd:   utc(time.October, 19, 2, 0),
+		}, {
+			Name:          "WeeklyBeforeReset",
+			Window:        WindowWeekly,
+			Time:          utc(time.October, 19, 1, 59),
+			ExpectedKey:   "2026-10-12",
+			ExpectedStart: utc(time.October, 12, 2, 0),
+			ExpectedEnd:   utc(time.October, 19, 2, 0),
+		}, {
+			Name:          "Monthly",
+			Window:        WindowMonthly,
+			Time:          utc(time.November, 1, 1, 0),
+			ExpectedKey:   "2026-10",
+			ExpectedStart: utc(time.October, 1, 2, 0),
+			ExpectedEnd:   utc(time.November, 1, 3, 0),
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			period, err := schedule.Period(test.Window, test.Time)
+			require.Nil(t, err)
+
+			assert.Equal(t, test.Window, period.Window)
+			assert.Equal(t, test.ExpectedKey, period.Key)
+			assert.Equal(t, test.ExpectedStart, period.Start.UTC())
+			assert.Equal(t, test.ExpectedEnd, period.End.UTC())
+
+			// The key parses back to the same period
+			parsed, err := schedule.ParsePeriod(test.Window, period.Key)
+			require.Nil(t, err)
+			assert.Equal(t, period, parsed)
+		}
+
+		t.Run(test.Name, fn)
+	}
+
+	_, err = schedule.Period("yearly", utc(time.October, 19, 10, 0))
+	assert.NotNil(t, err)
+}
+
+func TestLeaderboardScheduleParsePeriod(t *testing.T) {
+	tests := []struct {
+		Name            string
+		Window          string
+		Key             string
+		ExpectedSuccess bool
+	}{
+		{
+			Name:            "Daily",
+			Window:          WindowDaily,
+			Key:             "2026-10-13",
+			ExpectedSuccess: true,
+		}, {
+			Name:            "Weekly",
+			Window:          WindowWeekly,
+			Key:             "2026-10-12",
+			ExpectedSuccess: true,
+		}, {
+			Name:            "NotWeekStart",
+			Window:          WindowWeekly,
+			Key:             "2026-10-13",
+			ExpectedSuccess: false,
+		}, {
+			Name:            "Monthly",
+			Window:          WindowMonthly,
+			Key:             "2026-10",
+			ExpectedSuccess: true,
+		}, {
+			Name:            "InvalidDay",
+			Window:          WindowDaily,
+			Key:             "2026-13-01",
+			ExpectedSuccess: false,
+		}, {
+			Name:            "UnknownWindow",
+			Window:          "yearly",
+			Key:             "2026",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			period, err := DefaultLeaderboardSchedule.ParsePeriod(test.Window, test.Key)
+			if !test.ExpectedSuccess {
+				assert.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+			assert.Equal(t, test.Key, period.Key)
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
+
+func (suite *EndpointsTestSuite) TestArchiveLeaderboards() {
+	datastore := port.GetDatastore(suite.ParentCtx)
+	for i, userID := range []string{suite.Users[1], suite.Users[3]} {
+		_, err := datastore.SubmitScore(userID, 50+i*20, port.StateChange{Author: userID, Source: port.StateSourceScore})
+		suite.Require().Nil(err)
+	}
+
+	// Archive the periods ending before tomorrow, today included
+	now := time.Now()
+	period, stderr := DefaultLeaderboardSchedule.Period(WindowDaily, now)
+	suite.Require().Nil(stderr)
+
+	suite.Require().Nil(ArchiveLeaderboards(suite.ParentCtx, period.End))
+
+	archive, err := datastore.GetLeaderboardArchive(period)
+	suite.Require().Nil(err)
+	suite.Equal(2, archive.Total)
+
+	entries, err := datastore.GetArchivedLeaderboard(period, 0, 10)
+	suite.Require().Nil(err)
+	suite.Require().Len(entries, 2)
+	suite.Equal(suite.Users[3], entries[0].UserID)
+	suite.Equal(1, entries[0].Rank)
+	suite.Equal(suite.Users[1], entries[1].UserID)
+	suite.Equal(2, entries[1].Rank)
+
+	// Archived periods are kept as they are
+	_, err = datastore.SubmitScore(suite.Users[2], 90, port.StateChange{Author: suite.Users[2], Source: port.StateSourceScore})
+	suite.Require().Nil(err)
+	suite.Require().Nil(ArchiveLeaderboards(suite.ParentCtx, period.End))
+
+	archive, err = datastore.GetLeaderboardArchive(period)
+	suite.Require().Nil(err)
+	suite.Equal(2, archive.Total)
+}
@@ -0,0 +1,176 @@
+package endpoints
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Page size of lists
+const (
+	listDefaultLimit = 50
+	listMaxLimit     = 100
+)
+
+// Links contains the links to the pages next to the current page of a list
+type Links struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// listCursor is the content of the opaque cursors handed out in links
+type listCursor struct {
+	Sort       string `json:"s"`
+	Descending bool   `json:"d,omitempty"`
+	Before     bool   `json:"b,omitempty"`
+	UserID     string `json:"i"`
+	Name       string `json:"n,omitempty"`
+	Score      int    `json:"v,omitempty"`
+}
+
+func encodeCursor(c *listCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(listCursor)
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// readListQuery parses the sorting, limit and cursor of a list request.
+// Sort is given as a field name, prefixed by '-' for descending order.
+func readListQuery(r *http.Request, sortFields ...string) (*port.ListQuery, common.Error) {
+	query := &port.ListQuery{Sort: port.SortByID}
+
+	// Sorting
+	if sort := r.URL.Query().Get("sort"); sort != "" {
+		query.Descending = strings.HasPrefix(sort, "-")
+		query.Sort = strings.TrimPrefix(sort, "-")
+
+		valid := query.Sort == port.SortByID
+		for _, field := range sortFields {
+			valid = valid || query.Sort == field
+		}
+
+		if !valid {
+			return nil, common.NewError(ErrBadRequest, "Invalid sort field")
+		}
+	}
+
+	// Limit
+	var err common.Error
+	if query.Limit, err = common.ReadQueryInt(r, "limit", listDefaultLimit); err != nil {
+		return nil, common.NewError(ErrBadRequest, "Invalid limit").
+			SetInternal(err)
+	}
+
+	if query.Limit < 1 || query.Limit > listMaxLimit {
+		return nil, common.NewError(ErrBadRequest, "Invalid limit")
+	}
+
+	// Cursor
+	if s := r.URL.Query().Get("cursor"); s != "" {
+		c, stderr := decodeCursor(s)
+		if stderr != nil {
+			return nil, common.NewError(ErrBadRequest, "Invalid cursor").
+				SetInternal(stderr)
+		}
+
+		if c.Sort != query.Sort || c.Descending != query.Descending {
+			return nil, common.NewError(ErrBadRequest, "Cursor doesn't match the sort order")
+		}
+
+		key := &port.ListKey{UserID: c.UserID, Name: c.Name, Score: c.Score}
+		if c.Before {
+			query.Before = key
+		} else {
+			query.After = key
+		}
+	}
+
+	return query, nil
+}
+
+// trimPage trims a page fetched with one entry more than the limit, to tell
+// if there are more entries. Returns the bounds of the page within the
+// fetched entries, and whether there are more entries.
+func trimPage(query *port.ListQuery, fetched int) (int, int, bool) {
+	if fetched <= query.Limit {
+		return 0, fetched, false
+	}
+
+	// Pages before an entry end at the entry, the extra is at the start
+	if query.Before != nil {
+		return fetched - query.Limit, fetched, true
+	}
+
+	return 0, query.Limit, true
+}
+
+// listLinks builds the links to the pages around the given page of keys
+func listLinks(r *http.Request, query *port.ListQuery, keys []port.ListKey, more bool) Links {
+	link := func(key port.ListKey, before bool) string {
+		v := make(url.Values)
+		for k, vs := range r.URL.Query() {
+			v[k] = vs
+		}
+
+		v.Set("cursor", encodeCursor(&listCursor{
+			Sort:       query.Sort,
+			Descending: query.Descending,
+			Before:     before,
+			UserID:     key.UserID,
+			Name:       key.Name,
+			Score:      key.Score,
+		}))
+
+		return r.URL.Path + "?" + v.Encode()
+	}
+
+	links := Links{}
+
+	// Moving backwards there's always a next page, and a previous one if
+	// there were more entries. Moving forward it's the other way around.
+	hasNext, hasPrev := more, query.After != nil
+	if query.Before != nil {
+		hasNext, hasPrev = true, more
+	}
+
+	if len(keys) == 0 {
+		// An empty page links back to the entry it was relative to
+		if query.After != nil {
+			links.Prev = link(*query.After, true)
+		}
+
+		if query.Before != nil {
+			links.Next = link(*query.Before, false)
+		}
+
+		return links
+	}
+
+	if hasNext {
+		links.Next = link(keys[len(keys)-1], false)
+	}
+
+	if hasPrev {
+		links.Prev = link(keys[0], true)
+	}
+
+	return links
+}
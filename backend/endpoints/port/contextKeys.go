@@ -1,11 +1,17 @@
 package port
 
-import "context"
+import (
+	"context"
+
+	"github.com/valsgaard/interview-case/backend/common"
+)
 
 type contextKey int
 
 const (
 	contextKeyDatastore contextKey = iota
+	contextKeySocialGraph
+	contextKeyShardConnector
 )
 
 // SetDatastore sets the datastore adapter in the context
@@ -13,7 +19,26 @@ func SetDatastore(ctx context.Context, db Datastore) context.Context {
 	return context.WithValue(ctx, contextKeyDatastore, db)
 }
 
-// GetDatastore retrieves a datastore adapter from the given context
+// GetDatastore retrieves a datastore adapter from the given context.
+// Traceable adapters are scoped to the log of the context.
 func GetDatastore(ctx context.Context) Datastore {
-	return ctx.Value(contextKeyDatastore).(Datastore)
+	db := ctx.Value(contextKeyDatastore).(Datastore)
+	if t, ok := db.(Traceable); ok {
+		return t.WithLog(common.Log(ctx))
+	}
+
+	return db
+}
+
+// SetShardConnector sets the function opening the datastores of new shards in
+// the context
+func SetShardConnector(ctx context.Context, connect ShardConnector) context.Context {
+	return context.WithValue(ctx, contextKeyShardConnector, connect)
+}
+
+// GetShardConnector retrieves the function opening the datastores of new shards
+// from the given context, nil if shards can't be opened
+func GetShardConnector(ctx context.Context) ShardConnector {
+	connect, _ := ctx.Value(contextKeyShardConnector).(ShardConnector)
+	return connect
 }
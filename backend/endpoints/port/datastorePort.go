@@ -1,6 +1,10 @@
 package port
 
 import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
 	"github.com/valsgaard/interview-case/backend/common"
 )
 
@@ -8,18 +12,151 @@ import (
 type Datastore interface {
 	NewUser(id, name string) (*User, common.Error)
 	GetUsers() ([]*User, common.Error)
+	ListUsers(query *ListQuery) ([]*User, common.Error)
 	UserExists(id string) (bool, common.Error)
+	SearchUsersByPrefix(prefix, viewerID string, offset, limit int) ([]*UserMatch, common.Error)
+	SearchUsersFuzzy(query, viewerID string, offset, limit int) ([]*UserMatch, common.Error)
+
+	// Blocks are stored with the blocker. Users who blocked the viewer are
+	// left out of searches, lists and friend lists shown to the viewer.
+	BlockUser(userID, blockedID string) (bool, common.Error)
+	UnblockUser(userID, blockedID string) (bool, common.Error)
+	GetBlockedUsers(userID string) ([]*UserBlock, common.Error)
+	IsBlocked(userID, otherID string) (bool, common.Error)
+
+	FlagUser(userID, reason string) common.Error
+	GetFlaggedUsers() ([]*UserFlag, common.Error)
+	ClearUserFlag(userID string) common.Error
 
-	UpdateGameState(userID string, gamesPlayed, score int) common.Error
+	// Games played and the best score are owned by the server, and only
+	// changed by SubmitScore. Other writes of the game state keep them.
+	UpdateGameState(userID string, state *GameState, change StateChange) common.Error
 	GetGameState(userID string) (*GameState, common.Error)
+	SubmitScore(userID string, score int, change StateChange) (*ScoreResult, common.Error)
+	ModifyGameState(userID string, change StateChange, modify GameStateModifier) (*GameState, common.Error)
+	CompareAndSwapGameState(userID string, revision int, state *GameState, change StateChange) (int, common.Error)
+	GetGameStateRevisions(userID string) ([]*StateRevision, common.Error)
+	GetGameStateRevision(userID string, revision int) (*StateRevision, common.Error)
+
+	NewSaveSlot(userID string, slot *SaveSlot, maxSlots int) (*SaveSlot, common.Error)
+	GetSaveSlots(userID string) ([]*SaveSlot, common.Error)
+	GetSaveSlot(userID, slotID string) (*SaveSlot, common.Error)
+	UpdateSaveSlot(userID, slotID string, update *SaveSlotUpdate) (*SaveSlot, common.Error)
+	DeleteSaveSlot(userID, slotID string) common.Error
+	ActivateSaveSlot(userID, slotID string, change StateChange) (*GameState, common.Error)
+
+	NewGame(gameID string, userIDs []string) (*Game, common.Error)
+	GetGame(gameID string) (*Game, common.Error)
+	SetGameResult(gameID, userID string, score int) (*Game, common.Error)
 
 	UpdateFriends(userID string, friends []string) common.Error
+	AddFriend(userID, friendID string) (bool, common.Error)
+	RemoveFriend(userID, friendID string) (bool, common.Error)
 	GetFriends(userID string) ([]*Friend, common.Error)
+	ListFriends(userID string, query *ListQuery) ([]*Friend, common.Error)
+	GetFriendIDs(userID string) ([]string, common.Error)
+	GetFriendsByID(userIDs []string, viewerID string) ([]*Friend, common.Error)
+	GetFriendSuggestions(userID string, limit int) ([]*FriendSuggestion, common.Error)
+
+	// The high scores of the friends last seen by a user on the friends
+	// leaderboard are stored with the user. Viewing replaces the scores,
+	// returning the previous ones.
+	ViewFriendScores(userID string, views []*FriendScoreView) ([]*FriendScoreView, common.Error)
+
+	// Friend requests are stored with the recipient. Accepting a request adds
+	// each user as a friend of the other, if stored by the same datastore.
+	// Requests beyond the pending requests sent or received are refused.
+	NewFriendRequest(request *FriendRequest, maxPending int) (*FriendRequest, common.Error)
+	GetFriendRequests(userID string) ([]*FriendRequest, common.Error)
+	GetSentFriendRequests(userID string) ([]*FriendRequest, common.Error)
+	AcceptFriendRequest(fromID, toID string) common.Error
+	DeleteFriendRequest(fromID, toID string) common.Error
+
+	// Referral codes are stored with the referrer, together with the referrals
+	// made by the user. A user has a single code, creating another replaces it.
+	NewReferralCode(code *ReferralCode) (*ReferralCode, common.Error)
+	GetReferralCode(userID string) (*ReferralCode, common.Error)
+	LookupReferralCode(code string) (*ReferralCode, common.Error)
+	RedeemReferralCode(code, userID string) (*ReferralCode, common.Error)
+	GetReferrals(userID string) ([]*Referral, common.Error)
+
+	// Linked accounts are stored with the user, a user links a single account
+	// of each provider. An external account is linked to a single user.
+	LinkAccount(account *LinkedAccount) (*LinkedAccount, common.Error)
+	UnlinkAccount(userID, provider string) (bool, common.Error)
+	GetLinkedAccounts(userID string) ([]*LinkedAccount, common.Error)
+	FindLinkedAccounts(provider string, externalIDs []string) ([]*LinkedAccount, common.Error)
+
+	// The leaderboard ranks all users by descending high score, then UserID.
+	// Users with the same high score share the rank of the first of them.
+	// Users who blocked the viewer are left out, keeping the ranks of the
+	// others, and an empty viewer sees every user.
+	GetLeaderboard(offset, limit int, viewerID string) ([]*LeaderboardEntry, common.Error)
+	GetLeaderboardAround(userID string, radius int, viewerID string) ([]*LeaderboardEntry, common.Error)
+	GetLeaderboardSize() (int, common.Error)
+
+	// Used for merging the leaderboards of several datastores. The range is
+	// the entries before the key, and from the key on.
+	GetLeaderboardRange(key LeaderboardKey, before, after int) ([]*LeaderboardEntry, common.Error)
+	CountLeaderboardAhead(key LeaderboardKey) (int, common.Error)
+
+	// The score distribution counts the high scores of all users in buckets,
+	// kept up to date as scores change. Distributions of several datastores
+	// are merged by adding up the counts of the buckets.
+	GetScoreDistribution() (*ScoreDistribution, common.Error)
+
+	// Windowed leaderboards rank users by their best run submitted within a
+	// period. Results of past periods are archived with each user, and the
+	// archive of a period is complete once its summary is stored.
+	GetPeriodLeaderboard(period *LeaderboardPeriod, offset, limit int) ([]*LeaderboardEntry, common.Error)
+	GetPeriodLeaderboardSize(period *LeaderboardPeriod) (int, common.Error)
+	ArchiveLeaderboard(period *LeaderboardPeriod, entries []*LeaderboardEntry) common.Error
+	CompleteLeaderboardArchive(archive *LeaderboardArchive) common.Error
+	GetLeaderboardArchive(period *LeaderboardPeriod) (*LeaderboardArchive, common.Error)
+	GetArchivedLeaderboard(period *LeaderboardPeriod, offset, limit int) ([]*LeaderboardEntry, common.Error)
+
+	// Used for moving users between datastores
+	ExportUser(userID string) (*UserRecord, common.Error)
+	ImportUser(record *UserRecord) common.Error
+	GetGameIDs() ([]string, common.Error)
+	ExportGame(gameID string) (*Game, common.Error)
+	ImportGame(game *Game) common.Error
+	DeleteGame(gameID string) common.Error
 
 	// Used only for testing
 	DeleteUser(userID string) common.Error
 }
 
+// GameStateModifier changes a game state in place. It's given the current
+// state of the current version, and is called again if the state changed
+// before the result could be stored.
+type GameStateModifier func(state *GameState) common.Error
+
+// Traceable is implemented by adapters which can log their operations
+// through a given log, such as the request scoped log
+type Traceable interface {
+	WithLog(log *logrus.Entry) Datastore
+}
+
+// Reshardable is implemented by adapters spreading users across named shards,
+// which can move users onto a new set of shards while serving requests
+type Reshardable interface {
+	// Shards returns the current shards by name
+	Shards() map[string]Datastore
+
+	// ReshardStatus returns the shards being resharded onto, nil when idle,
+	// and whether the resharding is running
+	ReshardStatus() (map[string]Datastore, bool)
+
+	// Reshard moves users and games onto the given shards, returning the
+	// number moved. Shards keeping their name must be the same datastore,
+	// and an unfinished resharding must be resumed with the same shards.
+	Reshard(shards map[string]Datastore) (int, common.Error)
+}
+
+// ShardConnector opens the datastore of a shard from its URI
+type ShardConnector func(uri string) (Datastore, common.Error)
+
 /**************************************************************************
 ***************************************************************************
 **                                                                       **
@@ -34,17 +171,329 @@ type User struct {
 	Name   string
 }
 
-// GameState is the value object used to input / output GameState related data from the adapter
+// UserMatch is the value object used to output user search results from the
+// adapter. Matches are ordered by descending rank, then name and UserID.
+type UserMatch struct {
+	User
+	Rank float64
+}
+
+// GameState is the value object used to input / output GameState related data from the adapter.
+// It's a versioned JSON document, see gameState.go for the well-known fields.
+//
+// The revision is incremented by the adapter on every write of the state,
+// and isn't part of the document.
 type GameState struct {
-	GamesPlayed int
+	Version  int
+	Revision int
+	Document map[string]interface{}
+}
+
+// StateChange describes who changed a game state and how. It's recorded with
+// the revision created by the change.
+type StateChange struct {
+	Author string
+	Source string
+
+	// Key identifies a submitted score which may be retried, such as the
+	// result of a game. A score is only counted once for each key.
+	Key string
+}
+
+// Sources of game state changes
+const (
+	StateSourceUpdate  = "update"
+	StateSourcePatch   = "patch"
+	StateSourceScore   = "score"
+	StateSourceGame    = "game"
+	StateSourceRestore = "restore"
+	StateSourceSlot    = "slot"
+	StateSourceSync    = "sync"
+)
+
+// StateAuthorAdmin is the author of changes made by administrators, other
+// changes are authored by the user owning the state
+const StateAuthorAdmin = "admin"
+
+// StateRevision is the value object used to output a recorded revision of
+// a game state. Revisions are immutable, but only kept for a limited time.
+type StateRevision struct {
+	Revision  int
+	State     GameState
+	Author    string
+	Source    string
+	CreatedAt time.Time
+}
+
+// SaveSlot is the value object used to input / output a named save of a user.
+// The game state of the active slot is the game state of the user, while the
+// other slots keep theirs until activated.
+type SaveSlot struct {
+	SlotID string
+	Name   string
+	Active bool
+	State  GameState
+
+	// Metadata reported by the client
+	LastDevice string
+	PlayTime   int // Seconds
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SaveSlotUpdate is the value object used to change the metadata of a save
+// slot, fields which are nil are kept
+type SaveSlotUpdate struct {
+	Name       *string
+	LastDevice *string
+	PlayTime   *int
+}
+
+// DefaultSaveSlotName is the name of the slot every user is created with.
+// The default slot has the ID of its user.
+const DefaultSaveSlotName = "Default"
+
+// UserFlag is the value object used to output a user flagged for review,
+// with the reason it was last flagged for
+type UserFlag struct {
+	UserID         string
+	Reason         string
+	Count          int
+	FirstFlaggedAt time.Time
+	LastFlaggedAt  time.Time
+}
+
+// UserBlock is the value object used to output a user blocked by another.
+// Blocking removes the friendship of the users, and prevents a new one.
+type UserBlock struct {
+	UserID    string
+	BlockedID string
+	CreatedAt time.Time
+}
+
+// ScoreRun is the value object used to input / output a single recorded run
+type ScoreRun struct {
 	Score       int
+	SubmittedAt time.Time
+	Key         string // Key of the change submitting the run, if any
+}
+
+// ScoreResult is the value object used to output the result of a score
+// submission, containing the game state after the run was recorded
+type ScoreResult struct {
+	GameState    GameState
+	PersonalBest bool
+	Replayed     bool // The key of the change was already counted, and nothing changed
+}
+
+// Statuses of games and their participants, a game is finished once every
+// participant is
+const (
+	GamePlaying  = "playing"
+	GameFinished = "finished"
+)
+
+// Game is the value object used to input / output Game related data from the adapter.
+// Every user has a personal game, with the ID of the user and the user as its only
+// participant, scored with the best score. Its state is the game state of the user,
+// which /user/{id}/state is a view of. Other games are stored apart from the game state
+// of their participants, without a state, and a result is counted in the game state of
+// the participant when submitted.
+type Game struct {
+	GameID       string
+	Status       string
+	Participants []*Participant
+	State        *GameState // Set for personal games
+}
+
+// Participant is the value object containing the status and result of a
+// single user in a game
+type Participant struct {
+	UserID string
+	Status string
+	Score  int
 }
 
 // Friend is the value object used to input / output Friend related data from the adapter
 type Friend struct {
-	UserID    string
-	Name      string
-	HighScore int
+	UserID       string
+	Name         string
+	HighScore    int
+	LastActiveAt *time.Time // Time of the latest game state revision, nil if never written
+}
+
+// FriendScoreView is the value object used to input / output the high score of a friend,
+// as last seen by the user on the friends leaderboard
+type FriendScoreView struct {
+	FriendID string
+	Score    int
+	ViewedAt time.Time
+}
+
+// FriendSuggestion is the value object used to output a friend of the friends of a
+// user, who isn't a friend yet. Suggestions are ordered by descending mutual friends,
+// then the most recently active and UserID. Blocked users aren't suggested.
+type FriendSuggestion struct {
+	Friend
+	MutualFriends int
+}
+
+// FriendRequest is the value object used to input / output a pending friend
+// request. Requests are stored with the recipient, and ignored once expired.
+type FriendRequest struct {
+	FromID    string
+	ToID      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// ReferralCode is the value object used to input / output the shareable code of a
+// user. Codes can't be redeemed once expired, or redeemed MaxRedemptions times.
+type ReferralCode struct {
+	Code           string
+	UserID         string
+	MaxRedemptions int
+	Redemptions    int
+	CreatedAt      time.Time
+	ExpiresAt      time.Time
+}
+
+// Referral is the value object used to output the attribution of a new user to
+// the referrer whose code was redeemed
+type Referral struct {
+	ReferrerID string
+	UserID     string
+	Code       string
+	CreatedAt  time.Time
+}
+
+// LinkedAccount is the value object used to input / output the account of a user
+// on an external platform, known by the name of its SocialGraphProvider
+type LinkedAccount struct {
+	UserID     string
+	Provider   string
+	ExternalID string
+	LinkedAt   time.Time
+}
+
+// LeaderboardKey is the value object containing the sort keys of a leaderboard entry.
+// An empty UserID is ordered before the users with the same score.
+type LeaderboardKey struct {
+	Score  int
+	UserID string
+}
+
+// LeaderboardEntry is the value object used to output a ranked user of a leaderboard
+type LeaderboardEntry struct {
+	LeaderboardKey
+	Name string
+	Rank int
+}
+
+// ScoreDistribution is the value object used to output the high scores of all users,
+// counted in buckets ordered by score. Empty buckets are left out.
+type ScoreDistribution struct {
+	Total   int
+	Buckets []ScoreBucket
+}
+
+// ScoreBucket is a part of ScoreDistribution, counting the users with a high score
+// from Min up to but not including Max
+type ScoreBucket struct {
+	Min   int
+	Max   int
+	Count int
+}
+
+// LeaderboardPeriod is the value object describing a period of a windowed leaderboard,
+// known by its key within the window. The period ends right before End.
+type LeaderboardPeriod struct {
+	Window string
+	Key    string
+	Start  time.Time
+	End    time.Time
+}
+
+// LeaderboardArchive is the value object used to input / output the summary of an
+// archived period of a windowed leaderboard
+type LeaderboardArchive struct {
+	Window     string
+	Period     string
+	Total      int
+	ArchivedAt time.Time
+}
+
+// LeaderboardResult is the value object used to input / output the archived result
+// of a user in a period of a windowed leaderboard
+type LeaderboardResult struct {
+	Window string
+	Period string
+	Score  int
+	Rank   int
+}
+
+// UserRecord is the value object containing everything stored for a single user
+type UserRecord struct {
+	User      User
+	GameState GameState
+	FriendIDs []string
+	Runs      []ScoreRun
+	Revisions []StateRevision
+	Slots     []SaveSlot
+	Flag      *UserFlag // Nil unless flagged
+
+	// Pending friend requests received by the user
+	FriendRequests []FriendRequest
+
+	// Users blocked by the user
+	Blocks []UserBlock
+
+	// Referral code of the user, nil if never created, and the users referred
+	ReferralCode *ReferralCode
+	Referrals    []Referral
+
+	// Accounts on external platforms linked by the user
+	LinkedAccounts []LinkedAccount
+
+	// Archived results of the windowed leaderboards
+	LeaderboardResults []LeaderboardResult
+
+	// High scores of the friends last seen on the friends leaderboard
+	FriendScoreViews []FriendScoreView
+}
+
+// Fields lists can be sorted by
+const (
+	SortByID    = "id"
+	SortByName  = "name"
+	SortByScore = "score"
+)
+
+// ListQuery is the value object describing a page of a list, using keyset
+// pagination. Entries are sorted by the field, then UserID.
+type ListQuery struct {
+	Sort       string
+	Descending bool
+
+	// The page starts after, or ends before, the entry with the given keys.
+	// Pages are returned in sort order either way.
+	After  *ListKey
+	Before *ListKey
+
+	Limit int
+
+	// Filters, ignored if empty
+	NamePrefix string
+	MinScore   *int
+	ViewerID   string // Users who blocked the viewer are left out
+}
+
+// ListKey is the value object containing the sort keys of a list entry
+type ListKey struct {
+	UserID string
+	Name   string
+	Score  int
 }
 
 /**************************************************************************
@@ -60,3 +509,23 @@ var ErrEntryExists = common.PrepareError("D001", "Entry already exists")
 
 // ErrInvalidKey indicates that a given key is invalid or malformed
 var ErrInvalidKey = common.PrepareError("D002", "Invalid key")
+
+// ErrInvalidDocument indicates that a stored or given document can't be read
+var ErrInvalidDocument = common.PrepareError("D003", "Invalid document")
+
+// ErrConflict indicates that an entry kept changing while being modified, or
+// was changed since the revision it was compared against
+var ErrConflict = common.PrepareError("D004", "Entry was modified concurrently")
+
+// ErrEntryInUse indicates that an entry can't be removed while it's in use
+var ErrEntryInUse = common.PrepareError("D005", "Entry is in use")
+
+// ErrBlocked indicates that one of the users involved has blocked the other
+var ErrBlocked = common.PrepareError("D006", "User is blocked")
+
+// ErrUnavailable indicates that an entry has expired, or has been used up
+var ErrUnavailable = common.PrepareError("D007", "Entry is no longer available")
+
+// ErrLimitReached indicates that a user has as many entries as allowed, and
+// another can't be added
+var ErrLimitReached = common.PrepareError("D008", "Limit reached")
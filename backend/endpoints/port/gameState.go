@@ -0,0 +1,204 @@
+package port
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/valsgaard/interview-case/backend/common"
+)
+
+// GameStateVersion is the schema version of new game state documents
+const GameStateVersion = 2
+
+// Well-known fields of game state documents. Migrations must keep them at
+// the top level of the document, so adapters can store them apart.
+const (
+	GameStateFieldVersion     = "version"
+	GameStateFieldGamesPlayed = "gamesPlayed"
+	GameStateFieldScore       = "score"
+	GameStateFieldProgress    = "progress"
+	GameStateFieldSettings    = "settings"
+)
+
+// gameStateServerFields are the well-known fields owned by the server. They're
+// only changed by submitted scores, and other writes of the game state keep
+// their stored values.
+var gameStateServerFields = []string{GameStateFieldGamesPlayed, GameStateFieldScore}
+
+// gameStateMigrations upgrade documents from the version they're keyed by,
+// to the next version
+var gameStateMigrations = map[int]func(doc map[string]interface{}) common.Error{
+	// Version 1 is the original document of games played and score.
+	// Version 2 adds sections for the players progress and settings.
+	1: func(doc map[string]interface{}) common.Error {
+		for _, field := range []string{GameStateFieldProgress, GameStateFieldSettings} {
+			if _, ok := doc[field]; !ok {
+				doc[field] = map[string]interface{}{}
+			}
+		}
+
+		return nil
+	},
+}
+
+// NewGameState creates a game state document of the current version
+func NewGameState(gamesPlayed, score int) *GameState {
+	s := &GameState{Version: 1, Document: map[string]interface{}{}}
+	s.Upgrade()
+
+	s.SetGamesPlayed(gamesPlayed)
+	s.SetScore(score)
+	return s
+}
+
+// Upgrade migrates the document to the current version
+func (s *GameState) Upgrade() common.Error {
+	if s.Version > GameStateVersion {
+		return common.NewError(ErrInvalidDocument, "Unsupported game state version").
+			WithField("version", s.Version)
+	}
+
+	for s.Version < GameStateVersion {
+		migrate, ok := gameStateMigrations[s.Version]
+		if !ok {
+			return common.NewError(ErrInvalidDocument, "Unsupported game state version").
+				WithField("version", s.Version)
+		}
+
+		if err := migrate(s.Document); err != nil {
+			return err.WithField("version", s.Version)
+		}
+
+		s.Version++
+	}
+
+	return nil
+}
+
+// Copy returns a deep copy of the game state
+func (s *GameState) Copy() *GameState {
+	doc, _ := common.CopyJSON(s.Document).(map[string]interface{})
+	return &GameState{Version: s.Version, Revision: s.Revision, Document: doc}
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Well-known fields                                                   **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// GamesPlayed returns the number of games played
+func (s *GameState) GamesPlayed() int {
+	return s.intField(GameStateFieldGamesPlayed)
+}
+
+// SetGamesPlayed sets the number of games played
+func (s *GameState) SetGamesPlayed(gamesPlayed int) {
+	s.setIntField(GameStateFieldGamesPlayed, gamesPlayed)
+}
+
+// Score returns the best score
+func (s *GameState) Score() int {
+	return s.intField(GameStateFieldScore)
+}
+
+// SetScore sets the best score
+func (s *GameState) SetScore(score int) {
+	s.setIntField(GameStateFieldScore, score)
+}
+
+// GameStateServerField tells if the field at a JSON Pointer is owned by the server
+func GameStateServerField(pointer string) bool {
+	for _, field := range gameStateServerFields {
+		if pointer == "/"+field || strings.HasPrefix(pointer, "/"+field+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KeepServerFields sets the fields owned by the server to those of the stored
+// state, dropping any change made to them
+func (s *GameState) KeepServerFields(stored *GameState) {
+	s.SetGamesPlayed(stored.GamesPlayed())
+	s.SetScore(stored.Score())
+}
+
+// intField reads an integer field, zero if missing or not an integer
+func (s *GameState) intField(field string) int {
+	switch v := s.Document[field].(type) {
+	case json.Number:
+		i, _ := v.Int64()
+		return int(i)
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+
+	return 0
+}
+
+func (s *GameState) setIntField(field string, v int) {
+	s.Document[field] = json.Number(strconv.Itoa(v))
+}
+
+/**************************************************************************
+***************************************************************************
+**                                                                       **
+**   Encoding                                                            **
+**                                                                       **
+***************************************************************************
+**************************************************************************/
+
+// MarshalJSON encodes the document, with its version as a field
+func (s GameState) MarshalJSON() ([]byte, error) {
+	doc := make(map[string]interface{}, len(s.Document)+1)
+	for key, value := range s.Document {
+		doc[key] = value
+	}
+
+	doc[GameStateFieldVersion] = s.Version
+	return json.Marshal(doc)
+}
+
+// UnmarshalJSON decodes a document. Documents without a version are from
+// before game state was versioned.
+func (s *GameState) UnmarshalJSON(b []byte) error {
+	var doc map[string]interface{}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return err
+	}
+
+	if doc == nil {
+		return errors.New("game state must be an object")
+	}
+
+	s.Version = 1
+	if v, ok := doc[GameStateFieldVersion]; ok {
+		n, ok := v.(json.Number)
+		if !ok {
+			return errors.New("game state version must be a number")
+		}
+
+		version, err := n.Int64()
+		if err != nil {
+			return errors.New("game state version must be an integer")
+		}
+
+		s.Version = int(version)
+		delete(doc, GameStateFieldVersion)
+	}
+
+	s.Document = doc
+	return nil
+}
@@ -0,0 +1,80 @@
+package port
+
+import (
+	"sort"
+
+	"github.com/valsgaard/interview-case/backend/common"
+)
+
+// gameStateSchemas are the JSON Schemas of each game state version, which
+// documents are validated against when written. The version field isn't a
+// part of the document being validated. Integers are limited to what the
+// adapters can store, and the fields owned by the server are read only.
+var gameStateSchemas = map[int]*common.Schema{
+	1: common.MustParseSchema(`{
+		"type": "object",
+		"properties": {
+			"gamesPlayed": {"type": "integer", "minimum": 0, "maximum": 2147483647, "readOnly": true},
+			"score":       {"type": "integer", "minimum": 0, "maximum": 2147483647, "readOnly": true}
+		},
+		"additionalProperties": false
+	}`),
+
+	2: common.MustParseSchema(`{
+		"type": "object",
+		"required": ["progress", "settings"],
+		"properties": {
+			"gamesPlayed": {"type": "integer", "minimum": 0, "maximum": 2147483647, "readOnly": true},
+			"score":       {"type": "integer", "minimum": 0, "maximum": 2147483647, "readOnly": true},
+			"progress":    {"type": "object", "maxProperties": 256},
+			"settings": {
+				"type": "object",
+				"maxProperties": 64,
+				"additionalProperties": {"type": ["boolean", "number", "string"]}
+			}
+		},
+		"additionalProperties": false
+	}`),
+}
+
+// GameStateSchema returns the schema of a game state version, nil if the
+// version isn't supported
+func GameStateSchema(version int) *common.Schema {
+	if version > GameStateVersion {
+		return nil
+	}
+
+	return gameStateSchemas[version]
+}
+
+// GameStateSchemaVersions returns the game state versions accepted when
+// writing, oldest first
+func GameStateSchemaVersions() []int {
+	versions := make([]int, 0, len(gameStateSchemas))
+	for version := range gameStateSchemas {
+		if GameStateSchema(version) != nil {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Ints(versions)
+	return versions
+}
+
+// Validate validates the document against the schema of its version. The
+// violations are set as the details of the error.
+func (s *GameState) Validate() common.Error {
+	schema := GameStateSchema(s.Version)
+	if schema == nil {
+		return common.NewError(ErrInvalidDocument, "Unsupported game state version").
+			WithField("version", s.Version)
+	}
+
+	if errs := schema.Validate(s.Document); len(errs) > 0 {
+		return common.NewError(ErrInvalidDocument, "Game state doesn't match the schema of its version").
+			WithField("version", s.Version).
+			SetDetails(errs)
+	}
+
+	return nil
+}
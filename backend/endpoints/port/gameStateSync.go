@@ -0,0 +1,129 @@
+package port
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/valsgaard/interview-case/backend/common"
+)
+
+// MergeRule is how a field changed offline is merged with the changes made
+// to the stored game state in the meantime
+type MergeRule string
+
+// Rules fields are merged by
+const (
+	MergeMax        MergeRule = "max"        // Keeps the highest value, such as a best time
+	MergeSum        MergeRule = "sum"        // Adds the offline change, such as counters
+	MergeLastWriter MergeRule = "lastWriter" // Keeps the value written last, such as settings
+	MergeServer     MergeRule = "server"     // Keeps the stored value, of fields owned by the server
+)
+
+// gameStateMergeRules are the rules of fields not merged by the last writer,
+// keyed by JSON Pointer. Games played and the best score are owned by the
+// server, offline runs are counted by submitting their scores.
+var gameStateMergeRules = map[string]MergeRule{}
+
+// GameStateMergeRule returns the rule the field at a JSON Pointer is merged by
+func GameStateMergeRule(pointer string) MergeRule {
+	if GameStateServerField(pointer) {
+		return MergeServer
+	}
+
+	if rule, ok := gameStateMergeRules[pointer]; ok {
+		return rule
+	}
+
+	return MergeLastWriter
+}
+
+// SyncOperation is a field changed by a client while offline, with the value
+// the client ended up with and when it was written by the client
+type SyncOperation struct {
+	Path  string
+	Value interface{}
+	Time  time.Time
+}
+
+// Rebase merges the operations of a client, which started from the base state,
+// into the game state. Fields merged by the last writer keep the value of the
+// client, unless the field was changed since the base and written after the
+// client wrote it, as told by writtenAt.
+func (s *GameState) Rebase(base *GameState, ops []SyncOperation, writtenAt func(pointer string) time.Time) common.Error {
+	for i, op := range ops {
+		if op.Path == "" || op.Path == "/"+GameStateFieldVersion {
+			return common.NewError(ErrInvalidDocument, "Field can't be synced").
+				WithField("operation", i)
+		}
+
+		current, exists := common.GetPointer(s.Document, op.Path)
+		value := op.Value
+
+		rule := GameStateMergeRule(op.Path)
+		switch rule {
+		case MergeServer:
+			continue
+
+		case MergeMax, MergeSum:
+			offline, ok := syncNumber(op.Value)
+			if !ok {
+				return common.NewError(ErrInvalidDocument, "Field must be a number").
+					WithField("operation", i)
+			}
+
+			// Missing fields count as zero, sums add the change since the base
+			stored, _ := syncNumber(current)
+			if rule == MergeMax {
+				value = syncJSONNumber(math.Max(stored, offline))
+			} else {
+				from, _ := common.GetPointer(base.Document, op.Path)
+				origin, _ := syncNumber(from)
+				value = syncJSONNumber(stored + offline - origin)
+			}
+
+		case MergeLastWriter:
+			from, existed := common.GetPointer(base.Document, op.Path)
+			changed := exists != existed || !common.EqualJSON(current, from)
+			if changed && !op.Time.After(writtenAt(op.Path)) {
+				continue
+			}
+		}
+
+		doc, stderr := common.SetPointer(s.Document, op.Path, common.CopyJSON(value))
+		if stderr != nil {
+			return common.NewError(ErrInvalidDocument, "Invalid path").
+				WithField("operation", i).
+				SetInternal(stderr)
+		}
+
+		s.Document = doc.(map[string]interface{})
+	}
+
+	return nil
+}
+
+// syncNumber reads a decoded JSON number, zero if it isn't a number
+func syncNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	}
+
+	return 0, false
+}
+
+// syncJSONNumber encodes a number, integers without a fraction
+func syncJSONNumber(f float64) json.Number {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return json.Number(strconv.FormatInt(int64(f), 10))
+	}
+
+	return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+}
@@ -0,0 +1,40 @@
+package port
+
+import (
+	"context"
+
+	"github.com/valsgaard/interview-case/backend/common"
+)
+
+// SocialGraphProvider is the interface describing the methods required of an adapter
+// reading the social graph of an external platform
+type SocialGraphProvider interface {
+	// Name of the platform, used as the provider of linked accounts
+	Name() string
+
+	// VerifyToken returns the external ID of the account an access token of
+	// the platform was issued to, proving the account is owned by the holder
+	// of the token. ErrInvalidKey if the token isn't valid.
+	VerifyToken(token string) (string, common.Error)
+
+	// GetFriendIDs returns the external IDs of the friends of an external
+	// account, ErrInvalidKey if the account is unknown
+	GetFriendIDs(externalID string) ([]string, common.Error)
+}
+
+// SetSocialGraphProviders sets the social graph adapters in the context, known by their name
+func SetSocialGraphProviders(ctx context.Context, providers ...SocialGraphProvider) context.Context {
+	byName := make(map[string]SocialGraphProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+
+	return context.WithValue(ctx, contextKeySocialGraph, byName)
+}
+
+// GetSocialGraphProvider retrieves the social graph adapter of the named platform from
+// the given context, nil if it isn't configured
+func GetSocialGraphProvider(ctx context.Context, name string) SocialGraphProvider {
+	byName, _ := ctx.Value(contextKeySocialGraph).(map[string]SocialGraphProvider)
+	return byName[name]
+}
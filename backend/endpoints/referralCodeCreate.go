@@ -0,0 +1,142 @@
+package endpoints
+
+import (
+	"crypto/rand"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Limits of referral codes
+const (
+	referralDefaultRedemptions = 10
+	referralMaxRedemptions     = 100
+	referralDefaultDays        = 30
+	referralMaxDays            = 365
+)
+
+// Referral codes are drawn from an alphabet without look-alike characters,
+// and drawn again if taken
+const (
+	referralCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	referralCodeLength   = 8
+	referralCodeAttempts = 3
+)
+
+type ReferralCodeCreateInput struct {
+	UserID         string
+	MaxRedemptions int `json:"maxRedemptions"`
+	ExpiresInDays  int `json:"expiresInDays"`
+}
+
+// ReferralCode is the output of ReferralCodeCreate and a part of ReferralsGetOutput,
+// describing the shareable code of a user
+type ReferralCode struct {
+	Code           string    `json:"code"`
+	MaxRedemptions int       `json:"maxRedemptions"`
+	Redemptions    int       `json:"redemptions"`
+	CreatedAt      time.Time `json:"createdAt"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+// newReferralCodeOutput converts a referral code
+func newReferralCodeOutput(code *port.ReferralCode) *ReferralCode {
+	return &ReferralCode{
+		Code:           code.Code,
+		MaxRedemptions: code.MaxRedemptions,
+		Redemptions:    code.Redemptions,
+		CreatedAt:      code.CreatedAt,
+		ExpiresAt:      code.ExpiresAt,
+	}
+}
+
+// NewReferralCodeCreate is a HandlerFunc processing the request to create the referral
+// code of a user, replacing the previous code. Users referred by the previous code are
+// still reported.
+func NewReferralCodeCreate(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(ReferralCodeCreateInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.UserID = mux.Vars(r)["id"]
+	if input.MaxRedemptions == 0 {
+		input.MaxRedemptions = referralDefaultRedemptions
+	}
+
+	if input.ExpiresInDays == 0 {
+		input.ExpiresInDays = referralDefaultDays
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if input.MaxRedemptions < 0 || input.MaxRedemptions > referralMaxRedemptions {
+		return common.NewError(ErrBadRequest, "Invalid maxRedemptions").
+			WithField("max", referralMaxRedemptions)
+	}
+
+	if input.ExpiresInDays < 0 || input.ExpiresInDays > referralMaxDays {
+		return common.NewError(ErrBadRequest, "Invalid expiresInDays").
+			WithField("max", referralMaxDays)
+	}
+
+	// Process data storage
+	var code *port.ReferralCode
+	var err common.Error
+	for attempt := 0; attempt < referralCodeAttempts; attempt++ {
+		var s string
+		if s, err = newReferralCode(); err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		code, err = port.GetDatastore(ctx).NewReferralCode(&port.ReferralCode{
+			Code:           s,
+			UserID:         input.UserID,
+			MaxRedemptions: input.MaxRedemptions,
+			ExpiresAt:      time.Now().AddDate(0, 0, input.ExpiresInDays),
+		})
+
+		if err == nil || err.Code() != port.ErrEntryExists.Code() {
+			break
+		}
+	}
+
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newReferralCodeOutput(code))
+}
+
+// newReferralCode draws a random referral code
+func newReferralCode() (string, common.Error) {
+	b := make([]byte, referralCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", common.NewError(err, "")
+	}
+
+	// The alphabet divides 256, so every character is equally likely
+	for i := range b {
+		b[i] = referralCodeAlphabet[int(b[i])%len(referralCodeAlphabet)]
+	}
+
+	return string(b), nil
+}
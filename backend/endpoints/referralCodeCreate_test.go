@@ -0,0 +1,110 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestReferralCodeCreate() {
+	tests := []struct {
+		Name                   string
+		UserID                 string
+		Body                   string
+		ExpectedStatusCode     int
+		ExpectedMaxRedemptions int
+		ExpectedDays           int
+	}{
+		{
+			Name:                   "Defaults",
+			UserID:                 suite.Users[0],
+			Body:                   `{}`,
+			ExpectedStatusCode:     http.StatusOK,
+			ExpectedMaxRedemptions: 10,
+			ExpectedDays:           30,
+		}, {
+			Name:                   "Replace",
+			UserID:                 suite.Users[0],
+			Body:                   `{"maxRedemptions": 3, "expiresInDays": 7}`,
+			ExpectedStatusCode:     http.StatusOK,
+			ExpectedMaxRedemptions: 3,
+			ExpectedDays:           7,
+		}, {
+			Name:               "InvalidMaxRedemptions",
+			UserID:             suite.Users[0],
+			Body:               `{"maxRedemptions": 1000}`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidExpiry",
+			UserID:             suite.Users[0],
+			Body:               `{"expiresInDays": -1}`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidJSON",
+			UserID:             suite.Users[0],
+			Body:               `{"maxRedemptions": `,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Body:               `{}`,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			Body:               `{}`,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/referral"
+			req, err := http.NewRequest("POST", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewReferralCodeCreate)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the code replaced the code of the user
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(ReferralCode)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Len(t, output.Code, 8)
+				assert.Equal(t, test.ExpectedMaxRedemptions, output.MaxRedemptions)
+				assert.Equal(t, 0, output.Redemptions)
+
+				expires := time.Now().AddDate(0, 0, test.ExpectedDays)
+				assert.WithinDuration(t, expires, output.ExpiresAt, time.Minute)
+
+				code, err := port.GetDatastore(suite.ParentCtx).GetReferralCode(test.UserID)
+				require.Nil(t, err)
+				assert.Equal(t, output.Code, code.Code)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
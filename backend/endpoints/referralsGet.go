@@ -0,0 +1,80 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type ReferralsGetInput struct {
+	UserID string
+}
+
+type ReferralsGetOutput struct {
+	Code      *ReferralCode `json:"code,omitempty"` // Omitted if never created
+	Total     int           `json:"total"`
+	Referrals []*Referral   `json:"referrals"`
+}
+
+// Referral is a part of ReferralsGetOutput and describes a user referred by the user
+type Referral struct {
+	UserID     string    `json:"id"`
+	Code       string    `json:"code"`
+	ReferredAt time.Time `json:"referredAt"`
+}
+
+// NewReferralsGet is a HandlerFunc processing the request to report the referral code
+// of a user, and the users referred by it and previous codes, oldest first.
+func NewReferralsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := ReferralsGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	referrals, err := datastore.GetReferrals(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	code, err := datastore.GetReferralCode(input.UserID)
+	if err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	output := &ReferralsGetOutput{
+		Total:     len(referrals),
+		Referrals: make([]*Referral, 0, len(referrals)),
+	}
+
+	if code != nil {
+		output.Code = newReferralCodeOutput(code)
+	}
+
+	for _, referral := range referrals {
+		output.Referrals = append(output.Referrals, &Referral{
+			UserID:     referral.UserID,
+			Code:       referral.Code,
+			ReferredAt: referral.CreatedAt,
+		})
+	}
+
+	return common.SuccessResponseJSON(rw, output)
+}
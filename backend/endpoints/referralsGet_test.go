@@ -0,0 +1,105 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestReferralsGet() {
+	// Two users are referred by user 0
+	suite.newReferralCode(suite.Users[0], "REFER234", 5, time.Hour)
+	referred := []string{
+		"a2e6feba-043b-4ba4-a7a4-9d6705595049",
+		"a3e6feba-043b-4ba4-a7a4-9d6705595049",
+	}
+
+	for _, userID := range referred {
+		_, err := port.GetDatastore(suite.ParentCtx).RedeemReferralCode("REFER234", userID)
+		suite.Require().Nil(err)
+	}
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		ExpectedStatusCode int
+		ExpectedCode       string
+		ExpectedReferrals  []string
+	}{
+		{
+			Name:               "Get",
+			UserID:             suite.Users[0],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedCode:       "REFER234",
+			ExpectedReferrals:  referred,
+		}, {
+			Name:               "WithoutCode",
+			UserID:             suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedReferrals:  []string{},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/referrals"
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewReferralsGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(ReferralsGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				if test.ExpectedCode == "" {
+					assert.Nil(t, output.Code)
+				} else if assert.NotNil(t, output.Code) {
+					assert.Equal(t, test.ExpectedCode, output.Code.Code)
+					assert.Equal(t, len(test.ExpectedReferrals), output.Code.Redemptions)
+				}
+
+				userIDs := make([]string, 0)
+				for _, referral := range output.Referrals {
+					userIDs = append(userIDs, referral.UserID)
+				}
+
+				assert.Equal(t, test.ExpectedReferrals, userIDs)
+				assert.Equal(t, len(test.ExpectedReferrals), output.Total)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
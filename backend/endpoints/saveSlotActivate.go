@@ -0,0 +1,63 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type SaveSlotActivateInput struct {
+	UserID string
+	SlotID string
+}
+
+// NewSaveSlotActivate is a HandlerFunc processing the request to set the active save slot
+// of a user. The state of the slot becomes the game state of the user, as a new revision,
+// while the previous state is kept in the slot that was active.
+func NewSaveSlotActivate(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := SaveSlotActivateInput{
+		UserID: mux.Vars(r)["id"],
+		SlotID: mux.Vars(r)["slotId"],
+	}
+
+	// Validate input
+	if err := validateSaveSlotKey(input.UserID, input.SlotID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	state, err := port.GetDatastore(ctx).ActivateSaveSlot(input.UserID, input.SlotID, port.StateChange{
+		Author: input.UserID,
+		Source: port.StateSourceSlot,
+	})
+
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+		case port.ErrConflict.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	common.Log(ctx).WithFields(logrus.Fields{
+		"userId":   input.UserID,
+		"slotId":   input.SlotID,
+		"revision": state.Revision,
+	}).Info("Save slot activated")
+
+	// Response, tagged with the revision for conditional writes
+	rw.Header().Set("ETag", revisionETag(state.Revision))
+	if err := writeStateSignature(rw, r, input.UserID, state); err != nil {
+		return err
+	}
+
+	return common.SuccessResponseJSON(rw, state)
+}
@@ -0,0 +1,104 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestSaveSlotActivate() {
+	suite.newSaveSlot(suite.Users[0], "Hard mode", 50)
+
+	// Activations are applied in order, the state starts at revision 1. The
+	// progress is the slot's, the games played and best score the user's.
+	tests := []struct {
+		Name               string
+		SlotID             string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedETag       string
+		ExpectedLevel      int
+	}{
+		{
+			Name:               "Activate",
+			SlotID:             slotID,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedETag:       `"2"`,
+			ExpectedLevel:      1,
+		}, {
+			Name:               "AlreadyActive",
+			SlotID:             slotID,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedETag:       `"2"`,
+			ExpectedLevel:      1,
+		}, {
+			Name:               "Default",
+			SlotID:             suite.Users[0],
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedETag:       `"3"`,
+			ExpectedLevel:      0,
+		}, {
+			Name:               "UnknownSlot",
+			SlotID:             "a2e6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedLevel:      0,
+		}, {
+			Name:               "InvalidSlotID",
+			SlotID:             "flaf",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedLevel:      0,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + suite.Users[0] + "/slots/" + test.SlotID + "/activate"
+			req, err := http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": suite.Users[0], "slotId": test.SlotID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewSaveSlotActivate)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+			assert.Equal(t, test.ExpectedETag, rr.Header().Get("ETag"))
+
+			if test.ExpectedSuccess {
+				output := new(port.GameState)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.ExpectedLevel, stateLevel(output))
+				assert.Equal(t, 110, output.Score())
+			}
+
+			// The game state of the user is the state of the active slot
+			state, err := port.GetDatastore(suite.ParentCtx).GetGameState(suite.Users[0])
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedLevel, stateLevel(state))
+			assert.Equal(t, 110, state.Score())
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
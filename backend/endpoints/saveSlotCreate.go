@@ -0,0 +1,91 @@
+package endpoints
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Limits of save slots
+const (
+	maxSaveSlots          = 8
+	maxSaveSlotNameLength = 64
+	maxLastDeviceLength   = 128
+)
+
+type SaveSlotCreateInput struct {
+	UserID     string
+	Name       string `json:"name"`
+	LastDevice string `json:"lastDevice"`
+}
+
+// NewSaveSlotCreate is a HandlerFunc processing the request to create a save slot for a
+// user, starting from a new game state. The slot isn't activated.
+func NewSaveSlotCreate(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(SaveSlotCreateInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.UserID = mux.Vars(r)["id"]
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	name, err := validateSaveSlotName(input.Name)
+	if err != nil {
+		return err
+	}
+
+	if utf8.RuneCountInString(input.LastDevice) > maxLastDeviceLength {
+		return common.NewError(ErrBadRequest, "Invalid lastDevice")
+	}
+
+	// Process data storage. Note: V1 UUIDs, the same as users
+	slot, err := port.GetDatastore(ctx).NewSaveSlot(input.UserID, &port.SaveSlot{
+		SlotID:     uuid.NewV1().String(),
+		Name:       name,
+		State:      *port.NewGameState(0, 0),
+		LastDevice: input.LastDevice,
+	}, maxSaveSlots)
+
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+		case port.ErrEntryExists.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		case port.ErrLimitReached.Code():
+			return common.NewError(ErrBadRequest, "Too many save slots").
+				WithField("max", maxSaveSlots)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newSaveSlotOutput(slot, true))
+}
+
+// validateSaveSlotName trims a name of a save slot, and checks its length
+func validateSaveSlotName(name string) (string, common.Error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxSaveSlotNameLength {
+		return "", common.NewError(ErrBadRequest, "Invalid name")
+	}
+
+	return name, nil
+}
@@ -0,0 +1,127 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestSaveSlotCreate() {
+	suite.newSaveSlot(suite.Users[0], "Hard mode", 50)
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		Body               string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedName       string
+	}{
+		{
+			Name:               "Create",
+			UserID:             suite.Users[0],
+			Body:               `{"name": " Speedrun ", "lastDevice": "phone"}`,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedName:       "Speedrun",
+		}, {
+			Name:               "ExistingName",
+			UserID:             suite.Users[0],
+			Body:               `{"name": "Hard mode"}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusConflict,
+		}, {
+			Name:               "MissingName",
+			UserID:             suite.Users[0],
+			Body:               `{"name": "  "}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidJSON",
+			UserID:             suite.Users[0],
+			Body:               `{"name": `,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Body:               `{"name": "Speedrun"}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			Body:               `{"name": "Speedrun"}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/slots"
+			req, err := http.NewRequest("POST", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewSaveSlotCreate)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the slot starts from a new game state, without being activated
+			if test.ExpectedSuccess {
+				output := new(SaveSlot)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.ExpectedName, output.Name)
+				assert.False(t, output.Active)
+				require.NotNil(t, output.State)
+				assert.Equal(t, 0, output.State.GamesPlayed())
+
+				slot, err := port.GetDatastore(suite.ParentCtx).GetSaveSlot(test.UserID, output.SlotID)
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedName, slot.Name)
+				assert.Equal(t, "phone", slot.LastDevice)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// The number of slots is limited, user 1 has the default slot
+	for i := 1; i < 8; i++ {
+		_, err := port.GetDatastore(suite.ParentCtx).NewSaveSlot(suite.Users[1], &port.SaveSlot{
+			SlotID: fmt.Sprintf("a%de6feba-043b-4ba4-a7a4-9d6705595049", i),
+			Name:   fmt.Sprintf("Slot %d", i),
+			State:  *port.NewGameState(0, 0),
+		}, 8)
+		suite.Require().Nil(err)
+	}
+
+	req, err := http.NewRequest("POST", "/user/"+suite.Users[1]+"/slots", bytes.NewBufferString(`{"name": "Speedrun"}`))
+	suite.Require().Nil(err)
+	req = mux.SetURLVars(req, map[string]string{"id": suite.Users[1]})
+
+	rr := httptest.NewRecorder()
+	common.NewHandlerFunc(suite.ParentCtx, NewSaveSlotCreate).ServeHTTP(rr, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, rr.Code)
+}
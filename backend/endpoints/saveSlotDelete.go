@@ -0,0 +1,45 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type SaveSlotDeleteInput struct {
+	UserID string
+	SlotID string
+}
+
+// NewSaveSlotDelete is a HandlerFunc processing the request to delete a save slot, and
+// its game state. The active slot can't be deleted, another slot must be activated first.
+func NewSaveSlotDelete(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := SaveSlotDeleteInput{
+		UserID: mux.Vars(r)["id"],
+		SlotID: mux.Vars(r)["slotId"],
+	}
+
+	// Validate input
+	if err := validateSaveSlotKey(input.UserID, input.SlotID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	if err := port.GetDatastore(ctx).DeleteSaveSlot(input.UserID, input.SlotID); err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+		case port.ErrEntryInUse.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
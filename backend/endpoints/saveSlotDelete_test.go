@@ -0,0 +1,77 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestSaveSlotDelete() {
+	suite.newSaveSlot(suite.Users[0], "Hard mode", 50)
+
+	// Deletes are applied in order
+	tests := []struct {
+		Name               string
+		SlotID             string
+		ExpectedStatusCode int
+		ExpectedSlots      int
+	}{
+		{
+			Name:               "Active",
+			SlotID:             suite.Users[0],
+			ExpectedStatusCode: http.StatusConflict,
+			ExpectedSlots:      2,
+		}, {
+			Name:               "Delete",
+			SlotID:             slotID,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedSlots:      1,
+		}, {
+			Name:               "Deleted",
+			SlotID:             slotID,
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedSlots:      1,
+		}, {
+			Name:               "InvalidSlotID",
+			SlotID:             "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedSlots:      1,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + suite.Users[0] + "/slots/" + test.SlotID
+			req, err := http.NewRequest("DELETE", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": suite.Users[0], "slotId": test.SlotID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewSaveSlotDelete)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and the slots which are left
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			slots, err := port.GetDatastore(suite.ParentCtx).GetSaveSlots(suite.Users[0])
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedSlots, len(slots))
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
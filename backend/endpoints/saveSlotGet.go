@@ -0,0 +1,59 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type SaveSlotGetInput struct {
+	UserID string
+	SlotID string
+}
+
+// NewSaveSlotGet is a HandlerFunc processing the request to retrieve a save slot of a
+// user, with its game state as a document of the current version.
+func NewSaveSlotGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := SaveSlotGetInput{
+		UserID: mux.Vars(r)["id"],
+		SlotID: mux.Vars(r)["slotId"],
+	}
+
+	// Validate input
+	if err := validateSaveSlotKey(input.UserID, input.SlotID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	slot, err := port.GetDatastore(ctx).GetSaveSlot(input.UserID, input.SlotID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newSaveSlotOutput(slot, true))
+}
+
+// validateSaveSlotKey validates the IDs of a user and one of its slots
+func validateSaveSlotKey(userID, slotID string) common.Error {
+	if _, stderr := uuid.FromString(userID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if _, stderr := uuid.FromString(slotID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid SlotID").
+			SetInternal(stderr)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestSaveSlotGet() {
+	suite.newSaveSlot(suite.Users[0], "Hard mode", 50)
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		SlotID             string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedActive     bool
+		ExpectedScore      int
+	}{
+		{
+			Name:               "Active",
+			UserID:             suite.Users[0],
+			SlotID:             suite.Users[0],
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedActive:     true,
+			ExpectedScore:      110,
+		}, {
+			Name:               "Inactive",
+			UserID:             suite.Users[0],
+			SlotID:             slotID,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedActive:     false,
+			ExpectedScore:      50,
+		}, {
+			Name:               "UnknownSlot",
+			UserID:             suite.Users[1],
+			SlotID:             slotID,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidSlotID",
+			UserID:             suite.Users[0],
+			SlotID:             "flaf",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/slots/" + test.SlotID
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "slotId": test.SlotID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewSaveSlotGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect, with an upgraded state
+			if test.ExpectedSuccess {
+				output := new(SaveSlot)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.SlotID, output.SlotID)
+				assert.Equal(t, test.ExpectedActive, output.Active)
+				require.NotNil(t, output.State)
+				assert.Equal(t, port.GameStateVersion, output.State.Version)
+				assert.Equal(t, test.ExpectedScore, output.State.Score())
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
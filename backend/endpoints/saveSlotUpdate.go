@@ -0,0 +1,78 @@
+package endpoints
+
+import (
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type SaveSlotUpdateInput struct {
+	UserID     string
+	SlotID     string
+	Name       *string `json:"name"`
+	LastDevice *string `json:"lastDevice"`
+	PlayTime   *int    `json:"playTime"`
+}
+
+// NewSaveSlotUpdate is a HandlerFunc processing the request to rename a save slot, or
+// to change the metadata reported by the client. Fields which are left out are kept.
+func NewSaveSlotUpdate(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(SaveSlotUpdateInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.UserID = mux.Vars(r)["id"]
+	input.SlotID = mux.Vars(r)["slotId"]
+
+	// Validate input
+	if err := validateSaveSlotKey(input.UserID, input.SlotID); err != nil {
+		return err
+	}
+
+	update := &port.SaveSlotUpdate{
+		LastDevice: input.LastDevice,
+		PlayTime:   input.PlayTime,
+	}
+
+	if input.Name != nil {
+		name, err := validateSaveSlotName(*input.Name)
+		if err != nil {
+			return err
+		}
+
+		update.Name = &name
+	}
+
+	if input.LastDevice != nil && utf8.RuneCountInString(*input.LastDevice) > maxLastDeviceLength {
+		return common.NewError(ErrBadRequest, "Invalid lastDevice")
+	}
+
+	if input.PlayTime != nil && *input.PlayTime < 0 {
+		return common.NewError(ErrBadRequest, "Invalid playTime")
+	}
+
+	// Process data storage
+	slot, err := port.GetDatastore(ctx).UpdateSaveSlot(input.UserID, input.SlotID, update)
+	if err != nil {
+		switch err.Code() {
+		case port.ErrInvalidKey.Code():
+			return err.SetStatusCode(http.StatusNotFound)
+		case port.ErrEntryExists.Code():
+			return err.SetStatusCode(http.StatusConflict)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newSaveSlotOutput(slot, false))
+}
@@ -0,0 +1,107 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+)
+
+func (suite *EndpointsTestSuite) TestSaveSlotUpdate() {
+	suite.newSaveSlot(suite.Users[0], "Hard mode", 50)
+
+	// Updates are applied in order
+	tests := []struct {
+		Name               string
+		SlotID             string
+		Body               string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedName       string
+		ExpectedDevice     string
+		ExpectedPlayTime   int
+	}{
+		{
+			Name:               "Rename",
+			SlotID:             slotID,
+			Body:               `{"name": "Nightmare"}`,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedName:       "Nightmare",
+		}, {
+			Name:               "Metadata",
+			SlotID:             slotID,
+			Body:               `{"lastDevice": "tablet", "playTime": 3600}`,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedName:       "Nightmare",
+			ExpectedDevice:     "tablet",
+			ExpectedPlayTime:   3600,
+		}, {
+			Name:               "ExistingName",
+			SlotID:             slotID,
+			Body:               `{"name": "Default"}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusConflict,
+		}, {
+			Name:               "EmptyName",
+			SlotID:             slotID,
+			Body:               `{"name": ""}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "NegativePlayTime",
+			SlotID:             slotID,
+			Body:               `{"playTime": -1}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownSlot",
+			SlotID:             "a2e6feba-043b-4ba4-a7a4-9d6705595049",
+			Body:               `{"name": "Flaf"}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + suite.Users[0] + "/slots/" + test.SlotID
+			req, err := http.NewRequest("PATCH", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": suite.Users[0], "slotId": test.SlotID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewSaveSlotUpdate)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the fields left out are kept
+			if test.ExpectedSuccess {
+				output := new(SaveSlot)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.ExpectedName, output.Name)
+				assert.Equal(t, test.ExpectedDevice, output.LastDevice)
+				assert.Equal(t, test.ExpectedPlayTime, output.PlayTime)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
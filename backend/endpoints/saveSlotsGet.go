@@ -0,0 +1,89 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type SaveSlotsGetInput struct {
+	UserID string
+}
+
+type SaveSlotsGetOutput struct {
+	Slots []*SaveSlot `json:"slots"`
+}
+
+// SaveSlot is a part of SaveSlotsGetOutput and describes a named save of a
+// user. The state is only included when a single slot is requested.
+type SaveSlot struct {
+	SlotID     string          `json:"id"`
+	Name       string          `json:"name"`
+	Active     bool            `json:"active"`
+	LastDevice string          `json:"lastDevice"`
+	PlayTime   int             `json:"playTime"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	UpdatedAt  time.Time       `json:"updatedAt"`
+	State      *port.GameState `json:"state,omitempty"`
+}
+
+// newSaveSlotOutput converts a save slot, with or without its state
+func newSaveSlotOutput(slot *port.SaveSlot, withState bool) *SaveSlot {
+	output := &SaveSlot{
+		SlotID:     slot.SlotID,
+		Name:       slot.Name,
+		Active:     slot.Active,
+		LastDevice: slot.LastDevice,
+		PlayTime:   slot.PlayTime,
+		CreatedAt:  slot.CreatedAt,
+		UpdatedAt:  slot.UpdatedAt,
+	}
+
+	if withState {
+		output.State = &slot.State
+	}
+
+	return output
+}
+
+// NewSaveSlotsGet is a HandlerFunc processing the request to list the save slots of a
+// user, in the order they were created. The game state of the user is the state of the
+// active slot.
+func NewSaveSlotsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := SaveSlotsGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	slots, err := port.GetDatastore(ctx).GetSaveSlots(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	output := &SaveSlotsGetOutput{
+		Slots: make([]*SaveSlot, 0, len(slots)),
+	}
+
+	for _, slot := range slots {
+		output.Slots = append(output.Slots, newSaveSlotOutput(slot, false))
+	}
+
+	return common.SuccessResponseJSON(rw, output)
+}
@@ -0,0 +1,96 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestSaveSlotsGet() {
+	suite.newSaveSlot(suite.Users[0], "Hard mode", 50)
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedNames      []string
+		ExpectedActive     []bool
+	}{
+		{
+			Name:               "Get",
+			UserID:             suite.Users[0],
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedNames:      []string{port.DefaultSaveSlotName, "Hard mode"},
+			ExpectedActive:     []bool{true, false},
+		}, {
+			Name:               "DefaultOnly",
+			UserID:             suite.Users[1],
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedNames:      []string{port.DefaultSaveSlotName},
+			ExpectedActive:     []bool{true},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/slots"
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewSaveSlotsGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect, listed without states
+			if test.ExpectedSuccess {
+				output := new(SaveSlotsGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				names := make([]string, 0)
+				active := make([]bool, 0)
+				for _, slot := range output.Slots {
+					names = append(names, slot.Name)
+					active = append(active, slot.Active)
+					assert.Nil(t, slot.State)
+				}
+
+				assert.Equal(t, test.ExpectedNames, names)
+				assert.Equal(t, test.ExpectedActive, active)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
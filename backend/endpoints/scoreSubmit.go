@@ -0,0 +1,72 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type ScoreSubmitInput struct {
+	UserID string
+	Score  *int `json:"score"`
+}
+
+type ScoreSubmitOutput struct {
+	GamesPlayed  int  `json:"gamesPlayed"`
+	Score        int  `json:"score"`
+	PersonalBest bool `json:"personalBest"`
+}
+
+// NewScoreSubmit is a HandlerFunc processing the request to submit the score
+// of a single run. The game state is updated by the backend, rather than
+// being overwritten by the client.
+func NewScoreSubmit(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(ScoreSubmitInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	input.UserID = mux.Vars(r)["id"]
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if input.Score == nil {
+		return common.NewError(ErrBadRequest, "Missing score")
+	}
+
+	if *input.Score < 0 {
+		return common.NewError(ErrBadRequest, "Invalid score")
+	}
+
+	// Process data storage
+	result, err := port.GetDatastore(ctx).SubmitScore(input.UserID, *input.Score, port.StateChange{
+		Author: input.UserID,
+		Source: port.StateSourceScore,
+	})
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, &ScoreSubmitOutput{
+		GamesPlayed:  result.GameState.GamesPlayed(),
+		Score:        result.GameState.Score(),
+		PersonalBest: result.PersonalBest,
+	})
+}
@@ -0,0 +1,126 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestScoreSubmit() {
+	// Runs are submitted in order, starting from a game state of 10 / 110
+	tests := []struct {
+		Name                 string
+		UserID               string
+		Body                 string
+		ExpectedSuccess      bool
+		ExpectedStatusCode   int
+		ExpectedGamesPlayed  int
+		ExpectedScore        int
+		ExpectedPersonalBest bool
+	}{
+		{
+			Name:                 "LowerScore",
+			UserID:               suite.Users[0],
+			Body:                 `{"score": 50}`,
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedGamesPlayed:  11,
+			ExpectedScore:        110,
+			ExpectedPersonalBest: false,
+		}, {
+			Name:                 "PersonalBest",
+			UserID:               suite.Users[0],
+			Body:                 `{"score": 200}`,
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedGamesPlayed:  12,
+			ExpectedScore:        200,
+			ExpectedPersonalBest: true,
+		}, {
+			Name:                 "SameScore",
+			UserID:               suite.Users[0],
+			Body:                 `{"score": 200}`,
+			ExpectedSuccess:      true,
+			ExpectedStatusCode:   http.StatusOK,
+			ExpectedGamesPlayed:  13,
+			ExpectedScore:        200,
+			ExpectedPersonalBest: false,
+		}, {
+			Name:               "MissingScore",
+			UserID:             suite.Users[0],
+			Body:               `{}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "NegativeScore",
+			UserID:             suite.Users[0],
+			Body:               `{"score": -1}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidJSON",
+			UserID:             suite.Users[0],
+			Body:               `{"score": "high"}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "invalid",
+			Body:               `{"score": 10}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownUserID",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Body:               `{"score": 10}`,
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/scores"
+			req, err := http.NewRequest("POST", path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewScoreSubmit)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			if assert.Equal(t, test.ExpectedStatusCode, rr.Code) && test.ExpectedSuccess {
+				output := new(ScoreSubmitOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+				assert.Equal(t, test.ExpectedGamesPlayed, output.GamesPlayed)
+				assert.Equal(t, test.ExpectedScore, output.Score)
+				assert.Equal(t, test.ExpectedPersonalBest, output.PersonalBest)
+
+				// Check if state was properly updated
+				state, err := port.GetDatastore(suite.ParentCtx).GetGameState(test.UserID)
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedGamesPlayed, state.GamesPlayed())
+				assert.Equal(t, test.ExpectedScore, state.Score())
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,54 @@
+package endpoints
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type ShardsGetOutput struct {
+	Shards  []string `json:"shards"`
+	Target  []string `json:"target,omitempty"` // Omitted unless resharding
+	Running bool     `json:"running"`
+}
+
+// NewShardsGet is a HandlerFunc processing the admin request to get the shards users are
+// spread across, and the progress of resharding onto new shards.
+func NewShardsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	resharder, ok := port.GetDatastore(ctx).(port.Reshardable)
+	if !ok {
+		return common.NewError(ErrBadRequest, "Datastore isn't sharded")
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, newShardsGetOutput(resharder))
+}
+
+// newShardsGetOutput describes the current and target shards by name
+func newShardsGetOutput(resharder port.Reshardable) *ShardsGetOutput {
+	target, running := resharder.ReshardStatus()
+	output := &ShardsGetOutput{
+		Shards:  shardNames(resharder.Shards()),
+		Running: running,
+	}
+
+	if target != nil {
+		output.Target = shardNames(target)
+	}
+
+	return output
+}
+
+func shardNames(shards map[string]port.Datastore) []string {
+	names := make([]string, 0, len(shards))
+	for name := range shards {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,126 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// ShardsReshardInput names the shards to spread users across. Shards already known,
+// current or targeted by an unfinished resharding, are given without a URI.
+type ShardsReshardInput struct {
+	Shards map[string]string `json:"shards"`
+}
+
+// NewShardsReshard is a HandlerFunc processing the admin request to move users onto a new
+// set of shards. Resharding runs in the background while requests are served, and its
+// progress is read through NewShardsGet. A failed resharding is resumed by repeating the
+// request with the same shards.
+func NewShardsReshard(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := new(ShardsReshardInput)
+	if err := common.ReadJSONRequest(r, input); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid JSON format").
+			SetInternal(err)
+	}
+
+	// Validate input
+	if len(input.Shards) == 0 {
+		return common.NewError(ErrBadRequest, "Missing shards")
+	}
+
+	resharder, ok := port.GetDatastore(ctx).(port.Reshardable)
+	if !ok {
+		return common.NewError(ErrBadRequest, "Datastore isn't sharded")
+	}
+
+	target, running := resharder.ReshardStatus()
+	if running {
+		return common.NewError(port.ErrConflict, "Resharding is already running").
+			SetStatusCode(http.StatusConflict)
+	}
+
+	if target != nil {
+		resumed := len(input.Shards) == len(target)
+		for name := range input.Shards {
+			if _, ok := target[name]; !ok {
+				resumed = false
+			}
+		}
+
+		if !resumed {
+			return common.NewError(port.ErrConflict, "An unfinished resharding onto other shards must be resumed first").
+				SetStatusCode(http.StatusConflict)
+		}
+	}
+
+	// Known shards keep their datastore, new shards are opened
+	current := resharder.Shards()
+	known := make(map[string]port.Datastore, len(current)+len(target))
+	for name, shard := range current {
+		known[name] = shard
+	}
+
+	for name, shard := range target {
+		known[name] = shard
+	}
+
+	connect := port.GetShardConnector(ctx)
+	shards := make(map[string]port.Datastore, len(input.Shards))
+	for name, uri := range input.Shards {
+		if shard, ok := known[name]; ok {
+			if uri != "" {
+				return common.NewError(ErrBadRequest, "Shard "+name+" is already known, and is given without a URI")
+			}
+
+			shards[name] = shard
+			continue
+		}
+
+		if uri == "" {
+			return common.NewError(ErrBadRequest, "Missing URI of shard "+name)
+		}
+
+		if connect == nil {
+			return common.NewError(ErrBadRequest, "New shards can't be opened")
+		}
+
+		shard, err := connect(uri)
+		if err != nil {
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+
+		shards[name] = shard
+	}
+
+	// Process resharding
+	log := common.Log(ctx).WithFields(logrus.Fields{
+		"shards": shardNames(shards),
+	})
+
+	log.Info("Resharding started")
+
+	go func() {
+		moved, err := resharder.Reshard(shards)
+		if err != nil {
+			err.Log(log)
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"moved": moved,
+		}).Info("Resharding complete")
+	}()
+
+	// Response
+	return common.SuccessResponseJSON(rw, &ShardsGetOutput{
+		Shards:  shardNames(current),
+		Target:  shardNames(shards),
+		Running: true,
+	})
+}
@@ -0,0 +1,144 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/datastore"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestShardsReshard() {
+	// A sharded datastore of simulators, new shards are opened by their URI
+	shards := map[string]port.Datastore{
+		"shard0": datastore.NewDatastoreSimulator(),
+		"shard1": datastore.NewDatastoreSimulator(),
+	}
+
+	sharded := datastore.NewShardedDatastore(shards, common.NewLog("test", os.Stdout))
+	for _, userID := range suite.Users {
+		_, err := sharded.NewUser(userID, "bot")
+		require.Nil(suite.T(), err)
+	}
+
+	opened := make(map[string]port.Datastore)
+	ctx := port.SetDatastore(suite.ParentCtx, sharded)
+	ctx = port.SetShardConnector(ctx, func(uri string) (port.Datastore, common.Error) {
+		opened[uri] = datastore.NewDatastoreSimulator()
+		return opened[uri], nil
+	})
+
+	tests := []struct {
+		Name               string
+		Ctx                context.Context
+		Shards             map[string]string
+		ExpectedStatusCode int
+		ExpectedShards     []string
+	}{
+		{
+			Name:               "NotSharded",
+			Ctx:                suite.ParentCtx,
+			Shards:             map[string]string{"shard0": ""},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "MissingShards",
+			Ctx:                ctx,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "MissingURI",
+			Ctx:                ctx,
+			Shards:             map[string]string{"shard0": "", "shard2": ""},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "KnownShardURI",
+			Ctx:                ctx,
+			Shards:             map[string]string{"shard0": "sim://shard0"},
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "Reshard",
+			Ctx:                ctx,
+			Shards:             map[string]string{"shard0": "", "shard1": "", "shard2": "sim://shard2"},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedShards:     []string{"shard0", "shard1", "shard2"},
+		}, {
+			Name:               "Shrink",
+			Ctx:                ctx,
+			Shards:             map[string]string{"shard2": ""},
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedShards:     []string{"shard2"},
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			body, err := json.Marshal(&ShardsReshardInput{Shards: test.Shards})
+			require.Nil(t, err)
+
+			req, err := http.NewRequest("POST", "/admin/shards", bytes.NewReader(body))
+			require.Nil(t, err)
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(test.Ctx, AdminOnly(NewShardsReshard))
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			if test.ExpectedStatusCode != http.StatusOK {
+				return
+			}
+
+			// Resharding runs in the background, until the shards are replaced
+			require.Eventually(t, func() bool {
+				target, running := sharded.ReshardStatus()
+				return target == nil && !running
+			}, 5*time.Second, 10*time.Millisecond)
+
+			output := suite.getShards(t, ctx)
+			assert.Equal(t, test.ExpectedShards, output.Shards)
+			assert.False(t, output.Running)
+
+			// Every user is kept
+			for _, userID := range suite.Users {
+				exists, err := sharded.UserExists(userID)
+				require.Nil(t, err)
+				assert.True(t, exists, userID)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	assert.Len(suite.T(), opened, 1)
+}
+
+// getShards gets the shards of the sharded datastore in the context
+func (suite *EndpointsTestSuite) getShards(t *testing.T, ctx context.Context) *ShardsGetOutput {
+	req, err := http.NewRequest("GET", "/admin/shards", nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+
+	rr := httptest.NewRecorder()
+	handler := common.NewHandlerFunc(ctx, AdminOnly(NewShardsGet))
+	handler.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	output := new(ShardsGetOutput)
+	require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+	return output
+}
@@ -0,0 +1,331 @@
+package endpoints
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// StateSignatureHeader is the header carrying the signature of a game state,
+// returned alongside the state and required on updates derived from it
+const StateSignatureHeader = "X-State-Signature"
+
+// StateDigestHeader is the header carrying the digest of the game state an
+// update is derived from, the unpadded base64url SHA-256 of its canonical
+// document: keys sorted, numbers in their shortest form, no whitespace
+const StateDigestHeader = "X-State-Digest"
+
+// FlagReasonInvalidSignature is the reason users are flagged for, when
+// submitting an update derived from a state with an invalid signature
+const FlagReasonInvalidSignature = "Invalid game state signature"
+
+// SigningKey is a secret game state is signed with. Keys are known by their
+// ID, so signatures made with retired keys can still be verified.
+type SigningKey struct {
+	KeyID  string
+	Secret []byte
+}
+
+// ParseSigningKeys parses a comma separated list of keys as "id:secret".
+// The first key signs, the others are only used for verifying, so keys are
+// rotated by adding a new key in front and retiring the last.
+func ParseSigningKeys(s string) ([]SigningKey, error) {
+	keys := make([]SigningKey, 0)
+	seen := make(map[string]bool)
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.Contains(parts[0], ".") {
+			return nil, fmt.Errorf("invalid signing key %q, expected id:secret", parts[0])
+		}
+
+		if seen[parts[0]] {
+			return nil, fmt.Errorf("duplicate signing key %q", parts[0])
+		}
+
+		seen[parts[0]] = true
+		keys = append(keys, SigningKey{KeyID: parts[0], Secret: []byte(parts[1])})
+	}
+
+	return keys, nil
+}
+
+// SetSigningKeys sets the keys game state is signed with in the context,
+// the first key signs. Without keys, game state isn't signed.
+func SetSigningKeys(ctx context.Context, keys []SigningKey) context.Context {
+	return context.WithValue(ctx, contextKeySigningKeys, keys)
+}
+
+func signingKeys(ctx context.Context) []SigningKey {
+	keys, _ := ctx.Value(contextKeySigningKeys).([]SigningKey)
+	return keys
+}
+
+// writeStateSignature sets the signature header of a response containing the
+// game state of a user, if signing is enabled
+func writeStateSignature(rw http.ResponseWriter, r *http.Request, userID string, state *port.GameState) common.Error {
+	keys := signingKeys(r.Context())
+	if len(keys) == 0 {
+		return nil
+	}
+
+	digest, err := stateDigest(state)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	mac := stateMAC(keys[0], userID, state.Revision, digest)
+	rw.Header().Set(StateSignatureHeader, keys[0].KeyID+"."+strconv.Itoa(state.Revision)+"."+mac)
+	return nil
+}
+
+// verifyStateSignature verifies the signature of the state an update is
+// derived from, when signing is enabled, and returns the signed state the
+// update must be applied to. The signature is verified over the digest the
+// client submits, which must be the digest of the stored revision. It's a
+// freshness and base check, proving which issued state the update is based
+// on, not an integrity check of the submitted body: the handlers check the
+// body against the signed state with verifySignedFields. Users submitting
+// invalid signatures are flagged for review. Signatures made with keys no
+// longer known, or of revisions no longer retained, can't be verified, and
+// the state must be fetched again.
+func verifyStateSignature(r *http.Request, userID string) (*port.GameState, common.Error) {
+	ctx := r.Context()
+
+	keys := signingKeys(ctx)
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	header := strings.TrimSpace(r.Header.Get(StateSignatureHeader))
+	if header == "" {
+		return nil, common.NewError(ErrSignatureRequired, "Missing "+StateSignatureHeader+" header")
+	}
+
+	digest := strings.TrimSpace(r.Header.Get(StateDigestHeader))
+	if digest == "" {
+		return nil, common.NewError(ErrSignatureRequired, "Missing "+StateDigestHeader+" header")
+	}
+
+	// Signatures are "key.revision.mac"
+	parts := strings.Split(header, ".")
+	if len(parts) != 3 {
+		return nil, rejectSignature(ctx, userID, "Malformed signature")
+	}
+
+	revision, stderr := strconv.Atoi(parts[1])
+	if stderr != nil || revision < 0 {
+		return nil, rejectSignature(ctx, userID, "Malformed signature")
+	}
+
+	var key *SigningKey
+	for i := range keys {
+		if keys[i].KeyID == parts[0] {
+			key = &keys[i]
+		}
+	}
+
+	if key == nil {
+		return nil, common.NewError(ErrPreconditionFailed, "Signing key is no longer known, the state must be fetched").
+			WithField("key", parts[0])
+	}
+
+	expected := stateMAC(*key, userID, revision, digest)
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, rejectSignature(ctx, userID, "Signature doesn't match the game state")
+	}
+
+	state, err := signedState(ctx, userID, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := stateDigest(state)
+	if err != nil {
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	if stored != digest {
+		return nil, rejectSignature(ctx, userID, "Digest doesn't match the game state")
+	}
+
+	state.Revision = revision
+	return state, nil
+}
+
+// verifySignedFields rejects a signed update changing the games played or the
+// best score of the state it's derived from, flagging the user for review.
+func verifySignedFields(ctx context.Context, userID string, signed, submitted *port.GameState) common.Error {
+	if signedFieldsChanged(signed, submitted) {
+		return rejectSignedFields(ctx, userID)
+	}
+
+	return nil
+}
+
+// signedFieldsChanged tells if an update changes the games played or the best
+// score of the signed state it's derived from. Only scores change them, so a
+// difference is a client claiming values the backend never issued. Fields
+// missing from the update are kept, and unsigned updates change nothing.
+func signedFieldsChanged(signed, submitted *port.GameState) bool {
+	if signed == nil {
+		return false
+	}
+
+	fields := map[string]func(*port.GameState) int{
+		port.GameStateFieldGamesPlayed: (*port.GameState).GamesPlayed,
+		port.GameStateFieldScore:       (*port.GameState).Score,
+	}
+
+	for field, value := range fields {
+		if _, ok := submitted.Document[field]; ok && value(submitted) != value(signed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rejectSignedFields rejects a signed update changing the games played or the
+// best score, flagging the user for review
+func rejectSignedFields(ctx context.Context, userID string) common.Error {
+	return rejectSignature(ctx, userID, "Games played and the best score must match the signed game state")
+}
+
+// signedRevision returns the revision an update given If-Match is applied to.
+// Signed updates are only applied to the signed revision, so an update can't
+// replace a state other than the one it's derived from.
+func signedRevision(revision *int, signed *port.GameState) (*int, common.Error) {
+	if signed == nil {
+		return revision, nil
+	}
+
+	if revision != nil && *revision != signed.Revision {
+		return nil, common.NewError(ErrPreconditionFailed, "If-Match doesn't match the signed game state").
+			WithField("revision", signed.Revision)
+	}
+
+	return &signed.Revision, nil
+}
+
+// signedState loads the revision of the state a signature was made for
+func signedState(ctx context.Context, userID string, revision int) (*port.GameState, common.Error) {
+	state, err := port.GetDatastore(ctx).GetGameState(userID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return nil, err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	if state.Revision == revision {
+		return state, nil
+	}
+
+	stored, err := port.GetDatastore(ctx).GetGameStateRevision(userID, revision)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return nil, common.NewError(ErrPreconditionFailed, "Signed game state is no longer retained, the state must be fetched").
+				WithField("revision", revision)
+		}
+
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	return &stored.State, nil
+}
+
+// rejectSignature flags the user for review, and rejects the request
+func rejectSignature(ctx context.Context, userID, msg string) common.Error {
+	log := common.Log(ctx).WithFields(logrus.Fields{
+		"userId": userID,
+		"reason": msg,
+	})
+
+	if err := port.GetDatastore(ctx).FlagUser(userID, FlagReasonInvalidSignature); err != nil {
+		log.WithField("error", err.Error()).Warn("Unable to flag user")
+	} else {
+		log.Warn("User flagged for an invalid game state signature")
+	}
+
+	return common.NewError(ErrInvalidSignature, msg)
+}
+
+// stateDigest computes the SHA-256 of the canonical document of a state,
+// with its version field included
+func stateDigest(state *port.GameState) (string, common.Error) {
+	doc := make(map[string]interface{}, len(state.Document)+1)
+	for field, value := range state.Document {
+		doc[field] = value
+	}
+	doc[port.GameStateFieldVersion] = state.Version
+
+	// Maps are encoded with sorted keys, and numbers are normalized so the
+	// digest doesn't depend on how they were written
+	b, stderr := json.Marshal(canonicalJSON(doc))
+	if stderr != nil {
+		return "", common.NewError(stderr, "Unable to encode game state")
+	}
+
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// stateMAC computes the HMAC-SHA256 of the state of a user, over the user ID,
+// the revision and the digest of the state
+func stateMAC(key SigningKey, userID string, revision int, digest string) string {
+	mac := hmac.New(sha256.New, key.Secret)
+	mac.Write([]byte(userID + "\n" + strconv.Itoa(revision) + "\n" + digest))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// canonicalJSON returns a copy of a decoded JSON value, with every number
+// written the same way
+func canonicalJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			c[key] = canonicalJSON(value)
+		}
+		return c
+
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, value := range v {
+			c[i] = canonicalJSON(value)
+		}
+		return c
+
+	case json.Number:
+		if f, err := v.Float64(); err == nil {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+
+	case int:
+		return json.Number(strconv.FormatFloat(float64(v), 'g', -1, 64))
+	}
+
+	return v
+}
@@ -0,0 +1,361 @@
+package endpoints_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func TestParseSigningKeys(t *testing.T) {
+	tests := []struct {
+		Name            string
+		Keys            string
+		ExpectedSuccess bool
+		ExpectedKeyIDs  []string
+	}{
+		{
+			Name:            "Keys",
+			Keys:            "2026-10:new, 2026-04:old",
+			ExpectedSuccess: true,
+			ExpectedKeyIDs:  []string{"2026-10", "2026-04"},
+		}, {
+			Name:            "Empty",
+			Keys:            "",
+			ExpectedSuccess: true,
+			ExpectedKeyIDs:  []string{},
+		}, {
+			Name:            "MissingSecret",
+			Keys:            "2026-10:",
+			ExpectedSuccess: false,
+		}, {
+			Name:            "DotInID",
+			Keys:            "2026.10:new",
+			ExpectedSuccess: false,
+		}, {
+			Name:            "Duplicate",
+			Keys:            "a:new,a:old",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			keys, err := ParseSigningKeys(test.Keys)
+			if !test.ExpectedSuccess {
+				assert.NotNil(t, err)
+				return
+			}
+
+			require.Nil(t, err)
+
+			keyIDs := make([]string, 0)
+			for _, key := range keys {
+				keyIDs = append(keyIDs, key.KeyID)
+			}
+
+			assert.Equal(t, test.ExpectedKeyIDs, keyIDs)
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
+
+// getSignature returns the signature of the game state of user 0, and the
+// digest of the state computed the way clients do
+func (suite *EndpointsTestSuite) getSignature(ctx context.Context) (string, string) {
+	req, err := http.NewRequest("GET", "/user/"+suite.Users[0]+"/state", nil)
+	suite.Require().Nil(err)
+	req = mux.SetURLVars(req, map[string]string{"id": suite.Users[0]})
+
+	rr := httptest.NewRecorder()
+	common.NewHandlerFunc(ctx, NewGameStateGet).ServeHTTP(rr, req)
+	suite.Require().Equal(http.StatusOK, rr.Code)
+
+	return rr.Header().Get(StateSignatureHeader), digest(suite.T(), rr.Body.String())
+}
+
+// digest computes the digest of a game state document, re-encoding it with
+// sorted keys
+func digest(t *testing.T, doc string) string {
+	var v interface{}
+	require.Nil(t, json.Unmarshal([]byte(doc), &v))
+
+	b, err := json.Marshal(v)
+	require.Nil(t, err)
+
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (suite *EndpointsTestSuite) TestStateSignature() {
+	old := []SigningKey{{KeyID: "old", Secret: []byte("old secret")}}
+	rotated := []SigningKey{{KeyID: "new", Secret: []byte("new secret")}, old[0]}
+	retired := []SigningKey{rotated[0]}
+
+	// Every update is derived from the state fetched with the signing keys,
+	// then modified as given
+	tests := []struct {
+		Name               string
+		SignKeys           []SigningKey
+		Keys               []SigningKey
+		IfMatch            string
+		Stale              bool
+		Modify             func(signature, digest string) (string, string)
+		ExpectedStatusCode int
+		ExpectedFlagged    bool
+	}{
+		{
+			Name:               "Unsigned",
+			Keys:               nil,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:     "Missing",
+			SignKeys: rotated,
+			Keys:     rotated,
+			Modify: func(signature, digest string) (string, string) {
+				return "", digest
+			},
+			ExpectedStatusCode: http.StatusPreconditionRequired,
+		}, {
+			Name:     "MissingDigest",
+			SignKeys: rotated,
+			Keys:     rotated,
+			Modify: func(signature, digest string) (string, string) {
+				return signature, ""
+			},
+			ExpectedStatusCode: http.StatusPreconditionRequired,
+		}, {
+			Name:               "Signed",
+			SignKeys:           rotated,
+			Keys:               rotated,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "RotatedKey",
+			SignKeys:           old,
+			Keys:               rotated,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "RetiredKey",
+			SignKeys:           old,
+			Keys:               retired,
+			ExpectedStatusCode: http.StatusPreconditionFailed,
+		}, {
+			Name:               "Stale",
+			SignKeys:           rotated,
+			Keys:               rotated,
+			Stale:              true,
+			ExpectedStatusCode: http.StatusPreconditionFailed,
+		}, {
+			Name:               "OtherIfMatch",
+			SignKeys:           rotated,
+			Keys:               rotated,
+			IfMatch:            `"1"`,
+			ExpectedStatusCode: http.StatusPreconditionFailed,
+		}, {
+			Name:     "Tampered",
+			SignKeys: rotated,
+			Keys:     rotated,
+			Modify: func(signature, digest string) (string, string) {
+				return signature + "x", digest
+			},
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedFlagged:    true,
+		}, {
+			Name:     "OtherDigest",
+			SignKeys: rotated,
+			Keys:     rotated,
+			Modify: func(signature, _ string) (string, string) {
+				return signature, digest(suite.T(), `{"gamesPlayed": 99, "score": 990, "version": 2}`)
+			},
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedFlagged:    true,
+		}, {
+			Name:     "OtherRevision",
+			SignKeys: rotated,
+			Keys:     rotated,
+			Modify: func(signature, digest string) (string, string) {
+				parts := strings.Split(signature, ".")
+				return parts[0] + ".1." + parts[2], digest
+			},
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedFlagged:    true,
+		}, {
+			Name:     "Malformed",
+			SignKeys: rotated,
+			Keys:     rotated,
+			Modify: func(signature, digest string) (string, string) {
+				return "flaf", digest
+			},
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedFlagged:    true,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			require.Nil(t, port.GetDatastore(suite.ParentCtx).ClearUserFlag(suite.Users[0]))
+
+			signature, digest := "", ""
+			if test.SignKeys != nil {
+				signature, digest = suite.getSignature(SetSigningKeys(suite.ParentCtx, test.SignKeys))
+			}
+
+			if test.Modify != nil {
+				signature, digest = test.Modify(signature, digest)
+			}
+
+			if test.Stale {
+				state, err := port.GetDatastore(suite.ParentCtx).GetGameState(suite.Users[0])
+				require.Nil(t, err)
+				require.Nil(t, port.GetDatastore(suite.ParentCtx).UpdateGameState(
+					suite.Users[0],
+					state,
+					port.StateChange{Author: suite.Users[0], Source: port.StateSourceUpdate},
+				))
+			}
+
+			path := "/user/" + suite.Users[0] + "/state"
+			req, err := http.NewRequest("PUT", path, bytes.NewBufferString(`{"gamesPlayed": 10, "score": 110}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set(StateSignatureHeader, signature)
+			req.Header.Set(StateDigestHeader, digest)
+			req.Header.Set("If-Match", test.IfMatch)
+			req = mux.SetURLVars(req, map[string]string{"id": suite.Users[0]})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(SetSigningKeys(suite.ParentCtx, test.Keys), NewGameStateUpdate)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and whether the user was flagged
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			flags, err := port.GetDatastore(suite.ParentCtx).GetFlaggedUsers()
+			require.Nil(t, err)
+			if test.ExpectedFlagged {
+				require.Equal(t, 1, len(flags))
+				assert.Equal(t, suite.Users[0], flags[0].UserID)
+				assert.Equal(t, FlagReasonInvalidSignature, flags[0].Reason)
+			} else {
+				assert.Equal(t, 0, len(flags))
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+func (suite *EndpointsTestSuite) TestStateSignatureServerFields() {
+	keys := []SigningKey{{KeyID: "new", Secret: []byte("new secret")}}
+
+	// The signature only proves the base of an update, so signed updates
+	// can't claim games played or a best score other than the signed ones.
+	// Updates are applied in order, the state starts at revision 1.
+	tests := []struct {
+		Name               string
+		Method             string
+		Path               string
+		ContentType        string
+		Body               string
+		Handler            func(rw http.ResponseWriter, r *http.Request) common.Error
+		ExpectedStatusCode int
+		ExpectedFlagged    bool
+	}{
+		{
+			Name:               "SyncScore",
+			Method:             "POST",
+			Path:               "/sync",
+			Body:               `{"baseRevision": 1, "operations": [{"path": "/score", "value": 990}]}`,
+			Handler:            NewGameStateSync,
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedFlagged:    true,
+		}, {
+			Name:               "Update",
+			Method:             "PUT",
+			Body:               `{"version": 2, "gamesPlayed": 10, "score": 110, "progress": {}, "settings": {"sound": false}}`,
+			Handler:            NewGameStateUpdate,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "UpdateScore",
+			Method:             "PUT",
+			Body:               `{"gamesPlayed": 10, "score": 990}`,
+			Handler:            NewGameStateUpdate,
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedFlagged:    true,
+		}, {
+			Name:               "Patch",
+			Method:             "PATCH",
+			ContentType:        MediaTypeMergePatch,
+			Body:               `{"settings": {"sound": false}}`,
+			Handler:            NewGameStatePatch,
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "PatchGamesPlayed",
+			Method:             "PATCH",
+			ContentType:        MediaTypeMergePatch,
+			Body:               `{"gamesPlayed": 99}`,
+			Handler:            NewGameStatePatch,
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedFlagged:    true,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			require.Nil(t, port.GetDatastore(suite.ParentCtx).ClearUserFlag(suite.Users[0]))
+			signature, digest := suite.getSignature(SetSigningKeys(suite.ParentCtx, keys))
+
+			path := "/user/" + suite.Users[0] + "/state" + test.Path
+			req, err := http.NewRequest(test.Method, path, bytes.NewBufferString(test.Body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Content-Type", test.ContentType)
+			req.Header.Set(StateSignatureHeader, signature)
+			req.Header.Set(StateDigestHeader, digest)
+			req = mux.SetURLVars(req, map[string]string{"id": suite.Users[0]})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(SetSigningKeys(suite.ParentCtx, keys), test.Handler)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, that the stored fields are unchanged, and
+			// whether the user was flagged
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			state, err := port.GetDatastore(suite.ParentCtx).GetGameState(suite.Users[0])
+			require.Nil(t, err)
+			assert.Equal(t, 10, state.GamesPlayed())
+			assert.Equal(t, 110, state.Score())
+
+			flags, err := port.GetDatastore(suite.ParentCtx).GetFlaggedUsers()
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedFlagged, len(flags) == 1)
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
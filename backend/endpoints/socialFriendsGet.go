@@ -0,0 +1,147 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type SocialFriendsGetInput struct {
+	UserID   string
+	Provider string
+}
+
+type SocialFriendsGetOutput struct {
+	Friends []*SocialFriend `json:"friends"`
+}
+
+// SocialFriend is a part of SocialFriendsGetOutput and SocialFriendsImportOutput, and
+// describes a user who's a friend of the user on an external platform
+type SocialFriend struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Highscore  int    `json:"highscore"`
+	ExternalID string `json:"externalId"`
+}
+
+// NewSocialFriendsGet is a HandlerFunc processing the request to suggest the friends of
+// a user on an external platform, who linked their account as well. Friends and blocked
+// users aren't suggested.
+func NewSocialFriendsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := SocialFriendsGetInput{
+		UserID:   mux.Vars(r)["id"],
+		Provider: mux.Vars(r)["provider"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	provider, err := readSocialGraphProvider(r, input.Provider)
+	if err != nil {
+		return err
+	}
+
+	// Process data storage
+	friends, err := matchSocialFriends(ctx, input.UserID, provider)
+	if err != nil {
+		return err
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, &SocialFriendsGetOutput{
+		Friends: friends,
+	})
+}
+
+// matchSocialFriends matches the external friends of the linked account of the user
+// against the accounts linked by other users, ordered by UserID
+func matchSocialFriends(ctx context.Context, userID string, provider port.SocialGraphProvider) ([]*SocialFriend, common.Error) {
+	datastore := port.GetDatastore(ctx)
+	accounts, err := datastore.GetLinkedAccounts(userID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return nil, err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	var account *port.LinkedAccount
+	for _, linked := range accounts {
+		if linked.Provider == provider.Name() {
+			account = linked
+		}
+	}
+
+	if account == nil {
+		return nil, common.NewError(port.ErrInvalidKey, "No linked account").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	// Accounts removed from the platform have no friends
+	externalIDs, err := provider.GetFriendIDs(account.ExternalID)
+	if err != nil && err.Code() != port.ErrInvalidKey.Code() {
+		return nil, err.SetStatusCode(http.StatusBadGateway)
+	}
+
+	matches, err := datastore.FindLinkedAccounts(provider.Name(), externalIDs)
+	if err != nil {
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Leave out the user, existing friends and users blocked by the user
+	friendIDs, err := datastore.GetFriendIDs(userID)
+	if err != nil {
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	blocks, err := datastore.GetBlockedUsers(userID)
+	if err != nil {
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	excluded := map[string]bool{userID: true}
+	for _, friendID := range friendIDs {
+		excluded[friendID] = true
+	}
+
+	for _, block := range blocks {
+		excluded[block.BlockedID] = true
+	}
+
+	candidateIDs := make([]string, 0, len(matches))
+	externalByUser := make(map[string]string, len(matches))
+	for _, match := range matches {
+		if !excluded[match.UserID] {
+			candidateIDs = append(candidateIDs, match.UserID)
+			externalByUser[match.UserID] = match.ExternalID
+		}
+	}
+
+	// Users who blocked the user are left out as well
+	users, err := datastore.GetFriendsByID(candidateIDs, userID)
+	if err != nil {
+		return nil, err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	friends := make([]*SocialFriend, 0, len(users))
+	for _, user := range users {
+		friends = append(friends, &SocialFriend{
+			ID:         user.UserID,
+			Name:       user.Name,
+			Highscore:  user.HighScore,
+			ExternalID: externalByUser[user.UserID],
+		})
+	}
+
+	return friends, nil
+}
@@ -0,0 +1,118 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestSocialFriendsGet() {
+	// User 0 is a friend of user 1 and blocked by user 3 on the platform,
+	// user 2 didn't link an account
+	suite.linkAccount(suite.Users[0], "ext-0")
+	suite.linkAccount(suite.Users[1], "ext-1")
+	suite.linkAccount(suite.Users[3], "ext-3")
+
+	_, err := port.GetDatastore(suite.ParentCtx).BlockUser(suite.Users[3], suite.Users[0])
+	suite.Require().Nil(err)
+
+	tests := []struct {
+		Name                string
+		UserID              string
+		Provider            string
+		ExpectedStatusCode  int
+		ExpectedFriends     []string
+		ExpectedExternalIDs []string
+	}{
+		{
+			Name:                "Match",
+			UserID:              suite.Users[1],
+			Provider:            socialGraphProvider,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedFriends:     []string{suite.Users[0]},
+			ExpectedExternalIDs: []string{"ext-0"},
+		}, {
+			Name:                "FriendsAndBlockers",
+			UserID:              suite.Users[0],
+			Provider:            socialGraphProvider,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedFriends:     []string{},
+			ExpectedExternalIDs: []string{},
+		}, {
+			Name:                "Blocked",
+			UserID:              suite.Users[3],
+			Provider:            socialGraphProvider,
+			ExpectedStatusCode:  http.StatusOK,
+			ExpectedFriends:     []string{},
+			ExpectedExternalIDs: []string{},
+		}, {
+			Name:               "NotLinked",
+			UserID:             suite.Users[2],
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownProvider",
+			UserID:             suite.Users[1],
+			Provider:           "flaf",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/accounts/" + test.Provider + "/friends"
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "provider": test.Provider})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewSocialFriendsGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(SocialFriendsGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				userIDs, externalIDs := make([]string, 0), make([]string, 0)
+				for _, friend := range output.Friends {
+					userIDs = append(userIDs, friend.ID)
+					externalIDs = append(externalIDs, friend.ExternalID)
+				}
+
+				assert.Equal(t, test.ExpectedFriends, userIDs)
+				assert.Equal(t, test.ExpectedExternalIDs, externalIDs)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
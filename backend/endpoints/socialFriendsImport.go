@@ -0,0 +1,99 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type SocialFriendsImportInput struct {
+	UserID   string
+	Provider string
+}
+
+type SocialFriendsImportOutput struct {
+	Added     []*SocialFriend `json:"added"`     // Friend requests from them accepted
+	Requested []*SocialFriend `json:"requested"` // Friend requests sent to them
+}
+
+// NewSocialFriendsImport is a HandlerFunc processing the request to befriend the friends
+// of a user on an external platform, who linked their account as well. Friend requests
+// are sent to them, and the requests they already sent to the user are accepted.
+func NewSocialFriendsImport(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := SocialFriendsImportInput{
+		UserID:   mux.Vars(r)["id"],
+		Provider: mux.Vars(r)["provider"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	provider, err := readSocialGraphProvider(r, input.Provider)
+	if err != nil {
+		return err
+	}
+
+	// Process data storage
+	friends, err := matchSocialFriends(ctx, input.UserID, provider)
+	if err != nil {
+		return err
+	}
+
+	datastore := port.GetDatastore(ctx)
+	received, err := datastore.GetFriendRequests(input.UserID)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	pending := make(map[string]bool, len(received))
+	for _, request := range received {
+		pending[request.FromID] = true
+	}
+
+	// Users blocked or removed since being matched are skipped, as are the
+	// requests which are already pending or beyond the limits
+	output := &SocialFriendsImportOutput{
+		Added:     make([]*SocialFriend, 0, len(friends)),
+		Requested: make([]*SocialFriend, 0, len(friends)),
+	}
+
+	for _, friend := range friends {
+		if pending[friend.ID] {
+			err = datastore.AcceptFriendRequest(friend.ID, input.UserID)
+			if err == nil {
+				output.Added = append(output.Added, friend)
+			}
+		} else {
+			_, err = datastore.NewFriendRequest(&port.FriendRequest{
+				FromID:    input.UserID,
+				ToID:      friend.ID,
+				ExpiresAt: time.Now().Add(friendRequestTTL),
+			}, maxPendingFriendRequests)
+
+			if err == nil {
+				output.Requested = append(output.Requested, friend)
+			}
+		}
+
+		if err != nil {
+			switch err.Code() {
+			case port.ErrInvalidKey.Code(), port.ErrBlocked.Code(), port.ErrEntryExists.Code(), port.ErrLimitReached.Code():
+				continue
+			}
+
+			return err.SetStatusCode(http.StatusInternalServerError)
+		}
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, output)
+}
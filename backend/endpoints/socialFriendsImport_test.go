@@ -0,0 +1,129 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestSocialFriendsImport() {
+	// Imports are applied in order. All users linked an account, user 0 is
+	// a friend of user 1 and blocked by user 3. Importing sends requests, which
+	// are accepted by the friend importing.
+	for i, userID := range suite.Users {
+		suite.linkAccount(userID, []string{"ext-0", "ext-1", "ext-2", "ext-3"}[i])
+	}
+
+	datastore := port.GetDatastore(suite.ParentCtx)
+	suite.Require().Nil(datastore.UpdateFriends(suite.Users[0], []string{suite.Users[1]}))
+
+	_, err := datastore.BlockUser(suite.Users[3], suite.Users[0])
+	suite.Require().Nil(err)
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		Provider           string
+		ExpectedStatusCode int
+		ExpectedAdded      []string
+		ExpectedRequested  []string
+		ExpectedFriends    []string
+	}{
+		{
+			Name:               "Import",
+			UserID:             suite.Users[0],
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedAdded:      []string{},
+			ExpectedRequested:  []string{suite.Users[2]},
+			ExpectedFriends:    []string{suite.Users[1]},
+		}, {
+			Name:               "AlreadyImported",
+			UserID:             suite.Users[0],
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedAdded:      []string{},
+			ExpectedRequested:  []string{},
+			ExpectedFriends:    []string{suite.Users[1]},
+		}, {
+			Name:               "ImportedByFriend",
+			UserID:             suite.Users[2],
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedAdded:      []string{suite.Users[0]},
+			ExpectedRequested:  []string{},
+			ExpectedFriends:    []string{suite.Users[0]},
+		}, {
+			Name:               "UnknownProvider",
+			UserID:             suite.Users[1],
+			Provider:           "flaf",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			Provider:           socialGraphProvider,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/accounts/" + test.Provider + "/friends"
+			req, err := http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "provider": test.Provider})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewSocialFriendsImport)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body, and the resulting friend list
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(SocialFriendsImportOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				assert.Equal(t, test.ExpectedAdded, socialFriendIDs(output.Added))
+				assert.Equal(t, test.ExpectedRequested, socialFriendIDs(output.Requested))
+
+				friendIDs, err := datastore.GetFriendIDs(test.UserID)
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedFriends, friendIDs)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
+
+// socialFriendIDs returns the user IDs of the social friends
+func socialFriendIDs(friends []*SocialFriend) []string {
+	userIDs := make([]string, 0, len(friends))
+	for _, friend := range friends {
+		userIDs = append(userIDs, friend.ID)
+	}
+
+	return userIDs
+}
@@ -0,0 +1,73 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type UserBlockInput struct {
+	UserID    string
+	BlockedID string
+}
+
+// NewUserBlock is a HandlerFunc processing the request to block a user. Blocking removes
+// the friendship and pending friend requests of the users, prevents new ones, and hides
+// the user from searches and lists viewed by the blocked user. Blocking again does nothing.
+func NewUserBlock(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := UserBlockInput{
+		UserID:    mux.Vars(r)["id"],
+		BlockedID: mux.Vars(r)["blockedId"],
+	}
+
+	// Validate input
+	if err := validateBlockKey(input.UserID, input.BlockedID); err != nil {
+		return err
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	exists, err := datastore.UserExists(input.BlockedID)
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	if !exists {
+		return common.NewError(port.ErrInvalidKey, "Unknown BlockedID").
+			SetStatusCode(http.StatusNotFound)
+	}
+
+	if _, err := datastore.BlockUser(input.UserID, input.BlockedID); err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
+
+func validateBlockKey(userID, blockedID string) common.Error {
+	if _, stderr := uuid.FromString(userID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	if _, stderr := uuid.FromString(blockedID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid BlockedID").
+			SetInternal(stderr)
+	}
+
+	if userID == blockedID {
+		return common.NewError(ErrBadRequest, "A user can't block themself")
+	}
+
+	return nil
+}
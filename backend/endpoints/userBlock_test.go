@@ -0,0 +1,116 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestUserBlock() {
+	// User 1 is a friend of user 0, and has a pending request to user 3
+	datastore := port.GetDatastore(suite.ParentCtx)
+	_, err := datastore.AddFriend(suite.Users[1], suite.Users[0])
+	require.Nil(suite.T(), err)
+	suite.newFriendRequest(suite.Users[1], suite.Users[3])
+
+	// Blocks are applied in order
+	tests := []struct {
+		Name               string
+		UserID             string
+		BlockedID          string
+		ExpectedStatusCode int
+	}{
+		{
+			Name:               "BlockFriend",
+			UserID:             suite.Users[0],
+			BlockedID:          suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "BlockSender",
+			UserID:             suite.Users[3],
+			BlockedID:          suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "Blocked",
+			UserID:             suite.Users[0],
+			BlockedID:          suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+		}, {
+			Name:               "Self",
+			UserID:             suite.Users[0],
+			BlockedID:          suite.Users[0],
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "UnknownBlocked",
+			UserID:             suite.Users[0],
+			BlockedID:          "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			BlockedID:          suite.Users[0],
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/blocks/" + test.BlockedID
+			req, err := http.NewRequest("POST", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "blockedId": test.BlockedID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewUserBlock)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// The friendship is removed both ways
+	t := suite.T()
+	friendIDs, err := datastore.GetFriendIDs(suite.Users[0])
+	require.Nil(t, err)
+	assert.Equal(t, []string{suite.Users[2], suite.Users[3]}, friendIDs)
+
+	friendIDs, err = datastore.GetFriendIDs(suite.Users[1])
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(friendIDs))
+
+	// The pending request is removed, and no new ones can be sent either way
+	requests, err := datastore.GetFriendRequests(suite.Users[3])
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(requests))
+
+	for _, pair := range [][2]string{{suite.Users[1], suite.Users[3]}, {suite.Users[3], suite.Users[1]}} {
+		req, err := http.NewRequest("POST", "/user/"+pair[0]+"/friends/requests/"+pair[1], nil)
+		require.Nil(t, err)
+		req = mux.SetURLVars(req, map[string]string{"id": pair[0], "friendId": pair[1]})
+
+		rr := httptest.NewRecorder()
+		common.NewHandlerFunc(suite.ParentCtx, NewFriendRequestSend).ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	}
+
+	_, err = datastore.AddFriend(suite.Users[1], suite.Users[0])
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrBlocked.Code(), err.Code())
+}
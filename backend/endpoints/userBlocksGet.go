@@ -0,0 +1,65 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type UserBlocksGetInput struct {
+	UserID string
+}
+
+type UserBlocksGetOutput struct {
+	Blocked []*BlockedUser `json:"blocked"`
+}
+
+// BlockedUser is a part of UserBlocksGetOutput and describes a user blocked by another
+type BlockedUser struct {
+	UserID    string    `json:"id"`
+	BlockedAt time.Time `json:"blockedAt"`
+}
+
+// NewUserBlocksGet is a HandlerFunc processing the request to list the users blocked by
+// a user, in the order they were blocked.
+func NewUserBlocksGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := UserBlocksGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	blocks, err := port.GetDatastore(ctx).GetBlockedUsers(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	output := &UserBlocksGetOutput{
+		Blocked: make([]*BlockedUser, 0, len(blocks)),
+	}
+
+	for _, block := range blocks {
+		output.Blocked = append(output.Blocked, &BlockedUser{
+			UserID:    block.BlockedID,
+			BlockedAt: block.CreatedAt,
+		})
+	}
+
+	return common.SuccessResponseJSON(rw, output)
+}
@@ -0,0 +1,89 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestUserBlocksGet() {
+	datastore := port.GetDatastore(suite.ParentCtx)
+	for _, blockedID := range []string{suite.Users[3], suite.Users[1]} {
+		_, err := datastore.BlockUser(suite.Users[0], blockedID)
+		require.Nil(suite.T(), err)
+	}
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		ExpectedStatusCode int
+		ExpectedBlocked    []string
+	}{
+		{
+			Name:               "Get",
+			UserID:             suite.Users[0],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBlocked:    []string{suite.Users[3], suite.Users[1]},
+		}, {
+			Name:               "NoBlocks",
+			UserID:             suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBlocked:    []string{},
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/blocks"
+			req, err := http.NewRequest("GET", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewUserBlocksGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(UserBlocksGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				blocked := make([]string, 0)
+				for _, user := range output.Blocked {
+					assert.False(t, user.BlockedAt.IsZero())
+					blocked = append(blocked, user.UserID)
+				}
+
+				assert.Equal(t, test.ExpectedBlocked, blocked)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -3,7 +3,9 @@ package endpoints
 import (
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/Sirupsen/logrus"
 	uuid "github.com/satori/go.uuid"
 
 	"github.com/valsgaard/interview-case/backend/common"
@@ -11,15 +13,19 @@ import (
 )
 
 type UserCreateInput struct {
-	Name string `json:"name"`
+	Name         string `json:"name"`
+	ReferralCode string `json:"referralCode"` // Optional
 }
 
 type UserCreateOutput struct {
-	UserID string `json:"id"`
-	Name   string `json:"name"`
+	UserID     string `json:"id"`
+	Name       string `json:"name"`
+	ReferredBy string `json:"referredBy,omitempty"`
 }
 
-// NewUserCreate is a HandlerFunc processing the request to create new users.
+// NewUserCreate is a HandlerFunc processing the request to create new users. Redeeming
+// a referral code sends a friend request from the referrer to the new user. Once the user
+// is created, failing to redeem the code or send the request doesn't fail the request.
 func NewUserCreate(rw http.ResponseWriter, r *http.Request) common.Error {
 	ctx := r.Context()
 
@@ -54,8 +60,17 @@ func NewUserCreate(rw http.ResponseWriter, r *http.Request) common.Error {
 	// that there won't be any collisions
 	userID := uuid.NewV1().String()
 
-	// Process data storage
-	user, err := port.GetDatastore(ctx).NewUser(userID, input.Name)
+	// Process data storage. The code is checked before the user is created,
+	// and only redeemed once the user exists.
+	datastore := port.GetDatastore(ctx)
+	code := strings.ToUpper(strings.TrimSpace(input.ReferralCode))
+	if code != "" {
+		if err := checkReferralCode(datastore, code); err != nil {
+			return err
+		}
+	}
+
+	user, err := datastore.NewUser(userID, input.Name)
 	if err != nil {
 		return common.ErrorResponseJSON(
 			rw,
@@ -67,9 +82,68 @@ func NewUserCreate(rw http.ResponseWriter, r *http.Request) common.Error {
 		)
 	}
 
-	// Response
-	return common.SuccessResponseJSON(rw, &UserCreateOutput{
+	output := &UserCreateOutput{
 		UserID: user.UserID,
 		Name:   user.Name,
+	}
+
+	if code != "" {
+		output.ReferredBy = referUser(r, code, user.UserID)
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, output)
+}
+
+// checkReferralCode tells if a referral code can still be redeemed
+func checkReferralCode(datastore port.Datastore, code string) common.Error {
+	referralCode, err := datastore.LookupReferralCode(code)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return common.NewError(ErrBadRequest, "Invalid referral code").
+				SetInternal(err)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	switch {
+	case !time.Now().Before(referralCode.ExpiresAt):
+		return common.NewError(port.ErrUnavailable, "Referral code expired").
+			SetStatusCode(http.StatusGone)
+	case referralCode.Redemptions >= referralCode.MaxRedemptions:
+		return common.NewError(port.ErrUnavailable, "Referral code used up").
+			SetStatusCode(http.StatusGone)
+	}
+
+	return nil
+}
+
+// referUser redeems the referral code for a new user, and sends a friend
+// request from the referrer. Returns the referrer, unless the code couldn't
+// be redeemed.
+func referUser(r *http.Request, code, userID string) string {
+	datastore := port.GetDatastore(r.Context())
+	log := common.Log(r.Context()).WithFields(logrus.Fields{
+		"userId": userID,
+		"code":   code,
 	})
+
+	referralCode, err := datastore.RedeemReferralCode(code, userID)
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Unable to redeem referral code")
+		return ""
+	}
+
+	_, err = datastore.NewFriendRequest(&port.FriendRequest{
+		FromID:    referralCode.UserID,
+		ToID:      userID,
+		ExpiresAt: time.Now().Add(friendRequestTTL),
+	}, maxPendingFriendRequests)
+
+	if err != nil {
+		log.WithField("error", err.Error()).Warn("Unable to send friend request of referrer")
+	}
+
+	return referralCode.UserID
 }
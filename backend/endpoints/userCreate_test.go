@@ -6,20 +6,30 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	uuid "github.com/satori/go.uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/valsgaard/interview-case/backend/common"
 	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
 )
 
 func (suite *EndpointsTestSuite) TestUserCreate() {
+	// User 0 has a code which can be redeemed once, the code of user 1 expired
+	datastore := port.GetDatastore(suite.ParentCtx)
+	suite.newReferralCode(suite.Users[0], "SINGLE23", 1, time.Hour)
+	suite.newReferralCode(suite.Users[1], "EXPIRED2", 10, -time.Hour)
+
 	tests := []struct {
 		Name               string
 		InputName          string
+		ReferralCode       string
 		ExpectedSuccess    bool
 		ExpectedStatusCode int
+		ExpectedReferrer   string
 	}{
 		{
 			Name:               "Post",
@@ -31,13 +41,39 @@ func (suite *EndpointsTestSuite) TestUserCreate() {
 			InputName:          "",
 			ExpectedSuccess:    false,
 			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "Referred",
+			InputName:          "Name2",
+			ReferralCode:       "single23",
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedReferrer:   suite.Users[0],
+		}, {
+			Name:               "ReferralUsedUp",
+			InputName:          "Name3",
+			ReferralCode:       "SINGLE23",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusGone,
+		}, {
+			Name:               "ReferralExpired",
+			InputName:          "Name4",
+			ReferralCode:       "EXPIRED2",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusGone,
+		}, {
+			Name:               "InvalidReferral",
+			InputName:          "Name5",
+			ReferralCode:       "UNKNOWN2",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
 		},
 	}
 
 	for _, test := range tests {
 		fn := func(t *testing.T) {
 			b, err := json.Marshal(&UserCreateInput{
-				Name: test.InputName,
+				Name:         test.InputName,
+				ReferralCode: test.ReferralCode,
 			})
 			if err != nil {
 				t.Fatal(err)
@@ -48,6 +84,9 @@ func (suite *EndpointsTestSuite) TestUserCreate() {
 				t.Fatal(err)
 			}
 
+			before, err := datastore.ListUsers(&port.ListQuery{Sort: port.SortByID, Limit: 100})
+			require.Nil(t, err)
+
 			// Prepare recorder
 			rr := httptest.NewRecorder()
 			handler := common.NewHandlerFunc(suite.ParentCtx, NewUserCreate)
@@ -58,6 +97,13 @@ func (suite *EndpointsTestSuite) TestUserCreate() {
 			// Check the status code
 			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
 
+			// Users aren't created by failed requests
+			after, err := datastore.ListUsers(&port.ListQuery{Sort: port.SortByID, Limit: 100})
+			require.Nil(t, err)
+			if !test.ExpectedSuccess {
+				assert.Equal(t, len(before), len(after))
+			}
+
 			// Check the response body is what we expect.
 			if test.ExpectedSuccess {
 				v := new(UserCreateOutput)
@@ -68,6 +114,15 @@ func (suite *EndpointsTestSuite) TestUserCreate() {
 				_, err := uuid.FromString(v.UserID)
 				assert.Nil(t, err)
 				assert.Equal(t, test.InputName, v.Name)
+				assert.Equal(t, test.ExpectedReferrer, v.ReferredBy)
+
+				// The referrer is pending as a friend
+				if test.ExpectedReferrer != "" {
+					requests, err := datastore.GetFriendRequests(v.UserID)
+					require.Nil(t, err)
+					require.Equal(t, 1, len(requests))
+					assert.Equal(t, test.ExpectedReferrer, requests[0].FromID)
+				}
 			}
 		}
 
@@ -0,0 +1,47 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type UserFlagClearInput struct {
+	UserID string
+}
+
+// NewUserFlagClear is a HandlerFunc processing the admin request to clear the flag of a
+// user, once reviewed.
+func NewUserFlagClear(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := UserFlagClearInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	if err := port.GetDatastore(ctx).ClearUserFlag(input.UserID); err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	common.Log(ctx).WithFields(logrus.Fields{
+		"userId": input.UserID,
+	}).Info("User flag cleared")
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
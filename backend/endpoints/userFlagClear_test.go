@@ -0,0 +1,82 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestUserFlagClear() {
+	require.Nil(suite.T(), port.GetDatastore(suite.ParentCtx).FlagUser(suite.Users[1], FlagReasonInvalidSignature))
+
+	tests := []struct {
+		Name               string
+		UserID             string
+		Token              string
+		ExpectedStatusCode int
+		ExpectedFlags      int
+	}{
+		{
+			Name:               "InvalidToken",
+			UserID:             suite.Users[1],
+			Token:              "flaf",
+			ExpectedStatusCode: http.StatusForbidden,
+			ExpectedFlags:      1,
+		}, {
+			Name:               "Clear",
+			UserID:             suite.Users[1],
+			Token:              adminToken,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedFlags:      0,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			Token:              adminToken,
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedFlags:      0,
+		}, {
+			Name:               "InvalidUserID",
+			UserID:             "flaf",
+			Token:              adminToken,
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedFlags:      0,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/admin/user/" + test.UserID + "/flag"
+			req, err := http.NewRequest("DELETE", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+test.Token)
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, AdminOnly(NewUserFlagClear))
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and the flags left
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			flags, err := port.GetDatastore(suite.ParentCtx).GetFlaggedUsers()
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedFlags, len(flags))
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
@@ -0,0 +1,51 @@
+package endpoints
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type UserFlagsGetOutput struct {
+	Flags []*UserFlag `json:"flags"`
+}
+
+// UserFlag is a part of UserFlagsGetOutput and describes a user flagged for review
+type UserFlag struct {
+	UserID         string    `json:"userId"`
+	Reason         string    `json:"reason"`
+	Count          int       `json:"count"`
+	FirstFlaggedAt time.Time `json:"firstFlaggedAt"`
+	LastFlaggedAt  time.Time `json:"lastFlaggedAt"`
+}
+
+// NewUserFlagsGet is a HandlerFunc processing the admin request to list the users flagged
+// for review, most recently flagged first.
+func NewUserFlagsGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Process data storage
+	flags, err := port.GetDatastore(ctx).GetFlaggedUsers()
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	output := &UserFlagsGetOutput{
+		Flags: make([]*UserFlag, 0, len(flags)),
+	}
+
+	for _, flag := range flags {
+		output.Flags = append(output.Flags, &UserFlag{
+			UserID:         flag.UserID,
+			Reason:         flag.Reason,
+			Count:          flag.Count,
+			FirstFlaggedAt: flag.FirstFlaggedAt,
+			LastFlaggedAt:  flag.LastFlaggedAt,
+		})
+	}
+
+	return common.SuccessResponseJSON(rw, output)
+}
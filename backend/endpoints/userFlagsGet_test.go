@@ -0,0 +1,79 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestUserFlagsGet() {
+	require.Nil(suite.T(), port.GetDatastore(suite.ParentCtx).FlagUser(suite.Users[1], FlagReasonInvalidSignature))
+
+	tests := []struct {
+		Name               string
+		Token              string
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedUserIDs    []string
+	}{
+		{
+			Name:               "Get",
+			Token:              adminToken,
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUserIDs:    []string{suite.Users[1]},
+		}, {
+			Name:               "MissingToken",
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/admin/flags", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.Token)
+			}
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, AdminOnly(NewUserFlagsGet))
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedSuccess {
+				output := new(UserFlagsGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				userIDs := make([]string, 0)
+				for _, flag := range output.Flags {
+					userIDs = append(userIDs, flag.UserID)
+					assert.Equal(t, FlagReasonInvalidSignature, flag.Reason)
+					assert.Equal(t, 1, flag.Count)
+				}
+
+				assert.Equal(t, test.ExpectedUserIDs, userIDs)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
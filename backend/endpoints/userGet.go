@@ -9,6 +9,7 @@ import (
 
 type UserGetOutput struct {
 	Users []*User `json:"users"`
+	Links Links   `json:"links"`
 }
 
 // User is used as part of UserGetOutput and contains the details of a user
@@ -17,12 +18,26 @@ type User struct {
 	Name   string `json:"name"`
 }
 
-// NewUserGet is a HandlerFunc processing the request to retrieve users.
+// NewUserGet is a HandlerFunc processing the request to retrieve a page of users.
 func NewUserGet(rw http.ResponseWriter, r *http.Request) common.Error {
 	ctx := r.Context()
 
-	// Process data storage
-	users, err := port.GetDatastore(ctx).GetUsers()
+	// Parse input
+	query, err := readListQuery(r, port.SortByName)
+	if err != nil {
+		return err
+	}
+
+	query.NamePrefix = r.URL.Query().Get("name")
+	if query.ViewerID, err = readViewer(r); err != nil {
+		return err
+	}
+
+	// Process data storage, fetching an extra user to see if there's more
+	query.Limit++
+	users, err := port.GetDatastore(ctx).ListUsers(query)
+	query.Limit--
+
 	if err != nil {
 		return common.ErrorResponseJSON(
 			rw,
@@ -34,12 +49,20 @@ func NewUserGet(rw http.ResponseWriter, r *http.Request) common.Error {
 		)
 	}
 
+	start, end, more := trimPage(query, len(users))
+	users = users[start:end]
+
+	// Prepare output
 	output := new(UserGetOutput)
 	output.Users = make([]*User, 0, len(users))
+	keys := make([]port.ListKey, 0, len(users))
 	for _, u := range users {
 		output.Users = append(output.Users, &User{UserID: u.UserID, Name: u.Name})
+		keys = append(keys, port.ListKey{UserID: u.UserID, Name: u.Name})
 	}
 
+	output.Links = listLinks(r, query, keys, more)
+
 	// Response
 	return common.SuccessResponseJSON(rw, output)
 }
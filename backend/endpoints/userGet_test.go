@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/valsgaard/interview-case/backend/common"
 	. "github.com/valsgaard/interview-case/backend/endpoints"
@@ -15,10 +17,12 @@ import (
 func (suite *EndpointsTestSuite) TestUserGet() {
 	tests := []struct {
 		Name               string
+		Query              url.Values
 		ExpectedSuccess    bool
 		ExpectedStatusCode int
 		ExpectedUsers      []string
 		ExpectedNames      []string
+		ExpectedNext       bool
 	}{
 		{
 			Name:               "Get",
@@ -26,12 +30,44 @@ func (suite *EndpointsTestSuite) TestUserGet() {
 			ExpectedStatusCode: http.StatusOK,
 			ExpectedUsers:      suite.Users,
 			ExpectedNames:      []string{"bot0", "bot1", "bot2", "bot3"},
+		}, {
+			Name:               "Limit",
+			Query:              url.Values{"limit": {"2"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      suite.Users[:2],
+			ExpectedNames:      []string{"bot0", "bot1"},
+			ExpectedNext:       true,
+		}, {
+			Name:               "SortNameDescending",
+			Query:              url.Values{"sort": {"-name"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[3], suite.Users[2], suite.Users[1], suite.Users[0]},
+			ExpectedNames:      []string{"bot3", "bot2", "bot1", "bot0"},
+		}, {
+			Name:               "FilterName",
+			Query:              url.Values{"name": {"BOT2"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      suite.Users[2:3],
+			ExpectedNames:      []string{"bot2"},
+		}, {
+			Name:               "InvalidSort",
+			Query:              url.Values{"sort": {"score"}},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidCursor",
+			Query:              url.Values{"cursor": {"flaf"}},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
 		},
 	}
 
 	for _, test := range tests {
 		fn := func(t *testing.T) {
-			req, err := http.NewRequest("GET", "/user", nil)
+			req, err := http.NewRequest("GET", "/user?"+test.Query.Encode(), nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -59,9 +95,58 @@ func (suite *EndpointsTestSuite) TestUserGet() {
 						assert.Equal(t, test.ExpectedNames[i], v.Users[i].Name)
 					}
 				}
+
+				assert.Equal(t, test.ExpectedNext, v.Links.Next != "")
+				assert.Empty(t, v.Links.Prev)
 			}
 		}
 
 		suite.T().Run(test.Name, fn)
 	}
 }
+
+func (suite *EndpointsTestSuite) TestUserGetLinks() {
+	get := func(t *testing.T, path string) *UserGetOutput {
+		req, err := http.NewRequest("GET", path, nil)
+		require.Nil(t, err)
+
+		rr := httptest.NewRecorder()
+		common.NewHandlerFunc(suite.ParentCtx, NewUserGet).ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		v := new(UserGetOutput)
+		require.Nil(t, json.Unmarshal(rr.Body.Bytes(), v))
+		return v
+	}
+
+	ids := func(users []*User) []string {
+		ids := make([]string, 0, len(users))
+		for _, u := range users {
+			ids = append(ids, u.UserID)
+		}
+		return ids
+	}
+
+	for _, sort := range []string{"id", "-name"} {
+		fn := func(t *testing.T) {
+			first := get(t, "/user?limit=3&sort="+sort)
+			require.Len(t, first.Users, 3)
+			require.NotEmpty(t, first.Links.Next)
+			assert.Empty(t, first.Links.Prev)
+
+			// Forward to the last page
+			second := get(t, first.Links.Next)
+			require.Len(t, second.Users, 1)
+			assert.Empty(t, second.Links.Next)
+			require.NotEmpty(t, second.Links.Prev)
+
+			// And back again
+			back := get(t, second.Links.Prev)
+			assert.Equal(t, ids(first.Users), ids(back.Users))
+			assert.NotEmpty(t, back.Links.Next)
+			assert.Empty(t, back.Links.Prev)
+		}
+
+		suite.T().Run(sort, fn)
+	}
+}
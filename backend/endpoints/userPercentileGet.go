@@ -0,0 +1,115 @@
+package endpoints
+
+import (
+	"math"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type UserPercentileGetInput struct {
+	UserID string
+}
+
+type UserPercentileGetOutput struct {
+	ID         string         `json:"id"`
+	Highscore  int            `json:"highscore"`
+	Percentile float64        `json:"percentile"` // Share of the other users with a lower high score, in percent
+	Total      int            `json:"total"`
+	Histogram  []*ScoreBucket `json:"histogram"`
+}
+
+// ScoreBucket is a part of UserPercentileGetOutput, and counts the users with a high
+// score from min up to but not including max
+type ScoreBucket struct {
+	Min   int `json:"min"`
+	Max   int `json:"max"`
+	Count int `json:"count"`
+}
+
+// NewUserPercentileGet is a HandlerFunc processing the request to get the percentile of
+// the high score of a user, together with the distribution of the high scores of all
+// users. The percentile is estimated from the buckets of the distribution.
+func NewUserPercentileGet(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := UserPercentileGetInput{
+		UserID: mux.Vars(r)["id"],
+	}
+
+	// Validate input
+	if _, stderr := uuid.FromString(input.UserID); stderr != nil {
+		return common.NewError(ErrBadRequest, "Invalid UserID").
+			SetInternal(stderr)
+	}
+
+	// Process data storage
+	datastore := port.GetDatastore(ctx)
+	state, err := datastore.GetGameState(input.UserID)
+	if err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	distribution, err := datastore.GetScoreDistribution()
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Prepare output
+	output := &UserPercentileGetOutput{
+		ID:         input.UserID,
+		Highscore:  state.Score(),
+		Percentile: scorePercentile(distribution, state.Score()),
+		Total:      distribution.Total,
+		Histogram:  make([]*ScoreBucket, 0, len(distribution.Buckets)),
+	}
+
+	for _, bucket := range distribution.Buckets {
+		output.Histogram = append(output.Histogram, &ScoreBucket{
+			Min:   bucket.Min,
+			Max:   bucket.Max,
+			Count: bucket.Count,
+		})
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, output)
+}
+
+// scorePercentile estimates the share of the other users with a lower score, in
+// percent with a single decimal. Users are assumed to be spread evenly within the
+// bucket of the score.
+func scorePercentile(distribution *port.ScoreDistribution, score int) float64 {
+	if distribution.Total <= 1 {
+		return 0
+	}
+
+	below := 0.0
+	for _, bucket := range distribution.Buckets {
+		if bucket.Max <= score {
+			below += float64(bucket.Count)
+			continue
+		}
+
+		if bucket.Min < score {
+			below += float64(bucket.Count) * float64(score-bucket.Min) / float64(bucket.Max-bucket.Min)
+		}
+
+		break
+	}
+
+	// The user is counted in its own bucket
+	others := float64(distribution.Total - 1)
+	if below > others {
+		below = others
+	}
+
+	return math.Round(1000*below/others) / 10
+}
@@ -0,0 +1,102 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestUserPercentileGet() {
+	// User 0 has a high score of 110, users 1 and 3 share a high score of 50
+	for _, userID := range []string{suite.Users[1], suite.Users[3]} {
+		_, err := port.GetDatastore(suite.ParentCtx).SubmitScore(userID, 50, port.StateChange{Author: userID, Source: port.StateSourceScore})
+		require.Nil(suite.T(), err)
+	}
+
+	// Scores from 32 up share buckets of 2, from 64 up buckets of 4
+	histogram := []*ScoreBucket{
+		{Min: 0, Max: 1, Count: 1},
+		{Min: 50, Max: 52, Count: 2},
+		{Min: 108, Max: 112, Count: 1},
+	}
+
+	tests := []struct {
+		Name               string
+		ID                 string
+		ExpectedStatusCode int
+		ExpectedHighscore  int
+		ExpectedPercentile float64
+	}{
+		{
+			Name:               "Top",
+			ID:                 suite.Users[0],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedHighscore:  110,
+			ExpectedPercentile: 100,
+		}, {
+			Name:               "Shared",
+			ID:                 suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedHighscore:  50,
+			ExpectedPercentile: 33.3,
+		}, {
+			Name:               "Bottom",
+			ID:                 suite.Users[2],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedHighscore:  0,
+			ExpectedPercentile: 0,
+		}, {
+			Name:               "UnknownUser",
+			ID:                 "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			ExpectedStatusCode: http.StatusNotFound,
+		}, {
+			Name:               "InvalidUserID",
+			ID:                 "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/user/"+test.ID+"/percentile", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.ID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewUserPercentileGet)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedStatusCode == http.StatusOK {
+				output := new(UserPercentileGetOutput)
+				require.Nil(t, json.Unmarshal(rr.Body.Bytes(), output))
+
+				assert.Equal(t, test.ID, output.ID)
+				assert.Equal(t, test.ExpectedHighscore, output.Highscore)
+				assert.Equal(t, test.ExpectedPercentile, output.Percentile)
+				assert.Equal(t, len(suite.Users), output.Total)
+				assert.Equal(t, histogram, output.Histogram)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
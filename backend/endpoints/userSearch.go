@@ -0,0 +1,126 @@
+package endpoints
+
+import (
+	"net/http"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// Search modes
+const (
+	SearchModePrefix = "prefix"
+	SearchModeFuzzy  = "fuzzy"
+)
+
+// Search page size
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+)
+
+type UserSearchInput struct {
+	Query    string
+	Mode     string
+	Offset   int
+	Limit    int
+	ViewerID string
+}
+
+type UserSearchOutput struct {
+	Users []*User `json:"users"`
+
+	// Next is the offset of the next page, if there are more matches
+	Next *int `json:"next,omitempty"`
+}
+
+// NewUserSearch is a HandlerFunc processing the request to search users by name.
+func NewUserSearch(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+
+	// Parse input
+	input := UserSearchInput{
+		Query: strings.TrimSpace(r.URL.Query().Get("q")),
+		Mode:  r.URL.Query().Get("mode"),
+	}
+
+	var err common.Error
+	if input.Offset, err = common.ReadQueryInt(r, "offset", 0); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid offset").
+			SetInternal(err)
+	}
+
+	if input.Limit, err = common.ReadQueryInt(r, "limit", searchDefaultLimit); err != nil {
+		return common.NewError(ErrBadRequest, "Invalid limit").
+			SetInternal(err)
+	}
+
+	if input.ViewerID, err = readViewer(r); err != nil {
+		return err
+	}
+
+	// Validate input
+	if input.Query == "" {
+		return common.NewError(ErrBadRequest, "Missing search query")
+	}
+
+	if input.Offset < 0 {
+		return common.NewError(ErrBadRequest, "Invalid offset")
+	}
+
+	if input.Limit < 1 || input.Limit > searchMaxLimit {
+		return common.NewError(ErrBadRequest, "Invalid limit")
+	}
+
+	// Process data storage, fetching an extra match to see if there's more
+	var matches []*port.UserMatch
+	switch input.Mode {
+	case "", SearchModePrefix:
+		matches, err = port.GetDatastore(ctx).SearchUsersByPrefix(input.Query, input.ViewerID, input.Offset, input.Limit+1)
+
+	case SearchModeFuzzy:
+		matches, err = port.GetDatastore(ctx).SearchUsersFuzzy(input.Query, input.ViewerID, input.Offset, input.Limit+1)
+
+	default:
+		return common.NewError(ErrBadRequest, "Invalid search mode")
+	}
+
+	if err != nil {
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Prepare output
+	output := new(UserSearchOutput)
+	if len(matches) > input.Limit {
+		matches = matches[:input.Limit]
+		next := input.Offset + input.Limit
+		output.Next = &next
+	}
+
+	output.Users = make([]*User, 0, len(matches))
+	for _, m := range matches {
+		output.Users = append(output.Users, &User{UserID: m.UserID, Name: m.Name})
+	}
+
+	// Response
+	return common.SuccessResponseJSON(rw, output)
+}
+
+// readViewer reads the optional ID of the user viewing a list, users who blocked
+// the viewer are left out of it
+func readViewer(r *http.Request) (string, common.Error) {
+	viewerID := r.URL.Query().Get("viewer")
+	if viewerID == "" {
+		return "", nil
+	}
+
+	if _, stderr := uuid.FromString(viewerID); stderr != nil {
+		return "", common.NewError(ErrBadRequest, "Invalid viewer").
+			SetInternal(stderr)
+	}
+
+	return viewerID, nil
+}
@@ -0,0 +1,128 @@
+package endpoints_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestUserSearch() {
+	// User 2 is hidden from user 0
+	_, err := port.GetDatastore(suite.ParentCtx).BlockUser(suite.Users[2], suite.Users[0])
+	require.Nil(suite.T(), err)
+
+	tests := []struct {
+		Name               string
+		Query              url.Values
+		ExpectedSuccess    bool
+		ExpectedStatusCode int
+		ExpectedUsers      []string
+		ExpectedNext       *int
+	}{
+		{
+			Name:               "Prefix",
+			Query:              url.Values{"q": {"BOT"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      suite.Users,
+		}, {
+			Name:               "PrefixPage",
+			Query:              url.Values{"q": {"bot"}, "offset": {"1"}, "limit": {"2"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      suite.Users[1:3],
+			ExpectedNext:       intPtr(3),
+		}, {
+			Name:               "PrefixNoMatch",
+			Query:              url.Values{"q": {"ot"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{},
+		}, {
+			Name:               "Fuzzy",
+			Query:              url.Values{"q": {"bot2"}, "mode": {"fuzzy"}, "limit": {"1"}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      suite.Users[2:3],
+			ExpectedNext:       intPtr(1),
+		}, {
+			Name:               "HiddenFromBlocked",
+			Query:              url.Values{"q": {"bot"}, "viewer": {suite.Users[0]}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      []string{suite.Users[0], suite.Users[1], suite.Users[3]},
+		}, {
+			Name:               "VisibleToOthers",
+			Query:              url.Values{"q": {"bot"}, "viewer": {suite.Users[1]}},
+			ExpectedSuccess:    true,
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedUsers:      suite.Users,
+		}, {
+			Name:               "InvalidViewer",
+			Query:              url.Values{"q": {"bot"}, "viewer": {"flaf"}},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "MissingQuery",
+			Query:              url.Values{},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidMode",
+			Query:              url.Values{"q": {"bot"}, "mode": {"exact"}},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		}, {
+			Name:               "InvalidLimit",
+			Query:              url.Values{"q": {"bot"}, "limit": {"1000"}},
+			ExpectedSuccess:    false,
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/user/search?"+test.Query.Encode(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewUserSearch)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code
+			require.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			// Check the response body is what we expect.
+			if test.ExpectedSuccess {
+				v := new(UserSearchOutput)
+				if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+					t.Fatal(err)
+				}
+
+				if assert.Equal(t, len(test.ExpectedUsers), len(v.Users)) {
+					for i := range v.Users {
+						assert.Equal(t, test.ExpectedUsers[i], v.Users[i].UserID)
+					}
+				}
+
+				assert.Equal(t, test.ExpectedNext, v.Next)
+			}
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+}
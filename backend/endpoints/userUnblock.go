@@ -0,0 +1,42 @@
+package endpoints
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+type UserUnblockInput struct {
+	UserID    string
+	BlockedID string
+}
+
+// NewUserUnblock is a HandlerFunc processing the request to unblock a user. The users
+// don't become friends again. Unblocking a user which isn't blocked does nothing.
+func NewUserUnblock(rw http.ResponseWriter, r *http.Request) common.Error {
+	ctx := r.Context()
+	input := UserUnblockInput{
+		UserID:    mux.Vars(r)["id"],
+		BlockedID: mux.Vars(r)["blockedId"],
+	}
+
+	// Validate input
+	if err := validateBlockKey(input.UserID, input.BlockedID); err != nil {
+		return err
+	}
+
+	// Process data storage, the blocked user doesn't have to exist anymore
+	if _, err := port.GetDatastore(ctx).UnblockUser(input.UserID, input.BlockedID); err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return err.SetStatusCode(http.StatusNotFound)
+		}
+
+		return err.SetStatusCode(http.StatusInternalServerError)
+	}
+
+	// Response
+	return common.SuccessResponseEmpty(rw)
+}
@@ -0,0 +1,87 @@
+package endpoints_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	. "github.com/valsgaard/interview-case/backend/endpoints"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+func (suite *EndpointsTestSuite) TestUserUnblock() {
+	_, err := port.GetDatastore(suite.ParentCtx).BlockUser(suite.Users[0], suite.Users[1])
+	require.Nil(suite.T(), err)
+
+	// Unblocks are applied in order
+	tests := []struct {
+		Name               string
+		UserID             string
+		BlockedID          string
+		ExpectedStatusCode int
+		ExpectedBlocked    int
+	}{
+		{
+			Name:               "Unblock",
+			UserID:             suite.Users[0],
+			BlockedID:          suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBlocked:    0,
+		}, {
+			Name:               "NotBlocked",
+			UserID:             suite.Users[0],
+			BlockedID:          suite.Users[1],
+			ExpectedStatusCode: http.StatusOK,
+			ExpectedBlocked:    0,
+		}, {
+			Name:               "InvalidBlockedID",
+			UserID:             suite.Users[0],
+			BlockedID:          "flaf",
+			ExpectedStatusCode: http.StatusBadRequest,
+			ExpectedBlocked:    0,
+		}, {
+			Name:               "UnknownUser",
+			UserID:             "fee6feba-043b-4ba4-a7a4-9d6705595049",
+			BlockedID:          suite.Users[1],
+			ExpectedStatusCode: http.StatusNotFound,
+			ExpectedBlocked:    0,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			path := "/user/" + test.UserID + "/blocks/" + test.BlockedID
+			req, err := http.NewRequest("DELETE", path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req = mux.SetURLVars(req, map[string]string{"id": test.UserID, "blockedId": test.BlockedID})
+
+			// Prepare recorder
+			rr := httptest.NewRecorder()
+			handler := common.NewHandlerFunc(suite.ParentCtx, NewUserUnblock)
+
+			// Call endpoint
+			handler.ServeHTTP(rr, req)
+
+			// Check the status code, and the users left blocked
+			assert.Equal(t, test.ExpectedStatusCode, rr.Code)
+
+			blocks, err := port.GetDatastore(suite.ParentCtx).GetBlockedUsers(suite.Users[0])
+			require.Nil(t, err)
+			assert.Equal(t, test.ExpectedBlocked, len(blocks))
+		}
+
+		suite.T().Run(test.Name, fn)
+	}
+
+	// The users can become friends again
+	_, err = port.GetDatastore(suite.ParentCtx).AddFriend(suite.Users[1], suite.Users[0])
+	assert.Nil(suite.T(), err)
+}
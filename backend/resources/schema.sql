@@ -3,5 +3,238 @@ CREATE TABLE users (
     name           text   NOT NULL,
     games_played   int    NOT NULL DEFAULT 0,
     score          int    NOT NULL DEFAULT 0,
-    friends        uuid[] NOT NULL DEFAULT array[]::uuid[]
-);
\ No newline at end of file
+    friends        uuid[] NOT NULL DEFAULT array[]::uuid[],
+
+    -- Game state document, upgraded to the current version on read. The
+    -- well-known games_played and score fields are kept in their columns.
+    state          jsonb  NOT NULL DEFAULT '{"version": 1}',
+
+    -- Incremented on every write of the game state, for compare-and-swap
+    state_revision int    NOT NULL DEFAULT 0,
+
+    -- The save slot the game state belongs to
+    active_slot    uuid
+);
+
+-- Every submitted run, the best is kept in users.score. Runs submitted with a
+-- key, such as the result of a game, are only counted once.
+CREATE TABLE score_runs (
+    id             bigserial   PRIMARY KEY,
+    user_id        uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    score          int         NOT NULL,
+    submitted_at   timestamptz NOT NULL DEFAULT now(),
+    run_key        text
+);
+
+CREATE INDEX score_runs_user_idx ON score_runs (user_id, submitted_at);
+CREATE UNIQUE INDEX score_runs_key_idx ON score_runs (user_id, run_key) WHERE run_key IS NOT NULL;
+CREATE INDEX score_runs_submitted_idx ON score_runs (submitted_at);
+
+-- Every revision of the game state, with the well-known fields included.
+-- Old revisions are pruned by the retention of the datastore.
+CREATE TABLE state_revisions (
+    user_id        uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    revision       int         NOT NULL,
+    state          jsonb       NOT NULL,
+    author         text        NOT NULL,
+    source         text        NOT NULL,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, revision)
+);
+
+-- Named saves of a user. The state of the active slot is kept in the users
+-- row, the other slots keep theirs with the well-known fields included.
+CREATE TABLE save_slots (
+    user_id        uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    id             uuid        NOT NULL,
+    name           text        NOT NULL,
+    state          jsonb       NOT NULL DEFAULT '{"version": 1}',
+    last_device    text        NOT NULL DEFAULT '',
+    play_time      int         NOT NULL DEFAULT 0,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    updated_at     timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, id),
+    UNIQUE (user_id, name)
+);
+
+-- Users flagged for review, such as for submitting game state with an
+-- invalid signature. The flag is removed once reviewed.
+CREATE TABLE user_flags (
+    user_id          uuid        PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    reason           text        NOT NULL,
+    count            int         NOT NULL DEFAULT 1,
+    first_flagged_at timestamptz NOT NULL DEFAULT now(),
+    last_flagged_at  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX user_flags_last_idx ON user_flags (last_flagged_at);
+
+-- Pending friend requests, stored with the recipient. Senders may be stored
+-- in another shard, so they aren't referenced. Expired requests are ignored,
+-- and pruned when the recipient receives a new request.
+CREATE TABLE friend_requests (
+    to_id          uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    from_id        uuid        NOT NULL,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    expires_at     timestamptz NOT NULL,
+    PRIMARY KEY (to_id, from_id)
+);
+
+CREATE INDEX friend_requests_from_idx ON friend_requests (from_id);
+
+-- Users blocked by a user, stored with the blocker. Blocked users may be
+-- stored in another shard, so they aren't referenced.
+CREATE TABLE user_blocks (
+    user_id        uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    blocked_id     uuid        NOT NULL,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, blocked_id)
+);
+
+CREATE INDEX user_blocks_blocked_idx ON user_blocks (blocked_id);
+
+-- Shareable referral code of a user, a user has a single code. Codes are
+-- redeemed until they expire, or reach their redemptions limit.
+CREATE TABLE referral_codes (
+    code            text        PRIMARY KEY,
+    user_id         uuid        NOT NULL UNIQUE REFERENCES users (id) ON DELETE CASCADE,
+    max_redemptions int         NOT NULL,
+    redemptions     int         NOT NULL DEFAULT 0,
+    created_at      timestamptz NOT NULL DEFAULT now(),
+    expires_at      timestamptz NOT NULL
+);
+
+-- Users referred by a user, stored with the referrer. Referred users may be
+-- stored in another shard, so they aren't referenced.
+CREATE TABLE referrals (
+    referrer_id    uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    user_id        uuid        NOT NULL,
+    code           text        NOT NULL,
+    created_at     timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (referrer_id, user_id)
+);
+
+-- Accounts on external platforms linked by a user, a single account of each
+-- provider. An external account is linked to a single user.
+CREATE TABLE linked_accounts (
+    user_id        uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider       text        NOT NULL,
+    external_id    text        NOT NULL,
+    linked_at      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, provider),
+    UNIQUE (provider, external_id)
+);
+
+-- High scores of the friends of a user, as last seen by the user on the
+-- friends leaderboard. Friends may be stored in another shard, so they
+-- aren't referenced.
+CREATE TABLE friend_score_views (
+    user_id        uuid        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    friend_id      uuid        NOT NULL,
+    score          int         NOT NULL,
+    viewed_at      timestamptz NOT NULL,
+    PRIMARY KEY (user_id, friend_id)
+);
+
+-- Results of the past periods of the windowed leaderboards, archived with
+-- each user once the period closes. "window" is reserved, hence window_name.
+CREATE TABLE leaderboard_results (
+    user_id        uuid   NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    window_name    text   NOT NULL,
+    period         text   NOT NULL,
+    score          int    NOT NULL,
+    rank           int    NOT NULL,
+    PRIMARY KEY (user_id, window_name, period)
+);
+
+CREATE INDEX leaderboard_results_period_idx ON leaderboard_results (window_name, period, (-score), user_id);
+
+-- Periods of the windowed leaderboards archived completely. Sharded
+-- datastores keep the summary on every shard.
+CREATE TABLE leaderboard_archives (
+    window_name    text        NOT NULL,
+    period         text        NOT NULL,
+    total          int         NOT NULL,
+    archived_at    timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (window_name, period)
+);
+
+-- Counts of the high scores of all users in the buckets of the score sketch,
+-- kept up to date by a trigger on users. Counts of a bucket are spread
+-- across stripes by user, so concurrent score updates rarely wait on the
+-- same row.
+CREATE TABLE score_buckets (
+    bucket         int    NOT NULL,
+    stripe         int    NOT NULL,
+    count          bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (bucket, stripe)
+);
+
+-- Games, the status of a game is derived from its participants. Users may
+-- be stored in another shard, so participants don't reference them.
+CREATE TABLE games (
+    id             uuid   PRIMARY KEY
+);
+
+CREATE TABLE game_participants (
+    game_id        uuid   NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+    position       int    NOT NULL,
+    user_id        uuid   NOT NULL,
+    status         text   NOT NULL,
+    score          int    NOT NULL DEFAULT 0,
+    PRIMARY KEY (game_id, user_id)
+);
+
+-- Name search
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX users_name_prefix_idx ON users (lower(name) text_pattern_ops);
+CREATE INDEX users_name_fts_idx ON users USING gin (to_tsvector('simple', name));
+CREATE INDEX users_name_trgm_idx ON users USING gin (name gin_trgm_ops);
+
+-- Keyset pagination
+CREATE INDEX users_name_id_idx ON users (name COLLATE "C", id);
+CREATE INDEX users_score_id_idx ON users (score, id);
+
+-- Leaderboard, ordered by descending score then id. The score is negated so
+-- row comparisons can seek in the index.
+CREATE INDEX users_leaderboard_idx ON users ((-score), id);
+
+-- Score sketch, mirroring scoreBucket of the datastore. Scores below 16 have
+-- a bucket each, higher scores share buckets a 16th of their power of two
+-- wide.
+CREATE FUNCTION score_bucket(score int) RETURNS int AS $$
+    SELECT CASE
+        WHEN score < 16 THEN greatest(score, 0)
+        ELSE 16 + (e - 4) * 16 + ((score >> (e - 4)) & 15)
+    END
+    FROM (SELECT length(ltrim(greatest(score, 1)::bit(32)::text, '0')) - 1) AS bits(e);
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE FUNCTION count_score_bucket(id uuid, score int, n int) RETURNS void AS $$
+    INSERT INTO score_buckets (bucket, stripe, count)
+    VALUES (score_bucket(score), hashtext(id::text) & 15, n)
+    ON CONFLICT (bucket, stripe) DO UPDATE SET count = score_buckets.count + EXCLUDED.count;
+$$ LANGUAGE sql;
+
+-- Buckets are counted in order, so concurrent updates don't deadlock
+CREATE FUNCTION count_score_buckets() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM count_score_bucket(NEW.id, NEW.score, 1);
+    ELSIF TG_OP = 'DELETE' THEN
+        PERFORM count_score_bucket(OLD.id, OLD.score, -1);
+    ELSIF score_bucket(OLD.score) < score_bucket(NEW.score) THEN
+        PERFORM count_score_bucket(OLD.id, OLD.score, -1);
+        PERFORM count_score_bucket(NEW.id, NEW.score, 1);
+    ELSIF score_bucket(OLD.score) > score_bucket(NEW.score) THEN
+        PERFORM count_score_bucket(NEW.id, NEW.score, 1);
+        PERFORM count_score_bucket(OLD.id, OLD.score, -1);
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_score_buckets AFTER INSERT OR DELETE OR UPDATE OF score ON users
+    FOR EACH ROW EXECUTE PROCEDURE count_score_buckets();
@@ -0,0 +1,142 @@
+package socialgraph
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/valsgaard/interview-case/backend/common"
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// FileProvider is a fake social graph adapter for tests and local development. It
+// reads the graph of a platform from a JSON file, mapping the external IDs of accounts
+// to the external IDs of their friends. Friendships only need to be listed by one of
+// the accounts. The file is read on every call, so it can be edited while running.
+// Access tokens are fake as well, being the external ID prefixed by FileTokenPrefix.
+type FileProvider struct {
+	name string
+	path string
+}
+
+// FileTokenPrefix is the prefix of the fake access tokens of FileProvider
+const FileTokenPrefix = "token:"
+
+// NewFileProvider returns the provider of the named platform, failing if the file
+// can't be read
+func NewFileProvider(name, path string) (*FileProvider, common.Error) {
+	provider := &FileProvider{name: name, path: path}
+	if _, err := provider.read(); err != nil {
+		return nil, err
+	}
+
+	return provider, nil
+}
+
+// NewFileProviders parses a comma separated list of providers as "name:path"
+func NewFileProviders(s string) ([]port.SocialGraphProvider, common.Error) {
+	providers := make([]port.SocialGraphProvider, 0)
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, common.NewError(port.ErrInvalidDocument, "Invalid social graph provider, expected name:path")
+		}
+
+		if seen[parts[0]] {
+			return nil, common.NewError(port.ErrInvalidDocument, "Duplicate social graph provider "+parts[0])
+		}
+		seen[parts[0]] = true
+
+		provider, err := NewFileProvider(parts[0], parts[1])
+		if err != nil {
+			return nil, err
+		}
+
+		providers = append(providers, provider)
+	}
+
+	return providers, nil
+}
+
+// Name ...
+func (p *FileProvider) Name() string {
+	return p.name
+}
+
+// VerifyToken accepts the fake token of any account in the graph
+func (p *FileProvider) VerifyToken(token string) (string, common.Error) {
+	if !strings.HasPrefix(token, FileTokenPrefix) {
+		return "", common.NewError(port.ErrInvalidKey, "Invalid access token")
+	}
+
+	externalID := strings.TrimPrefix(token, FileTokenPrefix)
+	if _, err := p.GetFriendIDs(externalID); err != nil {
+		if err.Code() == port.ErrInvalidKey.Code() {
+			return "", common.NewError(port.ErrInvalidKey, "Invalid access token")
+		}
+
+		return "", err
+	}
+
+	return externalID, nil
+}
+
+// GetFriendIDs returns the friends listed by the account, and the accounts
+// listing it, ordered by external ID
+func (p *FileProvider) GetFriendIDs(externalID string) ([]string, common.Error) {
+	graph, err := p.read()
+	if err != nil {
+		return nil, err
+	}
+
+	listed, known := graph[externalID]
+	friends := make(map[string]bool)
+	for _, friendID := range listed {
+		friends[friendID] = true
+	}
+
+	for accountID, friendIDs := range graph {
+		for _, friendID := range friendIDs {
+			if friendID == externalID {
+				known = true
+				friends[accountID] = true
+			}
+		}
+	}
+
+	if !known {
+		return nil, common.NewError(port.ErrInvalidKey, "Unknown external account")
+	}
+
+	delete(friends, externalID)
+	friendIDs := make([]string, 0, len(friends))
+	for friendID := range friends {
+		friendIDs = append(friendIDs, friendID)
+	}
+
+	sort.Strings(friendIDs)
+
+	return friendIDs, nil
+}
+
+// read parses the graph file
+func (p *FileProvider) read() (map[string][]string, common.Error) {
+	b, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, common.NewError(err, "")
+	}
+
+	graph := make(map[string][]string)
+	if err := json.Unmarshal(b, &graph); err != nil {
+		return nil, common.NewError(port.ErrInvalidDocument, err.Error())
+	}
+
+	return graph, nil
+}
@@ -0,0 +1,158 @@
+package socialgraph
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/valsgaard/interview-case/backend/endpoints/port"
+)
+
+// writeGraph writes a temporary graph file, removed by the caller
+func writeGraph(t *testing.T, graph string) string {
+	f, err := ioutil.TempFile("", "socialgraph")
+	require.Nil(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(graph)
+	require.Nil(t, err)
+
+	return f.Name()
+}
+
+func TestFileProvider(t *testing.T) {
+	path := writeGraph(t, `{"a": ["b", "c"], "b": ["d"], "e": []}`)
+	defer os.Remove(path)
+
+	provider, err := NewFileProvider("fake", path)
+	require.Nil(t, err)
+	assert.Equal(t, "fake", provider.Name())
+
+	tests := []struct {
+		Name              string
+		ExternalID        string
+		ExpectedSuccess   bool
+		ExpectedFriendIDs []string
+	}{
+		{
+			Name:              "Listed",
+			ExternalID:        "a",
+			ExpectedSuccess:   true,
+			ExpectedFriendIDs: []string{"b", "c"},
+		}, {
+			Name:              "ListedBoth",
+			ExternalID:        "b",
+			ExpectedSuccess:   true,
+			ExpectedFriendIDs: []string{"a", "d"},
+		}, {
+			Name:              "ListedByOthers",
+			ExternalID:        "c",
+			ExpectedSuccess:   true,
+			ExpectedFriendIDs: []string{"a"},
+		}, {
+			Name:              "WithoutFriends",
+			ExternalID:        "e",
+			ExpectedSuccess:   true,
+			ExpectedFriendIDs: []string{},
+		}, {
+			Name:            "Unknown",
+			ExternalID:      "f",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			friendIDs, err := provider.GetFriendIDs(test.ExternalID)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedFriendIDs, friendIDs)
+			} else {
+				require.NotNil(t, err)
+				assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+			}
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
+
+func TestFileProviderVerifyToken(t *testing.T) {
+	path := writeGraph(t, `{"a": ["b"]}`)
+	defer os.Remove(path)
+
+	provider, err := NewFileProvider("fake", path)
+	require.Nil(t, err)
+
+	tests := []struct {
+		Name               string
+		Token              string
+		ExpectedSuccess    bool
+		ExpectedExternalID string
+	}{
+		{
+			Name:               "Listed",
+			Token:              FileTokenPrefix + "a",
+			ExpectedSuccess:    true,
+			ExpectedExternalID: "a",
+		}, {
+			Name:               "ListedByOthers",
+			Token:              FileTokenPrefix + "b",
+			ExpectedSuccess:    true,
+			ExpectedExternalID: "b",
+		}, {
+			Name:            "Unknown",
+			Token:           FileTokenPrefix + "c",
+			ExpectedSuccess: false,
+		}, {
+			Name:            "ExternalID",
+			Token:           "a",
+			ExpectedSuccess: false,
+		},
+	}
+
+	for _, test := range tests {
+		fn := func(t *testing.T) {
+			externalID, err := provider.VerifyToken(test.Token)
+			if test.ExpectedSuccess {
+				require.Nil(t, err)
+				assert.Equal(t, test.ExpectedExternalID, externalID)
+			} else {
+				require.NotNil(t, err)
+				assert.Equal(t, port.ErrInvalidKey.Code(), err.Code())
+			}
+		}
+
+		t.Run(test.Name, fn)
+	}
+}
+
+func TestNewFileProviders(t *testing.T) {
+	path := writeGraph(t, `{}`)
+	defer os.Remove(path)
+
+	providers, err := NewFileProviders(" one:" + path + ",two:" + path)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(providers))
+	assert.Equal(t, "one", providers[0].Name())
+	assert.Equal(t, "two", providers[1].Name())
+
+	providers, err = NewFileProviders("")
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(providers))
+
+	for _, s := range []string{"one", "one:" + path + ",one:" + path, "one:" + path + ".missing"} {
+		_, err = NewFileProviders(s)
+		assert.NotNil(t, err, s)
+	}
+
+	broken := writeGraph(t, `{"a": `)
+	defer os.Remove(broken)
+
+	_, err = NewFileProvider("broken", broken)
+	require.NotNil(t, err)
+	assert.Equal(t, port.ErrInvalidDocument.Code(), err.Code())
+}